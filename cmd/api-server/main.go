@@ -2,56 +2,301 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"flag"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/yourorg/spire-workload-mgmt/internal/agentauth"
+	"github.com/yourorg/spire-workload-mgmt/internal/auditarchive"
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/metrics"
+	"github.com/yourorg/spire-workload-mgmt/internal/migrate"
+	"github.com/yourorg/spire-workload-mgmt/internal/notify"
+	"github.com/yourorg/spire-workload-mgmt/internal/outbox"
+	"github.com/yourorg/spire-workload-mgmt/internal/policy"
+	"github.com/yourorg/spire-workload-mgmt/internal/ratelimit"
+	"github.com/yourorg/spire-workload-mgmt/internal/rbac"
+	"github.com/yourorg/spire-workload-mgmt/internal/readonly"
 	"github.com/yourorg/spire-workload-mgmt/internal/repository"
 	"github.com/yourorg/spire-workload-mgmt/internal/service"
+	"github.com/yourorg/spire-workload-mgmt/internal/snapshot"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify-audit":
+			os.Exit(runVerifyAudit(os.Args[2:]))
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "restore-audit-archive":
+			os.Exit(runRestoreAuditArchive(os.Args[2:]))
+		case "export-state":
+			os.Exit(runExportState(os.Args[2:]))
+		case "import-state":
+			os.Exit(runImportState(os.Args[2:]))
+		}
+	}
+
+	storageBackend := flag.String("storage", getEnv("STORAGE_BACKEND", "sql"), "storage backend: sql (DB_DRIVER selects mysql or postgres) or memory")
+	readOnlyDefault, _ := strconv.ParseBool(os.Getenv("READ_ONLY"))
+	readOnly := flag.Bool("read-only", readOnlyDefault, "serve reads and agent polls only, as a standby on a read replica")
+	insecureAgentsDefault, _ := strconv.ParseBool(os.Getenv("AGENT_INSECURE"))
+	insecureAgents := flag.Bool("insecure-agents", insecureAgentsDefault, "accept site agent calls without mTLS, for development only")
+	flag.Parse()
+
 	log.Println("Starting SPIRE Workload Management API Server...")
 
 	// Load configuration
 	grpcPort := getEnv("GRPC_PORT", "8080")
 	httpPort := getEnv("HTTP_PORT", "8081")
+	agentMTLSPort := getEnv("AGENT_MTLS_PORT", "")
+	agentTrustDomains, err := agentauth.ParseTrustDomains(os.Getenv("AGENT_TRUST_DOMAINS"))
+	if err != nil {
+		log.Fatalf("Invalid AGENT_TRUST_DOMAINS: %v", err)
+	}
+	// Without mTLS nothing authenticates site agents, so any caller could
+	// report results, purge tombstones and heartbeat for any site
+	if agentMTLSPort == "" && !*insecureAgents {
+		log.Fatal("AGENT_MTLS_PORT is not set, so site agents cannot be authenticated. " +
+			"Set it, or set AGENT_INSECURE=true to accept unauthenticated agents for development.")
+	}
+	spiffeSocket := getEnv("SPIFFE_ENDPOINT_SOCKET", "unix:///run/spire/agent-sockets/spire-agent.sock")
+	heartbeatTimeout := service.DefaultHeartbeatTimeout
+	if v, err := strconv.Atoi(os.Getenv("AGENT_HEARTBEAT_TIMEOUT_SECONDS")); err == nil && v > 0 {
+		heartbeatTimeout = time.Duration(v) * time.Second
+	}
+	policyFile := getEnv("POLICY_FILE", "")
+	policyReload := 10 * time.Second
+	if v, err := strconv.Atoi(os.Getenv("POLICY_RELOAD_SECONDS")); err == nil && v > 0 {
+		policyReload = time.Duration(v) * time.Second
+	}
+	checkpointInterval := time.Hour
+	if v, err := strconv.Atoi(os.Getenv("AUDIT_CHECKPOINT_INTERVAL_SECONDS")); err == nil && v > 0 {
+		checkpointInterval = time.Duration(v) * time.Second
+	}
+
+	metricsInterval := service.DefaultMetricsInterval
+	if v, err := strconv.Atoi(os.Getenv("METRICS_INTERVAL_SECONDS")); err == nil && v > 0 {
+		metricsInterval = time.Duration(v) * time.Second
+	}
+
+	outboxPoll := 5 * time.Second
+	if v, err := strconv.Atoi(os.Getenv("OUTBOX_POLL_SECONDS")); err == nil && v > 0 {
+		outboxPoll = time.Duration(v) * time.Second
+	}
+
+	// Audit log checkpoints are signed with this key; without it the hash
+	// chain is still written but not checkpointed
+	var checkpointKey ed25519.PrivateKey
+	if path := getEnv("AUDIT_SIGNING_KEY_FILE", ""); path != "" {
+		key, err := repository.LoadCheckpointSigningKey(path)
+		if err != nil {
+			log.Fatalf("Failed to load audit signing key: %v", err)
+		}
+		checkpointKey = key
+	}
+
+	// State archives are signed with this key on export and verified with it
+	// on import; without it both are refused
+	var snapshotKey ed25519.PrivateKey
+	if path := getEnv("SNAPSHOT_SIGNING_KEY_FILE", ""); path != "" {
+		key, err := snapshot.LoadSigningKey(path)
+		if err != nil {
+			log.Fatalf("Failed to load snapshot signing key: %v", err)
+		}
+		snapshotKey = key
+	}
 
-	// Connect to database
-	dbConfig := repository.ConfigFromEnv()
-	db, err := repository.NewDB(dbConfig)
+	// Expired audit log entries are archived before they are deleted
+	archiveConfig, err := auditarchive.ConfigFromEnv()
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Invalid audit archive configuration: %v", err)
+	}
+	if archiveConfig.Retention.Enabled() && checkpointKey == nil {
+		log.Fatalf("AUDIT_SIGNING_KEY_FILE is required when AUDIT_RETENTION_DAYS is set")
+	}
+	var archiveStore auditarchive.Store
+	if archiveConfig.Location != "" {
+		if archiveStore, err = auditarchive.NewStore(archiveConfig.Location); err != nil {
+			log.Fatalf("Failed to open audit archive store: %v", err)
+		}
+	}
+
+	// Notification hub wakes long-polling site agents when work is queued
+	hub := notify.NewHub()
+
+	// Open the storage backend. The in-memory backend starts empty apart from
+	// two demo sites and loses everything on exit; it is for local development.
+	var store *repository.Store
+	var db *sql.DB
+	switch *storageBackend {
+	case "sql":
+		dbConfig, err := repository.ConfigFromEnv()
+		if err != nil {
+			log.Fatalf("Invalid database configuration: %v", err)
+		}
+		db, err = repository.NewDB(dbConfig)
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer db.Close()
+		log.Printf("Connected to %s database", dbConfig.Driver)
+
+		// Apply pending schema migrations; replicas serialize on a database lock
+		if autoMigrate, _ := strconv.ParseBool(os.Getenv("DB_AUTO_MIGRATE")); autoMigrate && *readOnly {
+			log.Println("WARNING: DB_AUTO_MIGRATE is ignored in read-only mode")
+		} else if autoMigrate {
+			migrator, err := migrate.New(db, dbConfig.Driver)
+			if err != nil {
+				log.Fatalf("Failed to load migrations: %v", err)
+			}
+			n, err := migrator.Up(context.Background())
+			if err != nil {
+				log.Fatalf("Failed to migrate database: %v", err)
+			}
+			log.Printf("Applied %d migrations, schema is at version %d", n, migrator.Latest())
+		}
+		// Listings and audit reads go to the read replica, if there is one
+		if replicaConfig, ok := dbConfig.Replica(); ok {
+			replica, err := repository.NewDB(replicaConfig)
+			if err != nil {
+				log.Fatalf("Failed to connect to read replica: %v", err)
+			}
+			defer replica.Close()
+			log.Printf("Connected to read replica at %s", replicaConfig.Host)
+			store = repository.NewReplicatedSQLStore(db, replica, hub)
+		} else {
+			store = repository.NewSQLStore(db, hub)
+		}
+
+	case "memory":
+		log.Println("WARNING: using in-memory storage, all state is lost when the server exits")
+		store = repository.NewMemoryStore(hub)
+		for _, site := range demoSites {
+			site := site
+			if err := store.Sites.Create(context.Background(), &site); err != nil {
+				log.Fatalf("Failed to create demo site: %v", err)
+			}
+		}
+
+	default:
+		log.Fatalf("Unknown storage backend %q, expected sql or memory", *storageBackend)
 	}
-	defer db.Close()
-	log.Println("Connected to MySQL database")
 
 	// Initialize repositories
-	siteRepo := repository.NewSiteRepository(db)
-	entryRepo := repository.NewEntryRepository(db)
-	syncRepo := repository.NewSyncStatusRepository(db)
-	auditRepo := repository.NewAuditRepository(db)
+	siteRepo := store.Sites
+	entryRepo := store.Entries
+	syncRepo := store.SyncStatus
+	auditRepo := store.Audit
+	agentRepo := store.Agents
+	apiKeyRepo := store.APIKeys
+	roleBindingRepo := store.RoleBindings
+	grantRepo := store.NamespaceGrants
+	changeRepo := store.ChangeRequests
+
+	// Admission policy for workload entries, disabled without a policy file
+	var policyEngine *policy.Engine
+	if policyFile != "" {
+		policyEngine, err = policy.NewEngine(policyFile)
+		if err != nil {
+			log.Fatalf("Failed to load admission policy: %v", err)
+		}
+	}
 
 	// Initialize services
-	workloadEntrySvc := service.NewWorkloadEntryService(entryRepo, siteRepo, syncRepo, auditRepo)
-	siteAgentSvc := service.NewSiteAgentService(syncRepo, siteRepo, auditRepo)
+	workloadEntrySvc := service.NewWorkloadEntryService(entryRepo, siteRepo, syncRepo, grantRepo, policyEngine,
+		store.UnitOfWork)
+	siteAgentSvc := service.NewSiteAgentService(syncRepo, siteRepo, agentRepo, store.UnitOfWork, hub)
 	siteSvc := service.NewSiteService(siteRepo)
-	auditSvc := service.NewAuditService(auditRepo)
+	auditSvc := service.NewAuditService(auditRepo, checkpointKey, archiveStore)
+	apiKeySvc := service.NewAPIKeyService(apiKeyRepo, store.UnitOfWork)
+	apiKeySvc.RecordLastUsed(!*readOnly)
+	rbacSvc := service.NewRBACService(roleBindingRepo, grantRepo, store.UnitOfWork)
+	changeSvc := service.NewChangeRequestService(changeRepo, store.UnitOfWork)
+	stateSvc := service.NewStateService(store.State, auditRepo, snapshotKey)
+
+	// Bearer token authentication with OIDC JWTs or API keys. Without an OIDC
+	// issuer, requests that carry no token are anonymous.
+	var verifier *auth.Verifier
+	oidcConfig := auth.OIDCConfigFromEnv()
+	if oidcConfig.Issuer != "" {
+		verifier, err = auth.NewVerifier(context.Background(), oidcConfig)
+		if err != nil {
+			log.Fatalf("Failed to initialize OIDC verifier: %v", err)
+		}
+		log.Printf("OIDC authentication enabled for issuer %s", oidcConfig.Issuer)
+	} else {
+		log.Println("WARNING: OIDC_ISSUER not set, requests without an API key are anonymous")
+	}
+	authenticator := auth.NewAuthenticator(verifier, apiKeySvc)
+
+	// Role-based access control. Anonymous callers hold no role unless
+	// RBAC_ANONYMOUS_ROLE grants them one.
+	anonymousRole := os.Getenv("RBAC_ANONYMOUS_ROLE")
+	if anonymousRole != "" {
+		if !rbac.IsValidRole(anonymousRole) {
+			log.Fatalf("Invalid RBAC_ANONYMOUS_ROLE %q", anonymousRole)
+		}
+		log.Printf("WARNING: RBAC_ANONYMOUS_ROLE is set, every unauthenticated caller has the %s role. Never use this in production.", anonymousRole)
+	} else if verifier == nil {
+		log.Println("OIDC_ISSUER and RBAC_ANONYMOUS_ROLE are not set, only API keys and agents can call the API")
+	}
+	authorizer := rbac.NewAuthorizer(rbacSvc, auditRepo, anonymousRole)
+	if *insecureAgents {
+		log.Println("WARNING: AGENT_INSECURE is set, any caller can act as any site agent. Never use this in production.")
+		authorizer.AllowAnonymousAgents()
+	}
+	bootstrapAdmins, err := rbac.ParseSubjects(os.Getenv("RBAC_BOOTSTRAP_ADMINS"))
+	if err != nil {
+		log.Fatalf("Invalid RBAC_BOOTSTRAP_ADMINS: %v", err)
+	}
+	for _, subject := range bootstrapAdmins {
+		if err := authorizer.AddStaticBinding(rbac.RoleAdmin, subject); err != nil {
+			log.Fatalf("Invalid RBAC_BOOTSTRAP_ADMINS: %v", err)
+		}
+	}
+
+	// Per-identity rate limits, with separate defaults for site agents
+	rateLimitConfig, err := ratelimit.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid rate limit configuration: %v", err)
+	}
+	limiter := ratelimit.NewLimiter(rateLimitConfig)
 
-	// Create gRPC server
-	grpcServer := grpc.NewServer()
+	// Create gRPC server. Each call is a session that reads its own writes,
+	// and is counted whatever the interceptors after it decide.
+	unaryInterceptors := []grpc.UnaryServerInterceptor{metrics.UnaryServerInterceptor, sessionUnaryInterceptor,
+		authenticator.UnaryServerInterceptor, limiter.UnaryServerInterceptor, authorizer.UnaryServerInterceptor}
+	streamInterceptors := []grpc.StreamServerInterceptor{metrics.StreamServerInterceptor, sessionStreamInterceptor,
+		authenticator.StreamServerInterceptor, limiter.StreamServerInterceptor, authorizer.StreamServerInterceptor}
+	if *readOnly {
+		log.Println("Running as a read-only standby, mutations are rejected")
+		unaryInterceptors = append(unaryInterceptors, readonly.UnaryServerInterceptor)
+		streamInterceptors = append(streamInterceptors, readonly.StreamServerInterceptor)
+	}
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
 
 	// Register services with gRPC
-	RegisterServices(grpcServer, workloadEntrySvc, siteAgentSvc, siteSvc, auditSvc)
+	RegisterServices(grpcServer, workloadEntrySvc, siteAgentSvc, siteSvc, auditSvc, apiKeySvc, rbacSvc, changeSvc, stateSvc)
 	reflection.Register(grpcServer)
 
 	// Start gRPC server
@@ -67,10 +312,47 @@ func main() {
 		}
 	}()
 
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	defer stopMonitor()
+	if policyEngine != nil {
+		go policyEngine.Watch(monitorCtx, policyReload)
+	}
+	go siteAgentSvc.RunMetricsCollector(monitorCtx, metricsInterval)
+	// A standby leaves the background writes to the primary
+	if !*readOnly {
+		// Mark sites disconnected when their agents stop sending heartbeats
+		go siteAgentSvc.RunLivenessMonitor(monitorCtx, heartbeatTimeout, 30*time.Second)
+		go auditSvc.RunCheckpointer(monitorCtx, checkpointInterval)
+		go auditarchive.NewArchiver(auditRepo, archiveStore, archiveConfig, checkpointKey).Run(monitorCtx)
+
+		// Relay the domain events committed with each change: wake the
+		// agents of the affected sites and count the events
+		relay := outbox.NewRelay(store.Outbox)
+		relay.Subscribe("notify", outbox.NotifySites(hub))
+		relay.Subscribe("metrics", outbox.CountEvents)
+		go relay.Run(monitorCtx, outboxPoll)
+	}
+
 	// Start HTTP server for REST API (simpler browser access)
+	apiMux := newHTTPHandler(workloadEntrySvc, siteAgentSvc, siteSvc, auditSvc, apiKeySvc, rbacSvc, changeSvc, stateSvc, db)
+	var handler http.Handler = apiMux
+	if *readOnly {
+		handler = readonly.HTTPMiddleware(handler)
+	}
+	rootMux := http.NewServeMux()
+	rootMux.Handle("/metrics", promhttp.Handler())
+	handler = sessionMiddleware(authenticator.HTTPMiddleware(limiter.HTTPMiddleware(authorizer.HTTPMiddleware(handler))))
+	handler = metrics.HTTPMiddleware(handler, func(r *http.Request) string {
+		// Label requests by the pattern they matched, not their path
+		if _, pattern := apiMux.Handler(r); pattern != "" {
+			return pattern
+		}
+		return "other"
+	})
+	rootMux.Handle("/", handler)
 	httpServer := &http.Server{
 		Addr:    ":" + httpPort,
-		Handler: newHTTPHandler(workloadEntrySvc, siteAgentSvc, siteSvc, auditSvc, db),
+		Handler: rootMux,
 	}
 
 	go func() {
@@ -80,21 +362,64 @@ func main() {
 		}
 	}()
 
+	// mTLS listener for site agents, serving only the agent endpoints. They
+	// reject callers without a client SVID bound to the requested site, unless
+	// AGENT_INSECURE is set.
+	var agentServer *http.Server
+	if agentMTLSPort != "" {
+		source, err := agentauth.NewX509Source(context.Background(), spiffeSocket)
+		if err != nil {
+			log.Fatalf("Failed to initialize SPIFFE X.509 source: %v", err)
+		}
+		defer source.Close()
+		tlsConfig, err := agentauth.ServerTLSConfig(source, agentTrustDomains)
+		if err != nil {
+			log.Fatalf("Failed to configure agent mTLS: %v", err)
+		}
+
+		siteAgentSvc.RequireAgentIdentity(!*insecureAgents)
+
+		agentMux := http.NewServeMux()
+		agentMux.Handle("/api/v1/agent/", handler)
+		agentServer = &http.Server{
+			Addr:      ":" + agentMTLSPort,
+			Handler:   agentauth.HTTPMiddleware(agentMux),
+			TLSConfig: tlsConfig,
+		}
+
+		go func() {
+			log.Printf("Agent mTLS server listening on port %s", agentMTLSPort)
+			if err := agentServer.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Agent mTLS server failed: %v", err)
+			}
+		}()
+	}
+
 	// Wait for shutdown signal
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	<-sigCh
 
 	log.Println("Shutting down...")
+	stopMonitor()
 	grpcServer.GracefulStop()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	httpServer.Shutdown(ctx)
+	if agentServer != nil {
+		agentServer.Shutdown(ctx)
+	}
 
 	log.Println("Server stopped")
 }
 
+// demoSites are created when the server runs with in-memory storage
+var demoSites = []repository.Site{
+	{ID: "site-1", Name: "US East", Region: "us-east-1", SpireServerAddress: "spire-server-1:8081", TrustDomain: "example.org"},
+	{ID: "site-2", Name: "EU West", Region: "eu-west-1", SpireServerAddress: "spire-server-2:8081", TrustDomain: "example.org"},
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -102,17 +427,63 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// sessionMiddleware makes each request a repository session, so its reads
+// may use the read replica until it writes
+func sessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(repository.WithSession(r.Context())))
+	})
+}
+
+// sessionUnaryInterceptor makes each unary gRPC call a repository session
+func sessionUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(repository.WithSession(ctx), req)
+}
+
+// sessionStreamInterceptor makes each streaming gRPC call a repository session
+func sessionStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &sessionStream{ServerStream: ss, ctx: repository.WithSession(ss.Context())})
+}
+
+// sessionStream is a server stream with a session context
+type sessionStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *sessionStream) Context() context.Context {
+	return s.ctx
+}
+
+// httpStatus maps service errors to HTTP status codes
+func httpStatus(err error, fallback int) int {
+	if errors.Is(err, service.ErrPermissionDenied) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, service.ErrPolicyDenied) {
+		return http.StatusUnprocessableEntity
+	}
+	if errors.Is(err, service.ErrInvalidArgument) {
+		return http.StatusBadRequest
+	}
+	return fallback
+}
+
 // RegisterServices registers all gRPC services
 // This is a placeholder - will be replaced with generated code
 func RegisterServices(s *grpc.Server, workloadEntrySvc *service.WorkloadEntryService,
-	siteAgentSvc *service.SiteAgentService, siteSvc *service.SiteService, auditSvc *service.AuditService) {
+	siteAgentSvc *service.SiteAgentService, siteSvc *service.SiteService, auditSvc *service.AuditService,
+	apiKeySvc *service.APIKeyService, rbacSvc *service.RBACService, changeSvc *service.ChangeRequestService,
+	stateSvc *service.StateService) {
 	// Services will be registered once proto code is generated
 	log.Println("Services registered with gRPC server")
 }
 
 // HTTP Handler for REST API
 func newHTTPHandler(workloadEntrySvc *service.WorkloadEntryService, siteAgentSvc *service.SiteAgentService,
-	siteSvc *service.SiteService, auditSvc *service.AuditService, db *sql.DB) http.Handler {
+	siteSvc *service.SiteService, auditSvc *service.AuditService, apiKeySvc *service.APIKeyService,
+	rbacSvc *service.RBACService, changeSvc *service.ChangeRequestService, stateSvc *service.StateService,
+	db *sql.DB) *http.ServeMux {
 
 	mux := http.NewServeMux()
 
@@ -144,7 +515,7 @@ func newHTTPHandler(workloadEntrySvc *service.WorkloadEntryService, siteAgentSvc
 		if r.Method == "GET" {
 			sites, err := siteSvc.ListSites(ctx, "")
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
 				return
 			}
 			json.NewEncoder(w).Encode(map[string]interface{}{"sites": sites})
@@ -158,24 +529,43 @@ func newHTTPHandler(workloadEntrySvc *service.WorkloadEntryService, siteAgentSvc
 
 		switch r.Method {
 		case "GET":
-			entries, err := workloadEntrySvc.ListWorkloadEntries(ctx, 100, "", "", "")
+			q := r.URL.Query()
+			pageSize := 100
+			if v := q.Get("page_size"); v != "" {
+				pageSize, _ = strconv.Atoi(v)
+			}
+			// Each selector parameter is type:value, or a bare type to match
+			// any value of it
+			var bySelectors *service.SelectorFilter
+			if sels := q["selector"]; len(sels) > 0 {
+				bySelectors = &service.SelectorFilter{Match: q.Get("selector_match")}
+				for _, sel := range sels {
+					typ, value, _ := strings.Cut(sel, ":")
+					bySelectors.Selectors = append(bySelectors.Selectors, service.Selector{Type: typ, Value: value})
+				}
+			}
+			entries, err := workloadEntrySvc.ListWorkloadEntries(ctx, pageSize, q.Get("page_token"),
+				q.Get("site_id"), q.Get("spiffe_id_prefix"), bySelectors, q.Get("deleting") == "true",
+				q.Get("skip_total_count") != "true")
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
 				return
 			}
 			json.NewEncoder(w).Encode(map[string]interface{}{
-				"entries":     entries.Entries,
-				"total_count": entries.TotalCount,
+				"entries":         entries.Entries,
+				"next_page_token": entries.NextPageToken,
+				"total_count":     entries.TotalCount,
 			})
 
 		case "POST":
 			var req struct {
-				SpiffeID    string              `json:"spiffe_id"`
-				ParentID    string              `json:"parent_id"`
-				Selectors   []service.Selector  `json:"selectors"`
-				SiteIDs     []string            `json:"site_ids"`
-				TTL         int                 `json:"ttl"`
-				Description string              `json:"description"`
+				SpiffeID    string             `json:"spiffe_id"`
+				ParentID    string             `json:"parent_id"`
+				Selectors   []service.Selector `json:"selectors"`
+				SiteIDs     []string           `json:"site_ids"`
+				TTL         int                `json:"ttl"`
+				Description string             `json:"description"`
+				DryRun      bool               `json:"dry_run"`
 			}
 			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 				http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -187,13 +577,15 @@ func newHTTPHandler(workloadEntrySvc *service.WorkloadEntryService, siteAgentSvc
 			}
 
 			entry, err := workloadEntrySvc.CreateWorkloadEntry(ctx, req.SpiffeID, req.ParentID,
-				req.Selectors, req.SiteIDs, req.TTL, req.Description)
+				req.Selectors, req.SiteIDs, req.TTL, req.Description, req.DryRun)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
 				return
 			}
 
-			w.WriteHeader(http.StatusCreated)
+			if !req.DryRun {
+				w.WriteHeader(http.StatusCreated)
+			}
 			json.NewEncoder(w).Encode(entry)
 		}
 	}))
@@ -203,36 +595,48 @@ func newHTTPHandler(workloadEntrySvc *service.WorkloadEntryService, siteAgentSvc
 		ctx := r.Context()
 		w.Header().Set("Content-Type", "application/json")
 
-		// Extract ID from path
-		id := r.URL.Path[len("/api/v1/entries/"):]
+		// Extract ID and action from path
+		id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v1/entries/"), "/")
 		if id == "" {
 			http.Error(w, "Entry ID required", http.StatusBadRequest)
 			return
 		}
 
-		switch r.Method {
-		case "GET":
+		switch {
+		case action == "purge" && r.Method == "POST":
+			if err := workloadEntrySvc.PurgeWorkloadEntry(ctx, id); err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]bool{"success": true})
+
+		case action != "":
+			http.Error(w, "Not found", http.StatusNotFound)
+
+		case r.Method == "GET":
 			entry, err := workloadEntrySvc.GetWorkloadEntry(ctx, id)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusNotFound)
+				http.Error(w, err.Error(), httpStatus(err, http.StatusNotFound))
 				return
 			}
 			json.NewEncoder(w).Encode(entry)
 
-		case "DELETE":
-			if err := workloadEntrySvc.DeleteWorkloadEntry(ctx, id); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+		case r.Method == "DELETE":
+			change, err := workloadEntrySvc.DeleteWorkloadEntry(ctx, id)
+			if err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
+				return
+			}
+			if change != nil {
+				// Entry is on a protected site; deletion waits for approval
+				w.WriteHeader(http.StatusAccepted)
+				json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "pending_change": change})
 				return
 			}
 			json.NewEncoder(w).Encode(map[string]bool{"success": true})
 		}
 	}))
 
-	// Sync status endpoint
-	mux.HandleFunc("/api/v1/entries/", cors(func(w http.ResponseWriter, r *http.Request) {
-		// Already handled above
-	}))
-
 	// Site agent endpoints
 	mux.HandleFunc("/api/v1/agent/poll", cors(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -244,9 +648,20 @@ func newHTTPHandler(workloadEntrySvc *service.WorkloadEntryService, siteAgentSvc
 			return
 		}
 
-		entries, err := siteAgentSvc.PollEntries(ctx, siteID, 10)
+		maxEntries := 10
+		if v, err := strconv.Atoi(r.URL.Query().Get("max_entries")); err == nil {
+			maxEntries = v
+		}
+
+		// Optional long-poll: hold the request until work exists or the wait elapses
+		var wait time.Duration
+		if v, err := strconv.Atoi(r.URL.Query().Get("wait_seconds")); err == nil {
+			wait = time.Duration(v) * time.Second
+		}
+
+		entries, err := siteAgentSvc.PollEntries(ctx, siteID, maxEntries, wait)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]interface{}{"entries": entries})
@@ -270,7 +685,7 @@ func newHTTPHandler(workloadEntrySvc *service.WorkloadEntryService, siteAgentSvc
 
 		err := siteAgentSvc.ReportSyncResult(ctx, req.SiteID, req.WorkloadEntryID, req.Success, req.SpireEntryID, req.ErrorMessage)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]bool{"acknowledged": true})
@@ -288,7 +703,7 @@ func newHTTPHandler(workloadEntrySvc *service.WorkloadEntryService, siteAgentSvc
 
 		entries, err := siteAgentSvc.PollDeletions(ctx, siteID, 10)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]interface{}{"entries": entries})
@@ -311,12 +726,61 @@ func newHTTPHandler(workloadEntrySvc *service.WorkloadEntryService, siteAgentSvc
 
 		err := siteAgentSvc.ReportDeletionResult(ctx, req.SiteID, req.WorkloadEntryID, req.Success, req.ErrorMessage)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]bool{"acknowledged": true})
 	}))
 
+	mux.HandleFunc("/api/v1/agent/heartbeat", cors(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("Content-Type", "application/json")
+
+		var req struct {
+			SiteID             string    `json:"site_id"`
+			AgentID            string    `json:"agent_id"`
+			Version            string    `json:"version"`
+			StartedAt          time.Time `json:"started_at"`
+			IsLeader           bool      `json:"is_leader"`
+			Backlog            int       `json:"backlog"`
+			SpireReachable     bool      `json:"spire_reachable"`
+			SpireServerVersion string    `json:"spire_server_version"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		err := siteAgentSvc.Heartbeat(ctx, service.AgentInfo{
+			SiteID:             req.SiteID,
+			AgentID:            req.AgentID,
+			Version:            req.Version,
+			StartedAt:          req.StartedAt,
+			IsLeader:           req.IsLeader,
+			Backlog:            req.Backlog,
+			SpireReachable:     req.SpireReachable,
+			SpireServerVersion: req.SpireServerVersion,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]bool{"acknowledged": true})
+	}))
+
+	// Agent inventory endpoint
+	mux.HandleFunc("/api/v1/agents", cors(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("Content-Type", "application/json")
+
+		agents, err := siteAgentSvc.ListAgents(ctx, r.URL.Query().Get("site_id"))
+		if err != nil {
+			http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"agents": agents})
+	}))
+
 	// Audit log endpoint
 	mux.HandleFunc("/api/v1/audit", cors(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -327,11 +791,333 @@ func newHTTPHandler(workloadEntrySvc *service.WorkloadEntryService, siteAgentSvc
 
 		logs, err := auditSvc.ListAuditLogs(ctx, 100, "", resourceType, resourceID, "", nil, nil)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
 			return
 		}
 		json.NewEncoder(w).Encode(map[string]interface{}{"entries": logs.Entries})
 	}))
 
+	mux.HandleFunc("/api/v1/audit/verify", cors(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		result, err := auditSvc.VerifyAuditLog(r.Context(), r.URL.Query().Get("stream"))
+		if err != nil {
+			http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+
+	mux.HandleFunc("/api/v1/audit/archives/", cors(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v1/audit/archives/"), "/")
+		if name == "" {
+			http.Error(w, "Archive name required", http.StatusBadRequest)
+			return
+		}
+		if action != "restore" || r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		result, err := auditSvc.RestoreAuditArchive(r.Context(), name)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+
+	// API key endpoints
+	mux.HandleFunc("/api/v1/apikeys", cors(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case "GET":
+			keys, err := apiKeySvc.ListAPIKeys(ctx)
+			if err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+
+		case "POST":
+			var req struct {
+				Name           string     `json:"name"`
+				Scopes         []string   `json:"scopes"`
+				SpiffeIDPrefix string     `json:"spiffe_id_prefix"`
+				ExpiresAt      *time.Time `json:"expires_at"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			key, secret, err := apiKeySvc.CreateAPIKey(ctx, req.Name, req.Scopes, req.SpiffeIDPrefix, req.ExpiresAt)
+			if err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusBadRequest))
+				return
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{"key": key, "secret": secret})
+		}
+	}))
+
+	// Single API key endpoints: DELETE /api/v1/apikeys/{id}, POST /api/v1/apikeys/{id}/rotate
+	mux.HandleFunc("/api/v1/apikeys/", cors(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("Content-Type", "application/json")
+
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/apikeys/")
+		rotate := strings.HasSuffix(id, "/rotate")
+		id = strings.TrimSuffix(id, "/rotate")
+		if id == "" || strings.Contains(id, "/") {
+			http.Error(w, "API key ID required", http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case rotate && r.Method == "POST":
+			key, secret, err := apiKeySvc.RotateAPIKey(ctx, id)
+			if err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusNotFound))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"key": key, "secret": secret})
+
+		case !rotate && r.Method == "DELETE":
+			if err := apiKeySvc.RevokeAPIKey(ctx, id); err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusNotFound))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]bool{"success": true})
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	// RBAC endpoints
+	mux.HandleFunc("/api/v1/rbac/roles", cors(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"roles": rbacSvc.ListRoles(r.Context())})
+	}))
+
+	mux.HandleFunc("/api/v1/rbac/bindings", cors(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case "GET":
+			bindings, err := rbacSvc.ListRoleBindings(ctx, r.URL.Query().Get("subject_kind"), r.URL.Query().Get("subject"))
+			if err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"bindings": bindings})
+
+		case "POST":
+			var req struct {
+				Role        string `json:"role"`
+				SubjectKind string `json:"subject_kind"`
+				Subject     string `json:"subject"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			binding, err := rbacSvc.CreateRoleBinding(ctx, req.Role, req.SubjectKind, req.Subject)
+			if err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusBadRequest))
+				return
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(binding)
+		}
+	}))
+
+	mux.HandleFunc("/api/v1/rbac/bindings/", cors(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("Content-Type", "application/json")
+
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/rbac/bindings/")
+		if id == "" {
+			http.Error(w, "Role binding ID required", http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == "DELETE" {
+			if err := rbacSvc.DeleteRoleBinding(ctx, id); err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusNotFound))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]bool{"success": true})
+		}
+	}))
+
+	mux.HandleFunc("/api/v1/rbac/namespace-grants", cors(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case "GET":
+			grants, err := rbacSvc.ListNamespaceGrants(ctx, r.URL.Query().Get("subject_kind"), r.URL.Query().Get("subject"))
+			if err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"grants": grants})
+
+		case "POST":
+			var req struct {
+				SubjectKind      string   `json:"subject_kind"`
+				Subject          string   `json:"subject"`
+				Namespaces       []string `json:"namespaces"`
+				SpiffeIDPrefixes []string `json:"spiffe_id_prefixes"`
+				SiteIDs          []string `json:"site_ids"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			grant, err := rbacSvc.CreateNamespaceGrant(ctx, req.SubjectKind, req.Subject, req.Namespaces,
+				req.SpiffeIDPrefixes, req.SiteIDs)
+			if err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusBadRequest))
+				return
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(grant)
+		}
+	}))
+
+	mux.HandleFunc("/api/v1/rbac/namespace-grants/", cors(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("Content-Type", "application/json")
+
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/rbac/namespace-grants/")
+		if id == "" {
+			http.Error(w, "Namespace grant ID required", http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == "DELETE" {
+			if err := rbacSvc.DeleteNamespaceGrant(ctx, id); err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusNotFound))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]bool{"success": true})
+		}
+	}))
+
+	// Change request endpoints
+	mux.HandleFunc("/api/v1/changes", cors(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method == "GET" {
+			changes, err := changeSvc.ListChangeRequests(ctx, r.URL.Query().Get("status"), r.URL.Query().Get("entry_id"))
+			if err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"changes": changes})
+		}
+	}))
+
+	mux.HandleFunc("/api/v1/changes/", cors(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		w.Header().Set("Content-Type", "application/json")
+
+		id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v1/changes/"), "/")
+		if id == "" {
+			http.Error(w, "Change request ID required", http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case action == "" && r.Method == "GET":
+			change, err := changeSvc.GetChangeRequest(ctx, id)
+			if err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusNotFound))
+				return
+			}
+			json.NewEncoder(w).Encode(change)
+
+		case (action == "approve" || action == "reject") && r.Method == "POST":
+			var req struct {
+				Comment string `json:"comment"`
+			}
+			if r.ContentLength > 0 {
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					http.Error(w, "Invalid request body", http.StatusBadRequest)
+					return
+				}
+			}
+
+			review := changeSvc.ApproveChange
+			if action == "reject" {
+				review = changeSvc.RejectChange
+			}
+			change, err := review(ctx, id, req.Comment)
+			if err != nil {
+				http.Error(w, err.Error(), httpStatus(err, http.StatusConflict))
+				return
+			}
+			json.NewEncoder(w).Encode(change)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	// Desired state export and import, to rebuild a lost database
+	mux.HandleFunc("/api/v1/state/export", cors(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		result, err := stateSvc.ExportState(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
+			return
+		}
+		name := "spire-mgmt-state-" + result.Info.CreatedAt.UTC().Format("20060102T150405Z") + ".json.gz"
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		w.Write(result.Archive)
+	}))
+
+	mux.HandleFunc("/api/v1/state/import", cors(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		archive, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStateArchiveSize))
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		result, err := stateSvc.ImportState(r.Context(), archive, r.URL.Query().Get("mode"))
+		if err != nil {
+			http.Error(w, err.Error(), httpStatus(err, http.StatusInternalServerError))
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+
 	return mux
 }
+
+// maxStateArchiveSize bounds the state archives accepted for import
+const maxStateArchiveSize = 256 << 20
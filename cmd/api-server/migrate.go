@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/migrate"
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+)
+
+const migrateUsage = `usage: api-server migrate up|down|status [flags]
+
+  up      apply all pending migrations
+  down    revert the most recent migrations (-steps, default 1)
+  status  list migrations and whether they are applied`
+
+// runMigrate implements the migrate command. The exit status is 0 on success,
+// 1 if migrating failed and 2 on usage or connection errors.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	command := args[0]
+
+	fs := flag.NewFlagSet("migrate "+command, flag.ContinueOnError)
+	steps := fs.Int("steps", 1, "number of migrations to revert (down only)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if command != "up" && command != "down" && command != "status" {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if *steps < 1 {
+		fmt.Fprintln(os.Stderr, "-steps must be at least 1")
+		return 2
+	}
+
+	dbConfig, err := repository.ConfigFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid database configuration: %v\n", err)
+		return 2
+	}
+	db, err := repository.NewDB(dbConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 2
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db, dbConfig.Driver)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load migrations: %v\n", err)
+		return 2
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migration failed after applying %d: %v\n", n, err)
+			return 1
+		}
+		fmt.Printf("Applied %d migrations, schema is at version %d\n", n, migrator.Latest())
+
+	case "down":
+		n, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Revert failed after reverting %d: %v\n", n, err)
+			return 1
+		}
+		fmt.Printf("Reverted %d migrations\n", n)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+			return 1
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-40s %s\n", s.Version, s.Name, applied)
+		}
+	}
+	return 0
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/yourorg/spire-workload-mgmt/internal/sync"
 )
@@ -14,15 +16,25 @@ import (
 func main() {
 	log.Println("Starting SPIRE Workload Management Site Agent...")
 
+	hostname, _ := os.Hostname()
+
 	// Load configuration from environment
 	config := sync.Config{
-		SiteID:              getEnv("SITE_ID", "site-a"),
-		SiteName:            getEnv("SITE_NAME", "Default Site"),
-		APIServerAddress:    getEnv("API_SERVER_ADDRESS", "localhost:8081"),
-		SpireSocketPath:     getEnv("SPIRE_SOCKET_PATH", "/run/spire/agent-sockets/spire-agent.sock"),
-		SyncIntervalSeconds: getEnvInt("SYNC_INTERVAL_SECONDS", 10),
-		MaxEntries:          getEnvInt("MAX_ENTRIES", 10),
+		SiteID:                   getEnv("SITE_ID", "site-a"),
+		SiteName:                 getEnv("SITE_NAME", "Default Site"),
+		AgentID:                  getEnv("AGENT_ID", hostname),
+		APIServerAddress:         getEnv("API_SERVER_ADDRESS", "localhost:8081"),
+		SpireSocketPath:          getEnv("SPIRE_SOCKET_PATH", "/run/spire/agent-sockets/spire-agent.sock"),
+		SyncIntervalSeconds:      getEnvInt("SYNC_INTERVAL_SECONDS", 10),
+		MaxEntries:               getEnvInt("MAX_ENTRIES", 10),
+		LongPollSeconds:          getEnvInt("LONG_POLL_SECONDS", 0),
+		JournalPath:              getEnv("JOURNAL_PATH", ""),
+		HeartbeatIntervalSeconds: getEnvInt("HEARTBEAT_INTERVAL_SECONDS", 30),
+		SpiffeEndpointSocket:     getEnv("SPIFFE_ENDPOINT_SOCKET", ""),
+		APIServerSpiffeID:        getEnv("API_SERVER_SPIFFE_ID", ""),
+		UnreadyAfterCycles:       getEnvInt("UNREADY_AFTER_CYCLES", sync.DefaultUnreadyAfterCycles),
 	}
+	healthPort := getEnv("HEALTH_PORT", "8082")
 
 	// Validate required config
 	if config.SiteID == "" {
@@ -50,11 +62,34 @@ func main() {
 		cancel()
 	}()
 
+	// Serve probes and metrics. An empty HEALTH_PORT disables the server.
+	var httpServer *http.Server
+	if healthPort != "" {
+		httpServer = &http.Server{
+			Addr:    ":" + healthPort,
+			Handler: agent.Handler(),
+		}
+		go func() {
+			log.Printf("Health and metrics server listening on port %s", healthPort)
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Health server failed: %v", err)
+			}
+		}()
+	}
+
 	// Run agent
 	if err := agent.Run(ctx); err != nil && err != context.Canceled {
 		log.Fatalf("Agent error: %v", err)
 	}
 
+	if httpServer != nil {
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelShutdown()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shut down health server: %v", err)
+		}
+	}
+
 	log.Println("Site agent stopped")
 }
 
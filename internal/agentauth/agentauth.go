@@ -0,0 +1,183 @@
+package agentauth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+type peerIDKey struct{}
+
+// WithPeerID returns a context carrying the caller's verified SPIFFE ID
+func WithPeerID(ctx context.Context, id spiffeid.ID) context.Context {
+	return context.WithValue(ctx, peerIDKey{}, id)
+}
+
+// PeerIDFromContext returns the caller's verified SPIFFE ID, if the request
+// arrived over mTLS
+func PeerIDFromContext(ctx context.Context) (spiffeid.ID, bool) {
+	id, ok := ctx.Value(peerIDKey{}).(spiffeid.ID)
+	return id, ok
+}
+
+// Source provides the workload's X.509 SVID and the trust bundles that peer
+// SVIDs are verified against. A workloadapi.X509Source is one.
+type Source interface {
+	x509svid.Source
+	x509bundle.Source
+}
+
+// NewX509Source fetches the workload's X.509 SVID and trust bundles from the
+// SPIFFE Workload API at addr (e.g. unix:///run/spire/agent-sockets/spire-agent.sock)
+// and keeps them rotated until closed
+func NewX509Source(ctx context.Context, addr string) (*workloadapi.X509Source, error) {
+	source, err := workloadapi.NewX509Source(ctx,
+		workloadapi.WithClientOptions(workloadapi.WithAddr(addr)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch X.509 SVID from workload API: %w", err)
+	}
+	return source, nil
+}
+
+// ServerTLSConfig returns a TLS config that requires a client SVID chained to a
+// trust bundle known to source and issued in one of trustDomains. If
+// trustDomains is empty, only the server's own trust domain is accepted.
+// Per-site authorization of the client ID is done by the service layer.
+func ServerTLSConfig(source Source, trustDomains []spiffeid.TrustDomain) (*tls.Config, error) {
+	if len(trustDomains) == 0 {
+		svid, err := source.GetX509SVID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get X.509 SVID: %w", err)
+		}
+		trustDomains = []spiffeid.TrustDomain{svid.ID.TrustDomain()}
+	}
+	return tlsconfig.MTLSServerConfig(source, source, tlsconfig.AdaptMatcher(memberOfAny(trustDomains))), nil
+}
+
+// ParseTrustDomains parses a comma-separated list of trust domain names
+func ParseTrustDomains(s string) ([]spiffeid.TrustDomain, error) {
+	var tds []spiffeid.TrustDomain
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		td, err := spiffeid.TrustDomainFromString(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trust domain %q: %w", name, err)
+		}
+		tds = append(tds, td)
+	}
+	return tds, nil
+}
+
+// memberOfAny matches IDs in any of trustDomains
+func memberOfAny(trustDomains []spiffeid.TrustDomain) spiffeid.Matcher {
+	return func(id spiffeid.ID) error {
+		for _, td := range trustDomains {
+			if id.MemberOf(td) {
+				return nil
+			}
+		}
+		return fmt.Errorf("unexpected trust domain %q", id.TrustDomain())
+	}
+}
+
+// ClientTLSConfig returns a TLS config that presents the agent's SVID and only
+// accepts a server presenting serverID. If serverID is empty, any server in
+// the agent's own trust domain is accepted.
+func ClientTLSConfig(source Source, serverID string) (*tls.Config, error) {
+	if serverID != "" {
+		id, err := spiffeid.FromString(serverID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API server SPIFFE ID: %w", err)
+		}
+		return tlsconfig.MTLSClientConfig(source, source, tlsconfig.AuthorizeID(id)), nil
+	}
+
+	svid, err := source.GetX509SVID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get X.509 SVID: %w", err)
+	}
+	return tlsconfig.MTLSClientConfig(source, source, tlsconfig.AuthorizeMemberOf(svid.ID.TrustDomain())), nil
+}
+
+// HTTPMiddleware adds the SPIFFE ID from a verified client certificate to the
+// request context
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			if id, ok := idFromChains(r.TLS.VerifiedChains, r.TLS.PeerCertificates); ok {
+				r = r.WithContext(WithPeerID(r.Context(), id))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryServerInterceptor adds the SPIFFE ID of an mTLS peer to the context
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(contextWithGRPCPeer(ctx), req)
+}
+
+// StreamServerInterceptor adds the SPIFFE ID of an mTLS peer to the stream context
+func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &wrappedStream{ServerStream: ss, ctx: contextWithGRPCPeer(ss.Context())})
+}
+
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedStream) Context() context.Context {
+	return w.ctx
+}
+
+func contextWithGRPCPeer(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	if id, ok := idFromChains(tlsInfo.State.VerifiedChains, tlsInfo.State.PeerCertificates); ok {
+		return WithPeerID(ctx, id)
+	}
+	return ctx
+}
+
+// idFromChains extracts the SPIFFE ID from the peer's leaf certificate. The
+// go-spiffe TLS configs verify chains in VerifyPeerCertificate, so the leaf
+// of PeerCertificates is trusted once the handshake has completed.
+func idFromChains(verified [][]*x509.Certificate, peerCerts []*x509.Certificate) (spiffeid.ID, bool) {
+	var leaf *x509.Certificate
+	switch {
+	case len(verified) > 0 && len(verified[0]) > 0:
+		leaf = verified[0][0]
+	case len(peerCerts) > 0:
+		leaf = peerCerts[0]
+	default:
+		return spiffeid.ID{}, false
+	}
+
+	id, err := x509svid.IDFromCert(leaf)
+	if err != nil {
+		return spiffeid.ID{}, false
+	}
+	return id, true
+}
@@ -0,0 +1,285 @@
+package agentauth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+	"github.com/yourorg/spire-workload-mgmt/internal/agentauth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// testCA issues X.509 SVIDs in one trust domain
+type testCA struct {
+	td   spiffeid.TrustDomain
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+var serial int64
+
+func newTestCA(t *testing.T, trustDomain string) *testCA {
+	t.Helper()
+	td := spiffeid.RequireTrustDomainFromString(trustDomain)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: trustDomain + " CA"},
+		URIs:                  []*url.URL{td.ID().URL()},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	cert := sign(t, tmpl, nil, key.Public(), key)
+	return &testCA{td: td, cert: cert, key: key}
+}
+
+// issue returns an SVID for path in the CA's trust domain
+func (ca *testCA) issue(t *testing.T, path string) *x509svid.SVID {
+	t.Helper()
+	id := spiffeid.RequireFromPath(ca.td, path)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		URIs:                  []*url.URL{id.URL()},
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	cert := sign(t, tmpl, ca.cert, key.Public(), ca.key)
+	return &x509svid.SVID{ID: id, Certificates: []*x509.Certificate{cert}, PrivateKey: key}
+}
+
+func (ca *testCA) bundle() *x509bundle.Bundle {
+	return x509bundle.FromX509Authorities(ca.td, []*x509.Certificate{ca.cert})
+}
+
+// sign creates the certificate tmpl, signed by parent, or self-signed if
+// parent is nil
+func sign(t *testing.T, tmpl, parent *x509.Certificate, pub crypto.PublicKey, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+	serial++
+	tmpl.SerialNumber = big.NewInt(serial)
+	tmpl.NotBefore = time.Now().Add(-time.Minute)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent = tmpl
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// memorySource is an agentauth.Source over an in-memory SVID and bundles
+type memorySource struct {
+	svid    *x509svid.SVID
+	bundles *x509bundle.Set
+}
+
+func (s *memorySource) GetX509SVID() (*x509svid.SVID, error) { return s.svid, nil }
+
+func (s *memorySource) GetX509BundleForTrustDomain(td spiffeid.TrustDomain) (*x509bundle.Bundle, error) {
+	return s.bundles.GetX509BundleForTrustDomain(td)
+}
+
+// serveAgents starts an mTLS server that responds with the caller's SPIFFE
+// ID, and returns its URL
+func serveAgents(t *testing.T, tlsConfig *tls.Config) string {
+	t.Helper()
+	l, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{
+		Handler: agentauth.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := agentauth.PeerIDFromContext(r.Context()); ok {
+				io.WriteString(w, id.String())
+			}
+		})),
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return "https://" + l.Addr().String()
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	local := newTestCA(t, "example.org")
+	federated := newTestCA(t, "other.org")
+	bundles := x509bundle.NewSet(local.bundle(), federated.bundle())
+	server := &memorySource{svid: local.issue(t, "/api-server"), bundles: bundles}
+	serverID := server.svid.ID.String()
+
+	tests := []struct {
+		name         string
+		trustDomains []spiffeid.TrustDomain
+		client       *x509svid.SVID // nil presents no certificate
+		wantID       string
+	}{
+		{
+			name:   "agent in the server's trust domain",
+			client: local.issue(t, "/site/a"),
+			wantID: "spiffe://example.org/site/a",
+		},
+		{
+			// Which site an agent may act for is checked by the service
+			name:   "agent of another site",
+			client: local.issue(t, "/site/b"),
+			wantID: "spiffe://example.org/site/b",
+		},
+		{
+			name:   "trust domain not accepted",
+			client: federated.issue(t, "/site/a"),
+		},
+		{
+			name:         "listed trust domain",
+			trustDomains: []spiffeid.TrustDomain{federated.td},
+			client:       federated.issue(t, "/site/a"),
+			wantID:       "spiffe://other.org/site/a",
+		},
+		{
+			name:         "server's own trust domain not listed",
+			trustDomains: []spiffeid.TrustDomain{federated.td},
+			client:       local.issue(t, "/site/a"),
+		},
+		{
+			name: "no client certificate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := agentauth.ServerTLSConfig(server, tt.trustDomains)
+			if err != nil {
+				t.Fatalf("ServerTLSConfig: %v", err)
+			}
+			baseURL := serveAgents(t, tlsConfig)
+
+			var clientConfig *tls.Config
+			if tt.client != nil {
+				clientSource := &memorySource{svid: tt.client, bundles: bundles}
+				if clientConfig, err = agentauth.ClientTLSConfig(clientSource, serverID); err != nil {
+					t.Fatalf("ClientTLSConfig: %v", err)
+				}
+			} else {
+				clientConfig = tlsconfig.TLSClientConfig(bundles, tlsconfig.AuthorizeAny())
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+			defer client.CloseIdleConnections()
+
+			resp, err := client.Get(baseURL)
+			if tt.wantID == "" {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("request succeeded with status %d, want the handshake refused", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.wantID {
+				t.Errorf("peer ID in the request context %q, want %q", body, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestClientTLSConfigRejectsOtherServer(t *testing.T) {
+	ca := newTestCA(t, "example.org")
+	bundles := x509bundle.NewSet(ca.bundle())
+	server := &memorySource{svid: ca.issue(t, "/impostor"), bundles: bundles}
+	tlsConfig, err := agentauth.ServerTLSConfig(server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseURL := serveAgents(t, tlsConfig)
+
+	agent := &memorySource{svid: ca.issue(t, "/site/a"), bundles: bundles}
+	clientConfig, err := agentauth.ClientTLSConfig(agent, "spiffe://example.org/api-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+	defer client.CloseIdleConnections()
+	if resp, err := client.Get(baseURL); err == nil {
+		resp.Body.Close()
+		t.Error("agent accepted a server with another SPIFFE ID")
+	}
+}
+
+func TestGRPCPeerID(t *testing.T) {
+	ca := newTestCA(t, "example.org")
+	svid := ca.issue(t, "/site/a")
+
+	tests := []struct {
+		name   string
+		peer   *peer.Peer
+		wantID string
+	}{
+		{
+			name: "mTLS peer",
+			peer: &peer.Peer{AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+				PeerCertificates: svid.Certificates,
+				VerifiedChains:   [][]*x509.Certificate{{svid.Certificates[0], ca.cert}},
+			}}},
+			wantID: "spiffe://example.org/site/a",
+		},
+		{
+			name: "TLS without a client certificate",
+			peer: &peer.Peer{AuthInfo: credentials.TLSInfo{}},
+		},
+		{
+			name: "plaintext peer",
+			peer: &peer.Peer{Addr: &net.TCPAddr{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), tt.peer)
+			var gotID string
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				if id, ok := agentauth.PeerIDFromContext(ctx); ok {
+					gotID = id.String()
+				}
+				return nil, nil
+			}
+			if _, err := agentauth.UnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+				t.Fatal(err)
+			}
+			if gotID != tt.wantID {
+				t.Errorf("peer ID %q, want %q", gotID, tt.wantID)
+			}
+		})
+	}
+}
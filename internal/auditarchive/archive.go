@@ -0,0 +1,179 @@
+// Package auditarchive moves audit log entries that have outlived their
+// retention period out of the database. Expired entries are written to
+// gzip-compressed JSON Lines archives with a SHA-256 checksum, and deleted
+// only once the archive is stored. An archive can be loaded back into the
+// database for investigation.
+package auditarchive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+)
+
+// checksumSuffix names the file beside each archive holding its checksum, in
+// the format of sha256sum
+const checksumSuffix = ".sha256"
+
+// Store keeps archives. Object stores implement it alongside the local
+// directory store.
+type Store interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// NewStore opens the archive store at location, a directory path or a
+// file:// URL
+func NewStore(location string) (Store, error) {
+	scheme, path, ok := strings.Cut(location, "://")
+	switch {
+	case location == "":
+		return nil, fmt.Errorf("no archive location given")
+	case !ok:
+		path = location
+	case scheme != "file":
+		return nil, fmt.Errorf("unsupported archive store %q, expected a directory or file:// URL", scheme)
+	}
+	if err := os.MkdirAll(path, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &dirStore{dir: path}, nil
+}
+
+// dirStore keeps archives as files in a local directory
+type dirStore struct {
+	dir string
+}
+
+// Put writes an archive, replacing it atomically once it is synced to disk
+func (s *dirStore) Put(ctx context.Context, name string, data []byte) error {
+	f, err := os.CreateTemp(s.dir, "."+filepath.Base(name)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync %s: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := os.Rename(f.Name(), filepath.Join(s.dir, filepath.Base(name))); err != nil {
+		return fmt.Errorf("failed to store %s: %w", name, err)
+	}
+	return nil
+}
+
+// Get reads an archive
+func (s *dirStore) Get(ctx context.Context, name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.Base(name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// Delete removes an archive, if it exists
+func (s *dirStore) Delete(ctx context.Context, name string) error {
+	if err := os.Remove(filepath.Join(s.dir, filepath.Base(name))); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s: %w", name, err)
+	}
+	return nil
+}
+
+// Name names the archive of entries, which are in id order, after their first
+// timestamp and id range
+func Name(entries []repository.ArchivedAuditEntry) string {
+	first, last := entries[0], entries[len(entries)-1]
+	return fmt.Sprintf("audit-%s-%d-%d.jsonl.gz", first.Timestamp.UTC().Format("20060102T150405Z"), first.ID, last.ID)
+}
+
+// Encode writes entries as gzip-compressed JSON Lines and returns the
+// archive with its hex SHA-256 checksum
+func Encode(entries []repository.ArchivedAuditEntry) ([]byte, string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return nil, "", fmt.Errorf("failed to encode audit entry %d: %w", entries[i].ID, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to compress archive: %w", err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	return buf.Bytes(), hex.EncodeToString(sum[:]), nil
+}
+
+// Decode checks an archive against its checksum and reads its entries back.
+// It also checks that every entry still matches its hash and links to the
+// entry before it in its stream, where that entry is in the archive too.
+func Decode(data []byte, checksum string) ([]repository.ArchivedAuditEntry, error) {
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return nil, fmt.Errorf("archive checksum does not match")
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	defer zr.Close()
+
+	var entries []repository.ArchivedAuditEntry
+	last := make(map[string]repository.ArchivedAuditEntry)
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e repository.ArchivedAuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !e.HashMatches() {
+			return nil, fmt.Errorf("line %d: content hash of entry %d does not match: entry was modified", line, e.ID)
+		}
+		if prev, ok := last[e.Stream]; ok && e.Seq == prev.Seq+1 && e.PrevHash != prev.Hash {
+			return nil, fmt.Errorf("line %d: previous hash of entry %d does not match the preceding entry", line, e.ID)
+		}
+		last[e.Stream] = e
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return entries, nil
+}
+
+// checksumFile is the content of the checksum file of the named archive
+func checksumFile(name, checksum string) []byte {
+	return []byte(checksum + "  " + name + "\n")
+}
+
+// ReadChecksum reads the checksum stored beside the named archive
+func ReadChecksum(ctx context.Context, store Store, name string) (string, error) {
+	data, err := store.Get(ctx, name+checksumSuffix)
+	if err != nil {
+		return "", err
+	}
+	checksum, _, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	return checksum, nil
+}
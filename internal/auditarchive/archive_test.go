@@ -0,0 +1,39 @@
+package auditarchive
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecodeDetectsTampering(t *testing.T) {
+	a, repo, _ := newTestArchiver(t, "default=1")
+	logEntries(t, repo, "create", "site", 3)
+	entries, err := repo.ListExpired(context.Background(), a.cfg.Retention, a.now(), 10)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("ListExpired: %d, %v", len(entries), err)
+	}
+
+	data, checksum, err := Encode(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := Decode(data, checksum); err != nil || len(decoded) != 3 {
+		t.Fatalf("Decode: %d entries, %v", len(decoded), err)
+	}
+	if _, err := Decode(data, strings.Repeat("0", 64)); err == nil {
+		t.Error("decoded an archive against the wrong checksum")
+	}
+
+	// Re-encoding with a fresh checksum does not hide a modified entry
+	modified := append(entries[:0:0], entries...)
+	modified[1].Details = json.RawMessage(`{"n":42}`)
+	data, checksum, err = Encode(modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(data, checksum); err == nil || !strings.Contains(err.Error(), "was modified") {
+		t.Errorf("Decode of a modified entry: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package auditarchive
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+)
+
+var archivedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "spire_mgmt_audit_entries_archived_total",
+	Help: "Audit log entries archived and deleted after their retention period, by action.",
+}, []string{"action"})
+
+// defaultRetentionKey sets the retention period of actions not listed on
+// their own
+const defaultRetentionKey = "default"
+
+// Config configures audit log archival
+type Config struct {
+	Retention repository.AuditRetention
+	// Location is the directory or file:// URL archives are written to. It
+	// is required if any entries expire.
+	Location string
+	// Interval is the time between archival runs
+	Interval time.Duration
+	// BatchSize is the most entries written to one archive
+	BatchSize int
+	// PartitionsAhead is the number of months past the current one that
+	// audit_log is partitioned for in advance, on MySQL
+	PartitionsAhead int
+}
+
+// ConfigFromEnv reads the archival configuration from the environment
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Location:        os.Getenv("AUDIT_ARCHIVE_LOCATION"),
+		Interval:        time.Hour,
+		BatchSize:       1000,
+		PartitionsAhead: 3,
+	}
+
+	var err error
+	if cfg.Retention, err = ParseRetention(os.Getenv("AUDIT_RETENTION_DAYS")); err != nil {
+		return cfg, err
+	}
+	if cfg.Retention.Enabled() && cfg.Location == "" {
+		return cfg, fmt.Errorf("AUDIT_ARCHIVE_LOCATION is required when AUDIT_RETENTION_DAYS is set")
+	}
+	if v := os.Getenv("AUDIT_ARCHIVE_INTERVAL_SECONDS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("invalid AUDIT_ARCHIVE_INTERVAL_SECONDS %q", v)
+		}
+		cfg.Interval = time.Duration(n) * time.Second
+	}
+	if v := os.Getenv("AUDIT_ARCHIVE_BATCH_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("invalid AUDIT_ARCHIVE_BATCH_SIZE %q", v)
+		}
+		cfg.BatchSize = n
+	}
+	if v := os.Getenv("AUDIT_PARTITIONS_AHEAD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid AUDIT_PARTITIONS_AHEAD %q", v)
+		}
+		cfg.PartitionsAhead = n
+	}
+	return cfg, nil
+}
+
+// ParseRetention parses a comma-separated list of <action>=<days> retention
+// periods. The action "default" applies to actions not listed, and zero days
+// keeps entries forever. An empty list keeps every entry forever.
+func ParseRetention(s string) (repository.AuditRetention, error) {
+	retention := repository.AuditRetention{Actions: make(map[string]time.Duration)}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		action, daysStr, ok := strings.Cut(item, "=")
+		if !ok || action == "" {
+			return retention, fmt.Errorf("invalid retention period %q, expected <action>=<days>", item)
+		}
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days < 0 {
+			return retention, fmt.Errorf("invalid days in retention period %q", item)
+		}
+		period := time.Duration(days) * 24 * time.Hour
+		if action == defaultRetentionKey {
+			retention.Default = period
+		} else {
+			retention.Actions[action] = period
+		}
+	}
+	return retention, nil
+}
+
+// Archiver archives and deletes expired audit log entries, and maintains the
+// audit log partitions
+type Archiver struct {
+	repo  repository.AuditRepository
+	store Store
+	cfg   Config
+	key   ed25519.PrivateKey
+	now   func() time.Time
+}
+
+// NewArchiver creates an Archiver. store and key may be nil if no entries
+// expire; otherwise key is the audit checkpoint signing key, which signs the
+// record of each archive's entries so that verification can tell them from
+// deleted ones.
+func NewArchiver(repo repository.AuditRepository, store Store, cfg Config, key ed25519.PrivateKey) *Archiver {
+	return &Archiver{repo: repo, store: store, cfg: cfg, key: key, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// Run archives expired entries and maintains the partitions at once and then
+// every interval, until ctx is cancelled
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := a.ArchiveExpired(ctx); err != nil {
+			log.Printf("Failed to archive audit log: %v", err)
+		} else if n > 0 {
+			log.Printf("Archived %d expired audit log entries", n)
+		}
+		added, dropped, err := a.repo.ManagePartitions(ctx, a.now(), a.cfg.PartitionsAhead)
+		if err != nil {
+			log.Printf("Failed to manage audit log partitions: %v", err)
+		} else if added > 0 || dropped > 0 {
+			log.Printf("Added %d and dropped %d audit log partitions", added, dropped)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ArchiveExpired archives and deletes every expired entry, one batch per
+// archive, and returns the number archived
+func (a *Archiver) ArchiveExpired(ctx context.Context) (int, error) {
+	if !a.cfg.Retention.Enabled() {
+		return 0, nil
+	}
+
+	archived := 0
+	for {
+		entries, err := a.repo.ListExpired(ctx, a.cfg.Retention, a.now(), a.cfg.BatchSize)
+		if err != nil {
+			return archived, err
+		}
+		if len(entries) == 0 {
+			return archived, nil
+		}
+		if err := a.archive(ctx, entries); err != nil {
+			return archived, err
+		}
+		archived += len(entries)
+		for _, e := range entries {
+			archivedTotal.WithLabelValues(e.Action).Inc()
+		}
+		if len(entries) < a.cfg.BatchSize {
+			return archived, nil
+		}
+	}
+}
+
+// archive stores one archive of entries and then deletes them
+func (a *Archiver) archive(ctx context.Context, entries []repository.ArchivedAuditEntry) error {
+	data, checksum, err := Encode(entries)
+	if err != nil {
+		return err
+	}
+	name := Name(entries)
+	if err := a.store.Put(ctx, name, data); err != nil {
+		return err
+	}
+	if err := a.store.Put(ctx, name+checksumSuffix, checksumFile(name, checksum)); err != nil {
+		return err
+	}
+
+	record := &repository.AuditArchive{
+		Name:           name,
+		SHA256:         checksum,
+		Entries:        len(entries),
+		FirstTimestamp: entries[0].Timestamp,
+		LastTimestamp:  entries[len(entries)-1].Timestamp,
+		CreatedAt:      a.now().Truncate(time.Second),
+	}
+	if err := a.repo.Prune(ctx, record, entries, a.key); err != nil {
+		// Another server archiving the same entries writes the same archive;
+		// it is only removed if neither recorded it
+		if _, getErr := a.repo.GetArchive(ctx, name); getErr != nil {
+			a.store.Delete(ctx, name)
+			a.store.Delete(ctx, name+checksumSuffix)
+		}
+		return err
+	}
+	return nil
+}
+
+// Restore reads the named archive from store, checks it against the checksum
+// recorded when it was archived, or the one stored beside it if the database
+// has no record of it, and loads its entries into the database for
+// investigation. It returns the number of entries in the archive and the
+// number newly loaded.
+func Restore(ctx context.Context, repo repository.AuditRepository, store Store, name string) (int, int, error) {
+	var checksum string
+	if record, err := repo.GetArchive(ctx, name); err == nil {
+		checksum = record.SHA256
+	} else if checksum, err = ReadChecksum(ctx, store, name); err != nil {
+		return 0, 0, fmt.Errorf("no checksum for archive %s: %w", name, err)
+	}
+
+	data, err := store.Get(ctx, name)
+	if err != nil {
+		return 0, 0, err
+	}
+	entries, err := Decode(data, checksum)
+	if err != nil {
+		return 0, 0, fmt.Errorf("archive %s failed verification: %w", name, err)
+	}
+	restored, err := repo.Restore(ctx, name, entries)
+	if err != nil {
+		return len(entries), 0, err
+	}
+	return len(entries), restored, nil
+}
@@ -0,0 +1,174 @@
+package auditarchive
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/notify"
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+)
+
+// testKey signs the runs of entries the test archivers archive
+var testKey = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+// newTestArchiver creates an archiver over an in-memory audit log and an
+// archive directory, running two days in the future so day-long retention
+// periods have expired
+func newTestArchiver(t *testing.T, retention string) (*Archiver, repository.AuditRepository, Store) {
+	t.Helper()
+	repo := repository.NewMemoryStore(notify.NewHub()).Audit
+	store, err := NewStore("file://" + t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{BatchSize: 2}
+	if cfg.Retention, err = ParseRetention(retention); err != nil {
+		t.Fatal(err)
+	}
+	a := NewArchiver(repo, store, cfg, testKey)
+	a.now = func() time.Time { return time.Now().UTC().Add(48 * time.Hour) }
+	return a, repo, store
+}
+
+func logEntries(t *testing.T, repo repository.AuditRepository, action, resourceType string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("%s-%d", resourceType, i)
+		if err := repo.Log(context.Background(), "alice", action, resourceType, id, map[string]interface{}{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func verify(t *testing.T, repo repository.AuditRepository) *repository.AuditVerification {
+	t.Helper()
+	v, err := repo.Verify(context.Background(), "", testKey.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if v.FirstBreak != nil {
+		t.Fatalf("audit chain broken: %+v", *v.FirstBreak)
+	}
+	return v
+}
+
+func TestArchivePurgeRestoreRoundTrip(t *testing.T) {
+	a, repo, store := newTestArchiver(t, "default=1")
+	ctx := context.Background()
+	logEntries(t, repo, "create", "site", 2)
+	logEntries(t, repo, "sync", "workload_entry", 3)
+
+	archived, err := a.ArchiveExpired(ctx)
+	if err != nil || archived != 5 {
+		t.Fatalf("ArchiveExpired: %d, %v", archived, err)
+	}
+	if left, _ := repo.List(ctx, 100, 0, "", "", "", nil, nil); len(left) != 0 {
+		t.Errorf("%d entries left in the log after archival", len(left))
+	}
+
+	// Every archived entry is still accounted for by the chain
+	if v := verify(t, repo); v.Archived != 5 {
+		t.Errorf("Verify counted %d archived entries, want 5", v.Archived)
+	}
+
+	// Archives of at most two entries each, restored once
+	names := archiveNames(t, repo, store)
+	if len(names) != 3 {
+		t.Fatalf("archives: %v", names)
+	}
+	total := 0
+	for _, name := range names {
+		n, restored, err := Restore(ctx, repo, store, name)
+		if err != nil || restored != n {
+			t.Fatalf("Restore %s: %d of %d, %v", name, restored, n, err)
+		}
+		total += n
+		if _, again, err := Restore(ctx, repo, store, name); err != nil || again != 0 {
+			t.Errorf("restoring %s again loaded %d entries, %v", name, again, err)
+		}
+	}
+	if total != 5 {
+		t.Errorf("restored %d entries, want 5", total)
+	}
+}
+
+func TestRestoreWithoutArchiveRecord(t *testing.T) {
+	a, repo, store := newTestArchiver(t, "default=1")
+	ctx := context.Background()
+	logEntries(t, repo, "create", "site", 2)
+	if _, err := a.ArchiveExpired(ctx); err != nil {
+		t.Fatal(err)
+	}
+	name := archiveNames(t, repo, store)[0]
+
+	// A database that never recorded the archive checks it against the
+	// checksum stored beside it
+	other := repository.NewMemoryStore(notify.NewHub()).Audit
+	if n, restored, err := Restore(ctx, other, store, name); err != nil || n != 2 || restored != 2 {
+		t.Errorf("Restore without a record: %d of %d, %v", restored, n, err)
+	}
+
+	// And rejects an archive that no longer matches it
+	data, _ := store.Get(ctx, name)
+	data[len(data)-1] ^= 0xff
+	if err := store.Put(ctx, name, data); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Restore(ctx, other, store, name); err == nil {
+		t.Error("restored an archive that does not match its checksum")
+	}
+}
+
+func TestVerifyAcrossArchivedRuns(t *testing.T) {
+	// Only sync entries expire, so the archived entries are runs in the
+	// middle of the workload_entry chain
+	a, repo, _ := newTestArchiver(t, "sync=1")
+	ctx := context.Background()
+	logEntries(t, repo, "create", "workload_entry", 1)
+	logEntries(t, repo, "sync", "workload_entry", 3)
+	logEntries(t, repo, "update", "workload_entry", 1)
+	logEntries(t, repo, "sync", "workload_entry", 2)
+	logEntries(t, repo, "delete", "workload_entry", 1)
+
+	if archived, err := a.ArchiveExpired(ctx); err != nil || archived != 5 {
+		t.Fatalf("ArchiveExpired: %d, %v", archived, err)
+	}
+	v := verify(t, repo)
+	if v.Entries != 3 || v.Archived != 5 {
+		t.Errorf("Verify: %d entries in the log and %d archived, want 3 and 5", v.Entries, v.Archived)
+	}
+
+	// The chain keeps verifying as it grows past the archived runs, and
+	// across a second archival of later runs
+	logEntries(t, repo, "sync", "workload_entry", 1)
+	logEntries(t, repo, "create", "workload_entry", 1)
+	verify(t, repo)
+	if archived, err := a.ArchiveExpired(ctx); err != nil || archived != 1 {
+		t.Fatalf("second ArchiveExpired: %d, %v", archived, err)
+	}
+	if v := verify(t, repo); v.Entries != 4 || v.Archived != 6 {
+		t.Errorf("Verify after a second archival: %d entries in the log and %d archived", v.Entries, v.Archived)
+	}
+}
+
+// archiveNames returns the names of the archives in store, checking each is
+// recorded in repo
+func archiveNames(t *testing.T, repo repository.AuditRepository, store Store) []string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(store.(*dirStore).dir, "*.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = filepath.Base(path)
+		if _, err := repo.GetArchive(context.Background(), names[i]); err != nil {
+			t.Errorf("archive %s is not recorded: %v", names[i], err)
+		}
+	}
+	return names
+}
@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+// ErrUnauthenticated is returned when a request has no valid credentials
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+const (
+	// jwksRefreshInterval is how often keys are reloaded from their source
+	jwksRefreshInterval = time.Hour
+	// jwksMinRefreshInterval limits reloads triggered by unknown key IDs
+	jwksMinRefreshInterval = time.Minute
+	// clockSkew is the leeway allowed when validating exp, nbf and iat
+	clockSkew = time.Minute
+)
+
+// allowedAlgorithms are the asymmetric JWS algorithms accepted for ID/access tokens
+var allowedAlgorithms = map[string]bool{
+	string(jose.RS256): true, string(jose.RS384): true, string(jose.RS512): true,
+	string(jose.PS256): true, string(jose.PS384): true, string(jose.PS512): true,
+	string(jose.ES256): true, string(jose.ES384): true, string(jose.ES512): true,
+	string(jose.EdDSA): true,
+}
+
+// OIDCConfig configures bearer token verification
+type OIDCConfig struct {
+	Issuer      string
+	Audience    string
+	JWKSFile    string // Local JWKS document; takes precedence over JWKSURL
+	JWKSURL     string
+	GroupsClaim string // Defaults to "groups"
+}
+
+// OIDCConfigFromEnv creates an OIDCConfig from environment variables. An
+// empty Issuer means authentication is disabled.
+func OIDCConfigFromEnv() OIDCConfig {
+	return OIDCConfig{
+		Issuer:      os.Getenv("OIDC_ISSUER"),
+		Audience:    os.Getenv("OIDC_AUDIENCE"),
+		JWKSFile:    os.Getenv("OIDC_JWKS_FILE"),
+		JWKSURL:     os.Getenv("OIDC_JWKS_URL"),
+		GroupsClaim: getEnv("OIDC_GROUPS_CLAIM", "groups"),
+	}
+}
+
+// Verifier validates JWT bearer tokens issued by a single OIDC issuer
+type Verifier struct {
+	cfg        OIDCConfig
+	httpClient *http.Client
+
+	mu         sync.Mutex
+	keys       jose.JSONWebKeySet
+	loadedAt   time.Time
+	lastReload time.Time
+}
+
+// NewVerifier creates a Verifier and loads the issuer's signing keys
+func NewVerifier(ctx context.Context, cfg OIDCConfig) (*Verifier, error) {
+	if cfg.Issuer == "" {
+		return nil, fmt.Errorf("OIDC issuer is required")
+	}
+	// Without an audience, a token the issuer minted for any other client
+	// would be accepted
+	if cfg.Audience == "" {
+		return nil, fmt.Errorf("OIDC audience is required")
+	}
+	if cfg.JWKSFile == "" && cfg.JWKSURL == "" {
+		return nil, fmt.Errorf("either a JWKS file or a JWKS URL is required")
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+
+	v := &Verifier{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	if err := v.reload(ctx); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Verify validates a raw JWT and returns the principal it identifies
+func (v *Verifier) Verify(ctx context.Context, raw string) (*Principal, error) {
+	tok, err := jwt.ParseSigned(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token", ErrUnauthenticated)
+	}
+	if len(tok.Headers) != 1 {
+		return nil, fmt.Errorf("%w: unexpected token signatures", ErrUnauthenticated)
+	}
+	header := tok.Headers[0]
+	if !allowedAlgorithms[header.Algorithm] {
+		return nil, fmt.Errorf("%w: unsupported signing algorithm %q", ErrUnauthenticated, header.Algorithm)
+	}
+
+	keys := v.lookup(ctx, header.KeyID)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrUnauthenticated, header.KeyID)
+	}
+
+	var claims jwt.Claims
+	var extra map[string]interface{}
+	var verifyErr error
+	for _, key := range keys {
+		if verifyErr = tok.Claims(key.Key, &claims, &extra); verifyErr == nil {
+			break
+		}
+	}
+	if verifyErr != nil {
+		return nil, fmt.Errorf("%w: invalid token signature", ErrUnauthenticated)
+	}
+
+	expected := jwt.Expected{Issuer: v.cfg.Issuer, Audience: jwt.Audience{v.cfg.Audience}, Time: time.Now()}
+	if err := claims.ValidateWithLeeway(expected, clockSkew); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	if claims.Expiry == nil {
+		return nil, fmt.Errorf("%w: token has no expiry", ErrUnauthenticated)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrUnauthenticated)
+	}
+
+	// The email becomes the audit actor and a binding subject, so an email
+	// the IdP has not verified could claim someone else's role bindings
+	p := &Principal{Subject: claims.Subject}
+	if email, ok := extra["email"].(string); ok && isTrue(extra["email_verified"]) {
+		p.Email = email
+	}
+	p.Groups = stringList(extra[v.cfg.GroupsClaim])
+
+	return p, nil
+}
+
+// lookup returns the keys for kid, reloading the key set when the key is
+// unknown or the cached set is stale
+func (v *Verifier) lookup(ctx context.Context, kid string) []jose.JSONWebKey {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := v.keysFor(kid)
+	stale := time.Since(v.loadedAt) > jwksRefreshInterval
+	if (len(keys) == 0 || stale) && time.Since(v.lastReload) > jwksMinRefreshInterval {
+		if err := v.reloadLocked(ctx); err == nil {
+			keys = v.keysFor(kid)
+		}
+	}
+	return keys
+}
+
+func (v *Verifier) keysFor(kid string) []jose.JSONWebKey {
+	if kid != "" {
+		return v.keys.Key(kid)
+	}
+	return v.keys.Keys
+}
+
+func (v *Verifier) reload(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.reloadLocked(ctx)
+}
+
+func (v *Verifier) reloadLocked(ctx context.Context) error {
+	v.lastReload = time.Now()
+
+	data, err := v.fetchJWKS(ctx)
+	if err != nil {
+		return err
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+	if len(keys.Keys) == 0 {
+		return fmt.Errorf("JWKS contains no keys")
+	}
+
+	v.keys = keys
+	v.loadedAt = time.Now()
+	return nil
+}
+
+func (v *Verifier) fetchJWKS(ctx context.Context) ([]byte, error) {
+	if v.cfg.JWKSFile != "" {
+		data, err := os.ReadFile(v.cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", v.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	return data, nil
+}
+
+// isTrue reports whether a boolean claim is true. Some IdPs send booleans as
+// strings.
+func isTrue(v interface{}) bool {
+	switch val := v.(type) {
+	case bool:
+		return val
+	case string:
+		return val == "true"
+	}
+	return false
+}
+
+// stringList converts a claim that is either a string or a list of strings
+func stringList(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,120 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+)
+
+const (
+	testIssuer   = "https://issuer.example.org"
+	testAudience = "spire-mgmt"
+)
+
+// newTestVerifier returns a verifier for testIssuer and a function that signs
+// tokens with claims for it
+func newTestVerifier(t *testing.T) (*auth.Verifier, func(claims map[string]interface{}) string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: pub, KeyID: "test", Algorithm: string(jose.EdDSA)}}}
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	v, err := auth.NewVerifier(context.Background(), auth.OIDCConfig{Issuer: testIssuer, Audience: testAudience, JWKSFile: path})
+	if err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.EdDSA, Key: priv},
+		(&jose.SignerOptions{}).WithHeader("kid", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(claims map[string]interface{}) string {
+		std := jwt.Claims{Issuer: testIssuer, Audience: jwt.Audience{testAudience}, Subject: "user-123",
+			Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+		raw, err := jwt.Signed(signer).Claims(std).Claims(claims).CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	return v, sign
+}
+
+func TestVerifyEmailRequiresVerification(t *testing.T) {
+	v, sign := newTestVerifier(t)
+
+	tests := []struct {
+		name      string
+		claims    map[string]interface{}
+		wantEmail string
+		wantActor string
+	}{
+		{"verified", map[string]interface{}{"email": "alice@example.org", "email_verified": true}, "alice@example.org", "alice@example.org"},
+		{"verified as string", map[string]interface{}{"email": "alice@example.org", "email_verified": "true"}, "alice@example.org", "alice@example.org"},
+		{"unverified", map[string]interface{}{"email": "alice@example.org", "email_verified": false}, "", "user-123"},
+		{"unverified as string", map[string]interface{}{"email": "alice@example.org", "email_verified": "false"}, "", "user-123"},
+		{"verification missing", map[string]interface{}{"email": "alice@example.org"}, "", "user-123"},
+		{"no email", map[string]interface{}{"email_verified": true}, "", "user-123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := v.Verify(context.Background(), sign(tt.claims))
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if p.Email != tt.wantEmail || p.Actor() != tt.wantActor {
+				t.Errorf("email %q, actor %q; want %q, %q", p.Email, p.Actor(), tt.wantEmail, tt.wantActor)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsOtherIssuer(t *testing.T) {
+	v, sign := newTestVerifier(t)
+	if _, err := v.Verify(context.Background(), sign(map[string]interface{}{"iss": "https://evil.example.org"})); err == nil {
+		t.Error("token from another issuer was accepted")
+	}
+}
+
+func TestVerifyRequiresAudience(t *testing.T) {
+	v, sign := newTestVerifier(t)
+	tests := []struct {
+		name string
+		aud  interface{}
+	}{
+		{"other audience", "other-client"},
+		{"no audience", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(context.Background(), sign(map[string]interface{}{"aud": tt.aud})); err == nil {
+				t.Error("token for another audience was accepted")
+			}
+		})
+	}
+
+	if _, err := auth.NewVerifier(context.Background(), auth.OIDCConfig{Issuer: testIssuer, JWKSFile: "jwks.json"}); err == nil {
+		t.Error("NewVerifier succeeded without an audience")
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Site agents authenticate with their X.509 SVID rather than a bearer token
+const (
+	agentHTTPPrefix    = "/api/v1/agent/"
+	agentGRPCPrefix    = "/spire.mgmt.v1.SiteAgentService/"
+	listAgentsMethod   = "/spire.mgmt.v1.SiteAgentService/ListAgents"
+	reflectionPrefix   = "/grpc.reflection."
+	healthPath         = "/health"
+	bearerScheme       = "bearer "
+	authorizationField = "authorization"
+)
+
+// APIKeyPrefix marks bearer tokens that are API keys rather than JWTs
+const APIKeyPrefix = "swm_"
+
+// APIKeyVerifier resolves an API key secret to the principal it identifies
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, secret string) (*Principal, error)
+}
+
+// Authenticator authenticates API callers from bearer tokens, which are either
+// OIDC JWTs or API keys. Without an OIDC verifier, requests that carry no
+// token are accepted without an identity. A nil Authenticator accepts every
+// request without an identity.
+type Authenticator struct {
+	verifier *Verifier
+	apiKeys  APIKeyVerifier
+}
+
+// NewAuthenticator creates a new Authenticator. Either argument may be nil.
+func NewAuthenticator(verifier *Verifier, apiKeys APIKeyVerifier) *Authenticator {
+	return &Authenticator{verifier: verifier, apiKeys: apiKeys}
+}
+
+// Authenticate verifies the credentials in an Authorization header value. It
+// returns a nil principal for anonymous requests when anonymous access is allowed.
+func (a *Authenticator) Authenticate(ctx context.Context, authorization string) (*Principal, error) {
+	if authorization == "" && a.verifier == nil {
+		return nil, nil
+	}
+	if len(authorization) < len(bearerScheme) || !strings.EqualFold(authorization[:len(bearerScheme)], bearerScheme) {
+		return nil, ErrUnauthenticated
+	}
+	token := strings.TrimSpace(authorization[len(bearerScheme):])
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	if strings.HasPrefix(token, APIKeyPrefix) {
+		if a.apiKeys == nil {
+			return nil, fmt.Errorf("%w: API keys are not enabled", ErrUnauthenticated)
+		}
+		return a.apiKeys.VerifyAPIKey(ctx, token)
+	}
+
+	if a.verifier == nil {
+		return nil, fmt.Errorf("%w: OIDC authentication is not enabled", ErrUnauthenticated)
+	}
+	return a.verifier.Verify(ctx, token)
+}
+
+// HTTPMiddleware rejects requests without a valid bearer token and adds the
+// caller to the request context
+func (a *Authenticator) HTTPMiddleware(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" || r.URL.Path == healthPath || strings.HasPrefix(r.URL.Path, agentHTTPPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p, err := a.Authenticate(r.Context(), r.Header.Get("Authorization"))
+		if err != nil {
+			log.Printf("Authentication failed for %s %s: %v", r.Method, r.URL.Path, err)
+			if !errors.Is(err, ErrUnauthenticated) {
+				http.Error(w, "authentication failed", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="spire-mgmt"`)
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		if p != nil {
+			r = r.WithContext(WithPrincipal(r.Context(), p))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryServerInterceptor authenticates unary gRPC calls
+func (a *Authenticator) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticateGRPC(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor authenticates streaming gRPC calls
+func (a *Authenticator) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticateGRPC(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+}
+
+func (a *Authenticator) authenticateGRPC(ctx context.Context, method string) (context.Context, error) {
+	if a == nil || !requiresUserAuth(method) {
+		return ctx, nil
+	}
+
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(authorizationField); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+
+	p, err := a.Authenticate(ctx, authorization)
+	if err != nil {
+		log.Printf("Authentication failed for %s: %v", method, err)
+		if errors.Is(err, ErrUnauthenticated) {
+			return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+		}
+		return nil, status.Errorf(codes.Internal, "authentication failed: %v", err)
+	}
+	if p == nil {
+		return ctx, nil
+	}
+	return WithPrincipal(ctx, p), nil
+}
+
+// requiresUserAuth reports whether a gRPC method is called by users rather
+// than by site agents or tooling
+func requiresUserAuth(method string) bool {
+	if strings.HasPrefix(method, reflectionPrefix) {
+		return false
+	}
+	if strings.HasPrefix(method, agentGRPCPrefix) {
+		return method == listAgentsMethod
+	}
+	return true
+}
+
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedStream) Context() context.Context {
+	return w.ctx
+}
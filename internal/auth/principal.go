@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"strings"
+)
+
+// AnonymousActor is recorded as the actor when a request carries no identity,
+// which only happens when authentication is disabled
+const AnonymousActor = "anonymous"
+
+// API key scopes
+const (
+	ScopeEntriesRead  = "entries:read"
+	ScopeEntriesWrite = "entries:write"
+	ScopeSitesRead    = "sites:read"
+	ScopeAuditRead    = "audit:read"
+)
+
+// ValidScopes lists the scopes that can be granted to an API key
+var ValidScopes = []string{ScopeEntriesRead, ScopeEntriesWrite, ScopeSitesRead, ScopeAuditRead}
+
+// Principal is the authenticated caller of an API request
+type Principal struct {
+	Subject string
+	Email   string
+	Groups  []string
+
+	// Set when the caller authenticated with an API key
+	APIKeyID       string
+	APIKeyName     string
+	Scopes         []string
+	SpiffeIDPrefix string
+}
+
+// IsAPIKey reports whether the caller authenticated with an API key
+func (p *Principal) IsAPIKey() bool {
+	return p.APIKeyID != ""
+}
+
+// HasScope reports whether the caller may perform operations covered by scope.
+// Scopes only restrict API keys.
+func (p *Principal) HasScope(scope string) bool {
+	if !p.IsAPIKey() {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsSpiffeID reports whether the caller may manage entries for spiffeID
+func (p *Principal) AllowsSpiffeID(spiffeID string) bool {
+	return !p.IsAPIKey() || p.SpiffeIDPrefix == "" || UnderSpiffeIDPrefix(spiffeID, p.SpiffeIDPrefix)
+}
+
+// UnderSpiffeIDPrefix reports whether spiffeID is prefix or a path below it.
+// Prefixes end at a path segment, so spiffe://example.org/pay does not cover
+// spiffe://example.org/payroll.
+func UnderSpiffeIDPrefix(spiffeID, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return spiffeID == prefix || strings.HasPrefix(spiffeID, prefix+"/")
+}
+
+// Actor returns the name recorded in audit logs and created_by columns
+func (p *Principal) Actor() string {
+	if p.IsAPIKey() {
+		return "apikey:" + p.APIKeyName
+	}
+	if p.Email != "" {
+		return p.Email
+	}
+	return p.Subject
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated caller
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated caller, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// ActorFromContext returns the audit actor for the request in ctx
+func ActorFromContext(ctx context.Context) string {
+	if p, ok := PrincipalFromContext(ctx); ok {
+		return p.Actor()
+	}
+	return AnonymousActor
+}
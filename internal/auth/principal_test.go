@@ -0,0 +1,37 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+)
+
+func TestAllowsSpiffeID(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		spiffeID string
+		want     bool
+	}{
+		{"spiffe://example.org/pay", "spiffe://example.org/pay", true},
+		{"spiffe://example.org/pay", "spiffe://example.org/pay/api", true},
+		{"spiffe://example.org/pay", "spiffe://example.org/payroll", false},
+		{"spiffe://example.org/pay", "spiffe://example.org/pa", false},
+		{"spiffe://example.org/pay/", "spiffe://example.org/pay", true},
+		{"spiffe://example.org/pay/", "spiffe://example.org/pay/api", true},
+		{"spiffe://example.org/pay/", "spiffe://example.org/payroll/api", false},
+		{"spiffe://example.org", "spiffe://example.org.evil/api", false},
+		{"", "spiffe://other.org/api", true},
+	}
+	for _, tt := range tests {
+		p := &auth.Principal{APIKeyID: "key-1", APIKeyName: "ci", SpiffeIDPrefix: tt.prefix}
+		if got := p.AllowsSpiffeID(tt.spiffeID); got != tt.want {
+			t.Errorf("prefix %q allows %q = %v, want %v", tt.prefix, tt.spiffeID, got, tt.want)
+		}
+	}
+
+	// Prefixes only restrict API keys
+	user := &auth.Principal{Subject: "alice", SpiffeIDPrefix: "spiffe://example.org/pay"}
+	if !user.AllowsSpiffeID("spiffe://example.org/payroll") {
+		t.Error("SPIFFE ID prefix restricted a user")
+	}
+}
@@ -2,11 +2,17 @@ package grpcserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"time"
 
-	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+	"github.com/yourorg/spire-workload-mgmt/internal/agentauth"
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/metrics"
+	"github.com/yourorg/spire-workload-mgmt/internal/ratelimit"
+	"github.com/yourorg/spire-workload-mgmt/internal/rbac"
 	"github.com/yourorg/spire-workload-mgmt/internal/service"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -24,6 +30,13 @@ type Server struct {
 	siteAgentSvc     *service.SiteAgentService
 	siteSvc          *service.SiteService
 	auditSvc         *service.AuditService
+	apiKeySvc        *service.APIKeyService
+	rbacSvc          *service.RBACService
+	changeSvc        *service.ChangeRequestService
+	stateSvc         *service.StateService
+	authenticator    *auth.Authenticator
+	authorizer       *rbac.Authorizer
+	limiter          *ratelimit.Limiter
 }
 
 // NewServer creates a new gRPC server
@@ -32,12 +45,26 @@ func NewServer(
 	siteAgentSvc *service.SiteAgentService,
 	siteSvc *service.SiteService,
 	auditSvc *service.AuditService,
+	apiKeySvc *service.APIKeyService,
+	rbacSvc *service.RBACService,
+	changeSvc *service.ChangeRequestService,
+	stateSvc *service.StateService,
+	authenticator *auth.Authenticator,
+	authorizer *rbac.Authorizer,
+	limiter *ratelimit.Limiter,
 ) *Server {
 	return &Server{
 		workloadEntrySvc: workloadEntrySvc,
 		siteAgentSvc:     siteAgentSvc,
 		siteSvc:          siteSvc,
 		auditSvc:         auditSvc,
+		apiKeySvc:        apiKeySvc,
+		rbacSvc:          rbacSvc,
+		changeSvc:        changeSvc,
+		stateSvc:         stateSvc,
+		authenticator:    authenticator,
+		authorizer:       authorizer,
+		limiter:          limiter,
 	}
 }
 
@@ -50,7 +77,10 @@ func (s *Server) Start(port string) error {
 	s.listener = lis
 
 	s.grpcServer = grpc.NewServer(
-		grpc.UnaryInterceptor(loggingInterceptor),
+		grpc.ChainUnaryInterceptor(metrics.UnaryServerInterceptor, loggingInterceptor, agentauth.UnaryServerInterceptor,
+			s.authenticator.UnaryServerInterceptor, s.limiter.UnaryServerInterceptor, s.authorizer.UnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(metrics.StreamServerInterceptor, agentauth.StreamServerInterceptor,
+			s.authenticator.StreamServerInterceptor, s.limiter.StreamServerInterceptor, s.authorizer.StreamServerInterceptor),
 	)
 
 	// Register services
@@ -58,6 +88,10 @@ func (s *Server) Start(port string) error {
 	RegisterSiteServiceServer(s.grpcServer, &siteServer{svc: s.siteSvc})
 	RegisterSiteAgentServiceServer(s.grpcServer, &siteAgentServer{svc: s.siteAgentSvc})
 	RegisterAuditServiceServer(s.grpcServer, &auditServer{svc: s.auditSvc})
+	RegisterAPIKeyServiceServer(s.grpcServer, &apiKeyServer{svc: s.apiKeySvc})
+	RegisterRBACServiceServer(s.grpcServer, &rbacServer{svc: s.rbacSvc})
+	RegisterChangeRequestServiceServer(s.grpcServer, &changeRequestServer{svc: s.changeSvc})
+	RegisterStateServiceServer(s.grpcServer, &stateServer{svc: s.stateSvc})
 
 	// Enable reflection for grpcurl/debugging
 	reflection.Register(s.grpcServer)
@@ -83,22 +117,46 @@ func loggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnarySe
 	return resp, err
 }
 
+// errorCode maps service errors to gRPC status codes
+func errorCode(err error, fallback codes.Code) codes.Code {
+	if errors.Is(err, service.ErrPermissionDenied) {
+		return codes.PermissionDenied
+	}
+	if errors.Is(err, service.ErrPolicyDenied) {
+		return codes.FailedPrecondition
+	}
+	if errors.Is(err, service.ErrInvalidArgument) {
+		return codes.InvalidArgument
+	}
+	return fallback
+}
+
 // ============ Proto-compatible types and interfaces ============
 
 // These types mirror the proto definitions and will be replaced by generated code
 
 // WorkloadEntry proto message
 type WorkloadEntry struct {
-	Id           string
-	SpiffeId     string
-	ParentId     string
-	Selectors    []*Selector
-	Ttl          int32
-	Description  string
-	CreatedBy    string
-	CreatedAt    *timestamppb.Timestamp
-	UpdatedAt    *timestamppb.Timestamp
-	SiteStatuses []*SiteSyncStatus
+	Id            string
+	SpiffeId      string
+	ParentId      string
+	Selectors     []*Selector
+	Ttl           int32
+	Description   string
+	CreatedBy     string
+	CreatedAt     *timestamppb.Timestamp
+	UpdatedAt     *timestamppb.Timestamp
+	SiteStatuses  []*SiteSyncStatus
+	PolicyResults []*PolicyResult
+	PendingChange *ChangeRequest
+	DeletedAt     *timestamppb.Timestamp
+}
+
+// PolicyResult proto message
+type PolicyResult struct {
+	Rule    string
+	Action  string
+	Message string
 }
 
 type Selector struct {
@@ -106,6 +164,21 @@ type Selector struct {
 	Value string
 }
 
+// SelectorMatch proto enum
+type SelectorMatch int32
+
+const (
+	SelectorMatch_MATCH_EXACT    SelectorMatch = 0
+	SelectorMatch_MATCH_SUBSET   SelectorMatch = 1
+	SelectorMatch_MATCH_SUPERSET SelectorMatch = 2
+	SelectorMatch_MATCH_ANY      SelectorMatch = 3
+)
+
+type SelectorFilter struct {
+	Selectors []*Selector
+	Match     SelectorMatch
+}
+
 type SiteSyncStatus struct {
 	SiteId       string
 	SiteName     string
@@ -121,6 +194,8 @@ type Site struct {
 	Region             string
 	SpireServerAddress string
 	TrustDomain        string
+	AgentSpiffeId      string
+	Protected          bool
 	Status             string
 	LastSyncAt         *timestamppb.Timestamp
 }
@@ -156,6 +231,7 @@ type CreateWorkloadEntryRequest struct {
 	SiteIds     []string
 	Ttl         int32
 	Description string
+	DryRun      bool
 }
 
 type GetWorkloadEntryRequest struct{ Id string }
@@ -164,6 +240,9 @@ type ListWorkloadEntriesRequest struct {
 	PageToken      string
 	SiteId         string
 	SpiffeIdPrefix string
+	SkipTotalCount bool
+	BySelectors    *SelectorFilter
+	Deleting       bool
 }
 type ListWorkloadEntriesResponse struct {
 	Entries       []*WorkloadEntry
@@ -172,14 +251,22 @@ type ListWorkloadEntriesResponse struct {
 }
 type DeleteWorkloadEntryRequest struct{ Id string }
 type DeleteWorkloadEntryResponse struct {
-	Success bool
-	Message string
+	Success       bool
+	Message       string
+	PendingChange *ChangeRequest
 }
+type PurgeWorkloadEntryRequest struct{ Id string }
+type PurgeWorkloadEntryResponse struct{ Success bool }
 type AssignToSitesRequest struct {
 	WorkloadEntryId string
 	SiteIds         []string
+	DryRun          bool
+}
+type AssignToSitesResponse struct {
+	Statuses      []*SiteSyncStatus
+	PolicyResults []*PolicyResult
+	PendingChange *ChangeRequest
 }
-type AssignToSitesResponse struct{ Statuses []*SiteSyncStatus }
 type GetSyncStatusRequest struct{ WorkloadEntryId string }
 type SyncStatusResponse struct{ Statuses []*SiteSyncStatus }
 
@@ -188,8 +275,9 @@ type ListSitesResponse struct{ Sites []*Site }
 type GetSiteRequest struct{ Id string }
 
 type PollEntriesRequest struct {
-	SiteId     string
-	MaxEntries int32
+	SiteId      string
+	MaxEntries  int32
+	WaitSeconds int32
 }
 type PollEntriesResponse struct{ Entries []*PendingEntry }
 type ReportSyncResultRequest struct {
@@ -212,6 +300,30 @@ type ReportDeletionResultRequest struct {
 	ErrorMessage    string
 }
 type ReportDeletionResultResponse struct{ Acknowledged bool }
+type Agent struct {
+	SiteId             string
+	AgentId            string
+	Version            string
+	StartedAt          *timestamppb.Timestamp
+	IsLeader           bool
+	Backlog            int32
+	SpireReachable     bool
+	SpireServerVersion string
+	LastHeartbeatAt    *timestamppb.Timestamp
+}
+type HeartbeatRequest struct {
+	SiteId             string
+	AgentId            string
+	Version            string
+	StartedAt          *timestamppb.Timestamp
+	IsLeader           bool
+	Backlog            int32
+	SpireReachable     bool
+	SpireServerVersion string
+}
+type HeartbeatResponse struct{ Acknowledged bool }
+type ListAgentsRequest struct{ SiteId string }
+type ListAgentsResponse struct{ Agents []*Agent }
 
 type ListAuditLogsRequest struct {
 	PageSize     int32
@@ -226,6 +338,160 @@ type ListAuditLogsResponse struct {
 	Entries       []*AuditLogEntry
 	NextPageToken string
 }
+type VerifyAuditLogRequest struct{ Stream string }
+type AuditChainBreak struct {
+	Stream  string
+	Seq     int64
+	EntryId int64
+	Reason  string
+}
+type VerifyAuditLogResponse struct {
+	Valid              bool
+	StreamsChecked     int32
+	EntriesChecked     int64
+	CheckpointsChecked int32
+	ArchivedEntries    int64
+	FirstBreak         *AuditChainBreak
+}
+type RestoreAuditArchiveRequest struct{ Archive string }
+type RestoreAuditArchiveResponse struct {
+	Archive  string
+	Entries  int64
+	Restored int64
+}
+
+type APIKey struct {
+	Id             string
+	Name           string
+	KeyPrefix      string
+	Scopes         []string
+	SpiffeIdPrefix string
+	ExpiresAt      *timestamppb.Timestamp
+	CreatedBy      string
+	CreatedAt      *timestamppb.Timestamp
+	RotatedAt      *timestamppb.Timestamp
+	LastUsedAt     *timestamppb.Timestamp
+	RevokedAt      *timestamppb.Timestamp
+}
+
+type CreateAPIKeyRequest struct {
+	Name           string
+	Scopes         []string
+	SpiffeIdPrefix string
+	ExpiresAt      *timestamppb.Timestamp
+}
+type CreateAPIKeyResponse struct {
+	Key    *APIKey
+	Secret string
+}
+type ListAPIKeysRequest struct{}
+type ListAPIKeysResponse struct{ Keys []*APIKey }
+type RotateAPIKeyRequest struct{ Id string }
+type RotateAPIKeyResponse struct {
+	Key    *APIKey
+	Secret string
+}
+type RevokeAPIKeyRequest struct{ Id string }
+type RevokeAPIKeyResponse struct{ Success bool }
+
+type Role struct {
+	Name        string
+	Permissions []string
+}
+type RoleBinding struct {
+	Id          string
+	Role        string
+	SubjectKind string
+	Subject     string
+	CreatedBy   string
+	CreatedAt   *timestamppb.Timestamp
+}
+
+type ListRolesRequest struct{}
+type ListRolesResponse struct{ Roles []*Role }
+type ListRoleBindingsRequest struct {
+	SubjectKind string
+	Subject     string
+}
+type ListRoleBindingsResponse struct{ Bindings []*RoleBinding }
+type CreateRoleBindingRequest struct {
+	Role        string
+	SubjectKind string
+	Subject     string
+}
+type DeleteRoleBindingRequest struct{ Id string }
+type DeleteRoleBindingResponse struct{ Success bool }
+type NamespaceGrant struct {
+	Id               string
+	SubjectKind      string
+	Subject          string
+	Namespaces       []string
+	SpiffeIdPrefixes []string
+	SiteIds          []string
+	CreatedBy        string
+	CreatedAt        *timestamppb.Timestamp
+}
+type ListNamespaceGrantsRequest struct {
+	SubjectKind string
+	Subject     string
+}
+type ListNamespaceGrantsResponse struct{ Grants []*NamespaceGrant }
+type CreateNamespaceGrantRequest struct {
+	SubjectKind      string
+	Subject          string
+	Namespaces       []string
+	SpiffeIdPrefixes []string
+	SiteIds          []string
+}
+type DeleteNamespaceGrantRequest struct{ Id string }
+type DeleteNamespaceGrantResponse struct{ Success bool }
+
+type ChangeRequest struct {
+	Id              string
+	Operation       string
+	WorkloadEntryId string
+	SiteIds         []string
+	Status          string
+	RequestedBy     string
+	ReviewedBy      string
+	ReviewComment   string
+	ApplyError      string
+	CreatedAt       *timestamppb.Timestamp
+	ReviewedAt      *timestamppb.Timestamp
+}
+type ListChangeRequestsRequest struct {
+	Status          string
+	WorkloadEntryId string
+}
+type ListChangeRequestsResponse struct{ Changes []*ChangeRequest }
+type GetChangeRequestRequest struct{ Id string }
+type ReviewChangeRequest struct {
+	Id      string
+	Comment string
+}
+
+type StateArchiveInfo struct {
+	Version         int32
+	CreatedAt       *timestamppb.Timestamp
+	KeyId           string
+	Sites           int32
+	Entries         int32
+	Assignments     int32
+	NamespaceGrants int32
+}
+type ExportStateRequest struct{}
+type ExportStateResponse struct {
+	Archive []byte
+	Info    *StateArchiveInfo
+}
+type ImportStateRequest struct {
+	Archive []byte
+	Mode    string
+}
+type ImportStateResponse struct {
+	Info   *StateArchiveInfo
+	Queued int32
+}
 
 // ============ Service interfaces (to be implemented by generated code registration) ============
 
@@ -234,6 +500,7 @@ type WorkloadEntryServiceServer interface {
 	GetWorkloadEntry(context.Context, *GetWorkloadEntryRequest) (*WorkloadEntry, error)
 	ListWorkloadEntries(context.Context, *ListWorkloadEntriesRequest) (*ListWorkloadEntriesResponse, error)
 	DeleteWorkloadEntry(context.Context, *DeleteWorkloadEntryRequest) (*DeleteWorkloadEntryResponse, error)
+	PurgeWorkloadEntry(context.Context, *PurgeWorkloadEntryRequest) (*PurgeWorkloadEntryResponse, error)
 	AssignToSites(context.Context, *AssignToSitesRequest) (*AssignToSitesResponse, error)
 	GetSyncStatus(context.Context, *GetSyncStatusRequest) (*SyncStatusResponse, error)
 }
@@ -248,10 +515,43 @@ type SiteAgentServiceServer interface {
 	ReportSyncResult(context.Context, *ReportSyncResultRequest) (*ReportSyncResultResponse, error)
 	PollDeletions(context.Context, *PollDeletionsRequest) (*PollDeletionsResponse, error)
 	ReportDeletionResult(context.Context, *ReportDeletionResultRequest) (*ReportDeletionResultResponse, error)
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+	ListAgents(context.Context, *ListAgentsRequest) (*ListAgentsResponse, error)
 }
 
 type AuditServiceServer interface {
 	ListAuditLogs(context.Context, *ListAuditLogsRequest) (*ListAuditLogsResponse, error)
+	VerifyAuditLog(context.Context, *VerifyAuditLogRequest) (*VerifyAuditLogResponse, error)
+	RestoreAuditArchive(context.Context, *RestoreAuditArchiveRequest) (*RestoreAuditArchiveResponse, error)
+}
+
+type APIKeyServiceServer interface {
+	CreateAPIKey(context.Context, *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error)
+	ListAPIKeys(context.Context, *ListAPIKeysRequest) (*ListAPIKeysResponse, error)
+	RotateAPIKey(context.Context, *RotateAPIKeyRequest) (*RotateAPIKeyResponse, error)
+	RevokeAPIKey(context.Context, *RevokeAPIKeyRequest) (*RevokeAPIKeyResponse, error)
+}
+
+type RBACServiceServer interface {
+	ListRoles(context.Context, *ListRolesRequest) (*ListRolesResponse, error)
+	ListRoleBindings(context.Context, *ListRoleBindingsRequest) (*ListRoleBindingsResponse, error)
+	CreateRoleBinding(context.Context, *CreateRoleBindingRequest) (*RoleBinding, error)
+	DeleteRoleBinding(context.Context, *DeleteRoleBindingRequest) (*DeleteRoleBindingResponse, error)
+	ListNamespaceGrants(context.Context, *ListNamespaceGrantsRequest) (*ListNamespaceGrantsResponse, error)
+	CreateNamespaceGrant(context.Context, *CreateNamespaceGrantRequest) (*NamespaceGrant, error)
+	DeleteNamespaceGrant(context.Context, *DeleteNamespaceGrantRequest) (*DeleteNamespaceGrantResponse, error)
+}
+
+type ChangeRequestServiceServer interface {
+	ListChangeRequests(context.Context, *ListChangeRequestsRequest) (*ListChangeRequestsResponse, error)
+	GetChangeRequest(context.Context, *GetChangeRequestRequest) (*ChangeRequest, error)
+	ApproveChange(context.Context, *ReviewChangeRequest) (*ChangeRequest, error)
+	RejectChange(context.Context, *ReviewChangeRequest) (*ChangeRequest, error)
+}
+
+type StateServiceServer interface {
+	ExportState(context.Context, *ExportStateRequest) (*ExportStateResponse, error)
+	ImportState(context.Context, *ImportStateRequest) (*ImportStateResponse, error)
 }
 
 // Registration functions (placeholder - will use generated code)
@@ -272,6 +572,22 @@ func RegisterAuditServiceServer(s *grpc.Server, srv AuditServiceServer) {
 	log.Println("AuditService registered")
 }
 
+func RegisterAPIKeyServiceServer(s *grpc.Server, srv APIKeyServiceServer) {
+	log.Println("APIKeyService registered")
+}
+
+func RegisterRBACServiceServer(s *grpc.Server, srv RBACServiceServer) {
+	log.Println("RBACService registered")
+}
+
+func RegisterChangeRequestServiceServer(s *grpc.Server, srv ChangeRequestServiceServer) {
+	log.Println("ChangeRequestService registered")
+}
+
+func RegisterStateServiceServer(s *grpc.Server, srv StateServiceServer) {
+	log.Println("StateService registered")
+}
+
 // ============ Server implementations ============
 
 type workloadEntryServer struct {
@@ -284,9 +600,9 @@ func (s *workloadEntryServer) CreateWorkloadEntry(ctx context.Context, req *Crea
 		selectors[i] = service.Selector{Type: sel.Type, Value: sel.Value}
 	}
 
-	result, err := s.svc.CreateWorkloadEntry(ctx, req.SpiffeId, req.ParentId, selectors, req.SiteIds, int(req.Ttl), req.Description)
+	result, err := s.svc.CreateWorkloadEntry(ctx, req.SpiffeId, req.ParentId, selectors, req.SiteIds, int(req.Ttl), req.Description, req.DryRun)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to create workload entry: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to create workload entry: %v", err)
 	}
 
 	return toProtoWorkloadEntry(result), nil
@@ -295,15 +611,35 @@ func (s *workloadEntryServer) CreateWorkloadEntry(ctx context.Context, req *Crea
 func (s *workloadEntryServer) GetWorkloadEntry(ctx context.Context, req *GetWorkloadEntryRequest) (*WorkloadEntry, error) {
 	result, err := s.svc.GetWorkloadEntry(ctx, req.Id)
 	if err != nil {
-		return nil, status.Errorf(codes.NotFound, "workload entry not found: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.NotFound), "workload entry not found: %v", err)
 	}
 	return toProtoWorkloadEntry(result), nil
 }
 
+var selectorMatches = map[SelectorMatch]string{
+	SelectorMatch_MATCH_EXACT:    service.SelectorMatchExact,
+	SelectorMatch_MATCH_SUBSET:   service.SelectorMatchSubset,
+	SelectorMatch_MATCH_SUPERSET: service.SelectorMatchSuperset,
+	SelectorMatch_MATCH_ANY:      service.SelectorMatchAny,
+}
+
 func (s *workloadEntryServer) ListWorkloadEntries(ctx context.Context, req *ListWorkloadEntriesRequest) (*ListWorkloadEntriesResponse, error) {
-	result, err := s.svc.ListWorkloadEntries(ctx, int(req.PageSize), req.PageToken, req.SiteId, req.SpiffeIdPrefix)
+	var bySelectors *service.SelectorFilter
+	if req.BySelectors != nil {
+		match, ok := selectorMatches[req.BySelectors.Match]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown selector match %d", req.BySelectors.Match)
+		}
+		bySelectors = &service.SelectorFilter{Match: match, Selectors: make([]service.Selector, len(req.BySelectors.Selectors))}
+		for i, sel := range req.BySelectors.Selectors {
+			bySelectors.Selectors[i] = service.Selector{Type: sel.Type, Value: sel.Value}
+		}
+	}
+
+	result, err := s.svc.ListWorkloadEntries(ctx, int(req.PageSize), req.PageToken, req.SiteId, req.SpiffeIdPrefix,
+		bySelectors, req.Deleting, !req.SkipTotalCount)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to list workload entries: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to list workload entries: %v", err)
 	}
 
 	entries := make([]*WorkloadEntry, len(result.Entries))
@@ -319,21 +655,35 @@ func (s *workloadEntryServer) ListWorkloadEntries(ctx context.Context, req *List
 }
 
 func (s *workloadEntryServer) DeleteWorkloadEntry(ctx context.Context, req *DeleteWorkloadEntryRequest) (*DeleteWorkloadEntryResponse, error) {
-	err := s.svc.DeleteWorkloadEntry(ctx, req.Id)
+	change, err := s.svc.DeleteWorkloadEntry(ctx, req.Id)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to delete workload entry: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to delete workload entry: %v", err)
+	}
+	if change != nil {
+		return &DeleteWorkloadEntryResponse{
+			Success:       true,
+			Message:       "Entry is assigned to protected sites; deletion is pending approval",
+			PendingChange: toProtoChangeRequest(change),
+		}, nil
 	}
 	return &DeleteWorkloadEntryResponse{Success: true, Message: "Entry deleted successfully"}, nil
 }
 
+func (s *workloadEntryServer) PurgeWorkloadEntry(ctx context.Context, req *PurgeWorkloadEntryRequest) (*PurgeWorkloadEntryResponse, error) {
+	if err := s.svc.PurgeWorkloadEntry(ctx, req.Id); err != nil {
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to purge workload entry: %v", err)
+	}
+	return &PurgeWorkloadEntryResponse{Success: true}, nil
+}
+
 func (s *workloadEntryServer) AssignToSites(ctx context.Context, req *AssignToSitesRequest) (*AssignToSitesResponse, error) {
-	result, err := s.svc.AssignToSites(ctx, req.WorkloadEntryId, req.SiteIds)
+	result, err := s.svc.AssignToSites(ctx, req.WorkloadEntryId, req.SiteIds, req.DryRun)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to assign to sites: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to assign to sites: %v", err)
 	}
 
-	statuses := make([]*SiteSyncStatus, len(result))
-	for i, st := range result {
+	statuses := make([]*SiteSyncStatus, len(result.Statuses))
+	for i, st := range result.Statuses {
 		statuses[i] = &SiteSyncStatus{
 			SiteId:       st.SiteID,
 			SiteName:     st.SiteName,
@@ -344,13 +694,17 @@ func (s *workloadEntryServer) AssignToSites(ctx context.Context, req *AssignToSi
 		}
 	}
 
-	return &AssignToSitesResponse{Statuses: statuses}, nil
+	return &AssignToSitesResponse{
+		Statuses:      statuses,
+		PolicyResults: toProtoPolicyResults(result.PolicyResults),
+		PendingChange: toProtoChangeRequest(result.PendingChange),
+	}, nil
 }
 
 func (s *workloadEntryServer) GetSyncStatus(ctx context.Context, req *GetSyncStatusRequest) (*SyncStatusResponse, error) {
 	result, err := s.svc.GetSyncStatus(ctx, req.WorkloadEntryId)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to get sync status: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to get sync status: %v", err)
 	}
 
 	statuses := make([]*SiteSyncStatus, len(result))
@@ -375,7 +729,7 @@ type siteServer struct {
 func (s *siteServer) ListSites(ctx context.Context, req *ListSitesRequest) (*ListSitesResponse, error) {
 	result, err := s.svc.ListSites(ctx, req.Status)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to list sites: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to list sites: %v", err)
 	}
 
 	sites := make([]*Site, len(result))
@@ -386,6 +740,8 @@ func (s *siteServer) ListSites(ctx context.Context, req *ListSitesRequest) (*Lis
 			Region:             site.Region,
 			SpireServerAddress: site.SpireServerAddress,
 			TrustDomain:        site.TrustDomain,
+			AgentSpiffeId:      site.AgentSpiffeID,
+			Protected:          site.Protected,
 			Status:             site.Status,
 			LastSyncAt:         site.LastSyncAt,
 		}
@@ -397,7 +753,7 @@ func (s *siteServer) ListSites(ctx context.Context, req *ListSitesRequest) (*Lis
 func (s *siteServer) GetSite(ctx context.Context, req *GetSiteRequest) (*Site, error) {
 	result, err := s.svc.GetSite(ctx, req.Id)
 	if err != nil {
-		return nil, status.Errorf(codes.NotFound, "site not found: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.NotFound), "site not found: %v", err)
 	}
 	return &Site{
 		Id:                 result.ID,
@@ -405,6 +761,8 @@ func (s *siteServer) GetSite(ctx context.Context, req *GetSiteRequest) (*Site, e
 		Region:             result.Region,
 		SpireServerAddress: result.SpireServerAddress,
 		TrustDomain:        result.TrustDomain,
+		AgentSpiffeId:      result.AgentSpiffeID,
+		Protected:          result.Protected,
 		Status:             result.Status,
 		LastSyncAt:         result.LastSyncAt,
 	}, nil
@@ -415,9 +773,10 @@ type siteAgentServer struct {
 }
 
 func (s *siteAgentServer) PollEntries(ctx context.Context, req *PollEntriesRequest) (*PollEntriesResponse, error) {
-	result, err := s.svc.PollEntries(ctx, req.SiteId, int(req.MaxEntries))
+	wait := time.Duration(req.WaitSeconds) * time.Second
+	result, err := s.svc.PollEntries(ctx, req.SiteId, int(req.MaxEntries), wait)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to poll entries: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to poll entries: %v", err)
 	}
 
 	entries := make([]*PendingEntry, len(result))
@@ -441,7 +800,7 @@ func (s *siteAgentServer) PollEntries(ctx context.Context, req *PollEntriesReque
 func (s *siteAgentServer) ReportSyncResult(ctx context.Context, req *ReportSyncResultRequest) (*ReportSyncResultResponse, error) {
 	err := s.svc.ReportSyncResult(ctx, req.SiteId, req.WorkloadEntryId, req.Success, req.SpireEntryId, req.ErrorMessage)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to report sync result: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to report sync result: %v", err)
 	}
 	return &ReportSyncResultResponse{Acknowledged: true}, nil
 }
@@ -449,7 +808,7 @@ func (s *siteAgentServer) ReportSyncResult(ctx context.Context, req *ReportSyncR
 func (s *siteAgentServer) PollDeletions(ctx context.Context, req *PollDeletionsRequest) (*PollDeletionsResponse, error) {
 	result, err := s.svc.PollDeletions(ctx, req.SiteId, int(req.MaxEntries))
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to poll deletions: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to poll deletions: %v", err)
 	}
 
 	entries := make([]*DeletionEntry, len(result))
@@ -466,35 +825,74 @@ func (s *siteAgentServer) PollDeletions(ctx context.Context, req *PollDeletionsR
 func (s *siteAgentServer) ReportDeletionResult(ctx context.Context, req *ReportDeletionResultRequest) (*ReportDeletionResultResponse, error) {
 	err := s.svc.ReportDeletionResult(ctx, req.SiteId, req.WorkloadEntryId, req.Success, req.ErrorMessage)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to report deletion result: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to report deletion result: %v", err)
 	}
 	return &ReportDeletionResultResponse{Acknowledged: true}, nil
 }
 
+func (s *siteAgentServer) Heartbeat(ctx context.Context, req *HeartbeatRequest) (*HeartbeatResponse, error) {
+	hb := service.AgentInfo{
+		SiteID:             req.SiteId,
+		AgentID:            req.AgentId,
+		Version:            req.Version,
+		IsLeader:           req.IsLeader,
+		Backlog:            int(req.Backlog),
+		SpireReachable:     req.SpireReachable,
+		SpireServerVersion: req.SpireServerVersion,
+	}
+	if req.StartedAt != nil {
+		hb.StartedAt = req.StartedAt.AsTime()
+	}
+
+	if err := s.svc.Heartbeat(ctx, hb); err != nil {
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to record heartbeat: %v", err)
+	}
+	return &HeartbeatResponse{Acknowledged: true}, nil
+}
+
+func (s *siteAgentServer) ListAgents(ctx context.Context, req *ListAgentsRequest) (*ListAgentsResponse, error) {
+	result, err := s.svc.ListAgents(ctx, req.SiteId)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to list agents: %v", err)
+	}
+
+	agents := make([]*Agent, len(result))
+	for i, a := range result {
+		agents[i] = &Agent{
+			SiteId:             a.SiteID,
+			AgentId:            a.AgentID,
+			Version:            a.Version,
+			StartedAt:          timestamppb.New(a.StartedAt),
+			IsLeader:           a.IsLeader,
+			Backlog:            int32(a.Backlog),
+			SpireReachable:     a.SpireReachable,
+			SpireServerVersion: a.SpireServerVersion,
+			LastHeartbeatAt:    timestamppb.New(a.LastHeartbeatAt),
+		}
+	}
+
+	return &ListAgentsResponse{Agents: agents}, nil
+}
+
 type auditServer struct {
 	svc *service.AuditService
 }
 
 func (s *auditServer) ListAuditLogs(ctx context.Context, req *ListAuditLogsRequest) (*ListAuditLogsResponse, error) {
-	var startTime, endTime *timestamppb.Timestamp
+	var startTime, endTime *time.Time
 	if req.StartTime != nil {
-		startTime = req.StartTime
+		t := req.StartTime.AsTime()
+		startTime = &t
 	}
 	if req.EndTime != nil {
-		endTime = req.EndTime
+		t := req.EndTime.AsTime()
+		endTime = &t
 	}
 
-	var st, et *repository.Time
-	_ = startTime
-	_ = endTime
-	// Convert timestamps if needed
-
-	result, err := s.svc.ListAuditLogs(ctx, int(req.PageSize), req.PageToken, req.ResourceType, req.ResourceId, req.Actor, nil, nil)
+	result, err := s.svc.ListAuditLogs(ctx, int(req.PageSize), req.PageToken, req.ResourceType, req.ResourceId, req.Actor, startTime, endTime)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to list audit logs: %v", err)
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to list audit logs: %v", err)
 	}
-	_ = st
-	_ = et
 
 	entries := make([]*AuditLogEntry, len(result.Entries))
 	for i, e := range result.Entries {
@@ -515,6 +913,286 @@ func (s *auditServer) ListAuditLogs(ctx context.Context, req *ListAuditLogsReque
 	}, nil
 }
 
+func (s *auditServer) VerifyAuditLog(ctx context.Context, req *VerifyAuditLogRequest) (*VerifyAuditLogResponse, error) {
+	result, err := s.svc.VerifyAuditLog(ctx, req.Stream)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to verify audit log: %v", err)
+	}
+
+	resp := &VerifyAuditLogResponse{
+		Valid:              result.Valid,
+		StreamsChecked:     int32(result.StreamsChecked),
+		EntriesChecked:     int64(result.EntriesChecked),
+		CheckpointsChecked: int32(result.CheckpointsChecked),
+		ArchivedEntries:    int64(result.ArchivedEntries),
+	}
+	if b := result.FirstBreak; b != nil {
+		resp.FirstBreak = &AuditChainBreak{Stream: b.Stream, Seq: b.Seq, EntryId: b.EntryID, Reason: b.Reason}
+	}
+	return resp, nil
+}
+
+func (s *auditServer) RestoreAuditArchive(ctx context.Context, req *RestoreAuditArchiveRequest) (*RestoreAuditArchiveResponse, error) {
+	result, err := s.svc.RestoreAuditArchive(ctx, req.Archive)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to restore audit archive: %v", err)
+	}
+
+	return &RestoreAuditArchiveResponse{
+		Archive:  result.Archive,
+		Entries:  int64(result.Entries),
+		Restored: int64(result.Restored),
+	}, nil
+}
+
+type apiKeyServer struct {
+	svc *service.APIKeyService
+}
+
+func (s *apiKeyServer) CreateAPIKey(ctx context.Context, req *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		t := req.ExpiresAt.AsTime()
+		expiresAt = &t
+	}
+
+	key, secret, err := s.svc.CreateAPIKey(ctx, req.Name, req.Scopes, req.SpiffeIdPrefix, expiresAt)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.InvalidArgument), "failed to create API key: %v", err)
+	}
+	return &CreateAPIKeyResponse{Key: toProtoAPIKey(key), Secret: secret}, nil
+}
+
+func (s *apiKeyServer) ListAPIKeys(ctx context.Context, req *ListAPIKeysRequest) (*ListAPIKeysResponse, error) {
+	result, err := s.svc.ListAPIKeys(ctx)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to list API keys: %v", err)
+	}
+
+	keys := make([]*APIKey, len(result))
+	for i := range result {
+		keys[i] = toProtoAPIKey(&result[i])
+	}
+	return &ListAPIKeysResponse{Keys: keys}, nil
+}
+
+func (s *apiKeyServer) RotateAPIKey(ctx context.Context, req *RotateAPIKeyRequest) (*RotateAPIKeyResponse, error) {
+	key, secret, err := s.svc.RotateAPIKey(ctx, req.Id)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.NotFound), "failed to rotate API key: %v", err)
+	}
+	return &RotateAPIKeyResponse{Key: toProtoAPIKey(key), Secret: secret}, nil
+}
+
+func (s *apiKeyServer) RevokeAPIKey(ctx context.Context, req *RevokeAPIKeyRequest) (*RevokeAPIKeyResponse, error) {
+	if err := s.svc.RevokeAPIKey(ctx, req.Id); err != nil {
+		return nil, status.Errorf(errorCode(err, codes.NotFound), "failed to revoke API key: %v", err)
+	}
+	return &RevokeAPIKeyResponse{Success: true}, nil
+}
+
+func toProtoAPIKey(k *service.APIKey) *APIKey {
+	return &APIKey{
+		Id:             k.ID,
+		Name:           k.Name,
+		KeyPrefix:      k.KeyPrefix,
+		Scopes:         k.Scopes,
+		SpiffeIdPrefix: k.SpiffeIDPrefix,
+		ExpiresAt:      k.ExpiresAt,
+		CreatedBy:      k.CreatedBy,
+		CreatedAt:      k.CreatedAt,
+		RotatedAt:      k.RotatedAt,
+		LastUsedAt:     k.LastUsedAt,
+		RevokedAt:      k.RevokedAt,
+	}
+}
+
+type rbacServer struct {
+	svc *service.RBACService
+}
+
+func (s *rbacServer) ListRoles(ctx context.Context, req *ListRolesRequest) (*ListRolesResponse, error) {
+	result := s.svc.ListRoles(ctx)
+
+	roles := make([]*Role, len(result))
+	for i, r := range result {
+		roles[i] = &Role{Name: r.Name, Permissions: r.Permissions}
+	}
+	return &ListRolesResponse{Roles: roles}, nil
+}
+
+func (s *rbacServer) ListRoleBindings(ctx context.Context, req *ListRoleBindingsRequest) (*ListRoleBindingsResponse, error) {
+	result, err := s.svc.ListRoleBindings(ctx, req.SubjectKind, req.Subject)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to list role bindings: %v", err)
+	}
+
+	bindings := make([]*RoleBinding, len(result))
+	for i := range result {
+		bindings[i] = toProtoRoleBinding(&result[i])
+	}
+	return &ListRoleBindingsResponse{Bindings: bindings}, nil
+}
+
+func (s *rbacServer) CreateRoleBinding(ctx context.Context, req *CreateRoleBindingRequest) (*RoleBinding, error) {
+	result, err := s.svc.CreateRoleBinding(ctx, req.Role, req.SubjectKind, req.Subject)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.InvalidArgument), "failed to create role binding: %v", err)
+	}
+	return toProtoRoleBinding(result), nil
+}
+
+func (s *rbacServer) DeleteRoleBinding(ctx context.Context, req *DeleteRoleBindingRequest) (*DeleteRoleBindingResponse, error) {
+	if err := s.svc.DeleteRoleBinding(ctx, req.Id); err != nil {
+		return nil, status.Errorf(errorCode(err, codes.NotFound), "failed to delete role binding: %v", err)
+	}
+	return &DeleteRoleBindingResponse{Success: true}, nil
+}
+
+func (s *rbacServer) ListNamespaceGrants(ctx context.Context, req *ListNamespaceGrantsRequest) (*ListNamespaceGrantsResponse, error) {
+	result, err := s.svc.ListNamespaceGrants(ctx, req.SubjectKind, req.Subject)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to list namespace grants: %v", err)
+	}
+
+	grants := make([]*NamespaceGrant, len(result))
+	for i := range result {
+		grants[i] = toProtoNamespaceGrant(&result[i])
+	}
+	return &ListNamespaceGrantsResponse{Grants: grants}, nil
+}
+
+func (s *rbacServer) CreateNamespaceGrant(ctx context.Context, req *CreateNamespaceGrantRequest) (*NamespaceGrant, error) {
+	result, err := s.svc.CreateNamespaceGrant(ctx, req.SubjectKind, req.Subject, req.Namespaces, req.SpiffeIdPrefixes, req.SiteIds)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.InvalidArgument), "failed to create namespace grant: %v", err)
+	}
+	return toProtoNamespaceGrant(result), nil
+}
+
+func (s *rbacServer) DeleteNamespaceGrant(ctx context.Context, req *DeleteNamespaceGrantRequest) (*DeleteNamespaceGrantResponse, error) {
+	if err := s.svc.DeleteNamespaceGrant(ctx, req.Id); err != nil {
+		return nil, status.Errorf(errorCode(err, codes.NotFound), "failed to delete namespace grant: %v", err)
+	}
+	return &DeleteNamespaceGrantResponse{Success: true}, nil
+}
+
+type changeRequestServer struct {
+	svc *service.ChangeRequestService
+}
+
+func (s *changeRequestServer) ListChangeRequests(ctx context.Context, req *ListChangeRequestsRequest) (*ListChangeRequestsResponse, error) {
+	result, err := s.svc.ListChangeRequests(ctx, req.Status, req.WorkloadEntryId)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to list change requests: %v", err)
+	}
+
+	changes := make([]*ChangeRequest, len(result))
+	for i := range result {
+		changes[i] = toProtoChangeRequest(&result[i])
+	}
+	return &ListChangeRequestsResponse{Changes: changes}, nil
+}
+
+func (s *changeRequestServer) GetChangeRequest(ctx context.Context, req *GetChangeRequestRequest) (*ChangeRequest, error) {
+	result, err := s.svc.GetChangeRequest(ctx, req.Id)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.NotFound), "change request not found: %v", err)
+	}
+	return toProtoChangeRequest(result), nil
+}
+
+func (s *changeRequestServer) ApproveChange(ctx context.Context, req *ReviewChangeRequest) (*ChangeRequest, error) {
+	result, err := s.svc.ApproveChange(ctx, req.Id, req.Comment)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.FailedPrecondition), "failed to approve change: %v", err)
+	}
+	return toProtoChangeRequest(result), nil
+}
+
+func (s *changeRequestServer) RejectChange(ctx context.Context, req *ReviewChangeRequest) (*ChangeRequest, error) {
+	result, err := s.svc.RejectChange(ctx, req.Id, req.Comment)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.FailedPrecondition), "failed to reject change: %v", err)
+	}
+	return toProtoChangeRequest(result), nil
+}
+
+type stateServer struct {
+	svc *service.StateService
+}
+
+func (s *stateServer) ExportState(ctx context.Context, req *ExportStateRequest) (*ExportStateResponse, error) {
+	result, err := s.svc.ExportState(ctx)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to export state: %v", err)
+	}
+	return &ExportStateResponse{Archive: result.Archive, Info: toProtoStateArchiveInfo(&result.Info)}, nil
+}
+
+func (s *stateServer) ImportState(ctx context.Context, req *ImportStateRequest) (*ImportStateResponse, error) {
+	result, err := s.svc.ImportState(ctx, req.Archive, req.Mode)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err, codes.Internal), "failed to import state: %v", err)
+	}
+	return &ImportStateResponse{Info: toProtoStateArchiveInfo(&result.Info), Queued: int32(result.Queued)}, nil
+}
+
+func toProtoStateArchiveInfo(info *service.StateArchiveInfo) *StateArchiveInfo {
+	return &StateArchiveInfo{
+		Version:         int32(info.Version),
+		CreatedAt:       timestamppb.New(info.CreatedAt),
+		KeyId:           info.KeyID,
+		Sites:           int32(info.Sites),
+		Entries:         int32(info.Entries),
+		Assignments:     int32(info.Assignments),
+		NamespaceGrants: int32(info.NamespaceGrants),
+	}
+}
+
+func toProtoChangeRequest(c *service.ChangeRequest) *ChangeRequest {
+	if c == nil {
+		return nil
+	}
+	return &ChangeRequest{
+		Id:              c.ID,
+		Operation:       c.Operation,
+		WorkloadEntryId: c.WorkloadEntryID,
+		SiteIds:         c.SiteIDs,
+		Status:          c.Status,
+		RequestedBy:     c.RequestedBy,
+		ReviewedBy:      c.ReviewedBy,
+		ReviewComment:   c.ReviewComment,
+		ApplyError:      c.ApplyError,
+		CreatedAt:       c.CreatedAt,
+		ReviewedAt:      c.ReviewedAt,
+	}
+}
+
+func toProtoNamespaceGrant(g *service.NamespaceGrant) *NamespaceGrant {
+	return &NamespaceGrant{
+		Id:               g.ID,
+		SubjectKind:      g.SubjectKind,
+		Subject:          g.Subject,
+		Namespaces:       g.Namespaces,
+		SpiffeIdPrefixes: g.SpiffeIDPrefixes,
+		SiteIds:          g.SiteIDs,
+		CreatedBy:        g.CreatedBy,
+		CreatedAt:        g.CreatedAt,
+	}
+}
+
+func toProtoRoleBinding(b *service.RoleBinding) *RoleBinding {
+	return &RoleBinding{
+		Id:          b.ID,
+		Role:        b.Role,
+		SubjectKind: b.SubjectKind,
+		Subject:     b.Subject,
+		CreatedBy:   b.CreatedBy,
+		CreatedAt:   b.CreatedAt,
+	}
+}
+
 // Helper to convert service response to proto
 func toProtoWorkloadEntry(e *service.WorkloadEntryResponse) *WorkloadEntry {
 	selectors := make([]*Selector, len(e.Selectors))
@@ -535,15 +1213,26 @@ func toProtoWorkloadEntry(e *service.WorkloadEntryResponse) *WorkloadEntry {
 	}
 
 	return &WorkloadEntry{
-		Id:           e.ID,
-		SpiffeId:     e.SpiffeID,
-		ParentId:     e.ParentID,
-		Selectors:    selectors,
-		Ttl:          int32(e.TTL),
-		Description:  e.Description,
-		CreatedBy:    e.CreatedBy,
-		CreatedAt:    e.CreatedAt,
-		UpdatedAt:    e.UpdatedAt,
-		SiteStatuses: siteStatuses,
+		Id:            e.ID,
+		SpiffeId:      e.SpiffeID,
+		ParentId:      e.ParentID,
+		Selectors:     selectors,
+		Ttl:           int32(e.TTL),
+		Description:   e.Description,
+		CreatedBy:     e.CreatedBy,
+		CreatedAt:     e.CreatedAt,
+		UpdatedAt:     e.UpdatedAt,
+		SiteStatuses:  siteStatuses,
+		PolicyResults: toProtoPolicyResults(e.PolicyResults),
+		PendingChange: toProtoChangeRequest(e.PendingChange),
+		DeletedAt:     e.DeletedAt,
+	}
+}
+
+func toProtoPolicyResults(results []service.PolicyResult) []*PolicyResult {
+	out := make([]*PolicyResult, len(results))
+	for i, r := range results {
+		out[i] = &PolicyResult{Rule: r.Rule, Action: r.Action, Message: r.Message}
 	}
+	return out
 }
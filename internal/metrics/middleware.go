@@ -0,0 +1,82 @@
+// Package metrics records gRPC and REST request metrics in the default
+// Prometheus registry, which the /metrics endpoints serve.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	grpcRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_requests_total",
+		Help: "gRPC requests handled, by method and status code.",
+	}, []string{"method", "code"})
+	grpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "grpc_request_duration",
+		Help: "gRPC request latency in seconds, by method.",
+	}, []string{"method"})
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "REST requests handled, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "http_request_duration",
+		Help: "REST request latency in seconds, by method and route.",
+	}, []string{"method", "route"})
+)
+
+// UnaryServerInterceptor counts and times unary gRPC calls
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observeGRPC(info.FullMethod, err, start)
+	return resp, err
+}
+
+// StreamServerInterceptor counts and times streaming gRPC calls, from open to
+// close
+func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	observeGRPC(info.FullMethod, err, start)
+	return err
+}
+
+func observeGRPC(method string, err error, start time.Time) {
+	grpcRequestsTotal.WithLabelValues(method, status.Code(err).String()).Inc()
+	grpcRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// HTTPMiddleware counts and times REST requests. route names the route a
+// request matched, such as its ServeMux pattern, so that paths with IDs in
+// them share a series.
+func HTTPMiddleware(next http.Handler, route func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		name := route(r)
+		httpRequestsTotal.WithLabelValues(r.Method, name, strconv.Itoa(sw.status)).Inc()
+		httpRequestDuration.WithLabelValues(r.Method, name).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusWriter records the status code of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,422 @@
+// Package migrate applies the versioned database schema migrations embedded
+// in the binary. Each database driver has its own migrations in
+// migrations/<driver>/, as numbered pairs of files named
+// <version>_<name>.up.sql and <version>_<name>.down.sql, and the applied
+// versions are recorded in the schema_migrations table. Both drivers should
+// have the same versions, so a schema version means the same on either.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// lockName is the named lock held while migrating, so that replicas starting
+// together do not apply the same migration twice
+const lockName = "spire_mgmt_schema_migrations"
+
+// engine holds what differs between the database drivers
+type engine struct {
+	createTable string
+	insert      string
+	delete      string
+	// tableExists counts the tables of the current schema with a name
+	tableExists string
+	// transactionalDDL is set if a migration and its record can be applied
+	// in one transaction, so a failed script leaves nothing behind
+	transactionalDDL bool
+	lock             func(ctx context.Context, conn *sql.Conn, timeout time.Duration) error
+	unlock           func(ctx context.Context, conn *sql.Conn) error
+}
+
+var engines = map[string]engine{
+	"mysql": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		                  version BIGINT PRIMARY KEY,
+		                  name VARCHAR(255) NOT NULL,
+		                  applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		              ) ENGINE=InnoDB`,
+		insert:      `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`,
+		delete:      `DELETE FROM schema_migrations WHERE version = ?`,
+		tableExists: `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`,
+		lock:        mysqlLock,
+		unlock: func(ctx context.Context, conn *sql.Conn) error {
+			_, err := conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, lockName)
+			return err
+		},
+	},
+	"postgres": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		                  version BIGINT PRIMARY KEY,
+		                  name VARCHAR(255) NOT NULL,
+		                  applied_at TIMESTAMPTZ(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+		              )`,
+		insert:           `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+		delete:           `DELETE FROM schema_migrations WHERE version = $1`,
+		tableExists:      `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1`,
+		transactionalDDL: true,
+		lock:             postgresLock,
+		unlock: func(ctx context.Context, conn *sql.Conn) error {
+			_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock(hashtext($1))`, lockName)
+			return err
+		},
+	},
+}
+
+// Migration is one schema version
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db          *sql.DB
+	engine      engine
+	migrations  []Migration
+	lockTimeout time.Duration
+}
+
+// New creates a Migrator for the embedded migrations of driver, "mysql" or
+// "postgres", the driver db was opened with
+func New(db *sql.DB, driver string) (*Migrator, error) {
+	eng, ok := engines[driver]
+	if !ok {
+		return nil, fmt.Errorf("no migrations for database driver %q", driver)
+	}
+	migrations, err := load(migrationFiles, "migrations/"+driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, engine: eng, migrations: migrations, lockTimeout: 5 * time.Minute}, nil
+}
+
+// Latest returns the newest migration version
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies all pending migrations in order and returns how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			if err := m.checkUnversioned(ctx, conn); err != nil {
+				return err
+			}
+		}
+		for _, mig := range m.migrations {
+			if _, ok := versions[mig.Version]; ok {
+				continue
+			}
+			log.Printf("Applying migration %04d_%s", mig.Version, mig.Name)
+			if err := m.apply(ctx, conn, mig.Up, m.engine.insert, mig.Version, mig.Name); err != nil {
+				return fmt.Errorf("migration %04d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the most recently applied migrations, up to steps of them, and
+// returns how many were reverted
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := versions[mig.Version]; !ok {
+				continue
+			}
+			log.Printf("Reverting migration %04d_%s", mig.Version, mig.Name)
+			if err := m.apply(ctx, conn, mig.Down, m.engine.delete, mig.Version); err != nil {
+				return fmt.Errorf("revert of migration %04d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	versions, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		result[i] = Status{Migration: mig}
+		if at, ok := versions[mig.Version]; ok {
+			at := at
+			result[i].AppliedAt = &at
+		}
+	}
+	return result, nil
+}
+
+// withLock runs fn on a single connection holding the migration lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.engine.lock(ctx, conn, m.lockTimeout); err != nil {
+		return err
+	}
+	defer func() {
+		if err := m.engine.unlock(context.Background(), conn); err != nil {
+			log.Printf("Failed to release migration lock: %v", err)
+		}
+	}()
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func mysqlLock(ctx context.Context, conn *sql.Conn, timeout time.Duration) error {
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, lockName, int(timeout.Seconds())).Scan(&locked); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("timed out waiting for migration lock after %s", timeout)
+	}
+	return nil
+}
+
+// postgresLock polls for a session advisory lock, as pg_advisory_lock would
+// wait without a bound
+func postgresLock(ctx context.Context, conn *sql.Conn, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		var locked bool
+		if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, lockName).Scan(&locked); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if locked {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for migration lock after %s", timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// checkUnversioned refuses a database with no recorded migrations that
+// already has the sites table, as one set up by the old MySQL init script
+// does. Its tables differ from the first migration's, so its rows are moved
+// once into a new database instead.
+func (m *Migrator) checkUnversioned(ctx context.Context, conn *sql.Conn) error {
+	var n int
+	if err := conn.QueryRowContext(ctx, m.engine.tableExists, "sites").Scan(&n); err != nil {
+		return fmt.Errorf("failed to look for existing tables: %w", err)
+	}
+	if n > 0 {
+		return fmt.Errorf("database has tables but no recorded migrations; " +
+			"a database set up by the old init script is not migrated in place, see docs/SETUP.md")
+	}
+	return nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, m.engine.createTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+// apply runs each statement of a migration script in turn, then the record
+// statement with args. MySQL commits DDL implicitly, so there a failed script
+// can leave earlier statements applied; PostgreSQL runs it all in one
+// transaction.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, script, record string, args ...interface{}) error {
+	if !m.engine.transactionalDDL {
+		if err := execScript(ctx, conn, script); err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, record, args...); err != nil {
+			return fmt.Errorf("failed to record migration: %w", err)
+		}
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := execScript(ctx, tx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+	return tx.Commit()
+}
+
+// execer is a connection or transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// execScript runs each statement of a migration script in turn
+func execScript(ctx context.Context, conn execer, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\nstatement: %s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// splitStatements splits a script into statements at semicolons that end a
+// line, dropping comment lines
+func splitStatements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if s := strings.TrimSpace(current.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+	return stmts
+}
+
+// load reads the migration pairs in dir of fsys
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.Glob(fsys, dir+"/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		base := path.Base(file)
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s must end in .up.sql or .down.sql", base)
+		}
+		stem := strings.TrimSuffix(base, "."+direction+".sql")
+		versionStr, name, ok := strings.Cut(stem, "_")
+		version, err := strconv.Atoi(versionStr)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s must be named <version>_<name>", base)
+		}
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: name}
+			byVersion[version] = mig
+		} else if mig.Name != name {
+			return nil, fmt.Errorf("migration version %d has two names, %s and %s", version, mig.Name, name)
+		}
+		if direction == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both up and down files", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
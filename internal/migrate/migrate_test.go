@@ -0,0 +1,483 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// fakeDB is a database that understands only the statements the migrator
+// runs itself. Migration statements are recorded, with the tables they
+// create and drop, and fail if they contain failOn.
+type fakeDB struct {
+	mu       sync.Mutex
+	versions map[int]time.Time
+	tables   map[string]bool
+	failOn   string
+	locker   *fakeConn
+}
+
+// fakeState is a copy of a fakeDB's contents, taken when a transaction begins
+type fakeState struct {
+	versions map[int]time.Time
+	tables   map[string]bool
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{versions: make(map[int]time.Time), tables: make(map[string]bool)}
+}
+
+func (db *fakeDB) open() *sql.DB { return sql.OpenDB(fakeConnector{db}) }
+
+func (db *fakeDB) snapshot() fakeState {
+	s := fakeState{versions: make(map[int]time.Time), tables: make(map[string]bool)}
+	for v, at := range db.versions {
+		s.versions[v] = at
+	}
+	for t := range db.tables {
+		s.tables[t] = true
+	}
+	return s
+}
+
+func (db *fakeDB) appliedVersions() []int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	versions := make([]int, 0, len(db.versions))
+	for v := range db.versions {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+func (db *fakeDB) hasTable(name string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.tables[name]
+}
+
+type fakeConnector struct{ db *fakeDB }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: c.db}, nil }
+func (c fakeConnector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("open through the connector")
+}
+
+type fakeConn struct {
+	db       *fakeDB
+	rollback *fakeState
+}
+
+var (
+	createTableRe = regexp.MustCompile(`^CREATE TABLE (\w+)`)
+	dropTableRe   = regexp.MustCompile(`^DROP TABLE (?:IF EXISTS )?(\w+)`)
+)
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	s := c.db.snapshot()
+	c.rollback = &s
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.rollback = nil
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	if c.rollback != nil {
+		c.db.versions, c.db.tables = c.rollback.versions, c.rollback.tables
+		c.rollback = nil
+	}
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	q := strings.Join(strings.Fields(query), " ")
+	switch {
+	case strings.HasPrefix(q, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+	case strings.HasPrefix(q, "INSERT INTO schema_migrations"):
+		db.versions[int(args[0].Value.(int64))] = time.Now().UTC().Truncate(time.Second)
+	case strings.HasPrefix(q, "DELETE FROM schema_migrations"):
+		delete(db.versions, int(args[0].Value.(int64)))
+	case strings.HasPrefix(q, "SELECT RELEASE_LOCK("), strings.HasPrefix(q, "SELECT pg_advisory_unlock("):
+		if db.locker == c {
+			db.locker = nil
+		}
+	default:
+		if db.failOn != "" && strings.Contains(q, db.failOn) {
+			return nil, errors.New("injected failure")
+		}
+		if m := createTableRe.FindStringSubmatch(q); m != nil {
+			if db.tables[m[1]] {
+				return nil, fmt.Errorf("table %s already exists", m[1])
+			}
+			db.tables[m[1]] = true
+		}
+		if m := dropTableRe.FindStringSubmatch(q); m != nil {
+			delete(db.tables, m[1])
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	q := strings.Join(strings.Fields(query), " ")
+	switch {
+	case strings.HasPrefix(q, "SELECT GET_LOCK("), strings.HasPrefix(q, "SELECT pg_try_advisory_lock("):
+		locked := db.locker == nil || db.locker == c
+		if locked {
+			db.locker = c
+		}
+		if strings.Contains(q, "GET_LOCK") {
+			n := int64(0)
+			if locked {
+				n = 1
+			}
+			return &fakeRows{values: [][]driver.Value{{n}}}, nil
+		}
+		return &fakeRows{values: [][]driver.Value{{locked}}}, nil
+	case strings.HasPrefix(q, "SELECT version, applied_at FROM schema_migrations"):
+		rows := &fakeRows{}
+		for v, at := range db.versions {
+			rows.values = append(rows.values, []driver.Value{int64(v), at})
+		}
+		return rows, nil
+	case strings.Contains(q, "information_schema.tables"):
+		n := int64(0)
+		if db.tables[args[0].Value.(string)] {
+			n = 1
+		}
+		return &fakeRows{values: [][]driver.Value{{n}}}, nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", q)
+}
+
+type fakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.values) == 0 {
+		return []string{"version", "applied_at"}
+	}
+	cols := make([]string, len(r.values[0]))
+	for i := range cols {
+		cols[i] = fmt.Sprintf("c%d", i)
+	}
+	return cols
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var drivers = []string{"mysql", "postgres"}
+
+func newMigrator(t *testing.T, db *fakeDB, driver string) *Migrator {
+	t.Helper()
+	m, err := New(db.open(), driver)
+	if err != nil {
+		t.Fatalf("New(%s): %v", driver, err)
+	}
+	return m
+}
+
+func versionsUpTo(n int) []int {
+	versions := make([]int, n)
+	for i := range versions {
+		versions[i] = i + 1
+	}
+	return versions
+}
+
+func equalInts(a, b []int) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func TestUpDownStatus(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			db := newFakeDB()
+			m := newMigrator(t, db, driver)
+			latest := m.Latest()
+
+			statuses, err := m.Status(ctx)
+			if err != nil || len(statuses) != latest {
+				t.Fatalf("Status of an empty database: %d, %v", len(statuses), err)
+			}
+			for _, s := range statuses {
+				if s.AppliedAt != nil {
+					t.Errorf("migration %d applied before Up", s.Version)
+				}
+			}
+
+			if n, err := m.Up(ctx); err != nil || n != latest {
+				t.Fatalf("Up: %d, %v", n, err)
+			}
+			if got := db.appliedVersions(); !equalInts(got, versionsUpTo(latest)) {
+				t.Errorf("versions recorded after Up: %v", got)
+			}
+			if !db.hasTable("sites") || !db.hasTable("audit_archived_runs") {
+				t.Error("Up did not create the tables")
+			}
+			if n, err := m.Up(ctx); err != nil || n != 0 {
+				t.Errorf("second Up: %d, %v", n, err)
+			}
+
+			if n, err := m.Down(ctx, 2); err != nil || n != 2 {
+				t.Fatalf("Down: %d, %v", n, err)
+			}
+			if got := db.appliedVersions(); !equalInts(got, versionsUpTo(latest-2)) {
+				t.Errorf("versions recorded after Down: %v", got)
+			}
+			if db.hasTable("audit_archived_runs") || !db.hasTable("outbox_events") {
+				t.Error("Down did not drop only the tables of the reverted migrations")
+			}
+			statuses, err = m.Status(ctx)
+			if err != nil {
+				t.Fatalf("Status: %v", err)
+			}
+			for _, s := range statuses {
+				if applied := s.AppliedAt != nil; applied != (s.Version <= latest-2) {
+					t.Errorf("Status of migration %d: applied %v", s.Version, applied)
+				}
+			}
+
+			if n, err := m.Up(ctx); err != nil || n != 2 {
+				t.Errorf("Up after Down: %d, %v", n, err)
+			}
+			if n, err := m.Down(ctx, latest+1); err != nil || n != latest {
+				t.Errorf("Down past the first migration: %d, %v", n, err)
+			}
+			if got := db.appliedVersions(); len(got) != 0 {
+				t.Errorf("versions recorded after reverting all: %v", got)
+			}
+			if db.locker != nil {
+				t.Error("migration lock still held")
+			}
+		})
+	}
+}
+
+func TestFailedMigration(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newFakeDB()
+			db.failOn = "INSERT INTO workload_entry_selectors"
+			m := newMigrator(t, db, driver)
+
+			n, err := m.Up(context.Background())
+			if err == nil || !strings.Contains(err.Error(), "0003_workload_entry_selectors") {
+				t.Fatalf("Up with a failing migration returned %v", err)
+			}
+			if n != 2 {
+				t.Errorf("Up applied %d migrations before failing, want 2", n)
+			}
+			if got := db.appliedVersions(); !equalInts(got, []int{1, 2}) {
+				t.Errorf("versions recorded: %v", got)
+			}
+			// Only PostgreSQL rolls back the statements before the failing one
+			if created := db.hasTable("workload_entry_selectors"); created != (driver == "mysql") {
+				t.Errorf("workload_entry_selectors left created: %v", created)
+			}
+			if db.locker != nil {
+				t.Error("migration lock still held after a failure")
+			}
+		})
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newFakeDB()
+			other := &fakeConn{db: db}
+			db.locker = other
+			m := newMigrator(t, db, driver)
+			m.lockTimeout = 0
+
+			if _, err := m.Up(context.Background()); err == nil || !strings.Contains(err.Error(), "timed out") {
+				t.Fatalf("Up while another migrator holds the lock returned %v", err)
+			}
+			if _, err := m.Down(context.Background(), 1); err == nil {
+				t.Error("Down while another migrator holds the lock succeeded")
+			}
+			if got := db.appliedVersions(); len(got) != 0 || db.hasTable("sites") {
+				t.Errorf("migrated without the lock: versions %v", got)
+			}
+			if db.locker != other {
+				t.Error("lock taken from its holder")
+			}
+
+			db.locker = nil
+			if _, err := m.Up(context.Background()); err != nil {
+				t.Errorf("Up once the lock is free: %v", err)
+			}
+		})
+	}
+}
+
+func TestUnversionedDatabaseRefused(t *testing.T) {
+	for _, driver := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			db := newFakeDB()
+			db.tables["sites"] = true
+			m := newMigrator(t, db, driver)
+
+			if _, err := m.Up(context.Background()); err == nil || !strings.Contains(err.Error(), "no recorded migrations") {
+				t.Fatalf("Up of a database set up without migrations returned %v", err)
+			}
+			if got := db.appliedVersions(); len(got) != 0 || db.hasTable("workload_entries") {
+				t.Errorf("migrated a database set up without migrations: versions %v", got)
+			}
+		})
+	}
+}
+
+func TestDriversHaveSameVersions(t *testing.T) {
+	var want []Migration
+	for _, driver := range drivers {
+		migrations, err := load(migrationFiles, "migrations/"+driver)
+		if err != nil {
+			t.Fatalf("load %s: %v", driver, err)
+		}
+		if want == nil {
+			want = migrations
+			continue
+		}
+		if len(migrations) != len(want) {
+			t.Fatalf("%s has %d migrations, %s has %d", driver, len(migrations), drivers[0], len(want))
+		}
+		for i, mig := range migrations {
+			if mig.Version != want[i].Version || mig.Name != want[i].Name {
+				t.Errorf("%s migration %04d_%s, %s has %04d_%s", driver, mig.Version, mig.Name,
+					drivers[0], want[i].Version, want[i].Name)
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []int
+		wantErr string
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"m/0010_later.up.sql":   file("up 10"),
+				"m/0010_later.down.sql": file("down 10"),
+				"m/0002_first.up.sql":   file("up 2"),
+				"m/0002_first.down.sql": file("down 2"),
+			},
+			want: []int{2, 10},
+		},
+		{
+			name:    "missing down",
+			files:   fstest.MapFS{"m/0001_init.up.sql": file("up")},
+			wantErr: "needs both up and down",
+		},
+		{
+			name:    "no version",
+			files:   fstest.MapFS{"m/init.up.sql": file("up"), "m/init.down.sql": file("down")},
+			wantErr: "must be named",
+		},
+		{
+			name:    "not up or down",
+			files:   fstest.MapFS{"m/0001_init.sql": file("up")},
+			wantErr: "must end in",
+		},
+		{
+			name: "two names",
+			files: fstest.MapFS{
+				"m/0001_init.up.sql":  file("up"),
+				"m/0001_other.up.sql": file("up"),
+			},
+			wantErr: "two names",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := load(tt.files, "m")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("load returned %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			var got []int
+			for _, mig := range migrations {
+				got = append(got, mig.Version)
+			}
+			if !equalInts(got, tt.want) {
+				t.Errorf("versions %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- a comment
+CREATE TABLE a (
+    id INT -- trailing
+);
+
+INSERT INTO a VALUES (1);
+UPDATE a SET id = 2`
+	got := splitStatements(script)
+	want := []string{"CREATE TABLE a (\n    id INT -- trailing\n)", "INSERT INTO a VALUES (1)", "UPDATE a SET id = 2"}
+	if len(got) != len(want) {
+		t.Fatalf("splitStatements returned %q", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d: %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,164 @@
+-- SPIFFE/SPIRE Workload Entry Management System Database Schema
+--
+-- Databases set up by the old MySQL init script, deploy/kubernetes/schema.sql,
+-- are not upgraded in place: migrate refuses them, and their rows are moved
+-- once into a new database as docs/SETUP.md describes.
+
+-- Sites table
+CREATE TABLE sites (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    region VARCHAR(50) NOT NULL,
+    spire_server_address VARCHAR(255) NOT NULL,
+    trust_domain VARCHAR(255) NOT NULL DEFAULT '',
+    agent_spiffe_id VARCHAR(512) NULL,
+    protected BOOLEAN NOT NULL DEFAULT FALSE,
+    last_sync_at TIMESTAMP NULL,
+    status ENUM('active', 'inactive', 'disconnected') DEFAULT 'active',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
+
+-- Workload entries table
+CREATE TABLE workload_entries (
+    id VARCHAR(36) PRIMARY KEY,
+    spiffe_id VARCHAR(512) NOT NULL UNIQUE,
+    parent_id VARCHAR(512) NOT NULL,
+    selectors JSON NOT NULL,
+    ttl INT DEFAULT 3600,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    created_by VARCHAR(255) DEFAULT 'demo-user',
+    INDEX idx_spiffe_id (spiffe_id),
+    INDEX idx_parent_id (parent_id)
+) ENGINE=InnoDB;
+
+-- Site workload entry assignments
+CREATE TABLE site_workload_entries (
+    site_id VARCHAR(36),
+    workload_entry_id VARCHAR(36),
+    sync_status ENUM('pending', 'synced', 'failed', 'deleting') DEFAULT 'pending',
+    spire_entry_id VARCHAR(255) DEFAULT NULL,
+    last_sync_at TIMESTAMP NULL,
+    sync_error TEXT,
+    PRIMARY KEY (site_id, workload_entry_id),
+    FOREIGN KEY (site_id) REFERENCES sites(id) ON DELETE CASCADE,
+    FOREIGN KEY (workload_entry_id) REFERENCES workload_entries(id) ON DELETE CASCADE,
+    INDEX idx_sync_status (sync_status),
+    INDEX idx_site_status (site_id, sync_status)
+) ENGINE=InnoDB;
+
+-- Site agent inventory, updated by heartbeats
+CREATE TABLE agents (
+    site_id VARCHAR(36) NOT NULL,
+    agent_id VARCHAR(255) NOT NULL,
+    version VARCHAR(64) NOT NULL DEFAULT '',
+    started_at TIMESTAMP NULL,
+    is_leader BOOLEAN NOT NULL DEFAULT FALSE,
+    backlog INT NOT NULL DEFAULT 0,
+    spire_reachable BOOLEAN NOT NULL DEFAULT FALSE,
+    spire_server_version VARCHAR(64) NOT NULL DEFAULT '',
+    last_heartbeat_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (site_id, agent_id),
+    FOREIGN KEY (site_id) REFERENCES sites(id) ON DELETE CASCADE,
+    INDEX idx_last_heartbeat (last_heartbeat_at)
+) ENGINE=InnoDB;
+
+-- API keys for programmatic access; only SHA-256 hashes of secrets are stored
+CREATE TABLE api_keys (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    key_prefix VARCHAR(16) NOT NULL,
+    scopes JSON NOT NULL,
+    spiffe_id_prefix VARCHAR(512) NULL,
+    expires_at TIMESTAMP NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    rotated_at TIMESTAMP NULL,
+    last_used_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL
+) ENGINE=InnoDB;
+
+-- Role bindings grant RBAC roles to users, groups and API keys
+CREATE TABLE role_bindings (
+    id VARCHAR(36) PRIMARY KEY,
+    role ENUM('admin', 'operator', 'developer', 'viewer', 'site-agent') NOT NULL,
+    subject_kind ENUM('user', 'group', 'apikey') NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_binding (role, subject_kind, subject),
+    INDEX idx_subject (subject_kind, subject)
+) ENGINE=InnoDB;
+
+-- Namespace grants scope which entries developers may manage
+CREATE TABLE namespace_grants (
+    id VARCHAR(36) PRIMARY KEY,
+    subject_kind ENUM('user', 'group', 'apikey') NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    namespaces JSON NOT NULL,
+    spiffe_id_prefixes JSON NOT NULL,
+    site_ids JSON NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_subject (subject_kind, subject)
+) ENGINE=InnoDB;
+
+-- Change requests hold entry mutations aimed at protected sites until a
+-- second person approves them
+CREATE TABLE change_requests (
+    id VARCHAR(36) PRIMARY KEY,
+    operation ENUM('assign', 'delete') NOT NULL,
+    workload_entry_id VARCHAR(36) NOT NULL,
+    site_ids JSON NOT NULL,
+    status ENUM('pending_approval', 'approved', 'rejected', 'applied', 'failed') NOT NULL DEFAULT 'pending_approval',
+    requested_by VARCHAR(255) NOT NULL,
+    reviewed_by VARCHAR(255) NULL,
+    review_comment TEXT NULL,
+    apply_error TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP NULL,
+    INDEX idx_status (status, created_at),
+    INDEX idx_entry (workload_entry_id)
+) ENGINE=InnoDB;
+
+-- Audit log
+CREATE TABLE audit_log (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id VARCHAR(255) NOT NULL,
+    details JSON,
+    -- Hash chain: each row commits to the previous row of its stream
+    stream VARCHAR(50) NOT NULL,
+    seq BIGINT NOT NULL,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    UNIQUE KEY uk_stream_seq (stream, seq),
+    INDEX idx_timestamp (timestamp),
+    INDEX idx_actor (actor),
+    INDEX idx_resource (resource_type, resource_id)
+) ENGINE=InnoDB;
+
+-- Head of each audit log hash chain; locked to append entries in order
+CREATE TABLE audit_streams (
+    stream VARCHAR(50) PRIMARY KEY,
+    seq BIGINT NOT NULL,
+    hash CHAR(64) NOT NULL
+) ENGINE=InnoDB;
+
+-- Signed checkpoints of audit log chain heads
+CREATE TABLE audit_checkpoints (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    stream VARCHAR(50) NOT NULL,
+    seq BIGINT NOT NULL,
+    hash CHAR(64) NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    signature VARCHAR(128) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    INDEX idx_stream_seq (stream, seq)
+) ENGINE=InnoDB;
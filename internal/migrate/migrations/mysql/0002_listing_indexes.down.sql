@@ -0,0 +1,10 @@
+CREATE INDEX idx_resource ON audit_log (resource_type, resource_id);
+CREATE INDEX idx_actor ON audit_log (actor);
+DROP INDEX idx_resource_id ON audit_log;
+DROP INDEX idx_actor_id ON audit_log;
+
+CREATE INDEX idx_site_status ON site_workload_entries (site_id, sync_status);
+DROP INDEX idx_site_status_queued ON site_workload_entries;
+ALTER TABLE site_workload_entries DROP COLUMN queued_at;
+
+DROP INDEX idx_created_at_id ON workload_entries;
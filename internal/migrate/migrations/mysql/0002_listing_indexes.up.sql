@@ -0,0 +1,17 @@
+-- Indexes that keep entry listing, agent polling and audit queries fast with
+-- large inventories
+
+-- Newest-first keyset pagination of entries
+CREATE INDEX idx_created_at_id ON workload_entries (created_at, id);
+
+-- Each site's pending queue in order, so polling needs no sort
+ALTER TABLE site_workload_entries
+    ADD COLUMN queued_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+CREATE INDEX idx_site_status_queued ON site_workload_entries (site_id, sync_status, queued_at, workload_entry_id);
+DROP INDEX idx_site_status ON site_workload_entries;
+
+-- Audit log filters, each ordered by id for newest-first listing
+CREATE INDEX idx_actor_id ON audit_log (actor, id);
+CREATE INDEX idx_resource_id ON audit_log (resource_type, resource_id, id);
+DROP INDEX idx_actor ON audit_log;
+DROP INDEX idx_resource ON audit_log;
@@ -0,0 +1,22 @@
+-- Selectors of each entry, one row per distinct selector, for lookups by
+-- selector. The selectors JSON column stays the entry's own copy, in order.
+-- Selectors compare case-sensitively, as in SPIRE.
+CREATE TABLE workload_entry_selectors (
+    id VARCHAR(36) PRIMARY KEY,
+    workload_entry_id VARCHAR(36) NOT NULL,
+    type VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,
+    value VARCHAR(4096) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,
+    FOREIGN KEY (workload_entry_id) REFERENCES workload_entries(id) ON DELETE CASCADE,
+    INDEX idx_type_value (type, value(255))
+) ENGINE=InnoDB;
+
+INSERT INTO workload_entry_selectors (id, workload_entry_id, type, value)
+SELECT UUID(), d.workload_entry_id, d.type, d.value
+FROM (
+    SELECT DISTINCT we.id AS workload_entry_id, s.type, s.value
+    FROM workload_entries we,
+         JSON_TABLE(we.selectors, '$[*]' COLUMNS (
+             type VARCHAR(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin PATH '$.type',
+             value VARCHAR(4096) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin PATH '$.value'
+         )) s
+) d;
@@ -0,0 +1,12 @@
+-- Domain events written in the same transaction as the change they describe,
+-- until the relay has delivered them to every subscriber
+CREATE TABLE outbox_events (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    event_type VARCHAR(64) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id VARCHAR(255) NOT NULL,
+    site_ids JSON NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    payload JSON NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
@@ -0,0 +1,5 @@
+-- Tombstones would otherwise come back as live entries
+DELETE FROM workload_entries WHERE deleted_at IS NOT NULL;
+
+DROP INDEX idx_deleted_at ON workload_entries;
+ALTER TABLE workload_entries DROP COLUMN deleted_at;
@@ -0,0 +1,13 @@
+-- Entries already archived stay deleted; without the runs recording them
+-- their streams no longer verify
+ALTER TABLE audit_log REMOVE PARTITIONING;
+ALTER TABLE audit_log
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (id),
+    DROP INDEX uk_stream_seq,
+    ADD UNIQUE KEY uk_stream_seq (stream, seq),
+    MODIFY timestamp TIMESTAMP NULL DEFAULT CURRENT_TIMESTAMP;
+
+DROP TABLE IF EXISTS audit_log_restored;
+DROP TABLE IF EXISTS audit_archived_runs;
+DROP TABLE IF EXISTS audit_archives;
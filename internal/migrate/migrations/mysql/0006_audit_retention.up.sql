@@ -0,0 +1,59 @@
+-- Archives of expired audit log entries, recorded as the entries they hold
+-- are deleted
+CREATE TABLE audit_archives (
+    name VARCHAR(255) PRIMARY KEY,
+    sha256 CHAR(64) NOT NULL,
+    entries INT NOT NULL,
+    first_timestamp TIMESTAMP NOT NULL,
+    last_timestamp TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB;
+
+-- Runs of consecutive archived entries of a stream, with the hashes that
+-- carry the chain across the gap they leave, signed with the checkpoint key
+CREATE TABLE audit_archived_runs (
+    stream VARCHAR(50) NOT NULL,
+    first_seq BIGINT NOT NULL,
+    last_seq BIGINT NOT NULL,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    archive VARCHAR(255) NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    signature VARCHAR(128) NOT NULL,
+    PRIMARY KEY (stream, first_seq),
+    INDEX idx_archive (archive)
+) ENGINE=InnoDB;
+
+-- Archived entries loaded back for investigation
+CREATE TABLE audit_log_restored (
+    id BIGINT PRIMARY KEY,
+    archive VARCHAR(255) NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id VARCHAR(255) NOT NULL,
+    details JSON,
+    stream VARCHAR(50) NOT NULL,
+    seq BIGINT NOT NULL,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    restored_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_archive (archive),
+    INDEX idx_timestamp (timestamp),
+    INDEX idx_resource (resource_type, resource_id)
+) ENGINE=InnoDB;
+
+-- Partition audit_log by month so that emptied months are dropped rather
+-- than left as fragmented pages. Every unique key of a partitioned table must
+-- include the partitioning column. The API server splits monthly partitions
+-- off p_future as time passes; until then every entry is in p_future.
+ALTER TABLE audit_log
+    MODIFY timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (id, timestamp),
+    DROP INDEX uk_stream_seq,
+    ADD UNIQUE KEY uk_stream_seq (stream, seq, timestamp);
+ALTER TABLE audit_log PARTITION BY RANGE (UNIX_TIMESTAMP(timestamp)) (
+    PARTITION p_future VALUES LESS THAN MAXVALUE
+);
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS audit_checkpoints;
+DROP TABLE IF EXISTS audit_streams;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS change_requests;
+DROP TABLE IF EXISTS namespace_grants;
+DROP TABLE IF EXISTS role_bindings;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS agents;
+DROP TABLE IF EXISTS site_workload_entries;
+DROP TABLE IF EXISTS workload_entries;
+DROP TABLE IF EXISTS sites;
+DROP FUNCTION IF EXISTS set_updated_at();
@@ -0,0 +1,175 @@
+-- SPIFFE/SPIRE Workload Entry Management System Database Schema (PostgreSQL)
+--
+-- Timestamps are second precision, like MySQL's TIMESTAMP columns.
+
+-- Keeps updated_at current, as MySQL's ON UPDATE CURRENT_TIMESTAMP does
+CREATE FUNCTION set_updated_at() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN NEW.updated_at := NOW(); RETURN NEW; END $$;
+
+-- Sites table
+CREATE TABLE sites (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    region VARCHAR(50) NOT NULL,
+    spire_server_address VARCHAR(255) NOT NULL,
+    trust_domain VARCHAR(255) NOT NULL DEFAULT '',
+    agent_spiffe_id VARCHAR(512) NULL,
+    protected BOOLEAN NOT NULL DEFAULT FALSE,
+    last_sync_at TIMESTAMPTZ(0) NULL,
+    status VARCHAR(16) DEFAULT 'active' CHECK (status IN ('active', 'inactive', 'disconnected')),
+    created_at TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER sites_updated_at BEFORE UPDATE ON sites FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Workload entries table
+CREATE TABLE workload_entries (
+    id VARCHAR(36) PRIMARY KEY,
+    spiffe_id VARCHAR(512) NOT NULL UNIQUE,
+    parent_id VARCHAR(512) NOT NULL,
+    selectors JSONB NOT NULL,
+    ttl INT DEFAULT 3600,
+    description TEXT,
+    created_at TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    created_by VARCHAR(255) DEFAULT 'demo-user'
+);
+
+CREATE INDEX idx_workload_entries_parent_id ON workload_entries (parent_id);
+
+CREATE TRIGGER workload_entries_updated_at BEFORE UPDATE ON workload_entries FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Site workload entry assignments
+CREATE TABLE site_workload_entries (
+    site_id VARCHAR(36) REFERENCES sites(id) ON DELETE CASCADE,
+    workload_entry_id VARCHAR(36) REFERENCES workload_entries(id) ON DELETE CASCADE,
+    sync_status VARCHAR(16) DEFAULT 'pending' CHECK (sync_status IN ('pending', 'synced', 'failed', 'deleting')),
+    spire_entry_id VARCHAR(255) DEFAULT NULL,
+    last_sync_at TIMESTAMPTZ(0) NULL,
+    sync_error TEXT,
+    PRIMARY KEY (site_id, workload_entry_id)
+);
+
+CREATE INDEX idx_site_workload_entries_sync_status ON site_workload_entries (sync_status);
+CREATE INDEX idx_site_workload_entries_site_status ON site_workload_entries (site_id, sync_status);
+CREATE INDEX idx_site_workload_entries_entry ON site_workload_entries (workload_entry_id);
+
+-- Site agent inventory, updated by heartbeats
+CREATE TABLE agents (
+    site_id VARCHAR(36) NOT NULL REFERENCES sites(id) ON DELETE CASCADE,
+    agent_id VARCHAR(255) NOT NULL,
+    version VARCHAR(64) NOT NULL DEFAULT '',
+    started_at TIMESTAMPTZ(0) NULL,
+    is_leader BOOLEAN NOT NULL DEFAULT FALSE,
+    backlog INT NOT NULL DEFAULT 0,
+    spire_reachable BOOLEAN NOT NULL DEFAULT FALSE,
+    spire_server_version VARCHAR(64) NOT NULL DEFAULT '',
+    last_heartbeat_at TIMESTAMPTZ(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (site_id, agent_id)
+);
+
+CREATE INDEX idx_agents_last_heartbeat ON agents (last_heartbeat_at);
+
+-- API keys for programmatic access; only SHA-256 hashes of secrets are stored
+CREATE TABLE api_keys (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    key_prefix VARCHAR(16) NOT NULL,
+    scopes JSONB NOT NULL,
+    spiffe_id_prefix VARCHAR(512) NULL,
+    expires_at TIMESTAMPTZ(0) NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    rotated_at TIMESTAMPTZ(0) NULL,
+    last_used_at TIMESTAMPTZ(0) NULL,
+    revoked_at TIMESTAMPTZ(0) NULL
+);
+
+-- Role bindings grant RBAC roles to users, groups and API keys
+CREATE TABLE role_bindings (
+    id VARCHAR(36) PRIMARY KEY,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('admin', 'operator', 'developer', 'viewer', 'site-agent')),
+    subject_kind VARCHAR(16) NOT NULL CHECK (subject_kind IN ('user', 'group', 'apikey')),
+    subject VARCHAR(255) NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uk_binding UNIQUE (role, subject_kind, subject)
+);
+
+CREATE INDEX idx_role_bindings_subject ON role_bindings (subject_kind, subject);
+
+-- Namespace grants scope which entries developers may manage
+CREATE TABLE namespace_grants (
+    id VARCHAR(36) PRIMARY KEY,
+    subject_kind VARCHAR(16) NOT NULL CHECK (subject_kind IN ('user', 'group', 'apikey')),
+    subject VARCHAR(255) NOT NULL,
+    namespaces JSONB NOT NULL,
+    spiffe_id_prefixes JSONB NOT NULL,
+    site_ids JSONB NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_namespace_grants_subject ON namespace_grants (subject_kind, subject);
+
+-- Change requests hold entry mutations aimed at protected sites until a
+-- second person approves them
+CREATE TABLE change_requests (
+    id VARCHAR(36) PRIMARY KEY,
+    operation VARCHAR(16) NOT NULL CHECK (operation IN ('assign', 'delete')),
+    workload_entry_id VARCHAR(36) NOT NULL,
+    site_ids JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending_approval'
+        CHECK (status IN ('pending_approval', 'approved', 'rejected', 'applied', 'failed')),
+    requested_by VARCHAR(255) NOT NULL,
+    reviewed_by VARCHAR(255) NULL,
+    review_comment TEXT NULL,
+    apply_error TEXT NULL,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMPTZ(0) NULL
+);
+
+CREATE INDEX idx_change_requests_status ON change_requests (status, created_at);
+CREATE INDEX idx_change_requests_entry ON change_requests (workload_entry_id);
+
+-- Audit log
+CREATE TABLE audit_log (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    timestamp TIMESTAMPTZ(0) DEFAULT CURRENT_TIMESTAMP,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id VARCHAR(255) NOT NULL,
+    details JSONB,
+    -- Hash chain: each row commits to the previous row of its stream
+    stream VARCHAR(50) NOT NULL,
+    seq BIGINT NOT NULL,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    CONSTRAINT uk_stream_seq UNIQUE (stream, seq)
+);
+
+CREATE INDEX idx_audit_log_timestamp ON audit_log (timestamp);
+CREATE INDEX idx_audit_log_actor ON audit_log (actor);
+CREATE INDEX idx_audit_log_resource ON audit_log (resource_type, resource_id);
+
+-- Head of each audit log hash chain; locked to append entries in order
+CREATE TABLE audit_streams (
+    stream VARCHAR(50) PRIMARY KEY,
+    seq BIGINT NOT NULL,
+    hash CHAR(64) NOT NULL
+);
+
+-- Signed checkpoints of audit log chain heads
+CREATE TABLE audit_checkpoints (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    stream VARCHAR(50) NOT NULL,
+    seq BIGINT NOT NULL,
+    hash CHAR(64) NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    signature VARCHAR(128) NOT NULL,
+    created_at TIMESTAMPTZ(0) NOT NULL
+);
+
+CREATE INDEX idx_audit_checkpoints_stream_seq ON audit_checkpoints (stream, seq);
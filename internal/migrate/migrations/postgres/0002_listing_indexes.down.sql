@@ -0,0 +1,11 @@
+CREATE INDEX idx_audit_log_resource ON audit_log (resource_type, resource_id);
+CREATE INDEX idx_audit_log_actor ON audit_log (actor);
+DROP INDEX idx_audit_log_resource_id;
+DROP INDEX idx_audit_log_actor_id;
+
+CREATE INDEX idx_site_workload_entries_site_status ON site_workload_entries (site_id, sync_status);
+DROP INDEX idx_site_workload_entries_site_status_queued;
+ALTER TABLE site_workload_entries DROP COLUMN queued_at;
+
+DROP INDEX idx_workload_entries_spiffe_id_pattern;
+DROP INDEX idx_workload_entries_created_at_id;
@@ -0,0 +1,22 @@
+-- Indexes that keep entry listing, agent polling and audit queries fast with
+-- large inventories
+
+-- Newest-first keyset pagination of entries
+CREATE INDEX idx_workload_entries_created_at_id ON workload_entries (created_at, id);
+
+-- SPIFFE ID prefix search; LIKE can only use a pattern_ops index unless the
+-- database collation is C
+CREATE INDEX idx_workload_entries_spiffe_id_pattern ON workload_entries (spiffe_id varchar_pattern_ops);
+
+-- Each site's pending queue in order, so polling needs no sort
+ALTER TABLE site_workload_entries
+    ADD COLUMN queued_at TIMESTAMPTZ(0) NOT NULL DEFAULT CURRENT_TIMESTAMP;
+CREATE INDEX idx_site_workload_entries_site_status_queued
+    ON site_workload_entries (site_id, sync_status, queued_at, workload_entry_id);
+DROP INDEX idx_site_workload_entries_site_status;
+
+-- Audit log filters, each ordered by id for newest-first listing
+CREATE INDEX idx_audit_log_actor_id ON audit_log (actor, id);
+CREATE INDEX idx_audit_log_resource_id ON audit_log (resource_type, resource_id, id);
+DROP INDEX idx_audit_log_actor;
+DROP INDEX idx_audit_log_resource;
@@ -0,0 +1,20 @@
+-- Selectors of each entry, one row per distinct selector, for lookups by
+-- selector. The selectors JSON column stays the entry's own copy, in order.
+CREATE TABLE workload_entry_selectors (
+    id VARCHAR(36) PRIMARY KEY,
+    workload_entry_id VARCHAR(36) NOT NULL REFERENCES workload_entries(id) ON DELETE CASCADE,
+    type VARCHAR(255) NOT NULL,
+    value VARCHAR(4096) NOT NULL
+);
+
+CREATE INDEX idx_workload_entry_selectors_entry ON workload_entry_selectors (workload_entry_id);
+CREATE INDEX idx_workload_entry_selectors_type ON workload_entry_selectors (type);
+-- A hash index has no key size limit, unlike a B-tree over values this long
+CREATE INDEX idx_workload_entry_selectors_value ON workload_entry_selectors USING hash (value);
+
+INSERT INTO workload_entry_selectors (id, workload_entry_id, type, value)
+SELECT gen_random_uuid()::text, d.workload_entry_id, d.type, d.value
+FROM (
+    SELECT DISTINCT we.id AS workload_entry_id, s->>'type' AS type, s->>'value' AS value
+    FROM workload_entries we, jsonb_array_elements(we.selectors) s
+) d;
@@ -0,0 +1,12 @@
+-- Domain events written in the same transaction as the change they describe,
+-- until the relay has delivered them to every subscriber
+CREATE TABLE outbox_events (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id VARCHAR(255) NOT NULL,
+    site_ids JSONB NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
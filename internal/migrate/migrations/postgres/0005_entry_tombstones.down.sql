@@ -0,0 +1,5 @@
+-- Tombstones would otherwise come back as live entries
+DELETE FROM workload_entries WHERE deleted_at IS NOT NULL;
+
+DROP INDEX idx_workload_entries_deleted_at;
+ALTER TABLE workload_entries DROP COLUMN deleted_at;
@@ -0,0 +1,6 @@
+-- Deleted entries remain as tombstones, with their site assignments in the
+-- deleting state, until every site has removed the entry from its SPIRE
+-- server
+ALTER TABLE workload_entries
+    ADD COLUMN deleted_at TIMESTAMPTZ(0) NULL;
+CREATE INDEX idx_workload_entries_deleted_at ON workload_entries (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Entries already archived stay deleted; without the runs recording them
+-- their streams no longer verify
+DROP TABLE IF EXISTS audit_log_restored;
+DROP TABLE IF EXISTS audit_archived_runs;
+DROP TABLE IF EXISTS audit_archives;
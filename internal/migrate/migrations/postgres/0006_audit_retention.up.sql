@@ -0,0 +1,47 @@
+-- Archives of expired audit log entries, recorded as the entries they hold
+-- are deleted
+CREATE TABLE audit_archives (
+    name VARCHAR(255) PRIMARY KEY,
+    sha256 CHAR(64) NOT NULL,
+    entries INT NOT NULL,
+    first_timestamp TIMESTAMPTZ(0) NOT NULL,
+    last_timestamp TIMESTAMPTZ(0) NOT NULL,
+    created_at TIMESTAMPTZ(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Runs of consecutive archived entries of a stream, with the hashes that
+-- carry the chain across the gap they leave, signed with the checkpoint key
+CREATE TABLE audit_archived_runs (
+    stream VARCHAR(50) NOT NULL,
+    first_seq BIGINT NOT NULL,
+    last_seq BIGINT NOT NULL,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    archive VARCHAR(255) NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    signature VARCHAR(128) NOT NULL,
+    PRIMARY KEY (stream, first_seq)
+);
+
+CREATE INDEX idx_audit_archived_runs_archive ON audit_archived_runs (archive);
+
+-- Archived entries loaded back for investigation
+CREATE TABLE audit_log_restored (
+    id BIGINT PRIMARY KEY,
+    archive VARCHAR(255) NOT NULL,
+    timestamp TIMESTAMPTZ(0) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id VARCHAR(255) NOT NULL,
+    details JSONB,
+    stream VARCHAR(50) NOT NULL,
+    seq BIGINT NOT NULL,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    restored_at TIMESTAMPTZ(0) NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_log_restored_archive ON audit_log_restored (archive);
+CREATE INDEX idx_audit_log_restored_timestamp ON audit_log_restored (timestamp);
+CREATE INDEX idx_audit_log_restored_resource ON audit_log_restored (resource_type, resource_id);
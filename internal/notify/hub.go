@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"sync"
+)
+
+// Hub is an in-process notification hub that wakes site agents waiting for work.
+// Notifications are coalesced: a waiter that has not yet consumed a previous
+// notification is not signalled again.
+type Hub struct {
+	mu      sync.Mutex
+	waiters map[string]map[chan struct{}]struct{}
+}
+
+// NewHub creates a new Hub
+func NewHub() *Hub {
+	return &Hub{
+		waiters: make(map[string]map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe registers a waiter for a site. The returned channel receives a value
+// whenever work may exist for the site. The cancel function must be called to
+// release the subscription.
+func (h *Hub) Subscribe(siteID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	if h.waiters[siteID] == nil {
+		h.waiters[siteID] = make(map[chan struct{}]struct{})
+	}
+	h.waiters[siteID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	cancel := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.waiters[siteID], ch)
+		if len(h.waiters[siteID]) == 0 {
+			delete(h.waiters, siteID)
+		}
+	}
+
+	return ch, cancel
+}
+
+// Notify wakes all waiters for the given sites
+func (h *Hub) Notify(siteIDs ...string) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, siteID := range siteIDs {
+		for ch := range h.waiters[siteID] {
+			select {
+			case ch <- struct{}{}:
+			default:
+				// A notification is already pending for this waiter
+			}
+		}
+	}
+}
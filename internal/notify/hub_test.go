@@ -0,0 +1,112 @@
+package notify_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/notify"
+)
+
+func TestNotifyWakesSiteWaiters(t *testing.T) {
+	h := notify.NewHub()
+	a, cancelA := h.Subscribe("site-a")
+	defer cancelA()
+	b, cancelB := h.Subscribe("site-b")
+	defer cancelB()
+
+	h.Notify("site-a")
+
+	select {
+	case <-a:
+	case <-time.After(time.Second):
+		t.Fatal("site-a waiter was not woken")
+	}
+	select {
+	case <-b:
+		t.Error("site-b waiter was woken by a site-a notification")
+	default:
+	}
+}
+
+func TestNotifyCoalesces(t *testing.T) {
+	h := notify.NewHub()
+	ch, cancel := h.Subscribe("site-a")
+	defer cancel()
+
+	// Notifying a waiter that has not consumed the last notification neither
+	// blocks nor queues a second one
+	h.Notify("site-a")
+	h.Notify("site-a", "site-a")
+
+	<-ch
+	select {
+	case <-ch:
+		t.Error("coalesced notifications were delivered twice")
+	default:
+	}
+}
+
+func TestCancelledSubscriptionIsNotWoken(t *testing.T) {
+	h := notify.NewHub()
+	ch, cancel := h.Subscribe("site-a")
+	cancel()
+	// Cancelling twice is harmless
+	cancel()
+
+	h.Notify("site-a")
+	select {
+	case <-ch:
+		t.Error("cancelled subscription was woken")
+	default:
+	}
+}
+
+func TestNilHubNotify(t *testing.T) {
+	var h *notify.Hub
+	h.Notify("site-a")
+}
+
+// TestConcurrentSubscribeNotify races subscribers against notifiers; run it
+// with -race
+func TestConcurrentSubscribeNotify(t *testing.T) {
+	h := notify.NewHub()
+	sites := []string{"site-a", "site-b", "site-c"}
+
+	stop := make(chan struct{})
+	var notifiers sync.WaitGroup
+	for _, site := range sites {
+		notifiers.Add(1)
+		go func(site string) {
+			defer notifiers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					h.Notify(site)
+				}
+			}
+		}(site)
+	}
+
+	// Every waiter subscribed while notifications keep arriving is woken
+	var waiters sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		waiters.Add(1)
+		go func(site string) {
+			defer waiters.Done()
+			ch, cancel := h.Subscribe(site)
+			defer cancel()
+			select {
+			case <-ch:
+			case <-time.After(5 * time.Second):
+				t.Errorf("waiter for %s was not woken", site)
+			}
+		}(sites[i%len(sites)])
+	}
+
+	waiters.Wait()
+	close(stop)
+	notifiers.Wait()
+}
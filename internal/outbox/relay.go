@@ -0,0 +1,141 @@
+// Package outbox relays the domain events that units of work record in the
+// transactional outbox to in-process subscribers.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/yourorg/spire-workload-mgmt/internal/notify"
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+)
+
+// batchSize is the number of events claimed per delivery
+const batchSize = 100
+
+var (
+	eventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "spire_mgmt_domain_events_total",
+		Help: "Domain events published, by type.",
+	}, []string{"type"})
+	deliveryFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "spire_mgmt_outbox_delivery_failures_total",
+		Help: "Failed deliveries of domain events, by subscriber.",
+	}, []string{"subscriber"})
+)
+
+// Handler handles a published event. Delivery is at least once: an event a
+// handler fails is delivered to it again, in order, and events may repeat
+// after a restart, so handlers must be idempotent.
+type Handler func(ctx context.Context, e repository.Event) error
+
+type subscriber struct {
+	name   string
+	handle Handler
+}
+
+// Relay publishes outbox events to its subscribers
+type Relay struct {
+	repo repository.OutboxRepository
+
+	mu          sync.Mutex
+	subscribers []subscriber
+	// handled holds, for events a subscriber failed, the subscribers that
+	// already handled them, so only the failed ones see them again
+	handled map[int64]map[string]bool
+}
+
+// NewRelay creates a Relay for the events of repo
+func NewRelay(repo repository.OutboxRepository) *Relay {
+	return &Relay{repo: repo, handled: make(map[int64]map[string]bool)}
+}
+
+// Subscribe adds a subscriber, which receives the events published from then
+// on in the order they were recorded
+func (r *Relay) Subscribe(name string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, subscriber{name: name, handle: h})
+}
+
+// Run publishes events as units of work commit them, and every interval to
+// retry failed deliveries and pick up events of other servers, until ctx is
+// cancelled
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Drain(ctx); err != nil {
+			log.Printf("Failed to relay outbox events: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.repo.Appended():
+		}
+	}
+}
+
+// Drain publishes events until none are left or a delivery fails
+func (r *Relay) Drain(ctx context.Context) error {
+	for {
+		n, err := r.repo.Deliver(ctx, batchSize, func(e repository.Event) error {
+			return r.publish(ctx, e)
+		})
+		if err != nil || n < batchSize {
+			return err
+		}
+	}
+}
+
+// publish passes an event to the subscribers that have not handled it yet
+func (r *Relay) publish(ctx context.Context, e repository.Event) error {
+	r.mu.Lock()
+	var pending []subscriber
+	for _, s := range r.subscribers {
+		if !r.handled[e.ID][s.name] {
+			pending = append(pending, s)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, s := range pending {
+		if err := s.handle(ctx, e); err != nil {
+			deliveryFailuresTotal.WithLabelValues(s.name).Inc()
+			return fmt.Errorf("subscriber %s failed event %d: %w", s.name, e.ID, err)
+		}
+		r.mu.Lock()
+		if r.handled[e.ID] == nil {
+			r.handled[e.ID] = make(map[string]bool)
+		}
+		r.handled[e.ID][s.name] = true
+		r.mu.Unlock()
+	}
+
+	r.mu.Lock()
+	delete(r.handled, e.ID)
+	r.mu.Unlock()
+	return nil
+}
+
+// NotifySites wakes the site agents waiting for work at the sites an event
+// affects
+func NotifySites(hub *notify.Hub) Handler {
+	return func(ctx context.Context, e repository.Event) error {
+		hub.Notify(e.SiteIDs...)
+		return nil
+	}
+}
+
+// CountEvents counts published events by type
+func CountEvents(ctx context.Context, e repository.Event) error {
+	eventsTotal.WithLabelValues(e.Type).Inc()
+	return nil
+}
@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Engine evaluates admission policy loaded from a file and reloads it when
+// the file changes. A nil Engine admits everything.
+type Engine struct {
+	path string
+
+	mu      sync.RWMutex
+	policy  *Policy
+	modTime time.Time
+}
+
+// NewEngine loads the policy at path
+func NewEngine(path string) (*Engine, error) {
+	e := &Engine{path: path}
+	if err := e.reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Evaluate returns the rules violated by in using the current policy
+func (e *Engine) Evaluate(in Input) []Result {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.RLock()
+	p := e.policy
+	e.mu.RUnlock()
+
+	return p.Evaluate(in)
+}
+
+// Watch reloads the policy whenever the file's modification time changes,
+// until ctx is cancelled. An invalid policy is logged and the previous
+// policy stays in effect.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(e.path)
+			if err != nil {
+				log.Printf("Failed to stat policy file: %v", err)
+				continue
+			}
+
+			e.mu.RLock()
+			changed := !info.ModTime().Equal(e.modTime)
+			e.mu.RUnlock()
+			if !changed {
+				continue
+			}
+
+			if err := e.reload(); err != nil {
+				log.Printf("Failed to reload admission policy, keeping previous rules: %v", err)
+				continue
+			}
+		}
+	}
+}
+
+func (e *Engine) reload() error {
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return err
+	}
+
+	p, err := Load(e.path)
+	if err != nil {
+		// Remember the broken version so it is not re-parsed on every tick
+		e.mu.Lock()
+		e.modTime = info.ModTime()
+		e.mu.Unlock()
+		return err
+	}
+
+	e.mu.Lock()
+	e.policy = p
+	e.modTime = info.ModTime()
+	e.mu.Unlock()
+
+	log.Printf("Loaded admission policy with %d rules from %s", len(p.Rules), e.path)
+	return nil
+}
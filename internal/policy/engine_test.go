@@ -0,0 +1,114 @@
+package policy_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/policy"
+)
+
+const denyAll = `
+rules:
+  - name: deny-all
+    when:
+      spiffeId: "."
+`
+
+const denyLongTTL = `
+rules:
+  - name: max-ttl
+    require:
+      maxTtl: 3600
+`
+
+// writePolicy writes doc to path with a modification time of mtime
+func writePolicy(t *testing.T, path, doc string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitForRules waits until the engine's result for in is want
+func waitForRules(t *testing.T, e *policy.Engine, in policy.Input, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(e.Evaluate(in)) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("policy was not reloaded: %v", e.Evaluate(in))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestEngineReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	start := time.Now().Add(-time.Hour)
+	writePolicy(t, path, denyAll, start)
+
+	e, err := policy.NewEngine(path)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Watch(ctx, 10*time.Millisecond)
+
+	in := policy.Input{SpiffeID: "spiffe://example.org/api", TTL: 60}
+	if len(e.Evaluate(in)) != 1 {
+		t.Fatal("initial policy did not deny")
+	}
+
+	// A new policy is picked up once the modification time changes
+	writePolicy(t, path, denyLongTTL, start.Add(time.Minute))
+	waitForRules(t, e, in, 0)
+
+	// An invalid policy keeps the previous rules in effect
+	writePolicy(t, path, "rules: [", start.Add(2*time.Minute))
+	time.Sleep(100 * time.Millisecond)
+	if got := e.Evaluate(policy.Input{TTL: 7200}); len(got) != 1 || got[0].Rule != "max-ttl" {
+		t.Errorf("rules after an invalid reload: %v", got)
+	}
+
+	// And a fixed policy is loaded again
+	writePolicy(t, path, denyAll, start.Add(3*time.Minute))
+	waitForRules(t, e, in, 1)
+}
+
+func TestEngineIgnoresUnchangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	mtime := time.Now().Add(-time.Hour)
+	writePolicy(t, path, denyAll, mtime)
+
+	e, err := policy.NewEngine(path)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Watch(ctx, 10*time.Millisecond)
+
+	// Same modification time: the content change is not picked up
+	writePolicy(t, path, denyLongTTL, mtime)
+	time.Sleep(100 * time.Millisecond)
+	if len(e.Evaluate(policy.Input{SpiffeID: "spiffe://example.org/api"})) != 1 {
+		t.Error("policy was reloaded without a modification time change")
+	}
+}
+
+func TestNewEngineRejectsInvalidPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	writePolicy(t, path, "rules:\n  - name: a\n", time.Now())
+	if _, err := policy.NewEngine(path); err == nil {
+		t.Error("NewEngine accepted an invalid policy")
+	}
+	if _, err := policy.NewEngine(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("NewEngine accepted a missing file")
+	}
+}
@@ -0,0 +1,262 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule actions
+const (
+	ActionDeny = "deny"
+	ActionWarn = "warn"
+)
+
+// Operations that admission rules run on
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpAssign = "assign"
+)
+
+// Selector is a workload selector as seen by admission rules
+type Selector struct {
+	Type  string
+	Value string
+}
+
+// Input is the workload entry and request context a policy is evaluated against
+type Input struct {
+	Operation string
+	SpiffeID  string
+	ParentID  string
+	Selectors []Selector
+	SiteIDs   []string
+	TTL       int
+	Actor     string
+	Groups    []string
+}
+
+// Result is a rule that an input violated
+type Result struct {
+	Rule    string
+	Action  string
+	Message string
+}
+
+// Conditions are matched against an input. Every field that is set must hold.
+// Selector patterns have the form "<type>:<value>" (for example
+// "k8s:ns:payments" or "k8s:pod-label:owner:*") and "*" matches any characters.
+type Conditions struct {
+	// SpiffeID matches if the SPIFFE ID matches this regular expression
+	SpiffeID string `yaml:"spiffeId"`
+	// Sites matches if any of the entry's sites matches one of these patterns
+	Sites []string `yaml:"sites"`
+	// ActorGroups matches if the caller belongs to one of these groups
+	ActorGroups []string `yaml:"actorGroups"`
+	// NotActorGroups matches if the caller belongs to none of these groups
+	NotActorGroups []string `yaml:"notActorGroups"`
+	// MaxTTL matches if the entry TTL is at most this many seconds
+	MaxTTL *int `yaml:"maxTtl"`
+	// Selectors matches if, for every pattern, some selector matches it
+	Selectors []string `yaml:"selectors"`
+	// AnySelector matches if some selector matches one of these patterns
+	AnySelector []string `yaml:"anySelector"`
+	// SelectorsOnly matches if every selector matches one of these patterns
+	SelectorsOnly []string `yaml:"selectorsOnly"`
+
+	spiffeID *regexp.Regexp
+}
+
+// Rule is a single admission rule. A rule applies to an input when its When
+// conditions match, and is violated when it applies and its Require
+// conditions do not hold. A rule without Require is violated whenever it applies.
+type Rule struct {
+	Name       string      `yaml:"name"`
+	Action     string      `yaml:"action"`
+	Message    string      `yaml:"message"`
+	Operations []string    `yaml:"operations"`
+	When       *Conditions `yaml:"when"`
+	Require    *Conditions `yaml:"require"`
+}
+
+// Policy is a set of admission rules
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Load reads and validates a policy file
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses and validates a YAML policy document
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i+1)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("rule %s: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+
+		if r.Action == "" {
+			r.Action = ActionDeny
+		}
+		if r.Action != ActionDeny && r.Action != ActionWarn {
+			return nil, fmt.Errorf("rule %s: action must be deny or warn", r.Name)
+		}
+		for _, op := range r.Operations {
+			if op != OpCreate && op != OpUpdate && op != OpAssign {
+				return nil, fmt.Errorf("rule %s: unknown operation %q", r.Name, op)
+			}
+		}
+		if r.When == nil && r.Require == nil {
+			return nil, fmt.Errorf("rule %s: when or require is required", r.Name)
+		}
+		for _, c := range []*Conditions{r.When, r.Require} {
+			if c == nil || c.SpiffeID == "" {
+				continue
+			}
+			re, err := regexp.Compile(c.SpiffeID)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: invalid spiffeId pattern: %w", r.Name, err)
+			}
+			c.spiffeID = re
+		}
+		if r.Message == "" {
+			r.Message = "violates policy rule " + r.Name
+		}
+	}
+
+	return &p, nil
+}
+
+// Evaluate returns the rules violated by in
+func (p *Policy) Evaluate(in Input) []Result {
+	if p == nil {
+		return nil
+	}
+
+	var results []Result
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if len(r.Operations) > 0 && !containsString(r.Operations, in.Operation) {
+			continue
+		}
+		if r.When != nil && !r.When.matches(in) {
+			continue
+		}
+		if r.Require != nil && r.Require.matches(in) {
+			continue
+		}
+		results = append(results, Result{Rule: r.Name, Action: r.Action, Message: r.Message})
+	}
+	return results
+}
+
+// matches reports whether every condition that is set holds for in
+func (c *Conditions) matches(in Input) bool {
+	if c.spiffeID != nil && !c.spiffeID.MatchString(in.SpiffeID) {
+		return false
+	}
+	if len(c.Sites) > 0 && !anyMatch(c.Sites, in.SiteIDs) {
+		return false
+	}
+	if len(c.ActorGroups) > 0 && !intersects(c.ActorGroups, in.Groups) {
+		return false
+	}
+	if len(c.NotActorGroups) > 0 && intersects(c.NotActorGroups, in.Groups) {
+		return false
+	}
+	if c.MaxTTL != nil && in.TTL > *c.MaxTTL {
+		return false
+	}
+
+	selectors := make([]string, len(in.Selectors))
+	for i, s := range in.Selectors {
+		selectors[i] = s.Type + ":" + s.Value
+	}
+	for _, pattern := range c.Selectors {
+		if !anyMatch([]string{pattern}, selectors) {
+			return false
+		}
+	}
+	if len(c.AnySelector) > 0 && !anyMatch(c.AnySelector, selectors) {
+		return false
+	}
+	if len(c.SelectorsOnly) > 0 {
+		for _, s := range selectors {
+			if !anyMatch(c.SelectorsOnly, []string{s}) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// anyMatch reports whether any value matches any pattern
+func anyMatch(patterns, values []string) bool {
+	for _, v := range values {
+		for _, p := range patterns {
+			if globMatch(p, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// globMatch matches s against a pattern in which "*" matches any characters
+func globMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(s, part)
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		if containsString(b, x) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package policy_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/policy"
+)
+
+func parse(t *testing.T, doc string) *policy.Policy {
+	t.Helper()
+	p, err := policy.Parse([]byte(doc))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return p
+}
+
+// violated returns the names of the rules of p that in violates
+func violated(p *policy.Policy, in policy.Input) []string {
+	var names []string
+	for _, r := range p.Evaluate(in) {
+		names = append(names, r.Rule)
+	}
+	return names
+}
+
+func selectors(values ...string) []policy.Selector {
+	var sels []policy.Selector
+	for _, v := range values {
+		typ, value, _ := strings.Cut(v, ":")
+		sels = append(sels, policy.Selector{Type: typ, Value: value})
+	}
+	return sels
+}
+
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		name string
+		when string
+		in   policy.Input
+		want bool
+	}{
+		{"spiffeId matches", `spiffeId: "/admin(/|$)"`, policy.Input{SpiffeID: "spiffe://example.org/admin/db"}, true},
+		{"spiffeId anchored", `spiffeId: "/admin(/|$)"`, policy.Input{SpiffeID: "spiffe://example.org/administrator"}, false},
+		{"sites glob", `sites: ["prod*"]`, policy.Input{SiteIDs: []string{"dev-1", "prod-eu"}}, true},
+		{"sites no match", `sites: ["prod*"]`, policy.Input{SiteIDs: []string{"dev-1"}}, false},
+		{"sites exact", `sites: ["prod"]`, policy.Input{SiteIDs: []string{"prod-eu"}}, false},
+		{"actorGroups", `actorGroups: [platform]`, policy.Input{Groups: []string{"dev", "platform"}}, true},
+		{"actorGroups no match", `actorGroups: [platform]`, policy.Input{Groups: []string{"dev"}}, false},
+		{"notActorGroups", `notActorGroups: [platform]`, policy.Input{Groups: []string{"dev"}}, true},
+		{"notActorGroups member", `notActorGroups: [platform]`, policy.Input{Groups: []string{"platform"}}, false},
+		{"maxTtl at limit", `maxTtl: 3600`, policy.Input{TTL: 3600}, true},
+		{"maxTtl above limit", `maxTtl: 3600`, policy.Input{TTL: 3601}, false},
+		{"selectors all present", `selectors: ["k8s:ns:*", "k8s:sa:*"]`, policy.Input{Selectors: selectors("k8s:ns:payments", "k8s:sa:api")}, true},
+		{"selectors one missing", `selectors: ["k8s:ns:*", "k8s:sa:*"]`, policy.Input{Selectors: selectors("k8s:ns:payments")}, false},
+		{"anySelector", `anySelector: ["k8s:sa:*", "k8s:pod-label:*"]`, policy.Input{Selectors: selectors("k8s:ns:payments", "k8s:pod-label:app:api")}, true},
+		{"anySelector none", `anySelector: ["k8s:sa:*"]`, policy.Input{Selectors: selectors("k8s:ns:payments")}, false},
+		{"selectorsOnly", `selectorsOnly: ["k8s:ns:*"]`, policy.Input{Selectors: selectors("k8s:ns:payments")}, true},
+		{"selectorsOnly other", `selectorsOnly: ["k8s:ns:*"]`, policy.Input{Selectors: selectors("k8s:ns:payments", "k8s:sa:api")}, false},
+		{"glob infix", `anySelector: ["k8s:pod-label:*:payments"]`, policy.Input{Selectors: selectors("k8s:pod-label:team:payments")}, true},
+		{"glob infix suffix mismatch", `anySelector: ["k8s:pod-label:*:payments"]`, policy.Input{Selectors: selectors("k8s:pod-label:team:billing")}, false},
+		{"glob several wildcards", `anySelector: ["k8s:*:*:api"]`, policy.Input{Selectors: selectors("k8s:pod-label:app:api")}, true},
+		{"every condition must hold", "sites: [\"prod*\"]\n      maxTtl: 60", policy.Input{SiteIDs: []string{"prod"}, TTL: 61}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parse(t, "rules:\n  - name: r\n    when:\n      "+tt.when+"\n")
+			if got := len(p.Evaluate(tt.in)) == 1; got != tt.want {
+				t.Errorf("when %s matched %v, want %v", tt.when, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhenAndRequire(t *testing.T) {
+	p := parse(t, `
+rules:
+  - name: prod-max-ttl
+    when:
+      sites: ["prod*"]
+    require:
+      maxTtl: 3600
+  - name: owner-required
+    action: warn
+    message: add an owner label
+    operations: [create]
+    require:
+      selectors: ["k8s:pod-label:owner:*"]
+`)
+	owned := selectors("k8s:ns:payments", "k8s:pod-label:owner:payments")
+
+	tests := []struct {
+		name string
+		in   policy.Input
+		want []string
+	}{
+		{"when does not apply", policy.Input{Operation: policy.OpCreate, SiteIDs: []string{"dev"}, TTL: 7200, Selectors: owned}, nil},
+		{"when applies, require holds", policy.Input{Operation: policy.OpCreate, SiteIDs: []string{"prod-1"}, TTL: 600, Selectors: owned}, nil},
+		{"when applies, require fails", policy.Input{Operation: policy.OpCreate, SiteIDs: []string{"prod-1"}, TTL: 7200, Selectors: owned}, []string{"prod-max-ttl"}},
+		{"require only fails", policy.Input{Operation: policy.OpCreate, SiteIDs: []string{"dev"}, Selectors: selectors("k8s:ns:payments")}, []string{"owner-required"}},
+		{"operation not covered", policy.Input{Operation: policy.OpAssign, SiteIDs: []string{"dev"}, Selectors: selectors("k8s:ns:payments")}, nil},
+		{"both fail", policy.Input{Operation: policy.OpCreate, SiteIDs: []string{"prod-1"}, TTL: 7200}, []string{"prod-max-ttl", "owner-required"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := violated(p, tt.in); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("violated %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	results := p.Evaluate(policy.Input{Operation: policy.OpCreate, SiteIDs: []string{"prod-1"}, TTL: 7200})
+	if results[0].Action != policy.ActionDeny || results[0].Message != "violates policy rule prod-max-ttl" {
+		t.Errorf("defaults: %+v", results[0])
+	}
+	if results[1].Action != policy.ActionWarn || results[1].Message != "add an owner label" {
+		t.Errorf("warn rule: %+v", results[1])
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"yaml", "rules: [", "failed to parse policy"},
+		{"unnamed rule", "rules:\n  - when: {maxTtl: 1}\n", "name is required"},
+		{"duplicate name", "rules:\n  - name: a\n    when: {maxTtl: 1}\n  - name: a\n    when: {maxTtl: 1}\n", "duplicate name"},
+		{"action", "rules:\n  - name: a\n    action: allow\n    when: {maxTtl: 1}\n", "action must be deny or warn"},
+		{"operation", "rules:\n  - name: a\n    operations: [delete]\n    when: {maxTtl: 1}\n", "unknown operation"},
+		{"no conditions", "rules:\n  - name: a\n", "when or require is required"},
+		{"regex", "rules:\n  - name: a\n    when: {spiffeId: \"(\"}\n", "invalid spiffeId pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := policy.Parse([]byte(tt.doc))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse: %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSamplePolicy(t *testing.T) {
+	p, err := policy.Load("../../deploy/policy/admission-policy.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	good := selectors("k8s:ns:payments", "k8s:pod-label:owner:payments")
+	tests := []struct {
+		name string
+		in   policy.Input
+		want []string
+	}{
+		{"allowed", policy.Input{Operation: policy.OpCreate, SpiffeID: "spiffe://example.org/payments/api",
+			Selectors: good, SiteIDs: []string{"prod-us"}, TTL: 3600}, nil},
+		{"admin id by platform team", policy.Input{Operation: policy.OpCreate, SpiffeID: "spiffe://example.org/admin",
+			Selectors: good, SiteIDs: []string{"dev"}, Groups: []string{"platform-team"}}, nil},
+		{"admin id by others", policy.Input{Operation: policy.OpCreate, SpiffeID: "spiffe://example.org/admin/db",
+			Selectors: good, SiteIDs: []string{"dev"}, Groups: []string{"payments"}}, []string{"admin-ids-platform-only"}},
+		{"prod ttl", policy.Input{Operation: policy.OpAssign, SpiffeID: "spiffe://example.org/payments/api",
+			Selectors: good, SiteIDs: []string{"prod-us"}, TTL: 86401}, []string{"prod-max-ttl"}},
+		{"owner label on create", policy.Input{Operation: policy.OpCreate, SpiffeID: "spiffe://example.org/payments/api",
+			Selectors: selectors("k8s:ns:payments", "k8s:sa:api"), SiteIDs: []string{"dev"}}, []string{"owner-label-required"}},
+		{"owner label not on assign", policy.Input{Operation: policy.OpAssign, SpiffeID: "spiffe://example.org/payments/api",
+			Selectors: selectors("k8s:ns:payments", "k8s:sa:api"), SiteIDs: []string{"dev"}}, nil},
+		{"namespace only", policy.Input{Operation: policy.OpAssign, SpiffeID: "spiffe://example.org/payments/api",
+			Selectors: selectors("k8s:ns:payments"), SiteIDs: []string{"dev"}}, []string{"namespace-only-selectors"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := violated(p, tt.in); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("violated %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilPolicyAdmitsEverything(t *testing.T) {
+	var p *policy.Policy
+	var e *policy.Engine
+	if len(p.Evaluate(policy.Input{})) != 0 || len(e.Evaluate(policy.Input{})) != 0 {
+		t.Error("nil policy rejected an input")
+	}
+}
@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/yourorg/spire-workload-mgmt/internal/agentauth"
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Site agent routes are limited separately from human traffic
+const (
+	agentHTTPPrefix  = "/api/v1/agent/"
+	agentGRPCPrefix  = "/spire.mgmt.v1.SiteAgentService/"
+	listAgentsMethod = "/spire.mgmt.v1.SiteAgentService/ListAgents"
+	healthPath       = "/health"
+)
+
+var throttledTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "spire_mgmt_ratelimit_throttled_total",
+	Help: "Requests rejected by the API rate limiter.",
+}, []string{"class", "limit"})
+
+// HTTPMiddleware rejects requests over the caller's limit with 429 Too Many
+// Requests. It must run after authentication so that callers are keyed by
+// identity. A nil Limiter allows every request.
+func (l *Limiter) HTTPMiddleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" || r.URL.Path == healthPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		class := ClassHuman
+		if strings.HasPrefix(r.URL.Path, agentHTTPPrefix) {
+			class = ClassAgent
+		}
+		identity := identityFromContext(r.Context(), r.RemoteAddr)
+
+		limitName, ok, retryAfter := l.Allow(class, identity, r.Method, r.URL.Path)
+		if !ok {
+			throttledTotal.WithLabelValues(class, limitName).Inc()
+			log.Printf("Rate limited %s %s for %s (limit %s)", r.Method, r.URL.Path, identity, limitName)
+			w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryServerInterceptor rate limits unary gRPC calls
+func (l *Limiter) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := l.allowGRPC(ctx, info.FullMethod, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor rate limits the start of streaming gRPC calls
+func (l *Limiter) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := l.allowGRPC(ss.Context(), info.FullMethod, ss.SetHeader); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func (l *Limiter) allowGRPC(ctx context.Context, method string, setHeader func(metadata.MD) error) error {
+	if l == nil {
+		return nil
+	}
+
+	class := ClassHuman
+	if strings.HasPrefix(method, agentGRPCPrefix) && method != listAgentsMethod {
+		class = ClassAgent
+	}
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	identity := identityFromContext(ctx, remoteAddr)
+
+	limitName, ok, retryAfter := l.Allow(class, identity, "", method)
+	if ok {
+		return nil
+	}
+
+	throttledTotal.WithLabelValues(class, limitName).Inc()
+	log.Printf("Rate limited %s for %s (limit %s)", method, identity, limitName)
+	if err := setHeader(metadata.Pairs("retry-after", retryAfterSeconds(retryAfter))); err != nil {
+		log.Printf("Failed to set retry-after header: %v", err)
+	}
+	return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %s", retryAfterSeconds(retryAfter)+"s")
+}
+
+// identityFromContext keys a caller by authenticated principal, agent SVID
+// or, for anonymous callers, client IP address
+func identityFromContext(ctx context.Context, remoteAddr string) string {
+	if p, ok := auth.PrincipalFromContext(ctx); ok {
+		return p.Actor()
+	}
+	if id, ok := agentauth.PeerIDFromContext(ctx); ok {
+		return id.String()
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return "ip:" + host
+}
+
+// retryAfterSeconds formats a wait as whole seconds, rounded up
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(d.Seconds()))))
+}
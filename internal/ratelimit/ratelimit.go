@@ -0,0 +1,224 @@
+// Package ratelimit throttles API callers with per-identity token buckets.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Traffic classes with separate default limits
+const (
+	ClassHuman = "human"
+	ClassAgent = "agent"
+)
+
+// Limit is a sustained rate in requests per second and a burst size. A zero
+// rate disables limiting.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited reports whether l does not limit requests
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0
+}
+
+// Route overrides the class limits for matching requests. Pattern is an
+// optional HTTP method and a path or gRPC full method name; a trailing "*"
+// matches any suffix, e.g. "POST /api/v1/entries" or
+// "/spire.mgmt.v1.WorkloadEntryService/*".
+type Route struct {
+	Pattern string
+	Limit   Limit
+
+	method string
+	path   string
+	prefix bool
+}
+
+// Config holds the default limits per traffic class and per-route overrides
+type Config struct {
+	Human  Limit
+	Agent  Limit
+	Routes []Route
+}
+
+// ConfigFromEnv creates Config from environment variables:
+// RATE_LIMIT_RPS/RATE_LIMIT_BURST for human traffic,
+// RATE_LIMIT_AGENT_RPS/RATE_LIMIT_AGENT_BURST for site agents, and
+// RATE_LIMIT_ROUTES, a comma-separated list of <pattern>=<rps>:<burst>.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Human: Limit{Rate: 10, Burst: 20},
+		Agent: Limit{Rate: 20, Burst: 40},
+	}
+
+	var err error
+	if cfg.Human, err = limitFromEnv("RATE_LIMIT_RPS", "RATE_LIMIT_BURST", cfg.Human); err != nil {
+		return cfg, err
+	}
+	if cfg.Agent, err = limitFromEnv("RATE_LIMIT_AGENT_RPS", "RATE_LIMIT_AGENT_BURST", cfg.Agent); err != nil {
+		return cfg, err
+	}
+	if cfg.Routes, err = ParseRoutes(os.Getenv("RATE_LIMIT_ROUTES")); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func limitFromEnv(rateKey, burstKey string, def Limit) (Limit, error) {
+	l := def
+	if v := os.Getenv(rateKey); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 {
+			return l, fmt.Errorf("invalid %s %q", rateKey, v)
+		}
+		l.Rate = rate
+		l.Burst = int(math.Ceil(rate * 2))
+	}
+	if v := os.Getenv(burstKey); v != "" {
+		burst, err := strconv.Atoi(v)
+		if err != nil || burst < 1 {
+			return l, fmt.Errorf("invalid %s %q", burstKey, v)
+		}
+		l.Burst = burst
+	}
+	return l, nil
+}
+
+// ParseRoutes parses a comma-separated list of <pattern>=<rps>:<burst> route limits
+func ParseRoutes(s string) ([]Route, error) {
+	var routes []Route
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		pattern, spec, ok := strings.Cut(item, "=")
+		rateStr, burstStr, ok2 := strings.Cut(spec, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid route limit %q, expected <pattern>=<rps>:<burst>", item)
+		}
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate in route limit %q", item)
+		}
+		burst, err := strconv.Atoi(burstStr)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("invalid burst in route limit %q", item)
+		}
+
+		r := Route{Pattern: strings.TrimSpace(pattern), Limit: Limit{Rate: rate, Burst: burst}}
+		r.method, r.path, _ = strings.Cut(r.Pattern, " ")
+		if r.path == "" {
+			r.method, r.path = "", r.method
+		}
+		r.path = strings.TrimSpace(r.path)
+		if strings.HasSuffix(r.path, "*") {
+			r.path = strings.TrimSuffix(r.path, "*")
+			r.prefix = true
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+func (r *Route) matches(method, path string) bool {
+	if r.method != "" && r.method != method {
+		return false
+	}
+	if r.prefix {
+		return strings.HasPrefix(path, r.path)
+	}
+	return path == r.path
+}
+
+// bucket is a token bucket refilled continuously at its limit's rate
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// Limiter tracks a token bucket per identity, traffic class and route
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewLimiter creates a new Limiter
+func NewLimiter(cfg Config) *Limiter {
+	return &Limiter{cfg: cfg, now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token for a request by identity. method is the HTTP method,
+// empty for gRPC, and path the URL path or gRPC full method name. It returns
+// the name of the limit that applied and, if the request is rejected, how
+// long until a token is available.
+func (l *Limiter) Allow(class, identity, method, path string) (limitName string, ok bool, retryAfter time.Duration) {
+	limit, limitName := l.limitFor(class, method, path)
+	if limit.Unlimited() {
+		return limitName, true, 0
+	}
+
+	key := limitName + "|" + identity
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{limit: limit, tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return limitName, true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	return limitName, false, wait
+}
+
+// limitFor returns the limit for a request and the name its buckets are kept under
+func (l *Limiter) limitFor(class, method, path string) (Limit, string) {
+	for i := range l.cfg.Routes {
+		if r := &l.cfg.Routes[i]; r.matches(method, path) {
+			return r.Limit, r.Pattern
+		}
+	}
+	if class == ClassAgent {
+		return l.cfg.Agent, ClassAgent
+	}
+	return l.cfg.Human, ClassHuman
+}
+
+// sweep drops buckets that have been idle long enough to be full again,
+// since a new bucket starts out full
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
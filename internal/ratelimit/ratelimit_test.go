@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+)
+
+// fakeClock is a settable clock for limiters
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(cfg Config) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	l := NewLimiter(cfg)
+	l.now = clock.now
+	return l, clock
+}
+
+func TestTokenRefill(t *testing.T) {
+	l, clock := newTestLimiter(Config{Human: Limit{Rate: 2, Burst: 3}})
+	allow := func() (bool, time.Duration) {
+		_, ok, retryAfter := l.Allow(ClassHuman, "alice", "GET", "/api/v1/entries")
+		return ok, retryAfter
+	}
+
+	// A new bucket starts full
+	for i := 0; i < 3; i++ {
+		if ok, _ := allow(); !ok {
+			t.Fatalf("request %d of the burst was rejected", i+1)
+		}
+	}
+	ok, retryAfter := allow()
+	if ok || retryAfter != 500*time.Millisecond {
+		t.Fatalf("request over the burst: allowed %v, retry after %s", ok, retryAfter)
+	}
+
+	// Tokens refill continuously at the rate
+	clock.advance(250 * time.Millisecond)
+	if ok, retryAfter := allow(); ok || retryAfter != 250*time.Millisecond {
+		t.Errorf("half a token: allowed %v, retry after %s", ok, retryAfter)
+	}
+	clock.advance(250 * time.Millisecond)
+	if ok, _ := allow(); !ok {
+		t.Error("refilled token was not available")
+	}
+
+	// But never beyond the burst
+	clock.advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := allow(); !ok {
+			t.Fatalf("request %d after idling was rejected", i+1)
+		}
+	}
+	if ok, _ := allow(); ok {
+		t.Error("bucket refilled beyond its burst")
+	}
+}
+
+func TestBucketsPerIdentityAndClass(t *testing.T) {
+	l, _ := newTestLimiter(Config{Human: Limit{Rate: 1, Burst: 1}, Agent: Limit{Rate: 1, Burst: 1}})
+
+	if _, ok, _ := l.Allow(ClassHuman, "alice", "GET", "/api/v1/entries"); !ok {
+		t.Fatal("first request was rejected")
+	}
+	if _, ok, _ := l.Allow(ClassHuman, "alice", "GET", "/api/v1/sites"); ok {
+		t.Error("alice's second request was allowed")
+	}
+	if _, ok, _ := l.Allow(ClassHuman, "bob", "GET", "/api/v1/entries"); !ok {
+		t.Error("bob was limited by alice's requests")
+	}
+	if name, ok, _ := l.Allow(ClassAgent, "alice", "GET", "/api/v1/agent/poll"); !ok || name != ClassAgent {
+		t.Errorf("agent traffic was limited by human traffic: %s, %v", name, ok)
+	}
+}
+
+func TestRouteLimits(t *testing.T) {
+	routes, err := ParseRoutes("POST /api/v1/entries=1:1, /spire.mgmt.v1.StateService/*=0:1")
+	if err != nil {
+		t.Fatalf("ParseRoutes: %v", err)
+	}
+	l, _ := newTestLimiter(Config{Human: Limit{Rate: 1, Burst: 2}, Routes: routes})
+
+	if name, ok, _ := l.Allow(ClassHuman, "alice", "POST", "/api/v1/entries"); !ok || name != "POST /api/v1/entries" {
+		t.Fatalf("route request: %s, %v", name, ok)
+	}
+	if _, ok, _ := l.Allow(ClassHuman, "alice", "POST", "/api/v1/entries"); ok {
+		t.Error("route limit was not applied")
+	}
+	// The route has its own bucket, and other methods use the class limit
+	if name, ok, _ := l.Allow(ClassHuman, "alice", "GET", "/api/v1/entries"); !ok || name != ClassHuman {
+		t.Errorf("GET request: %s, %v", name, ok)
+	}
+	// A zero rate disables limiting for the route
+	for i := 0; i < 10; i++ {
+		if _, ok, _ := l.Allow(ClassHuman, "alice", "", "/spire.mgmt.v1.StateService/ExportState"); !ok {
+			t.Fatal("unlimited route was limited")
+		}
+	}
+}
+
+func TestParseRoutesInvalid(t *testing.T) {
+	for _, s := range []string{"/api/v1/entries", "/api/v1/entries=1", "/api/v1/entries=x:1", "/api/v1/entries=1:0", "/api/v1/entries=-1:1"} {
+		if _, err := ParseRoutes(s); err == nil {
+			t.Errorf("ParseRoutes(%q) succeeded", s)
+		}
+	}
+}
+
+func TestSweepDropsFullBuckets(t *testing.T) {
+	l, clock := newTestLimiter(Config{Human: Limit{Rate: 1, Burst: 100}})
+	l.Allow(ClassHuman, "alice", "GET", "/api/v1/entries")
+	for i := 0; i < 100; i++ {
+		l.Allow(ClassHuman, "bob", "GET", "/api/v1/entries")
+	}
+
+	// After a minute alice's bucket is full again and is dropped, while bob's
+	// still records his burst
+	clock.advance(time.Minute + time.Second)
+	l.Allow(ClassHuman, "carol", "GET", "/api/v1/entries")
+	if _, ok := l.buckets[ClassHuman+"|alice"]; ok {
+		t.Error("full bucket was kept")
+	}
+	if _, ok := l.buckets[ClassHuman+"|bob"]; !ok {
+		t.Error("bucket that is not full was dropped")
+	}
+}
+
+func TestHTTPMiddlewareKeysByIdentity(t *testing.T) {
+	l, _ := newTestLimiter(Config{Human: Limit{Rate: 1, Burst: 1}, Agent: Limit{Rate: 1, Burst: 1}})
+	handler := l.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(path, remoteAddr string, p *auth.Principal) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = remoteAddr
+		if p != nil {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+	alice := &auth.Principal{Subject: "alice"}
+	ci := &auth.Principal{APIKeyID: "key-1", APIKeyName: "ci"}
+
+	if rec := request("/api/v1/entries", "10.0.0.1:1000", alice); rec.Code != http.StatusOK {
+		t.Fatalf("first request: %d", rec.Code)
+	}
+	// From another address, alice is still the same caller
+	rec := request("/api/v1/entries", "10.0.0.2:1000", alice)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("second request: %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// Callers sharing alice's address are not
+	if rec := request("/api/v1/entries", "10.0.0.1:2000", ci); rec.Code != http.StatusOK {
+		t.Errorf("API key limited by alice: %d", rec.Code)
+	}
+	// Anonymous callers are keyed by address, whatever the port
+	if rec := request("/api/v1/entries", "10.0.0.3:1000", nil); rec.Code != http.StatusOK {
+		t.Errorf("anonymous request: %d", rec.Code)
+	}
+	if rec := request("/api/v1/entries", "10.0.0.3:2000", nil); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second anonymous request from the address: %d", rec.Code)
+	}
+	// Agent routes and health checks are not limited with human traffic
+	if rec := request("/api/v1/agent/poll", "10.0.0.3:1000", nil); rec.Code != http.StatusOK {
+		t.Errorf("agent request: %d", rec.Code)
+	}
+	if rec := request("/health", "10.0.0.3:1000", nil); rec.Code != http.StatusOK {
+		t.Errorf("health check: %d", rec.Code)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	handler := l.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/entries", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("nil limiter: %d", rec.Code)
+	}
+}
@@ -0,0 +1,375 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/agentauth"
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// methodPermissions maps gRPC full method names to the permission they require
+var methodPermissions = map[string]string{
+	"/spire.mgmt.v1.WorkloadEntryService/CreateWorkloadEntry": PermEntriesWrite,
+	"/spire.mgmt.v1.WorkloadEntryService/GetWorkloadEntry":    PermEntriesRead,
+	"/spire.mgmt.v1.WorkloadEntryService/ListWorkloadEntries": PermEntriesRead,
+	"/spire.mgmt.v1.WorkloadEntryService/DeleteWorkloadEntry": PermEntriesWrite,
+	"/spire.mgmt.v1.WorkloadEntryService/PurgeWorkloadEntry":  PermEntriesPurge,
+	"/spire.mgmt.v1.WorkloadEntryService/AssignToSites":       PermEntriesWrite,
+	"/spire.mgmt.v1.WorkloadEntryService/GetSyncStatus":       PermEntriesRead,
+
+	"/spire.mgmt.v1.SiteService/ListSites": PermSitesRead,
+	"/spire.mgmt.v1.SiteService/GetSite":   PermSitesRead,
+
+	"/spire.mgmt.v1.SiteAgentService/PollEntries":          PermAgentSync,
+	"/spire.mgmt.v1.SiteAgentService/ReportSyncResult":     PermAgentSync,
+	"/spire.mgmt.v1.SiteAgentService/PollDeletions":        PermAgentSync,
+	"/spire.mgmt.v1.SiteAgentService/ReportDeletionResult": PermAgentSync,
+	"/spire.mgmt.v1.SiteAgentService/Heartbeat":            PermAgentSync,
+	"/spire.mgmt.v1.SiteAgentService/ListAgents":           PermAgentsRead,
+
+	"/spire.mgmt.v1.AuditService/ListAuditLogs":       PermAuditRead,
+	"/spire.mgmt.v1.AuditService/VerifyAuditLog":      PermAuditRead,
+	"/spire.mgmt.v1.AuditService/RestoreAuditArchive": PermAuditRestore,
+
+	"/spire.mgmt.v1.APIKeyService/CreateAPIKey": PermAPIKeysManage,
+	"/spire.mgmt.v1.APIKeyService/ListAPIKeys":  PermAPIKeysManage,
+	"/spire.mgmt.v1.APIKeyService/RotateAPIKey": PermAPIKeysManage,
+	"/spire.mgmt.v1.APIKeyService/RevokeAPIKey": PermAPIKeysManage,
+
+	"/spire.mgmt.v1.RBACService/ListRoles":            PermRBACManage,
+	"/spire.mgmt.v1.RBACService/ListRoleBindings":     PermRBACManage,
+	"/spire.mgmt.v1.RBACService/CreateRoleBinding":    PermRBACManage,
+	"/spire.mgmt.v1.RBACService/DeleteRoleBinding":    PermRBACManage,
+	"/spire.mgmt.v1.RBACService/ListNamespaceGrants":  PermRBACManage,
+	"/spire.mgmt.v1.RBACService/CreateNamespaceGrant": PermRBACManage,
+	"/spire.mgmt.v1.RBACService/DeleteNamespaceGrant": PermRBACManage,
+
+	"/spire.mgmt.v1.ChangeRequestService/ListChangeRequests": PermEntriesRead,
+	"/spire.mgmt.v1.ChangeRequestService/GetChangeRequest":   PermEntriesRead,
+	"/spire.mgmt.v1.ChangeRequestService/ApproveChange":      PermChangeApprove,
+	"/spire.mgmt.v1.ChangeRequestService/RejectChange":       PermChangeApprove,
+
+	"/spire.mgmt.v1.StateService/ExportState": PermStateExport,
+	"/spire.mgmt.v1.StateService/ImportState": PermStateImport,
+}
+
+// route maps a REST method and path to the permission it requires. An empty
+// method matches any method; prefix routes match every path below them.
+type route struct {
+	method     string
+	path       string
+	prefix     bool
+	permission string
+}
+
+// routes is checked in order; the first match wins
+var routes = []route{
+	{"GET", "/api/v1/sites", false, PermSitesRead},
+	{"GET", "/api/v1/entries", false, PermEntriesRead},
+	{"POST", "/api/v1/entries", false, PermEntriesWrite},
+	{"GET", "/api/v1/entries/", true, PermEntriesRead},
+	{"DELETE", "/api/v1/entries/", true, PermEntriesWrite},
+	{"POST", "/api/v1/entries/", true, PermEntriesPurge},
+	{"", "/api/v1/agent/", true, PermAgentSync},
+	{"GET", "/api/v1/agents", false, PermAgentsRead},
+	{"GET", "/api/v1/audit", false, PermAuditRead},
+	{"GET", "/api/v1/audit/verify", false, PermAuditRead},
+	{"POST", "/api/v1/audit/archives/", true, PermAuditRestore},
+	{"", "/api/v1/apikeys", false, PermAPIKeysManage},
+	{"", "/api/v1/apikeys/", true, PermAPIKeysManage},
+	{"", "/api/v1/rbac/", true, PermRBACManage},
+	{"GET", "/api/v1/changes", false, PermEntriesRead},
+	{"GET", "/api/v1/changes/", true, PermEntriesRead},
+	{"POST", "/api/v1/changes/", true, PermChangeApprove},
+	{"GET", "/api/v1/state/export", false, PermStateExport},
+	{"POST", "/api/v1/state/import", false, PermStateImport},
+}
+
+// publicPaths are served without authorization
+var publicPaths = map[string]bool{
+	"/health": true,
+}
+
+const reflectionPrefix = "/grpc.reflection."
+
+// BindingStore resolves role bindings for subjects
+type BindingStore interface {
+	RolesFor(ctx context.Context, subjects []Subject) ([]string, error)
+}
+
+// Auditor records authorization denials
+type Auditor interface {
+	Log(ctx context.Context, actor, action, resourceType, resourceID string, details map[string]interface{}) error
+}
+
+// Authorizer enforces role-based access control for every gRPC method and
+// REST route. Roles come from stored bindings, static bootstrap bindings, the
+// implicit site-agent role of callers presenting an X.509 SVID, and the
+// configured role for anonymous callers.
+type Authorizer struct {
+	store         BindingStore
+	auditor       Auditor
+	static        map[Subject][]string
+	anonymousRole string
+	// anonymousAgents grants the site-agent role to anonymous callers
+	anonymousAgents bool
+}
+
+// NewAuthorizer creates a new Authorizer. anonymousRole is granted to
+// requests without an identity and may be empty to deny them.
+func NewAuthorizer(store BindingStore, auditor Auditor, anonymousRole string) *Authorizer {
+	return &Authorizer{
+		store:         store,
+		auditor:       auditor,
+		static:        make(map[Subject][]string),
+		anonymousRole: anonymousRole,
+	}
+}
+
+// AddStaticBinding grants role to subject without storing a binding. It is
+// used to bootstrap the first administrators.
+func (a *Authorizer) AddStaticBinding(role string, subject Subject) error {
+	if !IsValidRole(role) {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	if !IsValidSubjectKind(subject.Kind) {
+		return fmt.Errorf("unknown subject kind %q", subject.Kind)
+	}
+	a.static[subject] = append(a.static[subject], role)
+	return nil
+}
+
+// AllowAnonymousAgents grants the site-agent role to callers without an
+// identity, so agents can sync without mTLS. It is for development only: any
+// caller can then act as any site's agent.
+func (a *Authorizer) AllowAnonymousAgents() {
+	a.anonymousAgents = true
+}
+
+// ParseSubjects parses a comma-separated list of kind:name subjects,
+// e.g. "user:alice@example.com,group:platform-admins"
+func ParseSubjects(s string) ([]Subject, error) {
+	var subjects []Subject
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kind, name, ok := strings.Cut(item, ":")
+		if !ok || name == "" || !IsValidSubjectKind(kind) {
+			return nil, fmt.Errorf("invalid subject %q, expected user:<name>, group:<name> or apikey:<name>", item)
+		}
+		subjects = append(subjects, Subject{Kind: kind, Name: name})
+	}
+	return subjects, nil
+}
+
+// Authorize checks that the caller in ctx holds permission
+func (a *Authorizer) Authorize(ctx context.Context, permission string) (*Decision, error) {
+	roles, err := a.rolesFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Decision{Permission: permission, Roles: roles}
+	for _, role := range roles {
+		if Grants(role, permission) {
+			d.Allowed = true
+			d.GrantedBy = role
+			break
+		}
+	}
+	return d, nil
+}
+
+// rolesFor resolves the roles held by the caller in ctx
+func (a *Authorizer) rolesFor(ctx context.Context) ([]string, error) {
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		if _, ok := agentauth.PeerIDFromContext(ctx); ok {
+			// Per-site authorization of the SVID is done by the service layer
+			return []string{RoleSiteAgent}, nil
+		}
+		var roles []string
+		if a.anonymousRole != "" {
+			roles = append(roles, a.anonymousRole)
+		}
+		if a.anonymousAgents {
+			roles = append(roles, RoleSiteAgent)
+		}
+		return dedupe(roles), nil
+	}
+
+	subjects := SubjectsFor(p)
+	var roles []string
+	for _, s := range subjects {
+		roles = append(roles, a.static[s]...)
+	}
+	if a.store != nil {
+		stored, err := a.store.RolesFor(ctx, subjects)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve role bindings: %w", err)
+		}
+		roles = append(roles, stored...)
+	}
+	return dedupe(roles), nil
+}
+
+// SubjectsFor returns the binding subjects that identify a principal
+func SubjectsFor(p *auth.Principal) []Subject {
+	if p.IsAPIKey() {
+		return []Subject{{Kind: SubjectAPIKey, Name: p.APIKeyName}}
+	}
+
+	subjects := []Subject{{Kind: SubjectUser, Name: p.Subject}}
+	if p.Email != "" {
+		subjects = append(subjects, Subject{Kind: SubjectUser, Name: p.Email})
+	}
+	for _, g := range p.Groups {
+		subjects = append(subjects, Subject{Kind: SubjectGroup, Name: g})
+	}
+	return subjects
+}
+
+// check authorizes a request for resource, records denials and returns the
+// context carrying the decision
+func (a *Authorizer) check(ctx context.Context, resource, permission string) (context.Context, *Decision, error) {
+	if permission == "" {
+		// Unmapped methods and routes are denied
+		d := &Decision{Permission: "unmapped"}
+		a.recordDenial(ctx, resource, d)
+		return ctx, d, nil
+	}
+
+	d, err := a.Authorize(ctx, permission)
+	if err != nil {
+		return ctx, nil, err
+	}
+	if !d.Allowed {
+		a.recordDenial(ctx, resource, d)
+	}
+	return WithDecision(ctx, d), d, nil
+}
+
+func (a *Authorizer) recordDenial(ctx context.Context, resource string, d *Decision) {
+	actor := auth.ActorFromContext(ctx)
+	if _, ok := auth.PrincipalFromContext(ctx); !ok {
+		if id, ok := agentauth.PeerIDFromContext(ctx); ok {
+			actor = id.String()
+		}
+	}
+
+	log.Printf("Permission denied: %s lacks %s for %s (roles: %v)", actor, d.Permission, resource, d.Roles)
+
+	if a.auditor == nil {
+		return
+	}
+	details := map[string]interface{}{
+		"authz": d,
+	}
+	if err := a.auditor.Log(ctx, actor, "deny", "endpoint", resource, details); err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+	}
+}
+
+// UnaryServerInterceptor authorizes unary gRPC calls
+func (a *Authorizer) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authorizeGRPC(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor authorizes streaming gRPC calls
+func (a *Authorizer) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authorizeGRPC(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+}
+
+func (a *Authorizer) authorizeGRPC(ctx context.Context, method string) (context.Context, error) {
+	if a == nil || strings.HasPrefix(method, reflectionPrefix) {
+		return ctx, nil
+	}
+
+	ctx, d, err := a.check(ctx, method, methodPermissions[method])
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "authorization failed: %v", err)
+	}
+	if !d.Allowed {
+		return nil, status.Errorf(codes.PermissionDenied, "permission denied: %s required", d.Permission)
+	}
+	return ctx, nil
+}
+
+// HTTPMiddleware authorizes REST requests
+func (a *Authorizer) HTTPMiddleware(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, d, err := a.check(r.Context(), r.Method+" "+r.URL.Path, routePermission(r.Method, r.URL.Path))
+		if err != nil {
+			log.Printf("Authorization failed for %s %s: %v", r.Method, r.URL.Path, err)
+			http.Error(w, "authorization failed", http.StatusInternalServerError)
+			return
+		}
+		if !d.Allowed {
+			http.Error(w, "permission denied: "+d.Permission+" required", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// routePermission returns the permission required for a REST request, or
+// an empty string if the route is not mapped
+func routePermission(method, path string) string {
+	for _, rt := range routes {
+		if rt.method != "" && rt.method != method {
+			continue
+		}
+		if path == rt.path || (rt.prefix && strings.HasPrefix(path, rt.path)) {
+			return rt.permission
+		}
+	}
+	return ""
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var out []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedStream) Context() context.Context {
+	return w.ctx
+}
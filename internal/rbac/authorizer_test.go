@@ -0,0 +1,220 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+)
+
+func TestRoutePermission(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{"GET", "/api/v1/sites", PermSitesRead},
+		{"POST", "/api/v1/sites", ""},
+		{"GET", "/api/v1/entries", PermEntriesRead},
+		{"POST", "/api/v1/entries", PermEntriesWrite},
+		{"GET", "/api/v1/entries/abc", PermEntriesRead},
+		{"GET", "/api/v1/entries/abc/status", PermEntriesRead},
+		{"DELETE", "/api/v1/entries/abc", PermEntriesWrite},
+		{"POST", "/api/v1/entries/abc/purge", PermEntriesPurge},
+		{"GET", "/api/v1/agent/poll", PermAgentSync},
+		{"POST", "/api/v1/agent/report", PermAgentSync},
+		{"GET", "/api/v1/agents", PermAgentsRead},
+		{"GET", "/api/v1/audit", PermAuditRead},
+		{"GET", "/api/v1/audit/verify", PermAuditRead},
+		{"POST", "/api/v1/audit/archives/a.jsonl.gz/restore", PermAuditRestore},
+		{"GET", "/api/v1/audit/archives/a.jsonl.gz", ""},
+		{"POST", "/api/v1/apikeys", PermAPIKeysManage},
+		{"POST", "/api/v1/apikeys/abc/rotate", PermAPIKeysManage},
+		{"DELETE", "/api/v1/rbac/bindings/abc", PermRBACManage},
+		{"GET", "/api/v1/changes", PermEntriesRead},
+		{"POST", "/api/v1/changes/abc/approve", PermChangeApprove},
+		{"GET", "/api/v1/state/export", PermStateExport},
+		{"POST", "/api/v1/state/import", PermStateImport},
+		{"GET", "/api/v1/state/import", ""},
+		{"GET", "/api/v1/unknown", ""},
+		// Prefix routes do not match their path without the trailing slash
+		{"GET", "/api/v1/agent", ""},
+	}
+	for _, tt := range tests {
+		if got := routePermission(tt.method, tt.path); got != tt.want {
+			t.Errorf("%s %s requires %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestEveryRPCIsMapped checks the method map against the API definition, so
+// that a new RPC cannot ship unmapped (and denied) or a renamed one stay
+// mapped under its old name
+func TestEveryRPCIsMapped(t *testing.T) {
+	data, err := os.ReadFile("../../api/proto/spire_mgmt.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declared := make(map[string]bool)
+	var service string
+	re := regexp.MustCompile(`(?m)^\s*(service|rpc)\s+(\w+)`)
+	for _, m := range re.FindAllStringSubmatch(string(data), -1) {
+		if m[1] == "service" {
+			service = m[2]
+			continue
+		}
+		declared["/spire.mgmt.v1."+service+"/"+m[2]] = true
+	}
+	if len(declared) == 0 {
+		t.Fatal("no RPCs found in the API definition")
+	}
+
+	for method := range declared {
+		if methodPermissions[method] == "" {
+			t.Errorf("%s has no permission", method)
+		}
+	}
+	for method := range methodPermissions {
+		if !declared[method] {
+			t.Errorf("%s is mapped but not declared", method)
+		}
+	}
+}
+
+// TestEveryPermissionIsEnforced checks that each permission a role grants is
+// required by some method and route, and that each required permission is
+// granted to admins
+func TestEveryPermissionIsEnforced(t *testing.T) {
+	required := make(map[string]bool)
+	for _, perm := range methodPermissions {
+		required[perm] = true
+	}
+	for _, rt := range routes {
+		required[rt.permission] = true
+	}
+
+	for _, role := range Roles() {
+		for _, perm := range role.Permissions {
+			if !required[perm] {
+				t.Errorf("%s grants %s, which nothing requires", role.Name, perm)
+			}
+		}
+	}
+	for perm := range required {
+		if !Grants(RoleAdmin, perm) {
+			t.Errorf("admins lack %s", perm)
+		}
+	}
+}
+
+type fakeBindings map[Subject][]string
+
+func (f fakeBindings) RolesFor(ctx context.Context, subjects []Subject) ([]string, error) {
+	var roles []string
+	for _, s := range subjects {
+		roles = append(roles, f[s]...)
+	}
+	return roles, nil
+}
+
+func TestAuthorize(t *testing.T) {
+	store := fakeBindings{
+		{Kind: SubjectUser, Name: "alice@example.org"}: {RoleViewer},
+		{Kind: SubjectGroup, Name: "platform"}:         {RoleOperator},
+		{Kind: SubjectAPIKey, Name: "ci"}:              {RoleDeveloper},
+	}
+	alice := &auth.Principal{Subject: "alice", Email: "alice@example.org"}
+	platform := &auth.Principal{Subject: "bob", Groups: []string{"platform"}}
+	ci := &auth.Principal{APIKeyID: "key-1", APIKeyName: "ci"}
+	// An API key does not inherit the bindings of a user with its name
+	impostor := &auth.Principal{APIKeyID: "key-2", APIKeyName: "alice@example.org"}
+
+	tests := []struct {
+		name          string
+		principal     *auth.Principal
+		anonymousRole string
+		agents        bool
+		permission    string
+		want          bool
+	}{
+		{"anonymous denied by default", nil, "", false, PermEntriesRead, false},
+		{"anonymous role", nil, RoleViewer, false, PermEntriesRead, true},
+		{"anonymous role limits", nil, RoleViewer, false, PermEntriesWrite, false},
+		{"anonymous agents sync", nil, "", true, PermAgentSync, true},
+		{"anonymous agents only sync", nil, "", true, PermEntriesRead, false},
+		{"user binding by email", alice, "", false, PermEntriesRead, true},
+		{"user binding limits", alice, "", false, PermEntriesWrite, false},
+		{"group binding", platform, "", false, PermChangeApprove, true},
+		{"API key binding", ci, "", false, PermEntriesWrite, true},
+		{"API key binding limits", ci, "", false, PermStateExport, false},
+		{"API key is not a user", impostor, "", false, PermEntriesRead, false},
+		{"anonymous role not granted to principals", ci, RoleAdmin, false, PermStateExport, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAuthorizer(store, nil, tt.anonymousRole)
+			if tt.agents {
+				a.AllowAnonymousAgents()
+			}
+			ctx := context.Background()
+			if tt.principal != nil {
+				ctx = auth.WithPrincipal(ctx, tt.principal)
+			}
+			d, err := a.Authorize(ctx, tt.permission)
+			if err != nil {
+				t.Fatalf("Authorize: %v", err)
+			}
+			if d.Allowed != tt.want {
+				t.Errorf("Authorize(%s) = %v with roles %v, want %v", tt.permission, d.Allowed, d.Roles, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaticBinding(t *testing.T) {
+	a := NewAuthorizer(nil, nil, "")
+	if err := a.AddStaticBinding(RoleAdmin, Subject{Kind: SubjectGroup, Name: "admins"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddStaticBinding("root", Subject{Kind: SubjectUser, Name: "alice"}); err == nil {
+		t.Error("unknown role was bound")
+	}
+
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "carol", Groups: []string{"admins"}})
+	if d, _ := a.Authorize(ctx, PermStateImport); !d.Allowed {
+		t.Errorf("static admin denied: %+v", d)
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	a := NewAuthorizer(nil, nil, RoleViewer)
+	handler := a.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := DecisionFromContext(r.Context()); !ok && r.Method != "OPTIONS" && r.URL.Path != "/health" {
+			t.Errorf("%s %s reached the handler without a decision", r.Method, r.URL.Path)
+		}
+	}))
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{"GET", "/health", http.StatusOK},
+		{"OPTIONS", "/api/v1/entries", http.StatusOK},
+		{"GET", "/api/v1/entries", http.StatusOK},
+		{"POST", "/api/v1/entries", http.StatusForbidden},
+		{"GET", "/api/v1/unknown", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+}
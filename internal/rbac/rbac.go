@@ -0,0 +1,139 @@
+package rbac
+
+import (
+	"context"
+	"sort"
+)
+
+// Permissions checked by the authorizer
+const (
+	PermEntriesRead   = "entries:read"
+	PermEntriesWrite  = "entries:write"
+	PermEntriesPurge  = "entries:purge"
+	PermSitesRead     = "sites:read"
+	PermAuditRead     = "audit:read"
+	PermAuditRestore  = "audit:restore"
+	PermAgentsRead    = "agents:read"
+	PermAgentSync     = "agent:sync"
+	PermAPIKeysManage = "apikeys:manage"
+	PermRBACManage    = "rbac:manage"
+	PermChangeApprove = "changes:approve"
+	PermStateExport   = "state:export"
+	PermStateImport   = "state:import"
+)
+
+// Roles from DESIGN.md §8.2
+const (
+	RoleAdmin     = "admin"
+	RoleOperator  = "operator"
+	RoleDeveloper = "developer"
+	RoleViewer    = "viewer"
+	RoleSiteAgent = "site-agent"
+)
+
+// Binding subject kinds
+const (
+	SubjectUser   = "user"
+	SubjectGroup  = "group"
+	SubjectAPIKey = "apikey"
+)
+
+// rolePermissions maps each role to the permissions it grants
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermEntriesRead, PermEntriesWrite, PermEntriesPurge, PermSitesRead, PermAuditRead,
+		PermAuditRestore, PermAgentsRead, PermAgentSync, PermAPIKeysManage, PermRBACManage, PermChangeApprove,
+		PermStateExport, PermStateImport,
+	},
+	RoleOperator: {
+		PermEntriesRead, PermEntriesWrite, PermEntriesPurge, PermSitesRead, PermAuditRead, PermAgentsRead,
+		PermChangeApprove,
+	},
+	RoleDeveloper: {PermEntriesRead, PermEntriesWrite, PermSitesRead},
+	RoleViewer:    {PermEntriesRead, PermSitesRead, PermAgentsRead},
+	RoleSiteAgent: {PermAgentSync},
+}
+
+// Role describes a role and the permissions it grants
+type Role struct {
+	Name        string
+	Permissions []string
+}
+
+// Roles returns all roles, sorted by name
+func Roles() []Role {
+	roles := make([]Role, 0, len(rolePermissions))
+	for name, perms := range rolePermissions {
+		roles = append(roles, Role{Name: name, Permissions: append([]string(nil), perms...)})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles
+}
+
+// IsValidRole reports whether name is a known role
+func IsValidRole(name string) bool {
+	_, ok := rolePermissions[name]
+	return ok
+}
+
+// IsValidSubjectKind reports whether kind can be bound to a role
+func IsValidSubjectKind(kind string) bool {
+	return kind == SubjectUser || kind == SubjectGroup || kind == SubjectAPIKey
+}
+
+// Grants reports whether role grants permission
+func Grants(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Subject identifies a user, group or API key in a role binding
+type Subject struct {
+	Kind string
+	Name string
+}
+
+// Decision records the outcome of an authorization check
+type Decision struct {
+	Permission string   `json:"permission"`
+	Allowed    bool     `json:"allowed"`
+	Roles      []string `json:"roles"`
+	GrantedBy  string   `json:"granted_by,omitempty"`
+}
+
+type decisionKey struct{}
+
+// WithDecision returns a context carrying the authorization decision for the request
+func WithDecision(ctx context.Context, d *Decision) context.Context {
+	return context.WithValue(ctx, decisionKey{}, d)
+}
+
+// DecisionFromContext returns the authorization decision for the request, if any
+func DecisionFromContext(ctx context.Context) (*Decision, bool) {
+	d, ok := ctx.Value(decisionKey{}).(*Decision)
+	return d, ok && d != nil
+}
+
+// RolesFromContext returns the roles resolved for the caller of the request
+func RolesFromContext(ctx context.Context) []string {
+	if d, ok := DecisionFromContext(ctx); ok {
+		return d.Roles
+	}
+	return nil
+}
+
+// RequiresNamespaceScope reports whether a caller holding roles may only
+// manage entries covered by their namespace grants. Admins and operators
+// manage entries in every namespace.
+func RequiresNamespaceScope(roles []string) bool {
+	for _, r := range roles {
+		if r == RoleAdmin || r == RoleOperator {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+// Package readonly rejects mutations on API servers run as read-only
+// standbys, such as DR-site replicas serving from a read replica of the
+// database. Reads and agent polls are served; everything else fails with a
+// message naming the standby.
+package readonly
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// message tells callers why a mutation failed and where to send it
+const message = "server is a read-only standby; send changes to the primary API server"
+
+const reflectionPrefix = "/grpc.reflection."
+
+// readMethods are the gRPC methods a standby serves
+var readMethods = map[string]bool{
+	"/spire.mgmt.v1.WorkloadEntryService/GetWorkloadEntry":    true,
+	"/spire.mgmt.v1.WorkloadEntryService/ListWorkloadEntries": true,
+	"/spire.mgmt.v1.WorkloadEntryService/GetSyncStatus":       true,
+
+	"/spire.mgmt.v1.SiteService/ListSites": true,
+	"/spire.mgmt.v1.SiteService/GetSite":   true,
+
+	"/spire.mgmt.v1.SiteAgentService/PollEntries":   true,
+	"/spire.mgmt.v1.SiteAgentService/PollDeletions": true,
+	"/spire.mgmt.v1.SiteAgentService/ListAgents":    true,
+
+	"/spire.mgmt.v1.AuditService/ListAuditLogs":  true,
+	"/spire.mgmt.v1.AuditService/VerifyAuditLog": true,
+
+	"/spire.mgmt.v1.APIKeyService/ListAPIKeys": true,
+
+	"/spire.mgmt.v1.RBACService/ListRoles":           true,
+	"/spire.mgmt.v1.RBACService/ListRoleBindings":    true,
+	"/spire.mgmt.v1.RBACService/ListNamespaceGrants": true,
+
+	"/spire.mgmt.v1.ChangeRequestService/ListChangeRequests": true,
+	"/spire.mgmt.v1.ChangeRequestService/GetChangeRequest":   true,
+
+	"/spire.mgmt.v1.StateService/ExportState": true,
+}
+
+// HTTPMiddleware rejects REST requests other than GET, HEAD and OPTIONS with
+// 503 Service Unavailable
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			log.Printf("Rejected %s %s on read-only standby", r.Method, r.URL.Path)
+			http.Error(w, message, http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// UnaryServerInterceptor rejects unary gRPC calls that are not reads
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := check(info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor rejects streaming gRPC calls that are not reads
+func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := check(info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func check(method string) error {
+	if readMethods[method] || strings.HasPrefix(method, reflectionPrefix) {
+		return nil
+	}
+	log.Printf("Rejected %s on read-only standby", method)
+	return status.Error(codes.Unavailable, message)
+}
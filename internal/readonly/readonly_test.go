@@ -0,0 +1,76 @@
+package readonly
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	handler := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/api/v1/entries", http.StatusNoContent},
+		{http.MethodGet, "/api/v1/agent/poll", http.StatusNoContent},
+		{http.MethodHead, "/api/v1/sites", http.StatusNoContent},
+		{http.MethodOptions, "/api/v1/entries", http.StatusNoContent},
+		{http.MethodPost, "/api/v1/entries", http.StatusServiceUnavailable},
+		{http.MethodPost, "/api/v1/agent/report", http.StatusServiceUnavailable},
+		{http.MethodPut, "/api/v1/entries/e1", http.StatusServiceUnavailable},
+		{http.MethodDelete, "/api/v1/entries/e1", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestServerInterceptors(t *testing.T) {
+	tests := []struct {
+		method string
+		want   codes.Code
+	}{
+		{"/spire.mgmt.v1.WorkloadEntryService/ListWorkloadEntries", codes.OK},
+		{"/spire.mgmt.v1.SiteAgentService/PollEntries", codes.OK},
+		{"/spire.mgmt.v1.SiteAgentService/PollDeletions", codes.OK},
+		{"/spire.mgmt.v1.StateService/ExportState", codes.OK},
+		{"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", codes.OK},
+		{"/spire.mgmt.v1.WorkloadEntryService/CreateWorkloadEntry", codes.Unavailable},
+		{"/spire.mgmt.v1.SiteAgentService/ReportSyncResult", codes.Unavailable},
+		{"/spire.mgmt.v1.APIKeyService/CreateAPIKey", codes.Unavailable},
+		{"/spire.mgmt.v1.StateService/ImportState", codes.Unavailable},
+	}
+	for _, tt := range tests {
+		called := false
+		_, err := UnaryServerInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: tt.method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return nil, nil
+			})
+		if got := status.Code(err); got != tt.want || called != (tt.want == codes.OK) {
+			t.Errorf("unary %s: code %s, handler called %v", tt.method, got, called)
+		}
+
+		called = false
+		err = StreamServerInterceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: tt.method},
+			func(srv interface{}, ss grpc.ServerStream) error {
+				called = true
+				return nil
+			})
+		if got := status.Code(err); got != tt.want || called != (tt.want == codes.OK) {
+			t.Errorf("stream %s: code %s, handler called %v", tt.method, got, called)
+		}
+	}
+}
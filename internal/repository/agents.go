@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Agent represents a site agent instance and its last reported heartbeat
+type Agent struct {
+	SiteID             string
+	AgentID            string
+	Version            string
+	StartedAt          time.Time
+	IsLeader           bool
+	Backlog            int
+	SpireReachable     bool
+	SpireServerVersion string
+	LastHeartbeatAt    time.Time
+}
+
+// sqlAgentRepository handles site agent inventory database operations
+type sqlAgentRepository struct {
+	db *sqlDB
+}
+
+// NewAgentRepository creates an AgentRepository backed by MySQL or PostgreSQL
+func NewAgentRepository(db *sql.DB) AgentRepository {
+	return &sqlAgentRepository{db: newSQLDB(db)}
+}
+
+// RecordHeartbeat inserts or updates an agent and sets its heartbeat time to now
+func (r *sqlAgentRepository) RecordHeartbeat(ctx context.Context, a *Agent) error {
+	query := `INSERT INTO agents (site_id, agent_id, version, started_at, is_leader, backlog,
+	                              spire_reachable, spire_server_version, last_heartbeat_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW())
+	          ON DUPLICATE KEY UPDATE
+	              version = VALUES(version),
+	              started_at = VALUES(started_at),
+	              is_leader = VALUES(is_leader),
+	              backlog = VALUES(backlog),
+	              spire_reachable = VALUES(spire_reachable),
+	              spire_server_version = VALUES(spire_server_version),
+	              last_heartbeat_at = NOW()`
+	if r.db.dialect == dialectPostgres {
+		query = `INSERT INTO agents (site_id, agent_id, version, started_at, is_leader, backlog,
+		                             spire_reachable, spire_server_version, last_heartbeat_at)
+		         VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW())
+		         ON CONFLICT (site_id, agent_id) DO UPDATE SET
+		             version = EXCLUDED.version,
+		             started_at = EXCLUDED.started_at,
+		             is_leader = EXCLUDED.is_leader,
+		             backlog = EXCLUDED.backlog,
+		             spire_reachable = EXCLUDED.spire_reachable,
+		             spire_server_version = EXCLUDED.spire_server_version,
+		             last_heartbeat_at = NOW()`
+	}
+
+	_, err := r.db.ExecContext(ctx, query, a.SiteID, a.AgentID, a.Version, a.StartedAt, a.IsLeader,
+		a.Backlog, a.SpireReachable, a.SpireServerVersion)
+	if err != nil {
+		return fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+// List returns all known agents, optionally filtered by site
+func (r *sqlAgentRepository) List(ctx context.Context, siteID string) ([]Agent, error) {
+	query := `SELECT site_id, agent_id, version, started_at, is_leader, backlog,
+	                 spire_reachable, spire_server_version, last_heartbeat_at
+	          FROM agents`
+	args := []interface{}{}
+
+	if siteID != "" {
+		query += " WHERE site_id = ?"
+		args = append(args, siteID)
+	}
+
+	query += " ORDER BY site_id, agent_id"
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	defer rows.Close()
+
+	var agents []Agent
+	for rows.Next() {
+		var a Agent
+		if err := rows.Scan(&a.SiteID, &a.AgentID, &a.Version, &a.StartedAt, &a.IsLeader, &a.Backlog,
+			&a.SpireReachable, &a.SpireServerVersion, &a.LastHeartbeatAt); err != nil {
+			return nil, fmt.Errorf("failed to scan agent: %w", err)
+		}
+		agents = append(agents, a)
+	}
+
+	return agents, rows.Err()
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey represents a programmatic access key. Only the SHA-256 hash of the
+// secret is stored.
+type APIKey struct {
+	ID             string
+	Name           string
+	KeyHash        string
+	KeyPrefix      string // First characters of the secret, for identification
+	Scopes         []string
+	SpiffeIDPrefix string
+	ExpiresAt      *time.Time
+	CreatedBy      string
+	CreatedAt      time.Time
+	RotatedAt      *time.Time
+	LastUsedAt     *time.Time
+	RevokedAt      *time.Time
+}
+
+// sqlAPIKeyRepository handles API key database operations
+type sqlAPIKeyRepository struct {
+	db *sqlDB
+}
+
+// NewAPIKeyRepository creates an APIKeyRepository backed by MySQL or PostgreSQL
+func NewAPIKeyRepository(db *sql.DB) APIKeyRepository {
+	return &sqlAPIKeyRepository{db: newSQLDB(db)}
+}
+
+const apiKeyColumns = `id, name, key_hash, key_prefix, scopes, COALESCE(spiffe_id_prefix, ''), expires_at,
+	       created_by, created_at, rotated_at, last_used_at, revoked_at`
+
+// Create inserts a new API key
+func (r *sqlAPIKeyRepository) Create(ctx context.Context, key *APIKey) error {
+	if key.ID == "" {
+		key.ID = uuid.New().String()
+	}
+
+	scopesJSON, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal scopes: %w", err)
+	}
+
+	query := `INSERT INTO api_keys (id, name, key_hash, key_prefix, scopes, spiffe_id_prefix, expires_at, created_by)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = r.db.ExecContext(ctx, query, key.ID, key.Name, key.KeyHash, key.KeyPrefix, string(scopesJSON),
+		nullString(key.SpiffeIDPrefix), key.ExpiresAt, key.CreatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to insert API key: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns an API key by ID
+func (r *sqlAPIKeyRepository) Get(ctx context.Context, id string) (*APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = ?`
+	return r.getOne(ctx, query, id)
+}
+
+// GetByHash returns an API key by the hash of its secret
+func (r *sqlAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = ?`
+	return r.getOne(ctx, query, keyHash)
+}
+
+func (r *sqlAPIKeyRepository) getOne(ctx context.Context, query string, arg interface{}) (*APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	return key, nil
+}
+
+// List returns all API keys, including revoked ones
+func (r *sqlAPIKeyRepository) List(ctx context.Context) ([]APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY name`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Rotate replaces the secret of an active API key
+func (r *sqlAPIKeyRepository) Rotate(ctx context.Context, id, keyHash, keyPrefix string) error {
+	query := `UPDATE api_keys SET key_hash = ?, key_prefix = ?, rotated_at = NOW()
+	          WHERE id = ? AND revoked_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, keyHash, keyPrefix, id)
+	if err != nil {
+		return fmt.Errorf("failed to rotate API key: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("API key not found or revoked")
+	}
+
+	return nil
+}
+
+// Revoke marks an API key as revoked
+func (r *sqlAPIKeyRepository) Revoke(ctx context.Context, id string) error {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = ? AND revoked_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("API key not found or already revoked")
+	}
+
+	return nil
+}
+
+// TouchLastUsed records that an API key was used
+func (r *sqlAPIKeyRepository) TouchLastUsed(ctx context.Context, id string) error {
+	query := `UPDATE api_keys SET last_used_at = NOW() WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to update API key last use: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var key APIKey
+	var scopesJSON []byte
+	var expiresAt, rotatedAt, lastUsedAt, revokedAt sql.NullTime
+
+	if err := row.Scan(&key.ID, &key.Name, &key.KeyHash, &key.KeyPrefix, &scopesJSON, &key.SpiffeIDPrefix,
+		&expiresAt, &key.CreatedBy, &key.CreatedAt, &rotatedAt, &lastUsedAt, &revokedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(scopesJSON, &key.Scopes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal scopes: %w", err)
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if rotatedAt.Valid {
+		key.RotatedAt = &rotatedAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return &key, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -19,34 +19,78 @@ type AuditLogEntry struct {
 	Details      map[string]interface{}
 }
 
-// AuditRepository handles audit log database operations
-type AuditRepository struct {
-	db *sql.DB
+// sqlAuditRepository handles audit log database operations
+type sqlAuditRepository struct {
+	db *sqlDB
 }
 
-// NewAuditRepository creates a new AuditRepository
-func NewAuditRepository(db *sql.DB) *AuditRepository {
-	return &AuditRepository{db: db}
+// NewAuditRepository creates an AuditRepository backed by MySQL or PostgreSQL
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	return &sqlAuditRepository{db: newSQLDB(db)}
 }
 
-// Log creates a new audit log entry
-func (r *AuditRepository) Log(ctx context.Context, actor, action, resourceType, resourceID string, details map[string]interface{}) error {
-	detailsJSON, err := json.Marshal(details)
+// Log creates a new audit log entry and appends it to the hash chain of its
+// stream. Streams are per resource type; appends to a stream are serialized
+// by locking the stream's head row.
+func (r *sqlAuditRepository) Log(ctx context.Context, actor, action, resourceType, resourceID string, details map[string]interface{}) error {
+	detailsJSON, err := canonicalJSON(details)
 	if err != nil {
 		return fmt.Errorf("failed to marshal details: %w", err)
 	}
 
-	query := `INSERT INTO audit_log (actor, action, resource_type, resource_id, details) VALUES (?, ?, ?, ?, ?)`
-	_, err = r.db.ExecContext(ctx, query, actor, action, resourceType, resourceID, detailsJSON)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stream := resourceType
+	createStream := `INSERT IGNORE INTO audit_streams (stream, seq, hash) VALUES (?, 0, ?)`
+	if r.db.dialect == dialectPostgres {
+		createStream = `INSERT INTO audit_streams (stream, seq, hash) VALUES (?, 0, ?) ON CONFLICT (stream) DO NOTHING`
+	}
+	if _, err := tx.ExecContext(ctx, createStream, stream, genesisHash); err != nil {
+		return fmt.Errorf("failed to create audit stream: %w", err)
+	}
+
+	e := chainEntry{
+		Stream:       stream,
+		Timestamp:    time.Now().UTC().Truncate(time.Second),
+		Actor:        actor,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Details:      detailsJSON,
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT seq, hash FROM audit_streams WHERE stream = ? FOR UPDATE`, stream).
+		Scan(&e.Seq, &e.PrevHash); err != nil {
+		return fmt.Errorf("failed to lock audit stream: %w", err)
+	}
+	e.Seq++
+	hash := e.hash()
+
+	query := `INSERT INTO audit_log (timestamp, actor, action, resource_type, resource_id, details, stream, seq, prev_hash, hash)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, e.Timestamp, actor, action, resourceType, resourceID, string(detailsJSON),
+		stream, e.Seq, e.PrevHash, hash)
 	if err != nil {
 		return fmt.Errorf("failed to insert audit log: %w", err)
 	}
 
+	if _, err := tx.ExecContext(ctx, `UPDATE audit_streams SET seq = ?, hash = ? WHERE stream = ?`,
+		e.Seq, hash, stream); err != nil {
+		return fmt.Errorf("failed to advance audit stream: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit audit log: %w", err)
+	}
+
 	return nil
 }
 
 // List returns audit log entries with pagination and optional filters
-func (r *AuditRepository) List(ctx context.Context, pageSize, offset int, resourceType, resourceID, actor string, startTime, endTime *time.Time) ([]AuditLogEntry, error) {
+func (r *sqlAuditRepository) List(ctx context.Context, pageSize, offset int, resourceType, resourceID, actor string, startTime, endTime *time.Time) ([]AuditLogEntry, error) {
 	query := `SELECT id, timestamp, actor, action, resource_type, resource_id, details
 	          FROM audit_log WHERE 1=1`
 	args := []interface{}{}
@@ -76,10 +120,12 @@ func (r *AuditRepository) List(ctx context.Context, pageSize, offset int, resour
 		args = append(args, endTime)
 	}
 
-	query += " ORDER BY timestamp DESC LIMIT ? OFFSET ?"
+	// IDs follow insertion order, and the filter indexes end in id, so no
+	// sort is needed
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
 	args = append(args, pageSize, offset)
 
-	rows, err := r.db.QueryContext(ctx, query, args...)
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list audit logs: %w", err)
 	}
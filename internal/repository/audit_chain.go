@@ -0,0 +1,479 @@
+package repository
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// genesisHash is the previous hash of the first entry in every stream
+var genesisHash = strings.Repeat("0", 64)
+
+// chainEntry is the content of an audit log row covered by its hash
+type chainEntry struct {
+	Stream       string          `json:"stream"`
+	Seq          int64           `json:"seq"`
+	PrevHash     string          `json:"prev_hash"`
+	Timestamp    time.Time       `json:"-"`
+	Unix         int64           `json:"timestamp"`
+	Actor        string          `json:"actor"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	Details      json.RawMessage `json:"details"`
+}
+
+// hash returns the hex SHA-256 of the entry's canonical JSON encoding
+func (e chainEntry) hash() string {
+	e.Unix = e.Timestamp.Unix()
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalJSON encodes v so that it re-encodes identically after a round
+// trip through a MySQL JSON or PostgreSQL JSONB column, which reorder keys
+// and reformat values
+func canonicalJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return recanonicalize(data)
+}
+
+func recanonicalize(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+// LoadCheckpointSigningKey reads a PEM-encoded PKCS#8 Ed25519 private key
+// used to sign audit checkpoints
+func LoadCheckpointSigningKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint signing key: %w", err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("checkpoint signing key must be an Ed25519 key")
+	}
+	return priv, nil
+}
+
+// LoadCheckpointVerifyKey reads a PEM-encoded Ed25519 public key, or the
+// private key it belongs to, used to verify audit checkpoints
+func LoadCheckpointVerifyKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "PRIVATE KEY" {
+		priv, err := LoadCheckpointSigningKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return priv.Public().(ed25519.PublicKey), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint verify key: %w", err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("checkpoint verify key must be an Ed25519 key")
+	}
+	return pub, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	return block, nil
+}
+
+// checkpointKeyID identifies the key that signed a checkpoint
+func checkpointKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// checkpointMessage is the content a checkpoint signature covers
+func checkpointMessage(stream string, seq int64, hash string, createdAt time.Time) []byte {
+	return []byte(fmt.Sprintf("spire-mgmt audit checkpoint\n%s\n%d\n%s\n%d", stream, seq, hash, createdAt.Unix()))
+}
+
+// Checkpoint stores a signed checkpoint of every stream head that has
+// advanced since its last checkpoint. It returns the number of checkpoints
+// written.
+func (r *sqlAuditRepository) Checkpoint(ctx context.Context, key ed25519.PrivateKey) (int, error) {
+	query := `SELECT s.stream, s.seq, s.hash FROM audit_streams s
+	          WHERE s.seq > COALESCE((SELECT MAX(c.seq) FROM audit_checkpoints c WHERE c.stream = s.stream), 0)`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find audit stream heads: %w", err)
+	}
+	type head struct {
+		stream string
+		seq    int64
+		hash   string
+	}
+	var heads []head
+	for rows.Next() {
+		var h head
+		if err := rows.Scan(&h.stream, &h.seq, &h.hash); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan audit stream head: %w", err)
+		}
+		heads = append(heads, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	keyID := checkpointKeyID(key.Public().(ed25519.PublicKey))
+	for i, h := range heads {
+		createdAt := time.Now().UTC().Truncate(time.Second)
+		sig := ed25519.Sign(key, checkpointMessage(h.stream, h.seq, h.hash, createdAt))
+		_, err := r.db.ExecContext(ctx, `INSERT INTO audit_checkpoints (stream, seq, hash, key_id, signature, created_at)
+		                                 VALUES (?, ?, ?, ?, ?, ?)`,
+			h.stream, h.seq, h.hash, keyID, base64.StdEncoding.EncodeToString(sig), createdAt)
+		if err != nil {
+			return i, fmt.Errorf("failed to insert audit checkpoint: %w", err)
+		}
+	}
+
+	return len(heads), nil
+}
+
+// AuditVerification is the result of walking the audit log hash chains
+type AuditVerification struct {
+	Streams     int
+	Entries     int
+	Checkpoints int
+	// Archived counts the entries verified through the records of their
+	// archives rather than read from the log
+	Archived int
+	// FirstBreak is the first broken link found, or nil if every chain is intact
+	FirstBreak *AuditChainBreak
+}
+
+// AuditChainBreak describes where an audit log chain stops verifying
+type AuditChainBreak struct {
+	Stream  string
+	Seq     int64
+	EntryID int64 // zero if the entry is missing
+	Reason  string
+}
+
+type auditCheckpoint struct {
+	seq       int64
+	hash      string
+	keyID     string
+	signature string
+	createdAt time.Time
+}
+
+const verifyBatchSize = 1000
+
+// storedAuditEntry is an audit log row as read back for verification
+type storedAuditEntry struct {
+	chainEntry
+	id         int64
+	details    []byte // as stored, before canonicalization
+	storedHash string
+}
+
+// auditChainReader reads the stored hash chains for verification, so that
+// every storage backend is verified by the same rules
+type auditChainReader interface {
+	// auditStreams returns the streams known from entries, chain heads or
+	// checkpoints, so that a stream whose entries were all deleted is still
+	// checked
+	auditStreams(ctx context.Context) ([]string, error)
+	auditCheckpoints(ctx context.Context, stream string) ([]auditCheckpoint, error)
+	// auditEntries returns up to limit entries of stream after seq, in order
+	auditEntries(ctx context.Context, stream string, afterSeq int64, limit int) ([]storedAuditEntry, error)
+	// auditHead returns the head of stream's chain, if it has one
+	auditHead(ctx context.Context, stream string) (seq int64, hash string, ok bool, err error)
+	// auditArchivedRuns returns the runs of stream's entries that were
+	// archived and deleted, in order
+	auditArchivedRuns(ctx context.Context, stream string) ([]archivedRun, error)
+}
+
+// Verify walks the hash chain of stream, or of every stream if stream is
+// empty, and checks the signed checkpoints against it. Checkpoint and archived
+// run signatures are only checked when key is set; without it, runs recorded
+// as archived are trusted. It stops at the first broken link.
+func (r *sqlAuditRepository) Verify(ctx context.Context, stream string, key ed25519.PublicKey) (*AuditVerification, error) {
+	return verifyAuditChains(ctx, r, stream, key)
+}
+
+func verifyAuditChains(ctx context.Context, src auditChainReader, stream string, key ed25519.PublicKey) (*AuditVerification, error) {
+	streams := []string{stream}
+	if stream == "" {
+		var err error
+		if streams, err = src.auditStreams(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &AuditVerification{}
+	for _, st := range streams {
+		result.Streams++
+		brk, err := verifyStream(ctx, src, st, key, result)
+		if err != nil {
+			return nil, err
+		}
+		if brk != nil {
+			result.FirstBreak = brk
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
+
+func verifyStream(ctx context.Context, src auditChainReader, stream string, key ed25519.PublicKey,
+	result *AuditVerification) (*AuditChainBreak, error) {
+
+	checkpoints, err := src.auditCheckpoints(ctx, stream)
+	if err != nil {
+		return nil, err
+	}
+	keyID := ""
+	if key != nil {
+		keyID = checkpointKeyID(key)
+	}
+	for _, c := range checkpoints {
+		if key == nil {
+			continue
+		}
+		if c.keyID != keyID {
+			return &AuditChainBreak{Stream: stream, Seq: c.seq,
+				Reason: fmt.Sprintf("checkpoint signed by unknown key %s", c.keyID)}, nil
+		}
+		sig, err := base64.StdEncoding.DecodeString(c.signature)
+		if err != nil || !ed25519.Verify(key, checkpointMessage(stream, c.seq, c.hash, c.createdAt), sig) {
+			return &AuditChainBreak{Stream: stream, Seq: c.seq, Reason: "checkpoint signature is invalid"}, nil
+		}
+	}
+	checkpointAt := make(map[int64][]auditCheckpoint)
+	for _, c := range checkpoints {
+		checkpointAt[c.seq] = append(checkpointAt[c.seq], c)
+	}
+
+	runs, err := src.auditArchivedRuns(ctx, stream)
+	if err != nil {
+		return nil, err
+	}
+	runAt := make(map[int64]archivedRun, len(runs))
+	for _, run := range runs {
+		runAt[run.firstSeq] = run
+	}
+
+	prevSeq, prevHash := int64(0), genesisHash
+	// skipArchived follows the chain across archived runs. A run carries the
+	// chain only if it was signed when its entries were archived; otherwise
+	// deleting entries and recording a run in their place would go unseen.
+	// Checkpoints inside a run are checked when its archive is restored.
+	skipArchived := func() *AuditChainBreak {
+		for run, ok := runAt[prevSeq+1]; ok; run, ok = runAt[prevSeq+1] {
+			if key != nil {
+				if run.keyID != keyID {
+					return &AuditChainBreak{Stream: stream, Seq: run.firstSeq,
+						Reason: fmt.Sprintf("archived entries signed by unknown key %s", run.keyID)}
+				}
+				sig, err := base64.StdEncoding.DecodeString(run.signature)
+				if err != nil || !ed25519.Verify(key, archivedRunMessage(stream, run), sig) {
+					return &AuditChainBreak{Stream: stream, Seq: run.firstSeq,
+						Reason: "archived entries signature is invalid"}
+				}
+			}
+			if run.prevHash != prevHash {
+				return &AuditChainBreak{Stream: stream, Seq: run.firstSeq,
+					Reason: "previous hash of archived entries does not match the preceding entry"}
+			}
+			for _, c := range checkpointAt[run.lastSeq] {
+				result.Checkpoints++
+				if c.hash != run.hash {
+					return &AuditChainBreak{Stream: stream, Seq: run.lastSeq,
+						Reason: "archived entry hash does not match signed checkpoint"}
+				}
+			}
+			result.Archived += int(run.lastSeq - run.firstSeq + 1)
+			prevSeq, prevHash = run.lastSeq, run.hash
+		}
+		return nil
+	}
+
+	for {
+		entries, err := src.auditEntries(ctx, stream, prevSeq, verifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if brk := skipArchived(); brk != nil {
+				return brk, nil
+			}
+			result.Entries++
+
+			switch {
+			case e.Seq != prevSeq+1:
+				return &AuditChainBreak{Stream: stream, Seq: prevSeq + 1,
+					Reason: fmt.Sprintf("entry missing: chain jumps from seq %d to %d", prevSeq, e.Seq)}, nil
+			case e.PrevHash != prevHash:
+				return &AuditChainBreak{Stream: stream, Seq: e.Seq, EntryID: e.id,
+					Reason: "previous hash does not match the preceding entry"}, nil
+			}
+			e.Details, err = recanonicalize(e.details)
+			if err != nil || e.hash() != e.storedHash {
+				return &AuditChainBreak{Stream: stream, Seq: e.Seq, EntryID: e.id,
+					Reason: "content hash does not match: entry was modified"}, nil
+			}
+			for _, c := range checkpointAt[e.Seq] {
+				result.Checkpoints++
+				if c.hash != e.storedHash {
+					return &AuditChainBreak{Stream: stream, Seq: e.Seq, EntryID: e.id,
+						Reason: "entry hash does not match signed checkpoint"}, nil
+				}
+			}
+			prevSeq, prevHash = e.Seq, e.storedHash
+		}
+		if len(entries) < verifyBatchSize {
+			break
+		}
+	}
+	if brk := skipArchived(); brk != nil {
+		return brk, nil
+	}
+
+	// Entries removed from the end of the chain leave the head or a
+	// checkpoint pointing past the last entry
+	for seq := range checkpointAt {
+		if seq > prevSeq {
+			return &AuditChainBreak{Stream: stream, Seq: prevSeq + 1,
+				Reason: fmt.Sprintf("entries missing: checkpoint at seq %d but chain ends at seq %d", seq, prevSeq)}, nil
+		}
+	}
+	headSeq, headHash, ok, err := src.auditHead(ctx, stream)
+	switch {
+	case err != nil:
+		return nil, err
+	case !ok:
+		if prevSeq > 0 {
+			return &AuditChainBreak{Stream: stream, Seq: prevSeq, Reason: "stream head is missing"}, nil
+		}
+	case headSeq != prevSeq:
+		return &AuditChainBreak{Stream: stream, Seq: prevSeq + 1,
+			Reason: fmt.Sprintf("entries missing: stream head is at seq %d but chain ends at seq %d", headSeq, prevSeq)}, nil
+	case headHash != prevHash:
+		return &AuditChainBreak{Stream: stream, Seq: prevSeq, Reason: "stream head hash does not match the last entry"}, nil
+	}
+
+	return nil, nil
+}
+
+func (r *sqlAuditRepository) auditStreams(ctx context.Context) ([]string, error) {
+	query := `SELECT DISTINCT stream FROM audit_log
+	          UNION SELECT stream FROM audit_streams
+	          UNION SELECT DISTINCT stream FROM audit_checkpoints
+	          ORDER BY stream`
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit streams: %w", err)
+	}
+	defer rows.Close()
+
+	var streams []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, fmt.Errorf("failed to scan audit stream: %w", err)
+		}
+		streams = append(streams, s)
+	}
+	return streams, rows.Err()
+}
+
+func (r *sqlAuditRepository) auditEntries(ctx context.Context, stream string, afterSeq int64, limit int) ([]storedAuditEntry, error) {
+	query := `SELECT id, timestamp, actor, action, resource_type, resource_id, details, seq, prev_hash, hash
+	          FROM audit_log WHERE stream = ? AND seq > ? ORDER BY seq LIMIT ?`
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query, stream, afterSeq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []storedAuditEntry
+	for rows.Next() {
+		e := storedAuditEntry{chainEntry: chainEntry{Stream: stream}}
+		if err := rows.Scan(&e.id, &e.Timestamp, &e.Actor, &e.Action, &e.ResourceType, &e.ResourceID, &e.details,
+			&e.Seq, &e.PrevHash, &e.storedHash); err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (r *sqlAuditRepository) auditHead(ctx context.Context, stream string) (int64, string, bool, error) {
+	var seq int64
+	var hash string
+	err := r.db.reader(ctx).QueryRowContext(ctx, `SELECT seq, hash FROM audit_streams WHERE stream = ?`, stream).Scan(&seq, &hash)
+	if err == sql.ErrNoRows {
+		return 0, "", false, nil
+	}
+	if err != nil {
+		return 0, "", false, fmt.Errorf("failed to read audit stream head: %w", err)
+	}
+	return seq, hash, true, nil
+}
+
+func (r *sqlAuditRepository) auditCheckpoints(ctx context.Context, stream string) ([]auditCheckpoint, error) {
+	query := `SELECT seq, hash, key_id, signature, created_at FROM audit_checkpoints WHERE stream = ? ORDER BY seq`
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query, stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var checkpoints []auditCheckpoint
+	for rows.Next() {
+		var c auditCheckpoint
+		if err := rows.Scan(&c.seq, &c.hash, &c.keyID, &c.signature, &c.createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit checkpoint: %w", err)
+		}
+		checkpoints = append(checkpoints, c)
+	}
+	return checkpoints, rows.Err()
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// futurePartition is the catch-all partition of audit_log above the monthly
+// ones, which new months are split off from
+const futurePartition = "p_future"
+
+// auditPartition is a monthly range partition of audit_log
+type auditPartition struct {
+	name string
+	// below is the exclusive upper bound in Unix seconds; zero for the
+	// catch-all partition
+	below int64
+}
+
+// partitionName names the partition of the month starting at month
+func partitionName(month time.Time) string {
+	return "p" + month.Format("200601")
+}
+
+// ManagePartitions maintains the monthly partitions of audit_log on MySQL. It
+// adds partitions for the current month and the next ahead months, and drops
+// the partitions of past months that archival has emptied, returning how many
+// it added and dropped. Other databases do not partition audit_log, and for
+// them it does nothing.
+func (r *sqlAuditRepository) ManagePartitions(ctx context.Context, now time.Time, ahead int) (int, int, error) {
+	if r.db.dialect != dialectMySQL {
+		return 0, 0, nil
+	}
+
+	partitions, err := r.auditPartitions(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(partitions) == 0 {
+		return 0, 0, fmt.Errorf("audit_log is not partitioned, apply the schema migrations")
+	}
+
+	var highest int64
+	for _, p := range partitions {
+		highest = max(highest, p.below)
+	}
+
+	now = now.UTC()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var adds []string
+	for i := 0; i <= ahead; i++ {
+		month := thisMonth.AddDate(0, i, 0)
+		below := month.AddDate(0, 1, 0).Unix()
+		if below > highest {
+			adds = append(adds, fmt.Sprintf("PARTITION %s VALUES LESS THAN (%d)", partitionName(month), below))
+		}
+	}
+	added := len(adds)
+	if added > 0 {
+		adds = append(adds, fmt.Sprintf("PARTITION %s VALUES LESS THAN MAXVALUE", futurePartition))
+		query := `ALTER TABLE audit_log REORGANIZE PARTITION ` + futurePartition + ` INTO (` + strings.Join(adds, ", ") + `)`
+		if _, err := r.db.ExecContext(ctx, query); err != nil {
+			return 0, 0, fmt.Errorf("failed to add audit log partitions: %w", err)
+		}
+	}
+
+	// Entries leave a partition as they are archived, each action on its own
+	// schedule; once the last has gone the partition can be dropped
+	dropped := 0
+	for _, p := range partitions {
+		if p.below == 0 || p.below > thisMonth.Unix() {
+			continue
+		}
+		var populated bool
+		query := `SELECT EXISTS (SELECT 1 FROM audit_log PARTITION (` + p.name + `))`
+		if err := r.db.QueryRowContext(ctx, query).Scan(&populated); err != nil {
+			return added, dropped, fmt.Errorf("failed to check audit log partition %s: %w", p.name, err)
+		}
+		if populated {
+			continue
+		}
+		if _, err := r.db.ExecContext(ctx, `ALTER TABLE audit_log DROP PARTITION `+p.name); err != nil {
+			return added, dropped, fmt.Errorf("failed to drop audit log partition %s: %w", p.name, err)
+		}
+		dropped++
+	}
+
+	return added, dropped, nil
+}
+
+// auditPartitions lists the partitions of audit_log in order
+func (r *sqlAuditRepository) auditPartitions(ctx context.Context) ([]auditPartition, error) {
+	query := `SELECT partition_name, partition_description FROM information_schema.partitions
+	          WHERE table_schema = DATABASE() AND table_name = 'audit_log' AND partition_name IS NOT NULL
+	          ORDER BY partition_ordinal_position`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit log partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []auditPartition
+	for rows.Next() {
+		var p auditPartition
+		var description string
+		if err := rows.Scan(&p.name, &description); err != nil {
+			return nil, fmt.Errorf("failed to scan audit log partition: %w", err)
+		}
+		if description != "MAXVALUE" {
+			if p.below, err = strconv.ParseInt(description, 10, 64); err != nil {
+				return nil, fmt.Errorf("unexpected bound %q of audit log partition %s", description, p.name)
+			}
+		}
+		partitions = append(partitions, p)
+	}
+	return partitions, rows.Err()
+}
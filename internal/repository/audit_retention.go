@@ -0,0 +1,357 @@
+package repository
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AuditRetention is how long audit log entries are kept before they are
+// archived and deleted. A zero period keeps entries forever.
+type AuditRetention struct {
+	// Default applies to actions without a period of their own
+	Default time.Duration
+	Actions map[string]time.Duration
+}
+
+// Enabled reports whether any entries expire
+func (r AuditRetention) Enabled() bool {
+	if r.Default > 0 {
+		return true
+	}
+	for _, d := range r.Actions {
+		if d > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Period returns the retention period of action
+func (r AuditRetention) Period(action string) time.Duration {
+	if d, ok := r.Actions[action]; ok {
+		return d
+	}
+	return r.Default
+}
+
+// expired reports whether an entry of action logged at ts has expired at now
+func (r AuditRetention) expired(action string, ts, now time.Time) bool {
+	d := r.Period(action)
+	return d > 0 && ts.Before(now.Add(-d))
+}
+
+// ArchivedAuditEntry is an audit log row with the hash chain fields needed to
+// verify it once it has left the database
+type ArchivedAuditEntry struct {
+	ID           int64           `json:"id"`
+	Stream       string          `json:"stream"`
+	Seq          int64           `json:"seq"`
+	PrevHash     string          `json:"prev_hash"`
+	Hash         string          `json:"hash"`
+	Timestamp    time.Time       `json:"timestamp"`
+	Actor        string          `json:"actor"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	Details      json.RawMessage `json:"details"`
+}
+
+// HashMatches reports whether the entry's content still hashes to its
+// recorded hash
+func (e *ArchivedAuditEntry) HashMatches() bool {
+	details, err := recanonicalize(e.Details)
+	if err != nil {
+		return false
+	}
+	c := chainEntry{
+		Stream:       e.Stream,
+		Seq:          e.Seq,
+		PrevHash:     e.PrevHash,
+		Timestamp:    e.Timestamp,
+		Actor:        e.Actor,
+		Action:       e.Action,
+		ResourceType: e.ResourceType,
+		ResourceID:   e.ResourceID,
+		Details:      details,
+	}
+	return c.hash() == e.Hash
+}
+
+// archivedEntry converts a stored row for archiving
+func archivedEntry(e storedAuditEntry) (ArchivedAuditEntry, error) {
+	details, err := recanonicalize(e.details)
+	if err != nil {
+		return ArchivedAuditEntry{}, fmt.Errorf("failed to canonicalize details of audit entry %d: %w", e.id, err)
+	}
+	return ArchivedAuditEntry{
+		ID:           e.id,
+		Stream:       e.Stream,
+		Seq:          e.Seq,
+		PrevHash:     e.PrevHash,
+		Hash:         e.storedHash,
+		Timestamp:    e.Timestamp,
+		Actor:        e.Actor,
+		Action:       e.Action,
+		ResourceType: e.ResourceType,
+		ResourceID:   e.ResourceID,
+		Details:      details,
+	}, nil
+}
+
+// AuditArchive records an archive of expired audit log entries
+type AuditArchive struct {
+	// Name locates the archive in the archive store
+	Name           string
+	SHA256         string
+	Entries        int
+	FirstTimestamp time.Time
+	LastTimestamp  time.Time
+	CreatedAt      time.Time
+}
+
+// archivedRun is a run of consecutive entries of a stream that were archived
+// and deleted. It keeps the hashes the chain needs to verify across the gap,
+// signed with the checkpoint key so that a run cannot be forged to cover
+// entries deleted from the database.
+type archivedRun struct {
+	firstSeq  int64
+	lastSeq   int64
+	prevHash  string // previous hash of the first entry
+	hash      string // hash of the last entry
+	archive   string
+	keyID     string
+	signature string
+}
+
+// archivedRunMessage is the content an archived run signature covers
+func archivedRunMessage(stream string, run archivedRun) []byte {
+	return []byte(fmt.Sprintf("spire-mgmt audit archived run\n%s\n%d\n%d\n%s\n%s\n%s",
+		stream, run.firstSeq, run.lastSeq, run.prevHash, run.hash, run.archive))
+}
+
+// signedRunsOf groups the entries of archive into runs of consecutive entries
+// per stream, each signed with key
+func signedRunsOf(archive string, entries []ArchivedAuditEntry, key ed25519.PrivateKey) map[string][]archivedRun {
+	runs := archivedRunsOf(entries)
+	keyID := checkpointKeyID(key.Public().(ed25519.PublicKey))
+	for stream, rs := range runs {
+		for i := range rs {
+			rs[i].archive = archive
+			rs[i].keyID = keyID
+			rs[i].signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, archivedRunMessage(stream, rs[i])))
+		}
+	}
+	return runs
+}
+
+// archivedRunsOf groups entries into runs of consecutive entries per stream
+func archivedRunsOf(entries []ArchivedAuditEntry) map[string][]archivedRun {
+	byStream := make(map[string][]ArchivedAuditEntry)
+	for _, e := range entries {
+		byStream[e.Stream] = append(byStream[e.Stream], e)
+	}
+
+	runs := make(map[string][]archivedRun)
+	for stream, es := range byStream {
+		sort.Slice(es, func(i, j int) bool { return es[i].Seq < es[j].Seq })
+		for i, e := range es {
+			rs := runs[stream]
+			if i > 0 && e.Seq == es[i-1].Seq+1 {
+				rs[len(rs)-1].lastSeq, rs[len(rs)-1].hash = e.Seq, e.Hash
+				continue
+			}
+			runs[stream] = append(rs, archivedRun{firstSeq: e.Seq, lastSeq: e.Seq, prevHash: e.PrevHash, hash: e.Hash})
+		}
+	}
+	return runs
+}
+
+// ListExpired returns up to limit audit log entries that have outlived their
+// retention period at now, oldest first
+func (r *sqlAuditRepository) ListExpired(ctx context.Context, retention AuditRetention, now time.Time, limit int) ([]ArchivedAuditEntry, error) {
+	var conds []string
+	var args []interface{}
+	var listed []interface{}
+	for action, d := range retention.Actions {
+		listed = append(listed, action)
+		if d > 0 {
+			conds = append(conds, `(action = ? AND timestamp < ?)`)
+			args = append(args, action, now.Add(-d))
+		}
+	}
+	if retention.Default > 0 {
+		if len(listed) == 0 {
+			conds = append(conds, `timestamp < ?`)
+		} else {
+			placeholders := strings.TrimSuffix(strings.Repeat("?,", len(listed)), ",")
+			conds = append(conds, `(action NOT IN (`+placeholders+`) AND timestamp < ?)`)
+			args = append(args, listed...)
+		}
+		args = append(args, now.Add(-retention.Default))
+	}
+	if len(conds) == 0 {
+		return nil, nil
+	}
+
+	// Archival reads the primary, so nothing is deleted unseen
+	query := `SELECT id, timestamp, actor, action, resource_type, resource_id, details, stream, seq, prev_hash, hash
+	          FROM audit_log WHERE ` + strings.Join(conds, " OR ") + ` ORDER BY id LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []ArchivedAuditEntry
+	for rows.Next() {
+		var e storedAuditEntry
+		if err := rows.Scan(&e.id, &e.Timestamp, &e.Actor, &e.Action, &e.ResourceType, &e.ResourceID, &e.details,
+			&e.Stream, &e.Seq, &e.PrevHash, &e.storedHash); err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		a, err := archivedEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, a)
+	}
+	return entries, rows.Err()
+}
+
+// Prune deletes archived entries from the audit log and records the archive
+// that holds them, with the runs of the hash chains it covers signed by key.
+// It fails without deleting anything if any of the entries is already gone,
+// as when another server archived them first.
+func (r *sqlAuditRepository) Prune(ctx context.Context, archive *AuditArchive, entries []ArchivedAuditEntry,
+	key ed25519.PrivateKey) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if key == nil {
+		return fmt.Errorf("archived audit entries must be signed: no checkpoint signing key")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]interface{}, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	result, err := tx.ExecContext(ctx, `DELETE FROM audit_log WHERE id IN (`+placeholders+`)`, ids...)
+	if err != nil {
+		return fmt.Errorf("failed to delete archived audit entries: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete archived audit entries: %w", err)
+	} else if n != int64(len(entries)) {
+		return fmt.Errorf("audit entries already archived: %d of %d remain", n, len(entries))
+	}
+
+	query := `INSERT INTO audit_archives (name, sha256, entries, first_timestamp, last_timestamp, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, query, archive.Name, archive.SHA256, archive.Entries,
+		archive.FirstTimestamp, archive.LastTimestamp, archive.CreatedAt); err != nil {
+		return fmt.Errorf("failed to record audit archive: %w", err)
+	}
+
+	for stream, runs := range signedRunsOf(archive.Name, entries, key) {
+		for _, run := range runs {
+			query := `INSERT INTO audit_archived_runs (stream, first_seq, last_seq, prev_hash, hash, archive, key_id, signature)
+			          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+			if _, err := tx.ExecContext(ctx, query, stream, run.firstSeq, run.lastSeq, run.prevHash, run.hash,
+				run.archive, run.keyID, run.signature); err != nil {
+				return fmt.Errorf("failed to record archived audit entries: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetArchive returns the record of the named archive
+func (r *sqlAuditRepository) GetArchive(ctx context.Context, name string) (*AuditArchive, error) {
+	query := `SELECT name, sha256, entries, first_timestamp, last_timestamp, created_at
+	          FROM audit_archives WHERE name = ?`
+	var a AuditArchive
+	err := r.db.reader(ctx).QueryRowContext(ctx, query, name).Scan(&a.Name, &a.SHA256, &a.Entries,
+		&a.FirstTimestamp, &a.LastTimestamp, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("audit archive not found: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit archive: %w", err)
+	}
+	return &a, nil
+}
+
+// Restore loads archived entries into the audit_log_restored table for
+// investigation. Entries already restored are skipped. It returns the number
+// of entries loaded.
+func (r *sqlAuditRepository) Restore(ctx context.Context, archive string, entries []ArchivedAuditEntry) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT IGNORE INTO audit_log_restored (id, archive, timestamp, actor, action, resource_type, resource_id,
+	                                                 details, stream, seq, prev_hash, hash)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	if r.db.dialect == dialectPostgres {
+		query = `INSERT INTO audit_log_restored (id, archive, timestamp, actor, action, resource_type, resource_id,
+		                                         details, stream, seq, prev_hash, hash)
+		         VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`
+	}
+	restored := 0
+	for _, e := range entries {
+		result, err := tx.ExecContext(ctx, query, e.ID, archive, e.Timestamp, e.Actor, e.Action, e.ResourceType,
+			e.ResourceID, string(e.Details), e.Stream, e.Seq, e.PrevHash, e.Hash)
+		if err != nil {
+			return 0, fmt.Errorf("failed to restore audit entry %d: %w", e.ID, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			restored++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return restored, nil
+}
+
+func (r *sqlAuditRepository) auditArchivedRuns(ctx context.Context, stream string) ([]archivedRun, error) {
+	query := `SELECT first_seq, last_seq, prev_hash, hash, archive, key_id, signature
+	          FROM audit_archived_runs WHERE stream = ? ORDER BY first_seq`
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query, stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archived audit runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []archivedRun
+	for rows.Next() {
+		var run archivedRun
+		if err := rows.Scan(&run.firstSeq, &run.lastSeq, &run.prevHash, &run.hash, &run.archive, &run.keyID,
+			&run.signature); err != nil {
+			return nil, fmt.Errorf("failed to scan archived audit run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,271 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+)
+
+// The benchmarks measure the queries that grow with the inventory against a
+// database seeded with BENCH_ENTRIES entries (default 100000), each assigned
+// to every one of BENCH_SITES sites (default 20), and as many audit log rows.
+// Like the tests they need TEST_MYSQL_URL or TEST_POSTGRES_URL; the in-memory
+// store scans everything and is not meant for inventories this size. Each
+// benchmark fails if an operation averages over benchTarget.
+//
+//	make bench-db
+
+// benchTarget is the slowest acceptable mean time per operation
+const benchTarget = 50 * time.Millisecond
+
+// benchPageSize matches the service's default page size
+const benchPageSize = 20
+
+type benchEnv struct {
+	store *repository.Store
+	// middle is the cursor halfway through the entry listing
+	middle *repository.EntryCursor
+}
+
+type benchCase struct {
+	name string
+	fn   func(env *benchEnv) error
+}
+
+func BenchmarkEntryList(b *testing.B) {
+	runBenchmarks(b, []benchCase{
+		{"FirstPage", func(env *benchEnv) error {
+			_, _, err := env.store.Entries.List(context.Background(), repository.EntryListOptions{PageSize: benchPageSize})
+			return err
+		}},
+		{"MiddlePage", func(env *benchEnv) error {
+			_, _, err := env.store.Entries.List(context.Background(), repository.EntryListOptions{
+				PageSize: benchPageSize, After: env.middle,
+			})
+			return err
+		}},
+		{"BySite", func(env *benchEnv) error {
+			_, _, err := env.store.Entries.List(context.Background(), repository.EntryListOptions{
+				PageSize: benchPageSize, SiteID: "bench-site-07", After: env.middle,
+			})
+			return err
+		}},
+		{"ByPrefix", func(env *benchEnv) error {
+			_, _, err := env.store.Entries.List(context.Background(), repository.EntryListOptions{
+				PageSize: benchPageSize, SpiffeIDPrefix: "spiffe://example.org/ns-042/",
+			})
+			return err
+		}},
+		{"BySelectorSuperset", func(env *benchEnv) error {
+			_, _, err := env.store.Entries.List(context.Background(), repository.EntryListOptions{
+				PageSize: benchPageSize,
+				BySelectors: &repository.SelectorFilter{
+					Match:     repository.MatchSuperset,
+					Selectors: []repository.Selector{{Type: "k8s", Value: "ns:ns-042"}},
+				},
+			})
+			return err
+		}},
+		{"BySelectorSubset", func(env *benchEnv) error {
+			_, _, err := env.store.Entries.List(context.Background(), repository.EntryListOptions{
+				PageSize: benchPageSize,
+				BySelectors: &repository.SelectorFilter{
+					Match:     repository.MatchSubset,
+					Selectors: []repository.Selector{{Type: "k8s", Value: "ns:ns-042"}, {Type: "k8s", Value: "sa:default"}},
+				},
+			})
+			return err
+		}},
+		{"ByPrefixWithTotal", func(env *benchEnv) error {
+			_, _, err := env.store.Entries.List(context.Background(), repository.EntryListOptions{
+				PageSize: benchPageSize, SpiffeIDPrefix: "spiffe://example.org/ns-042/", WithTotal: true,
+			})
+			return err
+		}},
+	})
+}
+
+func BenchmarkGetPendingEntries(b *testing.B) {
+	runBenchmarks(b, []benchCase{
+		{"Batch100", func(env *benchEnv) error {
+			_, err := env.store.SyncStatus.GetPendingEntries(context.Background(), "bench-site-07", 100)
+			return err
+		}},
+	})
+}
+
+func BenchmarkAuditList(b *testing.B) {
+	runBenchmarks(b, []benchCase{
+		{"Latest", func(env *benchEnv) error {
+			_, err := env.store.Audit.List(context.Background(), 50, 0, "", "", "", nil, nil)
+			return err
+		}},
+		{"ByActor", func(env *benchEnv) error {
+			_, err := env.store.Audit.List(context.Background(), 50, 0, "", "", "user-07", nil, nil)
+			return err
+		}},
+		{"ByResource", func(env *benchEnv) error {
+			_, err := env.store.Audit.List(context.Background(), 50, 0, "entry", "bench-entry-004200", "", nil, nil)
+			return err
+		}},
+		{"ByTimeRange", func(env *benchEnv) error {
+			start := benchEpoch.Add(time.Hour)
+			end := start.Add(time.Hour)
+			_, err := env.store.Audit.List(context.Background(), 50, 0, "", "", "", &start, &end)
+			return err
+		}},
+	})
+}
+
+// runBenchmarks runs each benchmark against every configured database
+func runBenchmarks(b *testing.B, benchmarks []benchCase) {
+	for _, backend := range []struct{ env, driver string }{
+		{"TEST_MYSQL_URL", repository.DriverMySQL},
+		{"TEST_POSTGRES_URL", repository.DriverPostgres},
+	} {
+		b.Run(backend.driver, func(b *testing.B) {
+			env := benchSetup(b, backend.env, backend.driver)
+			for _, bc := range benchmarks {
+				b.Run(bc.name, func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						if err := bc.fn(env); err != nil {
+							b.Fatal(err)
+						}
+					}
+					if mean := b.Elapsed() / time.Duration(b.N); mean > benchTarget {
+						b.Errorf("%s per operation, want at most %s", mean, benchTarget)
+					}
+				})
+			}
+		})
+	}
+}
+
+// benchSetup opens the database, seeding it unless it already holds the
+// benchmark inventory
+func benchSetup(b *testing.B, env, driver string) *benchEnv {
+	db := openTestDB(b, env, driver)
+	entries := benchIntEnv(b, "BENCH_ENTRIES", 100000)
+	sites := benchIntEnv(b, "BENCH_SITES", 20)
+
+	var seeded int
+	db.QueryRow(`SELECT COUNT(*) FROM workload_entries WHERE id LIKE 'bench-entry-%'`).Scan(&seeded)
+	if seeded != entries {
+		resetSchema(b, db, driver)
+		b.Logf("seeding %s with %d entries at %d sites", driver, entries, sites)
+		if err := seedBenchData(db, entries, sites); err != nil {
+			b.Fatal(err)
+		}
+		// Fresh statistics, as a long-running database would have
+		analyze := "ANALYZE"
+		if driver == repository.DriverMySQL {
+			analyze = "ANALYZE TABLE sites, workload_entries, workload_entry_selectors, site_workload_entries, audit_log"
+		}
+		if _, err := db.Exec(analyze); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	// The listing is newest first, so the middle entry has the middle index
+	mid := entries / 2
+	return &benchEnv{
+		store: repository.NewSQLStore(db, nil),
+		middle: &repository.EntryCursor{
+			CreatedAt: benchEpoch.Add(time.Duration(mid) * time.Second),
+			ID:        fmt.Sprintf("bench-entry-%06d", mid),
+		},
+	}
+}
+
+func benchIntEnv(b *testing.B, key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		b.Fatalf("invalid %s %q", key, v)
+	}
+	return n
+}
+
+// benchEpoch is the creation time of the first seeded entry; each later one
+// is a second newer
+var benchEpoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// seedBenchData inserts the benchmark inventory with multi-row literal
+// inserts, which both engines load far faster than row-at-a-time statements.
+// Entries spread over 100 namespaces, and 5% of each site's assignments are
+// pending.
+func seedBenchData(db *sql.DB, entries, sites int) error {
+	ts := func(i int) string {
+		return "'" + benchEpoch.Add(time.Duration(i)*time.Second).Format("2006-01-02 15:04:05") + "'"
+	}
+
+	var rows []string
+	for s := 0; s < sites; s++ {
+		rows = append(rows, fmt.Sprintf("('bench-site-%02d', 'Bench site %02d', 'bench', 'spire-%02d:8081', 'example.org')", s, s, s))
+	}
+	if err := insertRows(db, "sites (id, name, region, spire_server_address, trust_domain)", rows); err != nil {
+		return err
+	}
+
+	rows = rows[:0]
+	for i := 0; i < entries; i++ {
+		rows = append(rows, fmt.Sprintf(
+			`('bench-entry-%06d', 'spiffe://example.org/ns-%03d/svc-%06d', 'spiffe://example.org/agent', '[{"type":"k8s","value":"ns:ns-%03d"}]', 3600, '', 'bench', %s, %s)`,
+			i, i%100, i, i%100, ts(i), ts(i)))
+	}
+	if err := insertRows(db, "workload_entries (id, spiffe_id, parent_id, selectors, ttl, description, created_by, created_at, updated_at)", rows); err != nil {
+		return err
+	}
+
+	rows = rows[:0]
+	for i := 0; i < entries; i++ {
+		rows = append(rows, fmt.Sprintf("('bench-selector-%06d', 'bench-entry-%06d', 'k8s', 'ns:ns-%03d')", i, i, i%100))
+	}
+	if err := insertRows(db, "workload_entry_selectors (id, workload_entry_id, type, value)", rows); err != nil {
+		return err
+	}
+
+	for s := 0; s < sites; s++ {
+		rows = rows[:0]
+		for i := 0; i < entries; i++ {
+			if i%20 == s%20 {
+				rows = append(rows, fmt.Sprintf("('bench-site-%02d', 'bench-entry-%06d', 'pending', NULL, %s)", s, i, ts(i)))
+			} else {
+				rows = append(rows, fmt.Sprintf("('bench-site-%02d', 'bench-entry-%06d', 'synced', 'spire-%06d', %s)", s, i, i, ts(i)))
+			}
+		}
+		if err := insertRows(db, "site_workload_entries (site_id, workload_entry_id, sync_status, spire_entry_id, queued_at)", rows); err != nil {
+			return err
+		}
+	}
+
+	// Audit rows are not chained; the listing never checks hashes
+	rows = rows[:0]
+	hash := "'" + strings.Repeat("0", 64) + "'"
+	for i := 0; i < entries; i++ {
+		rows = append(rows, fmt.Sprintf(`(%s, 'user-%02d', 'create', 'entry', 'bench-entry-%06d', '{}', 'bench', %d, %s, %s)`,
+			ts(i), i%50, i, i+1, hash, hash))
+	}
+	return insertRows(db, "audit_log (timestamp, actor, action, resource_type, resource_id, details, stream, seq, prev_hash, hash)", rows)
+}
+
+func insertRows(db *sql.DB, table string, rows []string) error {
+	const batch = 1000
+	for start := 0; start < len(rows); start += batch {
+		query := "INSERT INTO " + table + " VALUES " + strings.Join(rows[start:min(start+batch, len(rows))], ", ")
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to seed %s: %w", strings.Fields(table)[0], err)
+		}
+	}
+	return nil
+}
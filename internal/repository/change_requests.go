@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Change request operations
+const (
+	ChangeOpAssign = "assign"
+	ChangeOpDelete = "delete"
+)
+
+// Change request states
+const (
+	ChangePendingApproval = "pending_approval"
+	ChangeApproved        = "approved"
+	ChangeRejected        = "rejected"
+	ChangeApplied         = "applied"
+	ChangeFailed          = "failed"
+)
+
+// ChangeRequest is an entry mutation aimed at protected sites that waits for
+// a second person's approval before it is applied
+type ChangeRequest struct {
+	ID              string
+	Operation       string
+	WorkloadEntryID string
+	SiteIDs         []string
+	Status          string
+	RequestedBy     string
+	ReviewedBy      string
+	ReviewComment   string
+	ApplyError      string
+	CreatedAt       time.Time
+	ReviewedAt      *time.Time
+}
+
+// sqlChangeRequestRepository handles change request database operations
+type sqlChangeRequestRepository struct {
+	db *sqlDB
+}
+
+// NewChangeRequestRepository creates a ChangeRequestRepository backed by MySQL or PostgreSQL
+func NewChangeRequestRepository(db *sql.DB) ChangeRequestRepository {
+	return &sqlChangeRequestRepository{db: newSQLDB(db)}
+}
+
+const changeRequestColumns = `id, operation, workload_entry_id, site_ids, status, requested_by,
+	COALESCE(reviewed_by, ''), COALESCE(review_comment, ''), COALESCE(apply_error, ''), created_at, reviewed_at`
+
+// Create inserts a new change request in pending_approval state
+func (r *sqlChangeRequestRepository) Create(ctx context.Context, c *ChangeRequest) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	c.Status = ChangePendingApproval
+
+	siteIDs, err := json.Marshal(nonNil(c.SiteIDs))
+	if err != nil {
+		return fmt.Errorf("failed to marshal site IDs: %w", err)
+	}
+
+	query := `INSERT INTO change_requests (id, operation, workload_entry_id, site_ids, status, requested_by)
+	          VALUES (?, ?, ?, ?, ?, ?)`
+	_, err = r.db.ExecContext(ctx, query, c.ID, c.Operation, c.WorkloadEntryID, string(siteIDs), c.Status, c.RequestedBy)
+	if err != nil {
+		return fmt.Errorf("failed to insert change request: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns a change request by ID
+func (r *sqlChangeRequestRepository) Get(ctx context.Context, id string) (*ChangeRequest, error) {
+	query := `SELECT ` + changeRequestColumns + ` FROM change_requests WHERE id = ?`
+
+	c, err := scanChangeRequest(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get change request: %w", err)
+	}
+
+	return c, nil
+}
+
+// List returns change requests, newest first, optionally filtered by status
+// and workload entry
+func (r *sqlChangeRequestRepository) List(ctx context.Context, status, entryID string) ([]ChangeRequest, error) {
+	query := `SELECT ` + changeRequestColumns + ` FROM change_requests WHERE 1=1`
+	args := []interface{}{}
+
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	if entryID != "" {
+		query += " AND workload_entry_id = ?"
+		args = append(args, entryID)
+	}
+
+	query += " ORDER BY created_at DESC"
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list change requests: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []ChangeRequest
+	for rows.Next() {
+		c, err := scanChangeRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan change request: %w", err)
+		}
+		changes = append(changes, *c)
+	}
+
+	return changes, rows.Err()
+}
+
+// Review moves a pending change request to approved or rejected. It reports
+// false if the request was no longer pending, so that concurrent reviews
+// cannot both succeed.
+func (r *sqlChangeRequestRepository) Review(ctx context.Context, id, status, reviewer, comment string) (bool, error) {
+	query := `UPDATE change_requests SET status = ?, reviewed_by = ?, review_comment = ?, reviewed_at = NOW()
+	          WHERE id = ? AND status = ?`
+	result, err := r.db.ExecContext(ctx, query, status, reviewer, comment, id, ChangePendingApproval)
+	if err != nil {
+		return false, fmt.Errorf("failed to review change request: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// MarkApplied records that an approved change request was applied
+func (r *sqlChangeRequestRepository) MarkApplied(ctx context.Context, id string) error {
+	query := `UPDATE change_requests SET status = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, ChangeApplied, id); err != nil {
+		return fmt.Errorf("failed to mark change request applied: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records that applying an approved change request failed
+func (r *sqlChangeRequestRepository) MarkFailed(ctx context.Context, id, applyError string) error {
+	query := `UPDATE change_requests SET status = ?, apply_error = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, ChangeFailed, applyError, id); err != nil {
+		return fmt.Errorf("failed to mark change request failed: %w", err)
+	}
+	return nil
+}
+
+func scanChangeRequest(row rowScanner) (*ChangeRequest, error) {
+	var c ChangeRequest
+	var siteIDs []byte
+
+	if err := row.Scan(&c.ID, &c.Operation, &c.WorkloadEntryID, &siteIDs, &c.Status, &c.RequestedBy,
+		&c.ReviewedBy, &c.ReviewComment, &c.ApplyError, &c.CreatedAt, &c.ReviewedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(siteIDs, &c.SiteIDs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal site IDs: %w", err)
+	}
+
+	return &c, nil
+}
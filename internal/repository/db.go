@@ -0,0 +1,349 @@
+package repository
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// Config holds database configuration
+type Config struct {
+	// Driver is DriverMySQL or DriverPostgres
+	Driver string
+
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Database string
+
+	// ReplicaHost and ReplicaPort, if set, address a read replica of the
+	// database, reached with the same credentials and TLS settings
+	ReplicaHost string
+	ReplicaPort string
+
+	// PasswordFile, if set, is read for every new connection so that a
+	// rotated password takes effect without a restart. It overrides Password.
+	PasswordFile string
+
+	// TLS to the server is enabled by TLSEnabled or any of the TLS files. The
+	// server certificate is verified against TLSCAFile, or the system roots,
+	// and TLSServerName, which defaults to Host.
+	TLSEnabled    bool
+	TLSCAFile     string
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSServerName string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+
+	// ConnectRetryTimeout bounds how long NewDB retries the first connection
+	ConnectRetryTimeout time.Duration
+}
+
+// ConfigFromEnv creates Config from environment variables
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Driver:        getEnv("DB_DRIVER", DriverMySQL),
+		Host:          getEnv("DB_HOST", "localhost"),
+		User:          getEnv("DB_USER", "root"),
+		Password:      os.Getenv("DB_PASSWORD"),
+		Database:      getEnv("DB_NAME", "spire_mgmt"),
+		ReplicaHost:   os.Getenv("DB_REPLICA_HOST"),
+		PasswordFile:  os.Getenv("DB_PASSWORD_FILE"),
+		TLSCAFile:     os.Getenv("DB_TLS_CA_FILE"),
+		TLSCertFile:   os.Getenv("DB_TLS_CERT_FILE"),
+		TLSKeyFile:    os.Getenv("DB_TLS_KEY_FILE"),
+		TLSServerName: os.Getenv("DB_TLS_SERVER_NAME"),
+	}
+	switch cfg.Driver {
+	case DriverMySQL:
+		cfg.Port = getEnv("DB_PORT", "3306")
+	case DriverPostgres:
+		cfg.Port = getEnv("DB_PORT", "5432")
+	default:
+		return cfg, fmt.Errorf("invalid DB_DRIVER %q, expected %s or %s", cfg.Driver, DriverMySQL, DriverPostgres)
+	}
+	cfg.ReplicaPort = getEnv("DB_REPLICA_PORT", cfg.Port)
+	if cfg.Password == "" && cfg.PasswordFile == "" {
+		return cfg, fmt.Errorf("DB_PASSWORD or DB_PASSWORD_FILE must be set")
+	}
+
+	var err error
+	if v := os.Getenv("DB_TLS"); v != "" {
+		if cfg.TLSEnabled, err = strconv.ParseBool(v); err != nil {
+			return cfg, fmt.Errorf("invalid DB_TLS %q", v)
+		}
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return cfg, fmt.Errorf("DB_TLS_CERT_FILE and DB_TLS_KEY_FILE must be set together")
+	}
+
+	ints := []struct {
+		key string
+		dst *int
+		def int
+	}{
+		{"DB_MAX_OPEN_CONNS", &cfg.MaxOpenConns, 25},
+		{"DB_MAX_IDLE_CONNS", &cfg.MaxIdleConns, 5},
+	}
+	for _, v := range ints {
+		if *v.dst, err = intFromEnv(v.key, v.def); err != nil {
+			return cfg, err
+		}
+	}
+
+	durations := []struct {
+		key string
+		dst *time.Duration
+		def time.Duration
+	}{
+		{"DB_CONN_MAX_LIFETIME_SECONDS", &cfg.ConnMaxLifetime, 5 * time.Minute},
+		{"DB_CONN_MAX_IDLE_TIME_SECONDS", &cfg.ConnMaxIdleTime, 0},
+		{"DB_DIAL_TIMEOUT_SECONDS", &cfg.DialTimeout, 10 * time.Second},
+		{"DB_READ_TIMEOUT_SECONDS", &cfg.ReadTimeout, 30 * time.Second},
+		{"DB_WRITE_TIMEOUT_SECONDS", &cfg.WriteTimeout, 30 * time.Second},
+		{"DB_CONNECT_RETRY_SECONDS", &cfg.ConnectRetryTimeout, time.Minute},
+	}
+	for _, v := range durations {
+		n, err := intFromEnv(v.key, int(v.def/time.Second))
+		if err != nil {
+			return cfg, err
+		}
+		*v.dst = time.Duration(n) * time.Second
+	}
+
+	return cfg, nil
+}
+
+// Replica returns the configuration for the read replica, and false if none
+// is configured
+func (c Config) Replica() (Config, bool) {
+	if c.ReplicaHost == "" {
+		return c, false
+	}
+	replica := c
+	replica.Host, replica.Port = c.ReplicaHost, c.ReplicaPort
+	replica.ReplicaHost, replica.ReplicaPort = "", ""
+	// A server name set for the primary does not name the replica
+	replica.TLSServerName = ""
+	return replica, true
+}
+
+// NewDB creates a new database connection pool. The first connection is
+// retried with exponential backoff for up to cfg.ConnectRetryTimeout, so the
+// server can start before the database is reachable.
+func NewDB(cfg Config) (*sql.DB, error) {
+	connector, err := newConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+
+	// Configure connection pool
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	// Test connection
+	deadline := time.Now().Add(cfg.ConnectRetryTimeout)
+	backoff := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout+5*time.Second)
+		err = db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return db, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			db.Close()
+			return nil, fmt.Errorf("failed to ping database after %d attempts: %w", attempt, err)
+		}
+		log.Printf("Database not reachable (attempt %d), retrying in %s: %v", attempt, backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// connector opens connections with the current password
+type connector struct {
+	// open returns the driver's connector for a password
+	open         func(password string) (driver.Connector, error)
+	driver       driver.Driver
+	password     string
+	passwordFile string
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+func newConnector(cfg Config) (*connector, error) {
+	var tlsConfig *tls.Config
+	if cfg.TLSEnabled || cfg.TLSCAFile != "" || cfg.TLSCertFile != "" {
+		var err error
+		if tlsConfig, err = newTLSConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &connector{password: cfg.Password, passwordFile: cfg.PasswordFile}
+	switch cfg.Driver {
+	case DriverPostgres:
+		c.open = postgresOpener(cfg, tlsConfig)
+		c.driver = &pq.Driver{}
+	default:
+		c.open = mysqlOpener(cfg, tlsConfig)
+		c.driver = &mysql.MySQLDriver{}
+	}
+
+	if c.passwordFile != "" {
+		if _, err := c.currentPassword(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// mysqlOpener returns a function creating MySQL connectors
+func mysqlOpener(cfg Config, tlsConfig *tls.Config) func(password string) (driver.Connector, error) {
+	base := mysql.NewConfig()
+	base.User = cfg.User
+	base.Net = "tcp"
+	base.Addr = net.JoinHostPort(cfg.Host, cfg.Port)
+	base.DBName = cfg.Database
+	base.ParseTime = true
+	base.Loc = time.UTC
+	base.Timeout = cfg.DialTimeout
+	base.ReadTimeout = cfg.ReadTimeout
+	base.WriteTimeout = cfg.WriteTimeout
+	base.TLS = tlsConfig
+	// Report rows matched rather than rows changed by an UPDATE, as
+	// PostgreSQL does, so repositories can tell a no-op from a missing row
+	base.ClientFoundRows = true
+
+	return func(password string) (driver.Connector, error) {
+		mc := base.Clone()
+		mc.Passwd = password
+		return mysql.NewConnector(mc)
+	}
+}
+
+// Connect implements driver.Connector
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	password := c.password
+	if c.passwordFile != "" {
+		var err error
+		if password, err = c.currentPassword(); err != nil {
+			return nil, err
+		}
+	}
+
+	dc, err := c.open(password)
+	if err != nil {
+		return nil, err
+	}
+	return dc.Connect(ctx)
+}
+
+// Driver implements driver.Connector
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// currentPassword returns the password file's contents, re-reading it when
+// the file has changed
+func (c *connector) currentPassword() (string, error) {
+	info, err := os.Stat(c.passwordFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read database password file: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.modTime.IsZero() && info.ModTime().Equal(c.modTime) {
+		return c.password, nil
+	}
+	data, err := os.ReadFile(c.passwordFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read database password file: %w", err)
+	}
+	if !c.modTime.IsZero() {
+		log.Printf("Database password file %s changed, using new password for new connections", c.passwordFile)
+	}
+	c.password = strings.TrimRight(string(data), "\r\n")
+	c.modTime = info.ModTime()
+	return c.password, nil
+}
+
+// newTLSConfig builds the TLS configuration for connections to the server
+func newTLSConfig(cfg Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.TLSServerName,
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = cfg.Host
+	}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read database CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in database CA file %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load database client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func intFromEnv(key string, defaultValue int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, v)
+	}
+	return n, nil
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dbEnv lists the variables ConfigFromEnv reads
+var dbEnv = []string{
+	"DB_DRIVER", "DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_PASSWORD_FILE", "DB_NAME",
+	"DB_REPLICA_HOST", "DB_REPLICA_PORT",
+	"DB_TLS", "DB_TLS_CA_FILE", "DB_TLS_CERT_FILE", "DB_TLS_KEY_FILE", "DB_TLS_SERVER_NAME",
+	"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS",
+	"DB_CONN_MAX_LIFETIME_SECONDS", "DB_CONN_MAX_IDLE_TIME_SECONDS",
+	"DB_DIAL_TIMEOUT_SECONDS", "DB_READ_TIMEOUT_SECONDS", "DB_WRITE_TIMEOUT_SECONDS",
+	"DB_CONNECT_RETRY_SECONDS",
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+		check   func(t *testing.T, cfg Config)
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{"DB_PASSWORD": "secret"},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Driver != DriverMySQL || cfg.Port != "3306" || cfg.ReplicaPort != "3306" {
+					t.Errorf("driver %s port %s replica port %s, want mysql on 3306", cfg.Driver, cfg.Port, cfg.ReplicaPort)
+				}
+				if cfg.MaxOpenConns != 25 || cfg.ConnMaxLifetime != 5*time.Minute || cfg.ConnectRetryTimeout != time.Minute {
+					t.Errorf("pool defaults %d %s %s", cfg.MaxOpenConns, cfg.ConnMaxLifetime, cfg.ConnectRetryTimeout)
+				}
+				if cfg.TLSEnabled {
+					t.Error("TLS enabled by default")
+				}
+			},
+		},
+		{
+			name: "postgres default port",
+			env:  map[string]string{"DB_DRIVER": DriverPostgres, "DB_PASSWORD": "secret", "DB_REPLICA_HOST": "replica"},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.Port != "5432" || cfg.ReplicaPort != "5432" {
+					t.Errorf("port %s replica port %s, want 5432", cfg.Port, cfg.ReplicaPort)
+				}
+			},
+		},
+		{
+			name:    "unknown driver",
+			env:     map[string]string{"DB_DRIVER": "sqlite", "DB_PASSWORD": "secret"},
+			wantErr: "invalid DB_DRIVER",
+		},
+		{
+			name:    "no password",
+			env:     map[string]string{},
+			wantErr: "DB_PASSWORD or DB_PASSWORD_FILE must be set",
+		},
+		{
+			name: "password file only",
+			env:  map[string]string{"DB_PASSWORD_FILE": "/run/secrets/db"},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.PasswordFile != "/run/secrets/db" {
+					t.Errorf("password file %q", cfg.PasswordFile)
+				}
+			},
+		},
+		{
+			name:    "client certificate without key",
+			env:     map[string]string{"DB_PASSWORD": "secret", "DB_TLS_CERT_FILE": "client.crt"},
+			wantErr: "DB_TLS_CERT_FILE and DB_TLS_KEY_FILE must be set together",
+		},
+		{
+			name:    "client key without certificate",
+			env:     map[string]string{"DB_PASSWORD": "secret", "DB_TLS_KEY_FILE": "client.key"},
+			wantErr: "DB_TLS_CERT_FILE and DB_TLS_KEY_FILE must be set together",
+		},
+		{
+			name: "client certificate and key",
+			env:  map[string]string{"DB_PASSWORD": "secret", "DB_TLS_CERT_FILE": "client.crt", "DB_TLS_KEY_FILE": "client.key", "DB_TLS": "true"},
+			check: func(t *testing.T, cfg Config) {
+				if !cfg.TLSEnabled || cfg.TLSCertFile != "client.crt" || cfg.TLSKeyFile != "client.key" {
+					t.Errorf("TLS %v cert %q key %q", cfg.TLSEnabled, cfg.TLSCertFile, cfg.TLSKeyFile)
+				}
+			},
+		},
+		{
+			name:    "invalid DB_TLS",
+			env:     map[string]string{"DB_PASSWORD": "secret", "DB_TLS": "maybe"},
+			wantErr: "invalid DB_TLS",
+		},
+		{
+			name:    "negative pool size",
+			env:     map[string]string{"DB_PASSWORD": "secret", "DB_MAX_OPEN_CONNS": "-1"},
+			wantErr: "invalid DB_MAX_OPEN_CONNS",
+		},
+		{
+			name: "timeouts in seconds",
+			env:  map[string]string{"DB_PASSWORD": "secret", "DB_READ_TIMEOUT_SECONDS": "7"},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.ReadTimeout != 7*time.Second {
+					t.Errorf("read timeout %s, want 7s", cfg.ReadTimeout)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range dbEnv {
+				t.Setenv(key, tt.env[key])
+			}
+			cfg, err := ConfigFromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConfigFromEnv: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestCurrentPasswordRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	write := func(password string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(password+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write("first", start)
+
+	c, err := newConnector(Config{Driver: DriverMySQL, PasswordFile: path})
+	if err != nil {
+		t.Fatalf("newConnector: %v", err)
+	}
+	steps := []struct {
+		name    string
+		write   string
+		modTime time.Time
+		want    string
+	}{
+		{name: "read at startup", want: "first"},
+		{name: "unchanged file", want: "first"},
+		{name: "rotated password", write: "second", modTime: start.Add(time.Minute), want: "second"},
+		{name: "rotated again", write: "third", modTime: start.Add(2 * time.Minute), want: "third"},
+	}
+	for _, step := range steps {
+		if step.write != "" {
+			write(step.write, step.modTime)
+		}
+		got, err := c.currentPassword()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: password %q, want %q", step.name, got, step.want)
+		}
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.currentPassword(); err == nil {
+		t.Error("missing password file accepted")
+	}
+}
+
+func TestNewConnectorRequiresPasswordFile(t *testing.T) {
+	_, err := newConnector(Config{Driver: DriverMySQL, PasswordFile: filepath.Join(t.TempDir(), "missing")})
+	if err == nil {
+		t.Error("missing password file accepted")
+	}
+}
+
+func TestTLSServerName(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		wantPrimary string
+		wantReplica string
+	}{
+		{
+			name:        "defaults to each host",
+			cfg:         Config{Host: "primary.db", ReplicaHost: "replica.db"},
+			wantPrimary: "primary.db",
+			wantReplica: "replica.db",
+		},
+		{
+			// The primary's name would fail verification of the replica
+			name:        "server name set for the primary",
+			cfg:         Config{Host: "10.0.0.1", ReplicaHost: "replica.db", TLSServerName: "primary.db"},
+			wantPrimary: "primary.db",
+			wantReplica: "replica.db",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, err := newTLSConfig(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if primary.ServerName != tt.wantPrimary {
+				t.Errorf("primary server name %q, want %q", primary.ServerName, tt.wantPrimary)
+			}
+
+			replicaCfg, ok := tt.cfg.Replica()
+			if !ok {
+				t.Fatal("replica not configured")
+			}
+			replica, err := newTLSConfig(replicaCfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replica.ServerName != tt.wantReplica {
+				t.Errorf("replica server name %q, want %q", replica.ServerName, tt.wantReplica)
+			}
+		})
+	}
+
+	if _, ok := (Config{Host: "primary.db"}).Replica(); ok {
+		t.Error("replica configured without DB_REPLICA_HOST")
+	}
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Database drivers supported by NewDB
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+)
+
+// dialect is the SQL dialect of the database behind a repository
+type dialect int
+
+const (
+	dialectMySQL dialect = iota
+	dialectPostgres
+)
+
+// sqlDB runs queries written with MySQL's ? placeholders against either
+// engine. The few statements that differ between engines switch on dialect.
+// Inside a unit of work every query runs on the unit's transaction.
+type sqlDB struct {
+	*sql.DB
+	dialect dialect
+	tx      *sql.Tx
+	// replica, if set, serves the reads made through reader
+	replica *sqlDB
+}
+
+func newSQLDB(db *sql.DB) *sqlDB {
+	d := dialectMySQL
+	if _, ok := db.Driver().(*pq.Driver); ok {
+		d = dialectPostgres
+	}
+	return &sqlDB{DB: db, dialect: d}
+}
+
+// withReplica returns a sqlDB that sends the reads made through reader to
+// replica
+func (db *sqlDB) withReplica(replica *sql.DB) *sqlDB {
+	return &sqlDB{DB: db.DB, dialect: db.dialect, replica: &sqlDB{DB: replica, dialect: db.dialect}}
+}
+
+// withTx returns a sqlDB that runs every query on tx
+func (db *sqlDB) withTx(tx *sqlTx) *sqlDB {
+	return &sqlDB{DB: db.DB, dialect: db.dialect, tx: tx.Tx}
+}
+
+// ExecContext executes a query without returning any rows
+func (db *sqlDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if db.tx != nil {
+		return db.tx.ExecContext(ctx, db.dialect.rebind(query), args...)
+	}
+	markWritten(ctx)
+	return db.DB.ExecContext(ctx, db.dialect.rebind(query), args...)
+}
+
+// QueryContext executes a query that returns rows
+func (db *sqlDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if db.tx != nil {
+		return db.tx.QueryContext(ctx, db.dialect.rebind(query), args...)
+	}
+	return db.DB.QueryContext(ctx, db.dialect.rebind(query), args...)
+}
+
+// QueryRowContext executes a query that returns at most one row
+func (db *sqlDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if db.tx != nil {
+		return db.tx.QueryRowContext(ctx, db.dialect.rebind(query), args...)
+	}
+	return db.DB.QueryRowContext(ctx, db.dialect.rebind(query), args...)
+}
+
+// BeginTx starts a transaction. Inside a unit of work it joins the unit's
+// transaction instead, which only the unit commits or rolls back.
+func (db *sqlDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sqlTx, error) {
+	if db.tx != nil {
+		return &sqlTx{Tx: db.tx, dialect: db.dialect, joined: true}, nil
+	}
+	markWritten(ctx)
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, dialect: db.dialect}, nil
+}
+
+// sqlTx is a transaction that rewrites placeholders like sqlDB
+type sqlTx struct {
+	*sql.Tx
+	dialect dialect
+	// joined marks a unit of work's transaction, which outlives this use
+	joined bool
+}
+
+// Commit commits the transaction, unless it belongs to a unit of work
+func (tx *sqlTx) Commit() error {
+	if tx.joined {
+		return nil
+	}
+	return tx.Tx.Commit()
+}
+
+// Rollback aborts the transaction, unless it belongs to a unit of work
+func (tx *sqlTx) Rollback() error {
+	if tx.joined {
+		return nil
+	}
+	return tx.Tx.Rollback()
+}
+
+// ExecContext executes a query without returning any rows
+func (tx *sqlTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, tx.dialect.rebind(query), args...)
+}
+
+// QueryContext executes a query that returns rows
+func (tx *sqlTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, tx.dialect.rebind(query), args...)
+}
+
+// QueryRowContext executes a query that returns at most one row
+func (tx *sqlTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.dialect.rebind(query), args...)
+}
+
+// rebind rewrites the ? placeholders of query into the dialect's form.
+// Question marks inside quoted literals are left alone.
+func (d dialect) rebind(query string) string {
+	if d != dialectPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	inQuote := false
+	for _, c := range query {
+		switch {
+		case c == '\'':
+			inQuote = !inQuote
+		case c == '?' && !inQuote:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -5,9 +5,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/yourorg/spire-workload-mgmt/internal/notify"
 )
 
 // Selector represents a workload selector
@@ -27,6 +29,9 @@ type WorkloadEntry struct {
 	CreatedBy   string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+	// DeletedAt is set once the entry is deleted. The tombstone remains until
+	// every site has removed the entry or it is purged.
+	DeletedAt *time.Time
 }
 
 // SiteWorkloadEntry represents the sync status of an entry at a site
@@ -46,18 +51,52 @@ type WorkloadEntryWithSites struct {
 	SiteStatuses []SiteWorkloadEntry
 }
 
-// EntryRepository handles workload entry database operations
-type EntryRepository struct {
-	db *sql.DB
+// EntryListOptions selects a page of workload entries for
+// EntryRepository.List. Entries are listed newest first.
+type EntryListOptions struct {
+	PageSize       int
+	SiteID         string
+	SpiffeIDPrefix string
+	// SpiffeIDScope limits the listing to SPIFFE IDs equal to or below this
+	// path, whatever SpiffeIDPrefix selects
+	SpiffeIDScope string
+	BySelectors   *SelectorFilter
+	// Deleting lists deleted entries whose removal from sites is still in
+	// progress instead of live entries
+	Deleting bool
+	// After continues the listing after the last entry of the previous page
+	After *EntryCursor
+	// WithTotal requests the number of matching entries, which costs a scan
+	// of all of them; otherwise List returns -1 as the total
+	WithTotal bool
 }
 
-// NewEntryRepository creates a new EntryRepository
-func NewEntryRepository(db *sql.DB) *EntryRepository {
-	return &EntryRepository{db: db}
+// EntryCursor is the position of an entry in the listing order
+type EntryCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// CursorOf returns the listing position of e
+func CursorOf(e *WorkloadEntry) *EntryCursor {
+	return &EntryCursor{CreatedAt: e.CreatedAt, ID: e.ID}
+}
+
+// sqlEntryRepository handles workload entry database operations
+type sqlEntryRepository struct {
+	db  *sqlDB
+	hub *notify.Hub
+}
+
+// NewEntryRepository creates an EntryRepository backed by MySQL or
+// PostgreSQL. The hub is notified of the affected sites after every committed
+// write; it may be nil.
+func NewEntryRepository(db *sql.DB, hub *notify.Hub) EntryRepository {
+	return &sqlEntryRepository{db: newSQLDB(db), hub: hub}
 }
 
 // Create creates a new workload entry with site assignments
-func (r *EntryRepository) Create(ctx context.Context, entry *WorkloadEntry, siteIDs []string) (*WorkloadEntry, error) {
+func (r *sqlEntryRepository) Create(ctx context.Context, entry *WorkloadEntry, siteIDs []string) (*WorkloadEntryWithSites, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
@@ -79,11 +118,16 @@ func (r *EntryRepository) Create(ctx context.Context, entry *WorkloadEntry, site
 	query := `INSERT INTO workload_entries (id, spiffe_id, parent_id, selectors, ttl, description, created_by)
 	          VALUES (?, ?, ?, ?, ?, ?, ?)`
 	_, err = tx.ExecContext(ctx, query, entry.ID, entry.SpiffeID, entry.ParentID,
-		selectorsJSON, entry.TTL, entry.Description, entry.CreatedBy)
+		string(selectorsJSON), entry.TTL, entry.Description, entry.CreatedBy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert workload entry: %w", err)
 	}
 
+	// Index the selectors for lookups by selector
+	if err := insertSelectors(ctx, tx, entry.ID, entry.Selectors); err != nil {
+		return nil, err
+	}
+
 	// Create site assignments with pending status
 	if len(siteIDs) > 0 {
 		assignQuery := `INSERT INTO site_workload_entries (site_id, workload_entry_id, sync_status) VALUES (?, ?, 'pending')`
@@ -99,21 +143,24 @@ func (r *EntryRepository) Create(ctx context.Context, entry *WorkloadEntry, site
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	// Wake agents waiting for work at the assigned sites
+	r.hub.Notify(siteIDs...)
+
 	// Return the created entry
 	return r.Get(ctx, entry.ID)
 }
 
 // Get returns a workload entry by ID with its site statuses
-func (r *EntryRepository) Get(ctx context.Context, id string) (*WorkloadEntryWithSites, error) {
+func (r *sqlEntryRepository) Get(ctx context.Context, id string) (*WorkloadEntryWithSites, error) {
 	// Get entry
-	query := `SELECT id, spiffe_id, parent_id, selectors, ttl, description, created_by, created_at, updated_at
+	query := `SELECT id, spiffe_id, parent_id, selectors, ttl, description, created_by, created_at, updated_at, deleted_at
 	          FROM workload_entries WHERE id = ?`
 
 	var entry WorkloadEntry
 	var selectorsJSON []byte
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := r.db.reader(ctx).QueryRowContext(ctx, query, id).Scan(
 		&entry.ID, &entry.SpiffeID, &entry.ParentID, &selectorsJSON,
-		&entry.TTL, &entry.Description, &entry.CreatedBy, &entry.CreatedAt, &entry.UpdatedAt)
+		&entry.TTL, &entry.Description, &entry.CreatedBy, &entry.CreatedAt, &entry.UpdatedAt, &entry.DeletedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -138,49 +185,73 @@ func (r *EntryRepository) Get(ctx context.Context, id string) (*WorkloadEntryWit
 	}, nil
 }
 
-// List returns workload entries with pagination
-func (r *EntryRepository) List(ctx context.Context, pageSize int, offset int, siteID string, spiffeIDPrefix string) ([]WorkloadEntryWithSites, int, error) {
+// List returns a page of workload entries, newest first
+func (r *sqlEntryRepository) List(ctx context.Context, opts EntryListOptions) ([]WorkloadEntryWithSites, int, error) {
 	// Build query with optional filters
-	baseQuery := `FROM workload_entries we`
-	whereClause := " WHERE 1=1"
+	whereClause := " WHERE we.deleted_at IS NULL"
+	if opts.Deleting {
+		whereClause = " WHERE we.deleted_at IS NOT NULL"
+	}
 	args := []interface{}{}
 
-	if siteID != "" {
-		baseQuery += ` JOIN site_workload_entries swe ON we.id = swe.workload_entry_id`
-		whereClause += " AND swe.site_id = ?"
-		args = append(args, siteID)
+	if opts.SiteID != "" {
+		// EXISTS rather than a join needs no DISTINCT, so the scan of the
+		// created_at index can stop once the page is full
+		whereClause += ` AND EXISTS (SELECT 1 FROM site_workload_entries swe
+		                             WHERE swe.workload_entry_id = we.id AND swe.site_id = ?)`
+		args = append(args, opts.SiteID)
 	}
 
-	if spiffeIDPrefix != "" {
-		whereClause += " AND we.spiffe_id LIKE ?"
-		args = append(args, spiffeIDPrefix+"%")
+	if opts.SpiffeIDPrefix != "" {
+		whereClause += ` AND we.spiffe_id LIKE ? ESCAPE '!'`
+		args = append(args, escapeLike(opts.SpiffeIDPrefix)+"%")
 	}
 
-	// Get total count
-	countQuery := "SELECT COUNT(DISTINCT we.id) " + baseQuery + whereClause
-	var totalCount int
-	if err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&totalCount); err != nil {
-		return nil, 0, fmt.Errorf("failed to count entries: %w", err)
+	if opts.SpiffeIDScope != "" {
+		scope := strings.TrimSuffix(opts.SpiffeIDScope, "/")
+		whereClause += ` AND (we.spiffe_id = ? OR we.spiffe_id LIKE ? ESCAPE '!')`
+		args = append(args, scope, escapeLike(scope+"/")+"%")
+	}
+
+	if opts.BySelectors != nil {
+		clause, selectorArgs := opts.BySelectors.whereClause()
+		whereClause += clause
+		args = append(args, selectorArgs...)
+	}
+
+	totalCount := -1
+	if opts.WithTotal {
+		countQuery := "SELECT COUNT(*) FROM workload_entries we" + whereClause
+		if err := r.db.reader(ctx).QueryRowContext(ctx, countQuery, args...).Scan(&totalCount); err != nil {
+			return nil, 0, fmt.Errorf("failed to count entries: %w", err)
+		}
+	}
+
+	if opts.After != nil {
+		whereClause += " AND (we.created_at, we.id) < (?, ?)"
+		args = append(args, opts.After.CreatedAt, opts.After.ID)
 	}
 
 	// Get entries
-	selectQuery := `SELECT DISTINCT we.id, we.spiffe_id, we.parent_id, we.selectors, we.ttl,
-	                we.description, we.created_by, we.created_at, we.updated_at ` +
-		baseQuery + whereClause + " ORDER BY we.created_at DESC LIMIT ? OFFSET ?"
-	args = append(args, pageSize, offset)
+	selectQuery := `SELECT we.id, we.spiffe_id, we.parent_id, we.selectors, we.ttl,
+	                we.description, we.created_by, we.created_at, we.updated_at, we.deleted_at
+	                FROM workload_entries we` +
+		whereClause + " ORDER BY we.created_at DESC, we.id DESC LIMIT ?"
+	args = append(args, opts.PageSize)
 
-	rows, err := r.db.QueryContext(ctx, selectQuery, args...)
+	rows, err := r.db.reader(ctx).QueryContext(ctx, selectQuery, args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to list entries: %w", err)
 	}
 	defer rows.Close()
 
 	var entries []WorkloadEntryWithSites
+	var ids []string
 	for rows.Next() {
 		var entry WorkloadEntry
 		var selectorsJSON []byte
 		if err := rows.Scan(&entry.ID, &entry.SpiffeID, &entry.ParentID, &selectorsJSON,
-			&entry.TTL, &entry.Description, &entry.CreatedBy, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
+			&entry.TTL, &entry.Description, &entry.CreatedBy, &entry.CreatedAt, &entry.UpdatedAt, &entry.DeletedAt); err != nil {
 			return nil, 0, fmt.Errorf("failed to scan entry: %w", err)
 		}
 
@@ -188,48 +259,100 @@ func (r *EntryRepository) List(ctx context.Context, pageSize int, offset int, si
 			return nil, 0, fmt.Errorf("failed to unmarshal selectors: %w", err)
 		}
 
-		// Get site statuses for this entry
-		siteStatuses, err := r.getSiteStatuses(ctx, entry.ID)
-		if err != nil {
-			return nil, 0, err
-		}
+		entries = append(entries, WorkloadEntryWithSites{WorkloadEntry: entry})
+		ids = append(ids, entry.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list entries: %w", err)
+	}
+	rows.Close()
 
-		entries = append(entries, WorkloadEntryWithSites{
-			WorkloadEntry: entry,
-			SiteStatuses:  siteStatuses,
-		})
+	// Get site statuses for the whole page at once
+	statuses, err := r.getSiteStatusesByEntry(ctx, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range entries {
+		entries[i].SiteStatuses = statuses[entries[i].ID]
 	}
 
-	return entries, totalCount, rows.Err()
+	return entries, totalCount, nil
 }
 
-// Delete deletes a workload entry (site assignments cascade delete)
-func (r *EntryRepository) Delete(ctx context.Context, id string) error {
-	// First mark all site entries as deleting
-	updateQuery := `UPDATE site_workload_entries SET sync_status = 'deleting' WHERE workload_entry_id = ?`
-	_, err := r.db.ExecContext(ctx, updateQuery, id)
+// Delete marks a workload entry deleted and its site assignments deleting.
+// The entry remains as a tombstone until every site confirms the removal, or
+// is removed at once if it is assigned to no site.
+func (r *sqlEntryRepository) Delete(ctx context.Context, id string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to mark entries for deletion: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	// Delete the entry (cascade will handle site_workload_entries)
-	deleteQuery := `DELETE FROM workload_entries WHERE id = ?`
-	result, err := r.db.ExecContext(ctx, deleteQuery, id)
+	result, err := tx.ExecContext(ctx, `UPDATE workload_entries SET deleted_at = NOW() WHERE id = ? AND deleted_at IS NULL`, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete entry: %w", err)
 	}
-
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
 		return fmt.Errorf("entry not found")
 	}
 
+	// Assignments never synced are deleting too: the agent may be creating
+	// the SPIRE entry, and confirms the deletion once it knows
+	updateQuery := `UPDATE site_workload_entries SET sync_status = 'deleting', sync_error = NULL, queued_at = NOW()
+	                WHERE workload_entry_id = ?`
+	result, err = tx.ExecContext(ctx, updateQuery, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark entries for deletion: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM workload_entries WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to purge entry: %w", err)
+		}
+	}
+
+	siteIDs, err := getSiteIDs(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.hub.Notify(siteIDs...)
+
 	return nil
 }
 
-// AssignToSites assigns an entry to additional sites
-func (r *EntryRepository) AssignToSites(ctx context.Context, entryID string, siteIDs []string) error {
-	query := `INSERT IGNORE INTO site_workload_entries (site_id, workload_entry_id, sync_status) VALUES (?, ?, 'pending')`
+// Purge removes a deleted entry's tombstone and any site assignments still
+// deleting, whether or not the sites have removed the entry
+func (r *sqlEntryRepository) Purge(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM workload_entries WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return fmt.Errorf("failed to purge entry: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("deleted entry not found")
+	}
+	return nil
+}
+
+// AssignToSites assigns an entry to additional sites. Existing assignments,
+// unknown sites and deleted entries are skipped.
+func (r *sqlEntryRepository) AssignToSites(ctx context.Context, entryID string, siteIDs []string) error {
+	// Joining skips unknown sites and deleted entries
+	query := `INSERT IGNORE INTO site_workload_entries (site_id, workload_entry_id, sync_status)
+	          SELECT s.id, we.id, 'pending' FROM sites s, workload_entries we
+	          WHERE s.id = ? AND we.id = ? AND we.deleted_at IS NULL`
+	if r.db.dialect == dialectPostgres {
+		query = `INSERT INTO site_workload_entries (site_id, workload_entry_id, sync_status)
+		         SELECT s.id, we.id, 'pending' FROM sites s, workload_entries we
+		         WHERE s.id = ? AND we.id = ? AND we.deleted_at IS NULL
+		         ON CONFLICT DO NOTHING`
+	}
 
 	for _, siteID := range siteIDs {
 		_, err := r.db.ExecContext(ctx, query, siteID, entryID)
@@ -238,11 +361,35 @@ func (r *EntryRepository) AssignToSites(ctx context.Context, entryID string, sit
 		}
 	}
 
+	r.hub.Notify(siteIDs...)
+
 	return nil
 }
 
+// getSiteIDs returns the IDs of the sites an entry is assigned to
+func getSiteIDs(ctx context.Context, tx *sqlTx, entryID string) ([]string, error) {
+	query := `SELECT site_id FROM site_workload_entries WHERE workload_entry_id = ?`
+
+	rows, err := tx.QueryContext(ctx, query, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get assigned sites: %w", err)
+	}
+	defer rows.Close()
+
+	var siteIDs []string
+	for rows.Next() {
+		var siteID string
+		if err := rows.Scan(&siteID); err != nil {
+			return nil, fmt.Errorf("failed to scan site id: %w", err)
+		}
+		siteIDs = append(siteIDs, siteID)
+	}
+
+	return siteIDs, rows.Err()
+}
+
 // getSiteStatuses returns site sync statuses for an entry
-func (r *EntryRepository) getSiteStatuses(ctx context.Context, entryID string) ([]SiteWorkloadEntry, error) {
+func (r *sqlEntryRepository) getSiteStatuses(ctx context.Context, entryID string) ([]SiteWorkloadEntry, error) {
 	query := `SELECT swe.site_id, s.name, swe.workload_entry_id, swe.sync_status,
 	                 swe.spire_entry_id, swe.last_sync_at, swe.sync_error
 	          FROM site_workload_entries swe
@@ -250,7 +397,7 @@ func (r *EntryRepository) getSiteStatuses(ctx context.Context, entryID string) (
 	          WHERE swe.workload_entry_id = ?
 	          ORDER BY s.name`
 
-	rows, err := r.db.QueryContext(ctx, query, entryID)
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query, entryID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get site statuses: %w", err)
 	}
@@ -268,3 +415,48 @@ func (r *EntryRepository) getSiteStatuses(ctx context.Context, entryID string) (
 
 	return statuses, rows.Err()
 }
+
+// getSiteStatusesByEntry returns the site statuses of several entries, keyed
+// by entry ID and ordered by site name
+func (r *sqlEntryRepository) getSiteStatusesByEntry(ctx context.Context, entryIDs []string) (map[string][]SiteWorkloadEntry, error) {
+	statuses := make(map[string][]SiteWorkloadEntry, len(entryIDs))
+	if len(entryIDs) == 0 {
+		return statuses, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(entryIDs)), ",")
+	query := `SELECT swe.site_id, s.name, swe.workload_entry_id, swe.sync_status,
+	                 swe.spire_entry_id, swe.last_sync_at, swe.sync_error
+	          FROM site_workload_entries swe
+	          JOIN sites s ON swe.site_id = s.id
+	          WHERE swe.workload_entry_id IN (` + placeholders + `)
+	          ORDER BY s.name`
+	args := make([]interface{}, len(entryIDs))
+	for i, id := range entryIDs {
+		args[i] = id
+	}
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get site statuses: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s SiteWorkloadEntry
+		if err := rows.Scan(&s.SiteID, &s.SiteName, &s.WorkloadEntryID, &s.SyncStatus,
+			&s.SpireEntryID, &s.LastSyncAt, &s.SyncError); err != nil {
+			return nil, fmt.Errorf("failed to scan site status: %w", err)
+		}
+		statuses[s.WorkloadEntryID] = append(statuses[s.WorkloadEntryID], s)
+	}
+
+	return statuses, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards in s for a pattern with ESCAPE '!'
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
@@ -0,0 +1,341 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourorg/spire-workload-mgmt/internal/notify"
+)
+
+// Errors returned by the in-memory backend where a database would reject a
+// write with a constraint violation
+var (
+	errDuplicateKey = errors.New("duplicate key")
+	errForeignKey   = errors.New("foreign key constraint fails")
+)
+
+// memDB is the shared state of the in-memory repositories. Reads see a
+// consistent snapshot. Every write is a transaction on a copy of the state
+// that replaces it only if the write succeeds, so a failed write leaves
+// nothing behind, as a rolled back database transaction would.
+type memDB struct {
+	mu    sync.RWMutex
+	state *memState
+	hub   *notify.Hub
+	now   func() time.Time
+}
+
+type memState struct {
+	sites        map[string]Site
+	entries      map[string]memEntry
+	assignments  map[memAssignmentKey]memAssignment
+	agents       map[memAgentKey]Agent
+	apiKeys      map[string]APIKey
+	roleBindings map[string]RoleBinding
+	grants       map[string]memGrant
+	changes      map[string]memChange
+
+	auditLog      []storedAuditEntry
+	auditHeads    map[string]memStreamHead
+	checkpoints   []memCheckpoint
+	auditArchives map[string]AuditArchive
+	archivedRuns  []memArchivedRun
+	auditRestored []memRestoredEntry
+	// lastAuditID keeps audit log ids unique once the newest are archived
+	lastAuditID int64
+
+	outbox []Event
+
+	// nextSeq orders rows created within the same second, as the SQL
+	// backends' second-precision timestamps cannot
+	nextSeq int64
+}
+
+type memAssignmentKey struct {
+	siteID  string
+	entryID string
+}
+
+type memAgentKey struct {
+	siteID  string
+	agentID string
+}
+
+func newMemDB(hub *notify.Hub) *memDB {
+	return &memDB{
+		state: &memState{
+			sites:         make(map[string]Site),
+			entries:       make(map[string]memEntry),
+			assignments:   make(map[memAssignmentKey]memAssignment),
+			agents:        make(map[memAgentKey]Agent),
+			apiKeys:       make(map[string]APIKey),
+			roleBindings:  make(map[string]RoleBinding),
+			grants:        make(map[string]memGrant),
+			changes:       make(map[string]memChange),
+			auditHeads:    make(map[string]memStreamHead),
+			auditArchives: make(map[string]AuditArchive),
+		},
+		hub: hub,
+		now: func() time.Time { return time.Now().UTC().Truncate(time.Second) },
+	}
+}
+
+// NewMemoryStore creates a Store that keeps all state in memory, for local
+// development and tests. The hub is notified of sites with new work; it may
+// be nil.
+func NewMemoryStore(hub *notify.Hub) *Store {
+	db := newMemDB(hub)
+	appended := make(chan struct{}, 1)
+	return &Store{
+		Sites:           &memSiteRepository{db: db},
+		Entries:         &memEntryRepository{db: db},
+		SyncStatus:      &memSyncStatusRepository{db: db},
+		Audit:           &memAuditRepository{db: db},
+		Agents:          &memAgentRepository{db: db},
+		APIKeys:         &memAPIKeyRepository{db: db},
+		RoleBindings:    &memRoleBindingRepository{db: db},
+		NamespaceGrants: &memNamespaceGrantRepository{db: db},
+		ChangeRequests:  &memChangeRequestRepository{db: db},
+		Outbox:          &memOutboxRepository{db: db, appended: appended},
+		UnitOfWork:      &memUnitOfWork{db: db, appended: appended},
+		State:           &memStateRepository{db: db},
+	}
+}
+
+// view runs fn on the current state, which it must not modify
+func (db *memDB) view(fn func(s *memState) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return fn(db.state)
+}
+
+// update runs fn on a copy of the state and commits the copy if fn succeeds
+func (db *memDB) update(fn func(s *memState) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	next := db.state.clone()
+	if err := fn(next); err != nil {
+		return err
+	}
+	db.state = next
+	return nil
+}
+
+// clone copies the tables of s. Rows are values and are replaced rather than
+// modified in place, so the copy shares nothing that either side changes.
+func (s *memState) clone() *memState {
+	return &memState{
+		sites:         maps.Clone(s.sites),
+		entries:       maps.Clone(s.entries),
+		assignments:   maps.Clone(s.assignments),
+		agents:        maps.Clone(s.agents),
+		apiKeys:       maps.Clone(s.apiKeys),
+		roleBindings:  maps.Clone(s.roleBindings),
+		grants:        maps.Clone(s.grants),
+		changes:       maps.Clone(s.changes),
+		auditLog:      slices.Clip(s.auditLog),
+		auditHeads:    maps.Clone(s.auditHeads),
+		checkpoints:   slices.Clip(s.checkpoints),
+		auditArchives: maps.Clone(s.auditArchives),
+		archivedRuns:  slices.Clip(s.archivedRuns),
+		auditRestored: slices.Clip(s.auditRestored),
+		lastAuditID:   s.lastAuditID,
+		outbox:        slices.Clip(s.outbox),
+		nextSeq:       s.nextSeq,
+	}
+}
+
+func (s *memState) seq() int64 {
+	s.nextSeq++
+	return s.nextSeq
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// paginate returns the page of items starting at offset
+func paginate[T any](items []T, pageSize, offset int) []T {
+	if offset >= len(items) || pageSize <= 0 {
+		return nil
+	}
+	return items[offset:min(offset+pageSize, len(items))]
+}
+
+// memSiteRepository is the in-memory SiteRepository
+type memSiteRepository struct {
+	db *memDB
+}
+
+// List returns all sites, optionally filtered by status
+func (r *memSiteRepository) List(ctx context.Context, status string) ([]Site, error) {
+	var sites []Site
+	r.db.view(func(s *memState) error {
+		for _, site := range s.sites {
+			if status == "" || site.Status == status {
+				sites = append(sites, site)
+			}
+		}
+		return nil
+	})
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Name < sites[j].Name })
+	return sites, nil
+}
+
+// Get returns a site by ID
+func (r *memSiteRepository) Get(ctx context.Context, id string) (*Site, error) {
+	var result *Site
+	r.db.view(func(s *memState) error {
+		if site, ok := s.sites[id]; ok {
+			result = &site
+		}
+		return nil
+	})
+	return result, nil
+}
+
+// Create inserts a new site
+func (r *memSiteRepository) Create(ctx context.Context, site *Site) error {
+	if site.ID == "" {
+		site.ID = uuid.New().String()
+	}
+	if site.Status == "" {
+		site.Status = "active"
+	}
+
+	return r.db.update(func(s *memState) error {
+		if _, ok := s.sites[site.ID]; ok {
+			return fmt.Errorf("failed to insert site: %w: id %s", errDuplicateKey, site.ID)
+		}
+		for _, existing := range s.sites {
+			if existing.Name == site.Name {
+				return fmt.Errorf("failed to insert site: %w: name %s", errDuplicateKey, site.Name)
+			}
+		}
+		row := *site
+		row.LastSyncAt = nil
+		row.CreatedAt = r.db.now()
+		row.UpdatedAt = row.CreatedAt
+		s.sites[row.ID] = row
+		return nil
+	})
+}
+
+// ProtectedSiteIDs returns the IDs among siteIDs of sites flagged protected
+func (r *memSiteRepository) ProtectedSiteIDs(ctx context.Context, siteIDs []string) ([]string, error) {
+	var protected []string
+	r.db.view(func(s *memState) error {
+		for _, id := range siteIDs {
+			if site, ok := s.sites[id]; ok && site.Protected && !slices.Contains(protected, id) {
+				protected = append(protected, id)
+			}
+		}
+		return nil
+	})
+	sort.Strings(protected)
+	return protected, nil
+}
+
+// UpdateLastSyncAt updates the last sync timestamp for a site
+func (r *memSiteRepository) UpdateLastSyncAt(ctx context.Context, id string) error {
+	return r.db.update(func(s *memState) error {
+		if site, ok := s.sites[id]; ok {
+			site.LastSyncAt = timePtr(r.db.now())
+			site.UpdatedAt = r.db.now()
+			s.sites[id] = site
+		}
+		return nil
+	})
+}
+
+// MarkDisconnected marks active sites as disconnected when none of their agents
+// has sent a heartbeat within the timeout. Sites that have never reported a
+// heartbeat are left unchanged. It returns the IDs of the sites it changed.
+func (r *memSiteRepository) MarkDisconnected(ctx context.Context, timeout time.Duration) ([]string, error) {
+	var changed []string
+	err := r.db.update(func(s *memState) error {
+		lastHeartbeat := make(map[string]time.Time)
+		for _, a := range s.agents {
+			if a.LastHeartbeatAt.After(lastHeartbeat[a.SiteID]) {
+				lastHeartbeat[a.SiteID] = a.LastHeartbeatAt
+			}
+		}
+
+		cutoff := r.db.now().Add(-timeout)
+		for id, last := range lastHeartbeat {
+			site, ok := s.sites[id]
+			if ok && site.Status == "active" && last.Before(cutoff) {
+				site.Status = "disconnected"
+				site.UpdatedAt = r.db.now()
+				s.sites[id] = site
+				changed = append(changed, id)
+			}
+		}
+		return nil
+	})
+	sort.Strings(changed)
+	return changed, err
+}
+
+// MarkConnected marks a disconnected site as active again. It reports whether
+// the site status changed.
+func (r *memSiteRepository) MarkConnected(ctx context.Context, id string) (bool, error) {
+	changed := false
+	err := r.db.update(func(s *memState) error {
+		if site, ok := s.sites[id]; ok && site.Status == "disconnected" {
+			site.Status = "active"
+			site.UpdatedAt = r.db.now()
+			s.sites[id] = site
+			changed = true
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// memAgentRepository is the in-memory AgentRepository
+type memAgentRepository struct {
+	db *memDB
+}
+
+// RecordHeartbeat inserts or updates an agent and sets its heartbeat time to now
+func (r *memAgentRepository) RecordHeartbeat(ctx context.Context, a *Agent) error {
+	return r.db.update(func(s *memState) error {
+		if _, ok := s.sites[a.SiteID]; !ok {
+			return fmt.Errorf("failed to record heartbeat: %w: site %s", errForeignKey, a.SiteID)
+		}
+		row := *a
+		row.StartedAt = a.StartedAt.UTC().Truncate(time.Second)
+		row.LastHeartbeatAt = r.db.now()
+		s.agents[memAgentKey{siteID: a.SiteID, agentID: a.AgentID}] = row
+		return nil
+	})
+}
+
+// List returns all known agents, optionally filtered by site
+func (r *memAgentRepository) List(ctx context.Context, siteID string) ([]Agent, error) {
+	var agents []Agent
+	r.db.view(func(s *memState) error {
+		for _, a := range s.agents {
+			if siteID == "" || a.SiteID == siteID {
+				agents = append(agents, a)
+			}
+		}
+		return nil
+	})
+	sort.Slice(agents, func(i, j int) bool {
+		if agents[i].SiteID != agents[j].SiteID {
+			return agents[i].SiteID < agents[j].SiteID
+		}
+		return agents[i].AgentID < agents[j].AgentID
+	})
+	return agents, nil
+}
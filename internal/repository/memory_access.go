@@ -0,0 +1,440 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// memAPIKeyRepository is the in-memory APIKeyRepository
+type memAPIKeyRepository struct {
+	db *memDB
+}
+
+// Create inserts a new API key
+func (r *memAPIKeyRepository) Create(ctx context.Context, key *APIKey) error {
+	if key.ID == "" {
+		key.ID = uuid.New().String()
+	}
+
+	return r.db.update(func(s *memState) error {
+		if _, ok := s.apiKeys[key.ID]; ok {
+			return fmt.Errorf("failed to insert API key: %w: id %s", errDuplicateKey, key.ID)
+		}
+		for _, k := range s.apiKeys {
+			if k.Name == key.Name {
+				return fmt.Errorf("failed to insert API key: %w: name %s", errDuplicateKey, key.Name)
+			}
+			if k.KeyHash == key.KeyHash {
+				return fmt.Errorf("failed to insert API key: %w: key_hash", errDuplicateKey)
+			}
+		}
+
+		row := *key
+		row.Scopes = slices.Clone(key.Scopes)
+		row.CreatedAt = r.db.now()
+		row.RotatedAt, row.LastUsedAt, row.RevokedAt = nil, nil, nil
+		s.apiKeys[row.ID] = row
+		return nil
+	})
+}
+
+// Get returns an API key by ID
+func (r *memAPIKeyRepository) Get(ctx context.Context, id string) (*APIKey, error) {
+	return r.find(func(k *APIKey) bool { return k.ID == id }), nil
+}
+
+// GetByHash returns an API key by the hash of its secret
+func (r *memAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*APIKey, error) {
+	return r.find(func(k *APIKey) bool { return k.KeyHash == keyHash }), nil
+}
+
+func (r *memAPIKeyRepository) find(match func(k *APIKey) bool) *APIKey {
+	var result *APIKey
+	r.db.view(func(s *memState) error {
+		for _, k := range s.apiKeys {
+			if match(&k) {
+				k.Scopes = slices.Clone(k.Scopes)
+				result = &k
+				return nil
+			}
+		}
+		return nil
+	})
+	return result
+}
+
+// List returns all API keys, including revoked ones
+func (r *memAPIKeyRepository) List(ctx context.Context) ([]APIKey, error) {
+	var keys []APIKey
+	r.db.view(func(s *memState) error {
+		for _, k := range s.apiKeys {
+			k.Scopes = slices.Clone(k.Scopes)
+			keys = append(keys, k)
+		}
+		return nil
+	})
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys, nil
+}
+
+// Rotate replaces the secret of an active API key
+func (r *memAPIKeyRepository) Rotate(ctx context.Context, id, keyHash, keyPrefix string) error {
+	return r.db.update(func(s *memState) error {
+		k, ok := s.apiKeys[id]
+		if !ok || k.RevokedAt != nil {
+			return fmt.Errorf("API key not found or revoked")
+		}
+		for _, other := range s.apiKeys {
+			if other.ID != id && other.KeyHash == keyHash {
+				return fmt.Errorf("failed to rotate API key: %w: key_hash", errDuplicateKey)
+			}
+		}
+		k.KeyHash = keyHash
+		k.KeyPrefix = keyPrefix
+		k.RotatedAt = timePtr(r.db.now())
+		s.apiKeys[id] = k
+		return nil
+	})
+}
+
+// Revoke marks an API key as revoked
+func (r *memAPIKeyRepository) Revoke(ctx context.Context, id string) error {
+	return r.db.update(func(s *memState) error {
+		k, ok := s.apiKeys[id]
+		if !ok || k.RevokedAt != nil {
+			return fmt.Errorf("API key not found or already revoked")
+		}
+		k.RevokedAt = timePtr(r.db.now())
+		s.apiKeys[id] = k
+		return nil
+	})
+}
+
+// TouchLastUsed records that an API key was used
+func (r *memAPIKeyRepository) TouchLastUsed(ctx context.Context, id string) error {
+	return r.db.update(func(s *memState) error {
+		if k, ok := s.apiKeys[id]; ok {
+			k.LastUsedAt = timePtr(r.db.now())
+			s.apiKeys[id] = k
+		}
+		return nil
+	})
+}
+
+// memRoleBindingRepository is the in-memory RoleBindingRepository
+type memRoleBindingRepository struct {
+	db *memDB
+}
+
+// Create inserts a new role binding
+func (r *memRoleBindingRepository) Create(ctx context.Context, b *RoleBinding) error {
+	if b.ID == "" {
+		b.ID = uuid.New().String()
+	}
+
+	return r.db.update(func(s *memState) error {
+		if _, ok := s.roleBindings[b.ID]; ok {
+			return fmt.Errorf("failed to insert role binding: %w: id %s", errDuplicateKey, b.ID)
+		}
+		for _, existing := range s.roleBindings {
+			if existing.Role == b.Role && existing.SubjectKind == b.SubjectKind && existing.Subject == b.Subject {
+				return fmt.Errorf("failed to insert role binding: %w: %s %s:%s", errDuplicateKey, b.Role, b.SubjectKind, b.Subject)
+			}
+		}
+
+		row := *b
+		row.CreatedAt = r.db.now()
+		s.roleBindings[row.ID] = row
+		return nil
+	})
+}
+
+// Get returns a role binding by ID
+func (r *memRoleBindingRepository) Get(ctx context.Context, id string) (*RoleBinding, error) {
+	var result *RoleBinding
+	r.db.view(func(s *memState) error {
+		if b, ok := s.roleBindings[id]; ok {
+			result = &b
+		}
+		return nil
+	})
+	return result, nil
+}
+
+// List returns role bindings, optionally filtered by subject kind and subject
+func (r *memRoleBindingRepository) List(ctx context.Context, subjectKind, subject string) ([]RoleBinding, error) {
+	var bindings []RoleBinding
+	r.db.view(func(s *memState) error {
+		for _, b := range s.roleBindings {
+			if (subjectKind == "" || b.SubjectKind == subjectKind) && (subject == "" || b.Subject == subject) {
+				bindings = append(bindings, b)
+			}
+		}
+		return nil
+	})
+	sort.Slice(bindings, func(i, j int) bool {
+		a, b := bindings[i], bindings[j]
+		if a.SubjectKind != b.SubjectKind {
+			return a.SubjectKind < b.SubjectKind
+		}
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		return a.Role < b.Role
+	})
+	return bindings, nil
+}
+
+// Delete removes a role binding
+func (r *memRoleBindingRepository) Delete(ctx context.Context, id string) error {
+	return r.db.update(func(s *memState) error {
+		if _, ok := s.roleBindings[id]; !ok {
+			return fmt.Errorf("role binding not found")
+		}
+		delete(s.roleBindings, id)
+		return nil
+	})
+}
+
+// RolesForSubjects returns the distinct roles bound to any of the given
+// subjects, keyed by subject kind
+func (r *memRoleBindingRepository) RolesForSubjects(ctx context.Context, subjects map[string][]string) ([]string, error) {
+	var roles []string
+	r.db.view(func(s *memState) error {
+		for _, b := range s.roleBindings {
+			if slices.Contains(subjects[b.SubjectKind], b.Subject) && !slices.Contains(roles, b.Role) {
+				roles = append(roles, b.Role)
+			}
+		}
+		return nil
+	})
+	sort.Strings(roles)
+	return roles, nil
+}
+
+// memGrant is a stored namespace grant
+type memGrant struct {
+	NamespaceGrant
+	seq int64
+}
+
+func (g memGrant) copy() NamespaceGrant {
+	result := g.NamespaceGrant
+	result.Namespaces = slices.Clone(g.Namespaces)
+	result.SpiffeIDPrefixes = slices.Clone(g.SpiffeIDPrefixes)
+	result.SiteIDs = slices.Clone(g.SiteIDs)
+	return result
+}
+
+// memNamespaceGrantRepository is the in-memory NamespaceGrantRepository
+type memNamespaceGrantRepository struct {
+	db *memDB
+}
+
+// Create inserts a new namespace grant
+func (r *memNamespaceGrantRepository) Create(ctx context.Context, g *NamespaceGrant) error {
+	if g.ID == "" {
+		g.ID = uuid.New().String()
+	}
+
+	return r.db.update(func(s *memState) error {
+		if _, ok := s.grants[g.ID]; ok {
+			return fmt.Errorf("failed to insert namespace grant: %w: id %s", errDuplicateKey, g.ID)
+		}
+
+		row := memGrant{NamespaceGrant: *g, seq: s.seq()}
+		row.Namespaces = slices.Clone(g.Namespaces)
+		row.SpiffeIDPrefixes = nonNil(slices.Clone(g.SpiffeIDPrefixes))
+		row.SiteIDs = nonNil(slices.Clone(g.SiteIDs))
+		row.CreatedAt = r.db.now()
+		s.grants[row.ID] = row
+		return nil
+	})
+}
+
+// Get returns a namespace grant by ID
+func (r *memNamespaceGrantRepository) Get(ctx context.Context, id string) (*NamespaceGrant, error) {
+	var result *NamespaceGrant
+	r.db.view(func(s *memState) error {
+		if g, ok := s.grants[id]; ok {
+			grant := g.copy()
+			result = &grant
+		}
+		return nil
+	})
+	return result, nil
+}
+
+// List returns namespace grants, optionally filtered by subject kind and subject
+func (r *memNamespaceGrantRepository) List(ctx context.Context, subjectKind, subject string) ([]NamespaceGrant, error) {
+	return r.query(func(g *memGrant) bool {
+		return (subjectKind == "" || g.SubjectKind == subjectKind) && (subject == "" || g.Subject == subject)
+	}), nil
+}
+
+// ListForSubjects returns the grants held by any of the given subjects, keyed
+// by subject kind
+func (r *memNamespaceGrantRepository) ListForSubjects(ctx context.Context, subjects map[string][]string) ([]NamespaceGrant, error) {
+	return r.query(func(g *memGrant) bool {
+		return slices.Contains(subjects[g.SubjectKind], g.Subject)
+	}), nil
+}
+
+// Delete removes a namespace grant
+func (r *memNamespaceGrantRepository) Delete(ctx context.Context, id string) error {
+	return r.db.update(func(s *memState) error {
+		if _, ok := s.grants[id]; !ok {
+			return fmt.Errorf("namespace grant not found")
+		}
+		delete(s.grants, id)
+		return nil
+	})
+}
+
+func (r *memNamespaceGrantRepository) query(match func(g *memGrant) bool) []NamespaceGrant {
+	var matched []memGrant
+	r.db.view(func(s *memState) error {
+		for _, g := range s.grants {
+			if match(&g) {
+				matched = append(matched, g)
+			}
+		}
+		return nil
+	})
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if a.SubjectKind != b.SubjectKind {
+			return a.SubjectKind < b.SubjectKind
+		}
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		return a.seq < b.seq
+	})
+
+	var grants []NamespaceGrant
+	for _, g := range matched {
+		grants = append(grants, g.copy())
+	}
+	return grants
+}
+
+// memChange is a stored change request
+type memChange struct {
+	ChangeRequest
+	seq int64
+}
+
+func (c memChange) copy() ChangeRequest {
+	result := c.ChangeRequest
+	result.SiteIDs = slices.Clone(c.SiteIDs)
+	return result
+}
+
+// memChangeRequestRepository is the in-memory ChangeRequestRepository
+type memChangeRequestRepository struct {
+	db *memDB
+}
+
+// Create inserts a new change request in pending_approval state
+func (r *memChangeRequestRepository) Create(ctx context.Context, c *ChangeRequest) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	c.Status = ChangePendingApproval
+
+	return r.db.update(func(s *memState) error {
+		if _, ok := s.changes[c.ID]; ok {
+			return fmt.Errorf("failed to insert change request: %w: id %s", errDuplicateKey, c.ID)
+		}
+
+		row := memChange{ChangeRequest: *c, seq: s.seq()}
+		row.SiteIDs = nonNil(slices.Clone(c.SiteIDs))
+		row.ReviewedBy, row.ReviewComment, row.ApplyError, row.ReviewedAt = "", "", "", nil
+		row.CreatedAt = r.db.now()
+		s.changes[row.ID] = row
+		return nil
+	})
+}
+
+// Get returns a change request by ID
+func (r *memChangeRequestRepository) Get(ctx context.Context, id string) (*ChangeRequest, error) {
+	var result *ChangeRequest
+	r.db.view(func(s *memState) error {
+		if c, ok := s.changes[id]; ok {
+			change := c.copy()
+			result = &change
+		}
+		return nil
+	})
+	return result, nil
+}
+
+// List returns change requests, newest first, optionally filtered by status
+// and workload entry
+func (r *memChangeRequestRepository) List(ctx context.Context, status, entryID string) ([]ChangeRequest, error) {
+	var matched []memChange
+	r.db.view(func(s *memState) error {
+		for _, c := range s.changes {
+			if (status == "" || c.Status == status) && (entryID == "" || c.WorkloadEntryID == entryID) {
+				matched = append(matched, c)
+			}
+		}
+		return nil
+	})
+	sort.Slice(matched, func(i, j int) bool { return matched[i].seq > matched[j].seq })
+
+	var changes []ChangeRequest
+	for _, c := range matched {
+		changes = append(changes, c.copy())
+	}
+	return changes, nil
+}
+
+// Review moves a pending change request to approved or rejected. It reports
+// false if the request was no longer pending, so that concurrent reviews
+// cannot both succeed.
+func (r *memChangeRequestRepository) Review(ctx context.Context, id, status, reviewer, comment string) (bool, error) {
+	reviewed := false
+	err := r.db.update(func(s *memState) error {
+		c, ok := s.changes[id]
+		if !ok || c.Status != ChangePendingApproval {
+			return nil
+		}
+		c.Status = status
+		c.ReviewedBy = reviewer
+		c.ReviewComment = comment
+		c.ReviewedAt = timePtr(r.db.now())
+		s.changes[id] = c
+		reviewed = true
+		return nil
+	})
+	return reviewed, err
+}
+
+// MarkApplied records that an approved change request was applied
+func (r *memChangeRequestRepository) MarkApplied(ctx context.Context, id string) error {
+	return r.setStatus(id, ChangeApplied, "")
+}
+
+// MarkFailed records that applying an approved change request failed
+func (r *memChangeRequestRepository) MarkFailed(ctx context.Context, id, applyError string) error {
+	return r.setStatus(id, ChangeFailed, applyError)
+}
+
+func (r *memChangeRequestRepository) setStatus(id, status, applyError string) error {
+	return r.db.update(func(s *memState) error {
+		if c, ok := s.changes[id]; ok {
+			c.Status = status
+			if applyError != "" {
+				c.ApplyError = applyError
+			}
+			s.changes[id] = c
+		}
+		return nil
+	})
+}
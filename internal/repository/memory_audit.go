@@ -0,0 +1,335 @@
+package repository
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// memStreamHead is the head of an audit stream's hash chain
+type memStreamHead struct {
+	seq  int64
+	hash string
+}
+
+// memCheckpoint is a stored signed checkpoint
+type memCheckpoint struct {
+	stream string
+	auditCheckpoint
+}
+
+// memArchivedRun is a recorded run of archived entries
+type memArchivedRun struct {
+	stream string
+	archivedRun
+}
+
+// memRestoredEntry is an archived entry loaded back for investigation
+type memRestoredEntry struct {
+	archive string
+	ArchivedAuditEntry
+}
+
+// memAuditRepository is the in-memory AuditRepository
+type memAuditRepository struct {
+	db *memDB
+}
+
+// Log creates a new audit log entry and appends it to the hash chain of its
+// stream
+func (r *memAuditRepository) Log(ctx context.Context, actor, action, resourceType, resourceID string, details map[string]interface{}) error {
+	detailsJSON, err := canonicalJSON(details)
+	if err != nil {
+		return fmt.Errorf("failed to marshal details: %w", err)
+	}
+
+	return r.db.update(func(s *memState) error {
+		stream := resourceType
+		head, ok := s.auditHeads[stream]
+		if !ok {
+			head = memStreamHead{hash: genesisHash}
+		}
+
+		e := storedAuditEntry{
+			chainEntry: chainEntry{
+				Stream:       stream,
+				Seq:          head.seq + 1,
+				PrevHash:     head.hash,
+				Timestamp:    r.db.now(),
+				Actor:        actor,
+				Action:       action,
+				ResourceType: resourceType,
+				ResourceID:   resourceID,
+				Details:      detailsJSON,
+			},
+			details: detailsJSON,
+		}
+		s.lastAuditID++
+		e.id = s.lastAuditID
+		e.storedHash = e.hash()
+
+		s.auditLog = append(s.auditLog, e)
+		s.auditHeads[stream] = memStreamHead{seq: e.Seq, hash: e.storedHash}
+		return nil
+	})
+}
+
+// List returns audit log entries with pagination and optional filters
+func (r *memAuditRepository) List(ctx context.Context, pageSize, offset int, resourceType, resourceID, actor string, startTime, endTime *time.Time) ([]AuditLogEntry, error) {
+	var matched []storedAuditEntry
+	r.db.view(func(s *memState) error {
+		for _, e := range s.auditLog {
+			if (resourceType == "" || e.ResourceType == resourceType) &&
+				(resourceID == "" || e.ResourceID == resourceID) &&
+				(actor == "" || e.Actor == actor) &&
+				(startTime == nil || !e.Timestamp.Before(*startTime)) &&
+				(endTime == nil || !e.Timestamp.After(*endTime)) {
+				matched = append(matched, e)
+			}
+		}
+		return nil
+	})
+	sort.Slice(matched, func(i, j int) bool { return matched[i].id > matched[j].id })
+
+	var entries []AuditLogEntry
+	for _, e := range paginate(matched, pageSize, offset) {
+		entry := AuditLogEntry{
+			ID:           e.id,
+			Timestamp:    e.Timestamp,
+			Actor:        e.Actor,
+			Action:       e.Action,
+			ResourceType: e.ResourceType,
+			ResourceID:   e.ResourceID,
+		}
+		if err := json.Unmarshal(e.details, &entry.Details); err != nil {
+			entry.Details = map[string]interface{}{"raw": string(e.details)}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Checkpoint stores a signed checkpoint of every stream head that has
+// advanced since its last checkpoint. It returns the number of checkpoints
+// written.
+func (r *memAuditRepository) Checkpoint(ctx context.Context, key ed25519.PrivateKey) (int, error) {
+	keyID := checkpointKeyID(key.Public().(ed25519.PublicKey))
+	written := 0
+	err := r.db.update(func(s *memState) error {
+		checkpointed := make(map[string]int64)
+		for _, c := range s.checkpoints {
+			checkpointed[c.stream] = max(checkpointed[c.stream], c.seq)
+		}
+
+		streams := make([]string, 0, len(s.auditHeads))
+		for stream := range s.auditHeads {
+			streams = append(streams, stream)
+		}
+		sort.Strings(streams)
+
+		for _, stream := range streams {
+			head := s.auditHeads[stream]
+			if head.seq <= checkpointed[stream] {
+				continue
+			}
+			createdAt := r.db.now()
+			sig := ed25519.Sign(key, checkpointMessage(stream, head.seq, head.hash, createdAt))
+			s.checkpoints = append(s.checkpoints, memCheckpoint{
+				stream: stream,
+				auditCheckpoint: auditCheckpoint{
+					seq:       head.seq,
+					hash:      head.hash,
+					keyID:     keyID,
+					signature: base64.StdEncoding.EncodeToString(sig),
+					createdAt: createdAt,
+				},
+			})
+			written++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+// Verify walks the hash chain of stream, or of every stream if stream is
+// empty, and checks the signed checkpoints against it
+func (r *memAuditRepository) Verify(ctx context.Context, stream string, key ed25519.PublicKey) (*AuditVerification, error) {
+	var snapshot *memState
+	r.db.view(func(s *memState) error {
+		snapshot = s
+		return nil
+	})
+	return verifyAuditChains(ctx, snapshot, stream, key)
+}
+
+// ListExpired returns up to limit audit log entries that have outlived their
+// retention period at now, oldest first
+func (r *memAuditRepository) ListExpired(ctx context.Context, retention AuditRetention, now time.Time, limit int) ([]ArchivedAuditEntry, error) {
+	var entries []ArchivedAuditEntry
+	err := r.db.view(func(s *memState) error {
+		for _, e := range s.auditLog {
+			if len(entries) == limit {
+				break
+			}
+			if !retention.expired(e.Action, e.Timestamp, now) {
+				continue
+			}
+			a, err := archivedEntry(e)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, a)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Prune deletes archived entries from the audit log and records the archive
+// that holds them, with the runs of the hash chains it covers signed by key.
+// It fails without deleting anything if any of the entries is already gone.
+func (r *memAuditRepository) Prune(ctx context.Context, archive *AuditArchive, entries []ArchivedAuditEntry,
+	key ed25519.PrivateKey) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if key == nil {
+		return fmt.Errorf("archived audit entries must be signed: no checkpoint signing key")
+	}
+	runs := signedRunsOf(archive.Name, entries, key)
+
+	return r.db.update(func(s *memState) error {
+		archived := make(map[int64]bool, len(entries))
+		for _, e := range entries {
+			archived[e.ID] = true
+		}
+
+		kept := make([]storedAuditEntry, 0, len(s.auditLog))
+		for _, e := range s.auditLog {
+			if !archived[e.id] {
+				kept = append(kept, e)
+			}
+		}
+		if n := len(s.auditLog) - len(kept); n != len(entries) {
+			return fmt.Errorf("audit entries already archived: %d of %d remain", n, len(entries))
+		}
+		s.auditLog = kept
+
+		s.auditArchives[archive.Name] = *archive
+		for stream, runs := range runs {
+			for _, run := range runs {
+				s.archivedRuns = append(s.archivedRuns, memArchivedRun{stream: stream, archivedRun: run})
+			}
+		}
+		return nil
+	})
+}
+
+// GetArchive returns the record of the named archive
+func (r *memAuditRepository) GetArchive(ctx context.Context, name string) (*AuditArchive, error) {
+	var archive *AuditArchive
+	err := r.db.view(func(s *memState) error {
+		a, ok := s.auditArchives[name]
+		if !ok {
+			return fmt.Errorf("audit archive not found: %s", name)
+		}
+		archive = &a
+		return nil
+	})
+	return archive, err
+}
+
+// Restore loads archived entries back for investigation. Entries already
+// restored are skipped. It returns the number of entries loaded.
+func (r *memAuditRepository) Restore(ctx context.Context, archive string, entries []ArchivedAuditEntry) (int, error) {
+	restored := 0
+	err := r.db.update(func(s *memState) error {
+		seen := make(map[int64]bool, len(s.auditRestored))
+		for _, e := range s.auditRestored {
+			seen[e.ID] = true
+		}
+		for _, e := range entries {
+			if seen[e.ID] {
+				continue
+			}
+			seen[e.ID] = true
+			s.auditRestored = append(s.auditRestored, memRestoredEntry{archive: archive, ArchivedAuditEntry: e})
+			restored++
+		}
+		return nil
+	})
+	return restored, err
+}
+
+// ManagePartitions does nothing: the in-memory audit log is not partitioned
+func (r *memAuditRepository) ManagePartitions(ctx context.Context, now time.Time, ahead int) (int, int, error) {
+	return 0, 0, nil
+}
+
+// The memState audit tables are read for verification from a snapshot, which
+// update never modifies once it has been replaced
+
+func (s *memState) auditStreams(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, e := range s.auditLog {
+		seen[e.Stream] = true
+	}
+	for stream := range s.auditHeads {
+		seen[stream] = true
+	}
+	for _, c := range s.checkpoints {
+		seen[c.stream] = true
+	}
+
+	streams := make([]string, 0, len(seen))
+	for stream := range seen {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+	return streams, nil
+}
+
+func (s *memState) auditEntries(ctx context.Context, stream string, afterSeq int64, limit int) ([]storedAuditEntry, error) {
+	var entries []storedAuditEntry
+	for _, e := range s.auditLog {
+		if e.Stream == stream && e.Seq > afterSeq {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return paginate(entries, limit, 0), nil
+}
+
+func (s *memState) auditHead(ctx context.Context, stream string) (int64, string, bool, error) {
+	head, ok := s.auditHeads[stream]
+	return head.seq, head.hash, ok, nil
+}
+
+func (s *memState) auditCheckpoints(ctx context.Context, stream string) ([]auditCheckpoint, error) {
+	var checkpoints []auditCheckpoint
+	for _, c := range s.checkpoints {
+		if c.stream == stream {
+			checkpoints = append(checkpoints, c.auditCheckpoint)
+		}
+	}
+	sort.SliceStable(checkpoints, func(i, j int) bool { return checkpoints[i].seq < checkpoints[j].seq })
+	return checkpoints, nil
+}
+
+func (s *memState) auditArchivedRuns(ctx context.Context, stream string) ([]archivedRun, error) {
+	var runs []archivedRun
+	for _, run := range s.archivedRuns {
+		if run.stream == stream {
+			runs = append(runs, run.archivedRun)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].firstSeq < runs[j].firstSeq })
+	return runs, nil
+}
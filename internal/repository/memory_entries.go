@@ -0,0 +1,419 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+)
+
+// memEntry is a stored workload entry
+type memEntry struct {
+	WorkloadEntry
+	seq int64
+}
+
+// memAssignment is a stored site assignment
+type memAssignment struct {
+	SiteWorkloadEntry
+	// queued orders the site's pending entries, as the queued_at column does
+	queued   int64
+	queuedAt time.Time
+}
+
+// validSyncStatuses are the values of the sync_status column
+var validSyncStatuses = map[string]bool{"pending": true, "synced": true, "failed": true, "deleting": true}
+
+// memEntryRepository is the in-memory EntryRepository
+type memEntryRepository struct {
+	db *memDB
+}
+
+// Create creates a new workload entry with site assignments
+func (r *memEntryRepository) Create(ctx context.Context, entry *WorkloadEntry, siteIDs []string) (*WorkloadEntryWithSites, error) {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+
+	err := r.db.update(func(s *memState) error {
+		if _, ok := s.entries[entry.ID]; ok {
+			return fmt.Errorf("failed to insert workload entry: %w: id %s", errDuplicateKey, entry.ID)
+		}
+		for _, e := range s.entries {
+			if e.SpiffeID == entry.SpiffeID {
+				return fmt.Errorf("failed to insert workload entry: %w: spiffe_id %s", errDuplicateKey, entry.SpiffeID)
+			}
+		}
+
+		row := memEntry{WorkloadEntry: *entry, seq: s.seq()}
+		row.Selectors = slices.Clone(entry.Selectors)
+		row.CreatedAt = r.db.now()
+		row.UpdatedAt = row.CreatedAt
+		s.entries[row.ID] = row
+
+		for _, siteID := range siteIDs {
+			key := memAssignmentKey{siteID: siteID, entryID: entry.ID}
+			if _, ok := s.sites[siteID]; !ok {
+				return fmt.Errorf("failed to assign entry to site %s: %w", siteID, errForeignKey)
+			}
+			if _, ok := s.assignments[key]; ok {
+				return fmt.Errorf("failed to assign entry to site %s: %w", siteID, errDuplicateKey)
+			}
+			s.assignments[key] = memAssignment{
+				SiteWorkloadEntry: SiteWorkloadEntry{SiteID: siteID, WorkloadEntryID: entry.ID, SyncStatus: "pending"},
+				queued:            s.seq(),
+				queuedAt:          r.db.now(),
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Wake agents waiting for work at the assigned sites
+	r.db.hub.Notify(siteIDs...)
+
+	return r.Get(ctx, entry.ID)
+}
+
+// Get returns a workload entry by ID with its site statuses
+func (r *memEntryRepository) Get(ctx context.Context, id string) (*WorkloadEntryWithSites, error) {
+	var result *WorkloadEntryWithSites
+	r.db.view(func(s *memState) error {
+		if e, ok := s.entries[id]; ok {
+			result = s.entryWithSites(e)
+		}
+		return nil
+	})
+	return result, nil
+}
+
+// List returns a page of workload entries, newest first
+func (r *memEntryRepository) List(ctx context.Context, opts EntryListOptions) ([]WorkloadEntryWithSites, int, error) {
+	var entries []WorkloadEntryWithSites
+	total := -1
+	r.db.view(func(s *memState) error {
+		var matched []memEntry
+		for _, e := range s.entries {
+			if (e.DeletedAt != nil) != opts.Deleting {
+				continue
+			}
+			if opts.SiteID != "" {
+				if _, ok := s.assignments[memAssignmentKey{siteID: opts.SiteID, entryID: e.ID}]; !ok {
+					continue
+				}
+			}
+			if !strings.HasPrefix(e.SpiffeID, opts.SpiffeIDPrefix) {
+				continue
+			}
+			if opts.SpiffeIDScope != "" && !auth.UnderSpiffeIDPrefix(e.SpiffeID, opts.SpiffeIDScope) {
+				continue
+			}
+			if opts.BySelectors != nil && !opts.BySelectors.matches(e.Selectors) {
+				continue
+			}
+			matched = append(matched, e)
+		}
+		if opts.WithTotal {
+			total = len(matched)
+		}
+
+		sort.Slice(matched, func(i, j int) bool {
+			return entryAfter(&matched[j].WorkloadEntry, CursorOf(&matched[i].WorkloadEntry))
+		})
+		if opts.After != nil {
+			matched = slices.DeleteFunc(matched, func(e memEntry) bool { return !entryAfter(&e.WorkloadEntry, opts.After) })
+		}
+
+		for _, e := range paginate(matched, opts.PageSize, 0) {
+			entries = append(entries, *s.entryWithSites(e))
+		}
+		return nil
+	})
+	return entries, total, nil
+}
+
+// entryAfter reports whether e comes after the cursor c in the listing order
+func entryAfter(e *WorkloadEntry, c *EntryCursor) bool {
+	if !e.CreatedAt.Equal(c.CreatedAt) {
+		return e.CreatedAt.Before(c.CreatedAt)
+	}
+	return e.ID < c.ID
+}
+
+// Delete marks a workload entry deleted and its site assignments deleting.
+// The entry remains as a tombstone until every site confirms the removal, or
+// is removed at once if it is assigned to no site.
+func (r *memEntryRepository) Delete(ctx context.Context, id string) error {
+	var siteIDs []string
+	err := r.db.update(func(s *memState) error {
+		e, ok := s.entries[id]
+		if !ok || e.DeletedAt != nil {
+			return fmt.Errorf("entry not found")
+		}
+		e.DeletedAt = timePtr(r.db.now())
+		s.entries[id] = e
+
+		for key, a := range s.assignments {
+			if key.entryID == id {
+				siteIDs = append(siteIDs, key.siteID)
+				a.SyncStatus = "deleting"
+				a.SyncError = nil
+				a.queued = s.seq()
+				a.queuedAt = r.db.now()
+				s.assignments[key] = a
+			}
+		}
+		if len(siteIDs) == 0 {
+			delete(s.entries, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.db.hub.Notify(siteIDs...)
+
+	return nil
+}
+
+// Purge removes a deleted entry's tombstone and any site assignments still
+// deleting, whether or not the sites have removed the entry
+func (r *memEntryRepository) Purge(ctx context.Context, id string) error {
+	return r.db.update(func(s *memState) error {
+		if e, ok := s.entries[id]; !ok || e.DeletedAt == nil {
+			return fmt.Errorf("deleted entry not found")
+		}
+		delete(s.entries, id)
+		for key := range s.assignments {
+			if key.entryID == id {
+				delete(s.assignments, key)
+			}
+		}
+		return nil
+	})
+}
+
+// AssignToSites assigns an entry to additional sites. Existing assignments,
+// unknown sites and deleted entries are skipped.
+func (r *memEntryRepository) AssignToSites(ctx context.Context, entryID string, siteIDs []string) error {
+	err := r.db.update(func(s *memState) error {
+		if e, ok := s.entries[entryID]; !ok || e.DeletedAt != nil {
+			return nil
+		}
+		for _, siteID := range siteIDs {
+			key := memAssignmentKey{siteID: siteID, entryID: entryID}
+			if _, ok := s.sites[siteID]; !ok {
+				continue
+			}
+			if _, ok := s.assignments[key]; ok {
+				continue
+			}
+			s.assignments[key] = memAssignment{
+				SiteWorkloadEntry: SiteWorkloadEntry{SiteID: siteID, WorkloadEntryID: entryID, SyncStatus: "pending"},
+				queued:            s.seq(),
+				queuedAt:          r.db.now(),
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.db.hub.Notify(siteIDs...)
+
+	return nil
+}
+
+// entryWithSites returns a copy of an entry with its site statuses
+func (s *memState) entryWithSites(e memEntry) *WorkloadEntryWithSites {
+	entry := e.WorkloadEntry
+	entry.Selectors = slices.Clone(e.Selectors)
+	return &WorkloadEntryWithSites{
+		WorkloadEntry: entry,
+		SiteStatuses:  s.siteStatuses(e.ID),
+	}
+}
+
+// siteStatuses returns site sync statuses for an entry, ordered by site name
+func (s *memState) siteStatuses(entryID string) []SiteWorkloadEntry {
+	var statuses []SiteWorkloadEntry
+	for key, a := range s.assignments {
+		if key.entryID != entryID {
+			continue
+		}
+		st := a.SiteWorkloadEntry
+		st.SiteName = s.sites[key.siteID].Name
+		statuses = append(statuses, st)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].SiteName < statuses[j].SiteName })
+	return statuses
+}
+
+// memSyncStatusRepository is the in-memory SyncStatusRepository
+type memSyncStatusRepository struct {
+	db *memDB
+}
+
+// GetPendingEntries returns entries that need to be synced to a site
+func (r *memSyncStatusRepository) GetPendingEntries(ctx context.Context, siteID string, maxEntries int) ([]PendingEntry, error) {
+	var entries []PendingEntry
+	r.db.view(func(s *memState) error {
+		var pending []memAssignment
+		for key, a := range s.assignments {
+			if key.siteID == siteID && a.SyncStatus == "pending" {
+				pending = append(pending, a)
+			}
+		}
+		sort.Slice(pending, func(i, j int) bool { return pending[i].queued < pending[j].queued })
+
+		for _, a := range paginate(pending, maxEntries, 0) {
+			e := s.entries[a.WorkloadEntryID]
+			entries = append(entries, PendingEntry{
+				WorkloadEntryID: e.ID,
+				SpiffeID:        e.SpiffeID,
+				ParentID:        e.ParentID,
+				Selectors:       slices.Clone(e.Selectors),
+				TTL:             e.TTL,
+			})
+		}
+		return nil
+	})
+	return entries, nil
+}
+
+// GetDeletionEntries returns entries marked for deletion from a site. Entries
+// the site never synced have no SPIRE entry ID.
+func (r *memSyncStatusRepository) GetDeletionEntries(ctx context.Context, siteID string, maxEntries int) ([]DeletionEntry, error) {
+	var entries []DeletionEntry
+	r.db.view(func(s *memState) error {
+		var deleting []memAssignment
+		for key, a := range s.assignments {
+			if key.siteID == siteID && a.SyncStatus == "deleting" {
+				deleting = append(deleting, a)
+			}
+		}
+		sort.Slice(deleting, func(i, j int) bool { return deleting[i].queued < deleting[j].queued })
+
+		for _, a := range paginate(deleting, maxEntries, 0) {
+			e := DeletionEntry{WorkloadEntryID: a.WorkloadEntryID}
+			if a.SpireEntryID != nil {
+				e.SpireEntryID = *a.SpireEntryID
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, nil
+}
+
+// UpdateSyncStatus updates the sync status for an entry at a site. An entry
+// being deleted stays deleting.
+func (r *memSyncStatusRepository) UpdateSyncStatus(ctx context.Context, siteID, entryID string, status string, spireEntryID string, errorMsg string) error {
+	if !validSyncStatuses[status] {
+		return fmt.Errorf("failed to update sync status: invalid status %q", status)
+	}
+
+	return r.db.update(func(s *memState) error {
+		key := memAssignmentKey{siteID: siteID, entryID: entryID}
+		a, ok := s.assignments[key]
+		if !ok {
+			return fmt.Errorf("site entry not found")
+		}
+
+		if a.SyncStatus != "deleting" {
+			a.SyncStatus = status
+		}
+		switch status {
+		case "synced":
+			a.SpireEntryID = &spireEntryID
+			a.LastSyncAt = timePtr(r.db.now())
+			a.SyncError = nil
+		case "failed":
+			a.SyncError = &errorMsg
+			a.LastSyncAt = timePtr(r.db.now())
+		case "pending":
+			a.queued = s.seq()
+			a.queuedAt = r.db.now()
+		}
+		s.assignments[key] = a
+		return nil
+	})
+}
+
+// RemoveSiteEntry removes the site assignment after successful deletion, and
+// the entry's tombstone once no site has the entry. It reports whether the
+// tombstone was removed.
+func (r *memSyncStatusRepository) RemoveSiteEntry(ctx context.Context, siteID, entryID string) (bool, error) {
+	var purged bool
+	err := r.db.update(func(s *memState) error {
+		key := memAssignmentKey{siteID: siteID, entryID: entryID}
+		if a, ok := s.assignments[key]; ok && a.SyncStatus == "deleting" {
+			delete(s.assignments, key)
+		}
+		e, ok := s.entries[entryID]
+		if !ok || e.DeletedAt == nil {
+			return nil
+		}
+		for key := range s.assignments {
+			if key.entryID == entryID {
+				return nil
+			}
+		}
+		delete(s.entries, entryID)
+		purged = true
+		return nil
+	})
+	return purged, err
+}
+
+// GetSyncStatuses returns sync statuses for an entry across all sites
+func (r *memSyncStatusRepository) GetSyncStatuses(ctx context.Context, entryID string) ([]SiteWorkloadEntry, error) {
+	var statuses []SiteWorkloadEntry
+	r.db.view(func(s *memState) error {
+		statuses = s.siteStatuses(entryID)
+		return nil
+	})
+	return statuses, nil
+}
+
+// Stats counts live entries and every site's assignments by sync status
+func (r *memSyncStatusRepository) Stats(ctx context.Context) (*SyncStats, error) {
+	stats := &SyncStats{}
+	r.db.view(func(s *memState) error {
+		for _, e := range s.entries {
+			if e.DeletedAt == nil {
+				stats.Entries++
+			}
+		}
+
+		bySite := make(map[string]*SiteSyncStats, len(s.sites))
+		for id := range s.sites {
+			bySite[id] = &SiteSyncStats{SiteID: id, Statuses: make(map[string]int)}
+		}
+		for key, a := range s.assignments {
+			site := bySite[key.siteID]
+			site.Statuses[a.SyncStatus]++
+			if a.SyncStatus != "pending" && a.SyncStatus != "deleting" {
+				continue
+			}
+			if site.OldestQueuedAt == nil || a.queuedAt.Before(*site.OldestQueuedAt) {
+				site.OldestQueuedAt = timePtr(a.queuedAt)
+			}
+		}
+		for _, site := range bySite {
+			stats.Sites = append(stats.Sites, *site)
+		}
+		return nil
+	})
+	sort.Slice(stats.Sites, func(i, j int) bool { return stats.Sites[i].SiteID < stats.Sites[j].SiteID })
+	return stats, nil
+}
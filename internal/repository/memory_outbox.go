@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// memUnitOfWork runs units of work on a copy of the in-memory state
+type memUnitOfWork struct {
+	db       *memDB
+	appended chan struct{}
+}
+
+// Do calls fn with repositories sharing a copy of the state and commits the
+// copy if fn succeeds. Other writes wait until it is done.
+func (u *memUnitOfWork) Do(ctx context.Context, fn func(tx *Tx) error) error {
+	u.db.mu.Lock()
+	defer u.db.mu.Unlock()
+
+	// Agents are woken by the relay once the events are committed
+	db := &memDB{state: u.db.state.clone(), now: u.db.now}
+	outbox := &memOutboxRepository{db: db}
+	err := fn(&Tx{
+		Sites:           &memSiteRepository{db: db},
+		Entries:         &memEntryRepository{db: db},
+		SyncStatus:      &memSyncStatusRepository{db: db},
+		ChangeRequests:  &memChangeRequestRepository{db: db},
+		APIKeys:         &memAPIKeyRepository{db: db},
+		RoleBindings:    &memRoleBindingRepository{db: db},
+		NamespaceGrants: &memNamespaceGrantRepository{db: db},
+		Audit:           &memAuditRepository{db: db},
+		Outbox:          outbox,
+	})
+	if err != nil {
+		return err
+	}
+
+	u.db.state = db.state
+	if outbox.appendedEvents {
+		signal(u.appended)
+	}
+	return nil
+}
+
+// memOutboxRepository is the in-memory OutboxRepository
+type memOutboxRepository struct {
+	db *memDB
+	// appended is nil within a unit of work, which signals after commit
+	appended chan struct{}
+	// appendedEvents records an Append within a unit of work
+	appendedEvents bool
+
+	// deliverMu serializes deliveries, so each event is delivered once
+	// unless fn fails
+	deliverMu sync.Mutex
+}
+
+// Append records an event
+func (r *memOutboxRepository) Append(ctx context.Context, e *Event) error {
+	err := r.db.update(func(s *memState) error {
+		row := *e
+		row.ID = s.seq()
+		row.SiteIDs = slices.Clone(e.SiteIDs)
+		if row.SiteIDs == nil {
+			row.SiteIDs = []string{}
+		}
+		row.Payload = maps.Clone(e.Payload)
+		row.CreatedAt = r.db.now()
+		s.outbox = append(s.outbox, row)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.appendedEvents = true
+	if r.appended != nil {
+		// Outside a unit of work the event is already committed
+		signal(r.appended)
+	}
+	return nil
+}
+
+// Deliver passes undelivered events to fn and removes those it accepts
+func (r *memOutboxRepository) Deliver(ctx context.Context, limit int, fn func(e Event) error) (int, error) {
+	r.deliverMu.Lock()
+	defer r.deliverMu.Unlock()
+
+	var events []Event
+	r.db.view(func(s *memState) error {
+		events = slices.Clone(s.outbox[:min(limit, len(s.outbox))])
+		return nil
+	})
+
+	delivered := 0
+	var deliverErr error
+	for _, e := range events {
+		e.SiteIDs = slices.Clone(e.SiteIDs)
+		e.Payload = maps.Clone(e.Payload)
+		if deliverErr = fn(e); deliverErr != nil {
+			break
+		}
+		delivered++
+	}
+	if delivered == 0 {
+		return 0, deliverErr
+	}
+
+	// Only deliveries remove events, so the delivered ones are still first
+	err := r.db.update(func(s *memState) error {
+		s.outbox = s.outbox[delivered:]
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return delivered, deliverErr
+}
+
+// Appended receives a value after events are committed
+func (r *memOutboxRepository) Appended() <-chan struct{} {
+	return r.appended
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// memStateRepository is the in-memory StateRepository
+type memStateRepository struct {
+	db *memDB
+}
+
+// Export reads the desired state
+func (r *memStateRepository) Export(ctx context.Context) (*State, error) {
+	state := &State{}
+	r.db.view(func(s *memState) error {
+		for _, site := range s.sites {
+			site.LastSyncAt = nil
+			state.Sites = append(state.Sites, site)
+		}
+		for _, e := range s.entries {
+			entry := e.WorkloadEntry
+			entry.Selectors = slices.Clone(e.Selectors)
+			state.Entries = append(state.Entries, entry)
+		}
+		for key, a := range s.assignments {
+			assignment := Assignment{SiteID: key.siteID, WorkloadEntryID: key.entryID}
+			if a.SpireEntryID != nil {
+				assignment.SpireEntryID = *a.SpireEntryID
+			}
+			state.Assignments = append(state.Assignments, assignment)
+		}
+		for _, g := range s.grants {
+			state.NamespaceGrants = append(state.NamespaceGrants, g.copy())
+		}
+		return nil
+	})
+
+	sort.Slice(state.Sites, func(i, j int) bool { return state.Sites[i].ID < state.Sites[j].ID })
+	sort.Slice(state.Entries, func(i, j int) bool { return state.Entries[i].ID < state.Entries[j].ID })
+	sort.Slice(state.Assignments, func(i, j int) bool {
+		a, b := state.Assignments[i], state.Assignments[j]
+		if a.WorkloadEntryID != b.WorkloadEntryID {
+			return a.WorkloadEntryID < b.WorkloadEntryID
+		}
+		return a.SiteID < b.SiteID
+	})
+	sort.Slice(state.NamespaceGrants, func(i, j int) bool {
+		return state.NamespaceGrants[i].ID < state.NamespaceGrants[j].ID
+	})
+	return state, nil
+}
+
+// Import writes state and queues every assignment for reconciliation
+func (r *memStateRepository) Import(ctx context.Context, state *State, mode ImportMode) (int, error) {
+	var siteIDs []string
+	queued := 0
+	err := r.db.update(func(s *memState) error {
+		if mode == ImportReplace {
+			clear(s.sites)
+			clear(s.entries)
+			clear(s.assignments)
+			clear(s.agents)
+			clear(s.grants)
+		}
+
+		for _, site := range state.Sites {
+			for _, existing := range s.sites {
+				if existing.Name == site.Name && existing.ID != site.ID {
+					return fmt.Errorf("failed to import site %s: %w: name %s", site.ID, errDuplicateKey, site.Name)
+				}
+			}
+			row := site
+			row.LastSyncAt = s.sites[site.ID].LastSyncAt
+			s.sites[row.ID] = row
+		}
+
+		for _, entry := range state.Entries {
+			for _, existing := range s.entries {
+				if existing.SpiffeID == entry.SpiffeID && existing.ID != entry.ID {
+					return fmt.Errorf("failed to import workload entry %s: %w: spiffe_id %s", entry.ID, errDuplicateKey, entry.SpiffeID)
+				}
+			}
+			row := memEntry{WorkloadEntry: entry, seq: s.seq()}
+			if existing, ok := s.entries[entry.ID]; ok {
+				row.seq = existing.seq
+			}
+			row.Selectors = slices.Clone(entry.Selectors)
+			s.entries[row.ID] = row
+		}
+
+		for _, a := range state.Assignments {
+			key := memAssignmentKey{siteID: a.SiteID, entryID: a.WorkloadEntryID}
+			if _, ok := s.sites[a.SiteID]; !ok {
+				return fmt.Errorf("failed to import assignment of entry %s to site %s: %w", a.WorkloadEntryID, a.SiteID, errForeignKey)
+			}
+			if _, ok := s.entries[a.WorkloadEntryID]; !ok {
+				return fmt.Errorf("failed to import assignment of entry %s to site %s: %w", a.WorkloadEntryID, a.SiteID, errForeignKey)
+			}
+			if _, ok := s.assignments[key]; ok {
+				continue
+			}
+			row := memAssignment{SiteWorkloadEntry: SiteWorkloadEntry{SiteID: a.SiteID, WorkloadEntryID: a.WorkloadEntryID}}
+			if a.SpireEntryID != "" {
+				spireEntryID := a.SpireEntryID
+				row.SpireEntryID = &spireEntryID
+			}
+			s.assignments[key] = row
+		}
+
+		for _, g := range state.NamespaceGrants {
+			row := memGrant{NamespaceGrant: g, seq: s.seq()}
+			if existing, ok := s.grants[g.ID]; ok {
+				row.seq = existing.seq
+			}
+			row.Namespaces = slices.Clone(g.Namespaces)
+			row.SpiffeIDPrefixes = nonNil(slices.Clone(g.SpiffeIDPrefixes))
+			row.SiteIDs = nonNil(slices.Clone(g.SiteIDs))
+			s.grants[row.ID] = row
+		}
+
+		queued = len(s.assignments)
+		seen := make(map[string]bool)
+		for key, a := range s.assignments {
+			a.SyncStatus = "pending"
+			if s.entries[key.entryID].DeletedAt != nil {
+				a.SyncStatus = "deleting"
+			}
+			a.SyncError = nil
+			a.queued = s.seq()
+			a.queuedAt = r.db.now()
+			s.assignments[key] = a
+			if !seen[key.siteID] {
+				seen[key.siteID] = true
+				siteIDs = append(siteIDs, key.siteID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	r.db.hub.Notify(siteIDs...)
+	return queued, nil
+}
@@ -1,60 +0,0 @@
-package repository
-
-import (
-	"database/sql"
-	"fmt"
-	"os"
-	"time"
-
-	_ "github.com/go-sql-driver/mysql"
-)
-
-// Config holds database configuration
-type Config struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	Database string
-}
-
-// ConfigFromEnv creates Config from environment variables
-func ConfigFromEnv() Config {
-	return Config{
-		Host:     getEnv("DB_HOST", "localhost"),
-		Port:     getEnv("DB_PORT", "3306"),
-		User:     getEnv("DB_USER", "root"),
-		Password: getEnv("DB_PASSWORD", "demo-password"),
-		Database: getEnv("DB_NAME", "spire_mgmt"),
-	}
-}
-
-// NewDB creates a new database connection pool
-func NewDB(cfg Config) (*sql.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&loc=UTC",
-		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
-
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
-
-	// Configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
-
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
-	}
-
-	return db, nil
-}
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
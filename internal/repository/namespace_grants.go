@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NamespaceGrant limits the workload entries a developer may manage. An entry
+// is allowed if it selects one of the namespaces, its SPIFFE ID falls under
+// one of the prefixes and it is only assigned to the listed sites. Empty
+// prefix or site lists do not restrict that dimension.
+type NamespaceGrant struct {
+	ID               string
+	SubjectKind      string
+	Subject          string
+	Namespaces       []string
+	SpiffeIDPrefixes []string
+	SiteIDs          []string
+	CreatedBy        string
+	CreatedAt        time.Time
+}
+
+// sqlNamespaceGrantRepository handles namespace grant database operations
+type sqlNamespaceGrantRepository struct {
+	db *sqlDB
+}
+
+// NewNamespaceGrantRepository creates a NamespaceGrantRepository backed by MySQL or PostgreSQL
+func NewNamespaceGrantRepository(db *sql.DB) NamespaceGrantRepository {
+	return &sqlNamespaceGrantRepository{db: newSQLDB(db)}
+}
+
+const namespaceGrantColumns = `id, subject_kind, subject, namespaces, spiffe_id_prefixes, site_ids, created_by, created_at`
+
+// Create inserts a new namespace grant
+func (r *sqlNamespaceGrantRepository) Create(ctx context.Context, g *NamespaceGrant) error {
+	if g.ID == "" {
+		g.ID = uuid.New().String()
+	}
+
+	namespaces, err := json.Marshal(g.Namespaces)
+	if err != nil {
+		return fmt.Errorf("failed to marshal namespaces: %w", err)
+	}
+	prefixes, err := json.Marshal(nonNil(g.SpiffeIDPrefixes))
+	if err != nil {
+		return fmt.Errorf("failed to marshal SPIFFE ID prefixes: %w", err)
+	}
+	siteIDs, err := json.Marshal(nonNil(g.SiteIDs))
+	if err != nil {
+		return fmt.Errorf("failed to marshal site IDs: %w", err)
+	}
+
+	query := `INSERT INTO namespace_grants (id, subject_kind, subject, namespaces, spiffe_id_prefixes, site_ids, created_by)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err = r.db.ExecContext(ctx, query, g.ID, g.SubjectKind, g.Subject, string(namespaces), string(prefixes),
+		string(siteIDs), g.CreatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to insert namespace grant: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns a namespace grant by ID
+func (r *sqlNamespaceGrantRepository) Get(ctx context.Context, id string) (*NamespaceGrant, error) {
+	query := `SELECT ` + namespaceGrantColumns + ` FROM namespace_grants WHERE id = ?`
+
+	g, err := scanNamespaceGrant(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get namespace grant: %w", err)
+	}
+
+	return g, nil
+}
+
+// List returns namespace grants, optionally filtered by subject kind and subject
+func (r *sqlNamespaceGrantRepository) List(ctx context.Context, subjectKind, subject string) ([]NamespaceGrant, error) {
+	query := `SELECT ` + namespaceGrantColumns + ` FROM namespace_grants WHERE 1=1`
+	args := []interface{}{}
+
+	if subjectKind != "" {
+		query += " AND subject_kind = ?"
+		args = append(args, subjectKind)
+	}
+	if subject != "" {
+		query += " AND subject = ?"
+		args = append(args, subject)
+	}
+
+	query += " ORDER BY subject_kind, subject, created_at"
+
+	return r.query(ctx, query, args...)
+}
+
+// ListForSubjects returns the grants held by any of the given subjects, keyed
+// by subject kind
+func (r *sqlNamespaceGrantRepository) ListForSubjects(ctx context.Context, subjects map[string][]string) ([]NamespaceGrant, error) {
+	var conds []string
+	var args []interface{}
+	for kind, names := range subjects {
+		if len(names) == 0 {
+			continue
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(names)), ",")
+		conds = append(conds, "(subject_kind = ? AND subject IN ("+placeholders+"))")
+		args = append(args, kind)
+		for _, n := range names {
+			args = append(args, n)
+		}
+	}
+	if len(conds) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT ` + namespaceGrantColumns + ` FROM namespace_grants WHERE ` + strings.Join(conds, " OR ")
+	return r.query(ctx, query, args...)
+}
+
+// Delete removes a namespace grant
+func (r *sqlNamespaceGrantRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM namespace_grants WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete namespace grant: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("namespace grant not found")
+	}
+
+	return nil
+}
+
+func (r *sqlNamespaceGrantRepository) query(ctx context.Context, query string, args ...interface{}) ([]NamespaceGrant, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespace grants: %w", err)
+	}
+	defer rows.Close()
+
+	var grants []NamespaceGrant
+	for rows.Next() {
+		g, err := scanNamespaceGrant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan namespace grant: %w", err)
+		}
+		grants = append(grants, *g)
+	}
+
+	return grants, rows.Err()
+}
+
+func scanNamespaceGrant(row rowScanner) (*NamespaceGrant, error) {
+	var g NamespaceGrant
+	var namespaces, prefixes, siteIDs []byte
+
+	if err := row.Scan(&g.ID, &g.SubjectKind, &g.Subject, &namespaces, &prefixes, &siteIDs,
+		&g.CreatedBy, &g.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(namespaces, &g.Namespaces); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal namespaces: %w", err)
+	}
+	if err := json.Unmarshal(prefixes, &g.SpiffeIDPrefixes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal SPIFFE ID prefixes: %w", err)
+	}
+	if err := json.Unmarshal(siteIDs, &g.SiteIDs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal site IDs: %w", err)
+	}
+
+	return &g, nil
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Domain event types
+const (
+	EventEntryCreated  = "workload_entry.created"
+	EventEntryDeleted  = "workload_entry.deleted"
+	EventEntryAssigned = "workload_entry.assigned"
+)
+
+// Event is a domain event recorded in the outbox by the unit of work that
+// made the change it describes
+type Event struct {
+	ID           int64
+	Type         string
+	ResourceType string
+	ResourceID   string
+	// SiteIDs are the sites whose desired state the change affects
+	SiteIDs   []string
+	Actor     string
+	Payload   map[string]interface{}
+	CreatedAt time.Time
+}
+
+// sqlUnitOfWork runs units of work in MySQL or PostgreSQL transactions
+type sqlUnitOfWork struct {
+	db       *sqlDB
+	appended chan struct{}
+}
+
+// Do calls fn with repositories sharing one transaction and commits it if fn
+// succeeds
+func (u *sqlUnitOfWork) Do(ctx context.Context, fn func(tx *Tx) error) error {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	db := u.db.withTx(tx)
+	outbox := &sqlOutboxRepository{db: db}
+	err = fn(&Tx{
+		// Agents are woken by the relay once the events are committed
+		Sites:           &sqlSiteRepository{db: db},
+		Entries:         &sqlEntryRepository{db: db},
+		SyncStatus:      &sqlSyncStatusRepository{db: db},
+		ChangeRequests:  &sqlChangeRequestRepository{db: db},
+		APIKeys:         &sqlAPIKeyRepository{db: db},
+		RoleBindings:    &sqlRoleBindingRepository{db: db},
+		NamespaceGrants: &sqlNamespaceGrantRepository{db: db},
+		Audit:           &sqlAuditRepository{db: db},
+		Outbox:          outbox,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if outbox.appendedEvents {
+		signal(u.appended)
+	}
+	return nil
+}
+
+// signal wakes the receiver of ch unless a wake-up is already pending
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// sqlOutboxRepository handles outbox database operations
+type sqlOutboxRepository struct {
+	db *sqlDB
+	// appended is nil within a unit of work, which signals after commit
+	appended chan struct{}
+	// appendedEvents records an Append within a unit of work
+	appendedEvents bool
+}
+
+// Append records an event
+func (r *sqlOutboxRepository) Append(ctx context.Context, e *Event) error {
+	siteIDs := e.SiteIDs
+	if siteIDs == nil {
+		siteIDs = []string{}
+	}
+	siteIDsJSON, err := json.Marshal(siteIDs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal site ids: %w", err)
+	}
+	payloadJSON, err := json.Marshal(e.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	query := `INSERT INTO outbox_events (event_type, resource_type, resource_id, site_ids, actor, payload)
+	          VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := r.db.ExecContext(ctx, query, e.Type, e.ResourceType, e.ResourceID,
+		string(siteIDsJSON), e.Actor, string(payloadJSON)); err != nil {
+		return fmt.Errorf("failed to insert outbox event: %w", err)
+	}
+	r.appendedEvents = true
+	if r.appended != nil {
+		// Outside a unit of work the event is already committed
+		signal(r.appended)
+	}
+	return nil
+}
+
+// Deliver passes undelivered events to fn and deletes those it accepts. The
+// events are locked until then, and locked events are skipped, so concurrent
+// relays on several servers deliver each event once unless fn fails.
+func (r *sqlOutboxRepository) Deliver(ctx context.Context, limit int, fn func(e Event) error) (int, error) {
+	// Read committed takes no gap locks, which would block new events
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `SELECT id, event_type, resource_type, resource_id, site_ids, actor, payload, created_at
+	          FROM outbox_events ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var siteIDsJSON, payloadJSON []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.ResourceType, &e.ResourceID, &siteIDsJSON, &e.Actor,
+			&payloadJSON, &e.CreatedAt); err != nil {
+			return 0, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		if err := json.Unmarshal(siteIDsJSON, &e.SiteIDs); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal site ids: %w", err)
+		}
+		if err := json.Unmarshal(payloadJSON, &e.Payload); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	rows.Close()
+
+	var delivered []interface{}
+	var deliverErr error
+	for _, e := range events {
+		if deliverErr = fn(e); deliverErr != nil {
+			break
+		}
+		delivered = append(delivered, e.ID)
+	}
+	if len(delivered) == 0 {
+		return 0, deliverErr
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(delivered)), ",")
+	if _, err := tx.ExecContext(ctx, `DELETE FROM outbox_events WHERE id IN (`+placeholders+`)`, delivered...); err != nil {
+		return 0, fmt.Errorf("failed to remove delivered events: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit delivered events: %w", err)
+	}
+	return len(delivered), deliverErr
+}
+
+// Appended receives a value after events are committed
+func (r *sqlOutboxRepository) Appended() <-chan struct{} {
+	return r.appended
+}
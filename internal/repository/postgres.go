@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// sslRequestCode asks a PostgreSQL server to switch the connection to TLS
+const sslRequestCode = 80877103
+
+// postgresOpener returns a function creating PostgreSQL connectors. TLS is
+// negotiated by the dialer rather than by pq, so that both drivers share the
+// same TLS configuration.
+func postgresOpener(cfg Config, tlsConfig *tls.Config) func(password string) (driver.Connector, error) {
+	dialer := &pgDialer{
+		dialer:       net.Dialer{Timeout: cfg.DialTimeout},
+		tlsConfig:    tlsConfig,
+		readTimeout:  cfg.ReadTimeout,
+		writeTimeout: cfg.WriteTimeout,
+	}
+
+	return func(password string) (driver.Connector, error) {
+		params := []string{
+			"host=" + pgQuote(cfg.Host),
+			"port=" + pgQuote(cfg.Port),
+			"user=" + pgQuote(cfg.User),
+			"password=" + pgQuote(password),
+			"dbname=" + pgQuote(cfg.Database),
+			"sslmode=disable",
+			// Sessions work in UTC like the MySQL driver's connections
+			"timezone=UTC",
+		}
+		pc, err := pq.NewConnector(strings.Join(params, " "))
+		if err != nil {
+			return nil, fmt.Errorf("invalid PostgreSQL configuration: %w", err)
+		}
+		pc.Dialer(dialer)
+		return pc, nil
+	}
+}
+
+// pgQuote quotes a connection string value
+func pgQuote(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// pgDialer opens connections to a PostgreSQL server, upgrading them to TLS
+// when configured and applying read and write timeouts
+type pgDialer struct {
+	dialer       net.Dialer
+	tlsConfig    *tls.Config
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// Dial implements pq.Dialer
+func (d *pgDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialTimeout implements pq.Dialer
+func (d *pgDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
+
+// DialContext implements pq.DialerContext
+func (d *pgDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if d.tlsConfig != nil {
+		if conn, err = startTLS(ctx, conn, d.tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+	return &deadlineConn{Conn: conn, readTimeout: d.readTimeout, writeTimeout: d.writeTimeout}, nil
+}
+
+// startTLS sends an SSLRequest and performs the TLS handshake if the server
+// accepts it. A server that refuses TLS is an error, never a fallback.
+func startTLS(ctx context.Context, conn net.Conn, tlsConfig *tls.Config) (net.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], sslRequestCode)
+	if _, err := conn.Write(request); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to request TLS: %w", err)
+	}
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to request TLS: %w", err)
+	}
+	if reply[0] != 'S' {
+		conn.Close()
+		return nil, fmt.Errorf("PostgreSQL server does not support TLS")
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	return tlsConn, nil
+}
+
+// deadlineConn bounds each network read and write, like the MySQL driver's
+// read and write timeouts
+type deadlineConn struct {
+	net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	if c.readTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *deadlineConn) Write(b []byte) (int, error) {
+	if c.writeTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	return c.Conn.Write(b)
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// session tracks whether a request has written to the primary
+type session struct {
+	wrote atomic.Bool
+}
+
+type sessionKey struct{}
+
+// WithSession returns a context whose reads may go to a replica until the
+// first write made with it, and go to the primary from then on, so a request
+// reads its own writes. Reads outside a session always go to the primary.
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// noSession stands in for a session in a context made by WithoutSession
+type noSession struct{}
+
+// WithoutSession returns a context outside any session of ctx, for writes
+// that the request's later reads do not depend on, so they do not send those
+// reads to the primary
+func WithoutSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, noSession{})
+}
+
+// markWritten records a write in the session of ctx, if any
+func markWritten(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.wrote.Store(true)
+	}
+}
+
+// reader returns the database for a read that tolerates replication lag: the
+// replica, unless there is none, the read is part of a transaction, or the
+// session of ctx has written or is missing
+func (db *sqlDB) reader(ctx context.Context) *sqlDB {
+	if db.replica == nil || db.tx != nil {
+		return db
+	}
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	if !ok || s.wrote.Load() {
+		return db
+	}
+	return db.replica
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSessionReads(t *testing.T) {
+	db := (&sqlDB{}).withReplica(nil)
+
+	if got := db.reader(context.Background()); got != db {
+		t.Error("read outside a session went to the replica")
+	}
+
+	ctx := WithSession(context.Background())
+	if got := db.reader(ctx); got != db.replica {
+		t.Error("read before the session's first write went to the primary")
+	}
+
+	// A write made without the session leaves its reads on the replica
+	markWritten(WithoutSession(ctx))
+	if got := db.reader(ctx); got != db.replica {
+		t.Error("write outside the session sent its reads to the primary")
+	}
+	if got := db.reader(WithoutSession(ctx)); got != db {
+		t.Error("read outside the session went to the replica")
+	}
+
+	markWritten(ctx)
+	if got := db.reader(ctx); got != db {
+		t.Error("read after the session's write went to the replica")
+	}
+	if got := db.reader(WithSession(context.Background())); got != db.replica {
+		t.Error("a write in one session sent another session's reads to the primary")
+	}
+}
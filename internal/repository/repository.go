@@ -0,0 +1,204 @@
+// Package repository stores the management plane's state. Each repository is
+// an interface with a SQL implementation, for MySQL and PostgreSQL, and an
+// in-memory implementation for local development and tests; a Store bundles
+// one of either.
+package repository
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"time"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/notify"
+)
+
+// SiteRepository stores SPIRE server sites
+type SiteRepository interface {
+	List(ctx context.Context, status string) ([]Site, error)
+	Get(ctx context.Context, id string) (*Site, error)
+	Create(ctx context.Context, s *Site) error
+	ProtectedSiteIDs(ctx context.Context, siteIDs []string) ([]string, error)
+	UpdateLastSyncAt(ctx context.Context, id string) error
+	MarkDisconnected(ctx context.Context, timeout time.Duration) ([]string, error)
+	MarkConnected(ctx context.Context, id string) (bool, error)
+}
+
+// EntryRepository stores workload entries and their site assignments
+type EntryRepository interface {
+	Create(ctx context.Context, entry *WorkloadEntry, siteIDs []string) (*WorkloadEntryWithSites, error)
+	Get(ctx context.Context, id string) (*WorkloadEntryWithSites, error)
+	List(ctx context.Context, opts EntryListOptions) ([]WorkloadEntryWithSites, int, error)
+	Delete(ctx context.Context, id string) error
+	Purge(ctx context.Context, id string) error
+	AssignToSites(ctx context.Context, entryID string, siteIDs []string) error
+}
+
+// SyncStatusRepository tracks the sync state of entries at each site
+type SyncStatusRepository interface {
+	GetPendingEntries(ctx context.Context, siteID string, maxEntries int) ([]PendingEntry, error)
+	GetDeletionEntries(ctx context.Context, siteID string, maxEntries int) ([]DeletionEntry, error)
+	UpdateSyncStatus(ctx context.Context, siteID, entryID string, status string, spireEntryID string, errorMsg string) error
+	RemoveSiteEntry(ctx context.Context, siteID, entryID string) (bool, error)
+	GetSyncStatuses(ctx context.Context, entryID string) ([]SiteWorkloadEntry, error)
+	Stats(ctx context.Context) (*SyncStats, error)
+}
+
+// AuditLogger appends to the hash-chained audit log
+type AuditLogger interface {
+	Log(ctx context.Context, actor, action, resourceType, resourceID string, details map[string]interface{}) error
+}
+
+// AuditRepository stores the hash-chained audit log
+type AuditRepository interface {
+	AuditLogger
+	List(ctx context.Context, pageSize, offset int, resourceType, resourceID, actor string, startTime, endTime *time.Time) ([]AuditLogEntry, error)
+	Checkpoint(ctx context.Context, key ed25519.PrivateKey) (int, error)
+	Verify(ctx context.Context, stream string, key ed25519.PublicKey) (*AuditVerification, error)
+	ListExpired(ctx context.Context, retention AuditRetention, now time.Time, limit int) ([]ArchivedAuditEntry, error)
+	Prune(ctx context.Context, archive *AuditArchive, entries []ArchivedAuditEntry, key ed25519.PrivateKey) error
+	GetArchive(ctx context.Context, name string) (*AuditArchive, error)
+	Restore(ctx context.Context, archive string, entries []ArchivedAuditEntry) (int, error)
+	ManagePartitions(ctx context.Context, now time.Time, ahead int) (added, dropped int, err error)
+}
+
+// AgentRepository stores the site agent inventory
+type AgentRepository interface {
+	RecordHeartbeat(ctx context.Context, a *Agent) error
+	List(ctx context.Context, siteID string) ([]Agent, error)
+}
+
+// APIKeyRepository stores API keys
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) error
+	Get(ctx context.Context, id string) (*APIKey, error)
+	GetByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	List(ctx context.Context) ([]APIKey, error)
+	Rotate(ctx context.Context, id, keyHash, keyPrefix string) error
+	Revoke(ctx context.Context, id string) error
+	TouchLastUsed(ctx context.Context, id string) error
+}
+
+// RoleBindingRepository stores RBAC role bindings
+type RoleBindingRepository interface {
+	Create(ctx context.Context, b *RoleBinding) error
+	Get(ctx context.Context, id string) (*RoleBinding, error)
+	List(ctx context.Context, subjectKind, subject string) ([]RoleBinding, error)
+	Delete(ctx context.Context, id string) error
+	RolesForSubjects(ctx context.Context, subjects map[string][]string) ([]string, error)
+}
+
+// NamespaceGrantRepository stores developer namespace grants
+type NamespaceGrantRepository interface {
+	Create(ctx context.Context, g *NamespaceGrant) error
+	Get(ctx context.Context, id string) (*NamespaceGrant, error)
+	List(ctx context.Context, subjectKind, subject string) ([]NamespaceGrant, error)
+	ListForSubjects(ctx context.Context, subjects map[string][]string) ([]NamespaceGrant, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// ChangeRequestRepository stores change requests for protected sites
+type ChangeRequestRepository interface {
+	Create(ctx context.Context, c *ChangeRequest) error
+	Get(ctx context.Context, id string) (*ChangeRequest, error)
+	List(ctx context.Context, status, entryID string) ([]ChangeRequest, error)
+	Review(ctx context.Context, id, status, reviewer, comment string) (bool, error)
+	MarkApplied(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id, applyError string) error
+}
+
+// OutboxWriter records domain events for the relay to publish
+type OutboxWriter interface {
+	Append(ctx context.Context, e *Event) error
+}
+
+// OutboxRepository stores domain events until they are delivered
+type OutboxRepository interface {
+	OutboxWriter
+	// Deliver passes up to limit undelivered events to fn, oldest first,
+	// stopping at the first error. Events fn accepted are removed; the rest
+	// are delivered again by a later call. It returns the number accepted.
+	Deliver(ctx context.Context, limit int, fn func(e Event) error) (int, error)
+	// Appended receives a value after a unit of work in this process commits
+	// events
+	Appended() <-chan struct{}
+}
+
+// Tx holds the repositories of a unit of work, which all write through the
+// unit's transaction
+type Tx struct {
+	Sites           SiteRepository
+	Entries         EntryRepository
+	SyncStatus      SyncStatusRepository
+	ChangeRequests  ChangeRequestRepository
+	APIKeys         APIKeyRepository
+	RoleBindings    RoleBindingRepository
+	NamespaceGrants NamespaceGrantRepository
+	Audit           AuditLogger
+	Outbox          OutboxWriter
+}
+
+// UnitOfWork runs a set of writes that commit or fail together
+type UnitOfWork interface {
+	// Do calls fn with the repositories of a new transaction, which commits
+	// if fn returns nil and rolls back otherwise. fn must return every error
+	// of the writes it makes and must not use repositories outside tx.
+	Do(ctx context.Context, fn func(tx *Tx) error) error
+}
+
+// StateRepository exports and imports the desired state, to rebuild a lost
+// database
+type StateRepository interface {
+	Export(ctx context.Context) (*State, error)
+	// Import writes state in one transaction and queues every assignment for
+	// reconciliation, returning the number queued
+	Import(ctx context.Context, state *State, mode ImportMode) (int, error)
+}
+
+// Store bundles the repositories of one storage backend
+type Store struct {
+	Sites           SiteRepository
+	Entries         EntryRepository
+	SyncStatus      SyncStatusRepository
+	Audit           AuditRepository
+	Agents          AgentRepository
+	APIKeys         APIKeyRepository
+	RoleBindings    RoleBindingRepository
+	NamespaceGrants NamespaceGrantRepository
+	ChangeRequests  ChangeRequestRepository
+	Outbox          OutboxRepository
+	UnitOfWork      UnitOfWork
+	State           StateRepository
+}
+
+// NewSQLStore creates a Store backed by the MySQL or PostgreSQL database db
+// was opened for by NewDB. The hub is notified of sites with new work; it may
+// be nil.
+func NewSQLStore(db *sql.DB, hub *notify.Hub) *Store {
+	return newSQLStore(newSQLDB(db), hub)
+}
+
+// NewReplicatedSQLStore creates a Store like NewSQLStore that sends listings,
+// lookups and audit reads made within a session (see WithSession) to a
+// read replica of primary, until the session writes
+func NewReplicatedSQLStore(primary, replica *sql.DB, hub *notify.Hub) *Store {
+	return newSQLStore(newSQLDB(primary).withReplica(replica), hub)
+}
+
+func newSQLStore(db *sqlDB, hub *notify.Hub) *Store {
+	appended := make(chan struct{}, 1)
+	return &Store{
+		Sites:           &sqlSiteRepository{db: db},
+		Entries:         &sqlEntryRepository{db: db, hub: hub},
+		SyncStatus:      &sqlSyncStatusRepository{db: db},
+		Audit:           &sqlAuditRepository{db: db},
+		Agents:          &sqlAgentRepository{db: db},
+		APIKeys:         &sqlAPIKeyRepository{db: db},
+		RoleBindings:    &sqlRoleBindingRepository{db: db},
+		NamespaceGrants: &sqlNamespaceGrantRepository{db: db},
+		ChangeRequests:  &sqlChangeRequestRepository{db: db},
+		Outbox:          &sqlOutboxRepository{db: db, appended: appended},
+		UnitOfWork:      &sqlUnitOfWork{db: db, appended: appended},
+		State:           &sqlStateRepository{db: db, hub: hub},
+	}
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoleBinding grants a role to a user, group or API key
+type RoleBinding struct {
+	ID          string
+	Role        string
+	SubjectKind string
+	Subject     string
+	CreatedBy   string
+	CreatedAt   time.Time
+}
+
+// sqlRoleBindingRepository handles role binding database operations
+type sqlRoleBindingRepository struct {
+	db *sqlDB
+}
+
+// NewRoleBindingRepository creates a RoleBindingRepository backed by MySQL or PostgreSQL
+func NewRoleBindingRepository(db *sql.DB) RoleBindingRepository {
+	return &sqlRoleBindingRepository{db: newSQLDB(db)}
+}
+
+// Create inserts a new role binding
+func (r *sqlRoleBindingRepository) Create(ctx context.Context, b *RoleBinding) error {
+	if b.ID == "" {
+		b.ID = uuid.New().String()
+	}
+
+	query := `INSERT INTO role_bindings (id, role, subject_kind, subject, created_by) VALUES (?, ?, ?, ?, ?)`
+	if _, err := r.db.ExecContext(ctx, query, b.ID, b.Role, b.SubjectKind, b.Subject, b.CreatedBy); err != nil {
+		return fmt.Errorf("failed to insert role binding: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns a role binding by ID
+func (r *sqlRoleBindingRepository) Get(ctx context.Context, id string) (*RoleBinding, error) {
+	query := `SELECT id, role, subject_kind, subject, created_by, created_at FROM role_bindings WHERE id = ?`
+
+	var b RoleBinding
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&b.ID, &b.Role, &b.SubjectKind, &b.Subject, &b.CreatedBy, &b.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role binding: %w", err)
+	}
+
+	return &b, nil
+}
+
+// List returns role bindings, optionally filtered by subject kind and subject
+func (r *sqlRoleBindingRepository) List(ctx context.Context, subjectKind, subject string) ([]RoleBinding, error) {
+	query := `SELECT id, role, subject_kind, subject, created_by, created_at FROM role_bindings WHERE 1=1`
+	args := []interface{}{}
+
+	if subjectKind != "" {
+		query += " AND subject_kind = ?"
+		args = append(args, subjectKind)
+	}
+	if subject != "" {
+		query += " AND subject = ?"
+		args = append(args, subject)
+	}
+
+	query += " ORDER BY subject_kind, subject, role"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+	defer rows.Close()
+
+	var bindings []RoleBinding
+	for rows.Next() {
+		var b RoleBinding
+		if err := rows.Scan(&b.ID, &b.Role, &b.SubjectKind, &b.Subject, &b.CreatedBy, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan role binding: %w", err)
+		}
+		bindings = append(bindings, b)
+	}
+
+	return bindings, rows.Err()
+}
+
+// Delete removes a role binding
+func (r *sqlRoleBindingRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM role_bindings WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete role binding: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("role binding not found")
+	}
+
+	return nil
+}
+
+// RolesForSubjects returns the distinct roles bound to any of the given
+// subjects, keyed by subject kind
+func (r *sqlRoleBindingRepository) RolesForSubjects(ctx context.Context, subjects map[string][]string) ([]string, error) {
+	var conds []string
+	var args []interface{}
+	for kind, names := range subjects {
+		if len(names) == 0 {
+			continue
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(names)), ",")
+		conds = append(conds, "(subject_kind = ? AND subject IN ("+placeholders+"))")
+		args = append(args, kind)
+		for _, n := range names {
+			args = append(args, n)
+		}
+	}
+	if len(conds) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT DISTINCT role FROM role_bindings WHERE ` + strings.Join(conds, " OR ")
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role bindings: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// SelectorMatch is how a SelectorFilter compares an entry's selectors with
+// its own, with the meanings of SPIRE's ListEntries selector filter
+type SelectorMatch int
+
+const (
+	// MatchExact matches entries whose selectors are exactly the filter's
+	MatchExact SelectorMatch = iota
+	// MatchSubset matches entries whose selectors are all in the filter
+	MatchSubset
+	// MatchSuperset matches entries that have every selector of the filter
+	MatchSuperset
+	// MatchAny matches entries that have any selector of the filter
+	MatchAny
+)
+
+// SelectorFilter restricts EntryRepository.List to entries by selector. A
+// filter selector with an empty value matches any selector of its type.
+type SelectorFilter struct {
+	Selectors []Selector
+	Match     SelectorMatch
+}
+
+// covers reports whether the filter selector f matches the entry selector s
+func (f Selector) covers(s Selector) bool {
+	return f.Type == s.Type && (f.Value == "" || f.Value == s.Value)
+}
+
+// matches reports whether an entry with selectors passes the filter
+func (f *SelectorFilter) matches(selectors []Selector) bool {
+	coveredByFilter := func(s Selector) bool {
+		for _, fs := range f.Selectors {
+			if fs.covers(s) {
+				return true
+			}
+		}
+		return false
+	}
+	hasFilterSelector := func(fs Selector) bool {
+		for _, s := range selectors {
+			if fs.covers(s) {
+				return true
+			}
+		}
+		return false
+	}
+	all := func(items []Selector, pred func(Selector) bool) bool {
+		for _, s := range items {
+			if !pred(s) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch f.Match {
+	case MatchExact:
+		return len(selectors) > 0 && all(selectors, coveredByFilter) && all(f.Selectors, hasFilterSelector)
+	case MatchSubset:
+		return len(selectors) > 0 && all(selectors, coveredByFilter)
+	case MatchSuperset:
+		return all(f.Selectors, hasFilterSelector)
+	case MatchAny:
+		for _, fs := range f.Selectors {
+			if hasFilterSelector(fs) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// whereClause returns the condition on workload_entries we that the filter
+// adds to a query, and its arguments. Lookups by selector drive from the
+// workload_entry_selectors index; "all of the entry's selectors" is a NOT
+// EXISTS over the entry's own rows.
+func (f *SelectorFilter) whereClause() (string, []interface{}) {
+	var args []interface{}
+	covers := func(fs Selector) string {
+		args = append(args, fs.Type)
+		if fs.Value == "" {
+			return "(wes.type = ?)"
+		}
+		args = append(args, fs.Value)
+		return "(wes.type = ? AND wes.value = ?)"
+	}
+	anyOf := func() string {
+		conds := make([]string, len(f.Selectors))
+		for i, fs := range f.Selectors {
+			conds[i] = covers(fs)
+		}
+		return strings.Join(conds, " OR ")
+	}
+	entriesWith := func(cond string) string {
+		return " AND we.id IN (SELECT wes.workload_entry_id FROM workload_entry_selectors wes WHERE " + cond + ")"
+	}
+	// Every selector of the entry is covered by the filter, and the entry has
+	// at least one, which also narrows the candidates to the index lookup
+	onlyFilterSelectors := func() string {
+		clause := entriesWith(anyOf())
+		clause += " AND NOT EXISTS (SELECT 1 FROM workload_entry_selectors wes" +
+			" WHERE wes.workload_entry_id = we.id AND NOT (" + anyOf() + "))"
+		return clause
+	}
+	everyFilterSelector := func() string {
+		var clause string
+		for _, fs := range f.Selectors {
+			clause += entriesWith(covers(fs))
+		}
+		return clause
+	}
+
+	var clause string
+	switch f.Match {
+	case MatchExact:
+		clause = everyFilterSelector() + onlyFilterSelectors()
+	case MatchSubset:
+		clause = onlyFilterSelectors()
+	case MatchSuperset:
+		clause = everyFilterSelector()
+	case MatchAny:
+		clause = entriesWith(anyOf())
+	}
+	return clause, args
+}
+
+// insertSelectors indexes an entry's selectors in workload_entry_selectors
+func insertSelectors(ctx context.Context, tx *sqlTx, entryID string, selectors []Selector) error {
+	seen := make(map[Selector]bool, len(selectors))
+	var rows []string
+	var args []interface{}
+	for _, s := range selectors {
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		rows = append(rows, "(?, ?, ?, ?)")
+		args = append(args, uuid.New().String(), entryID, s.Type, s.Value)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO workload_entry_selectors (id, workload_entry_id, type, value) VALUES ` + strings.Join(rows, ", ")
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to insert selectors: %w", err)
+	}
+	return nil
+}
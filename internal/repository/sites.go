@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // Site represents a SPIRE server site
@@ -14,25 +17,28 @@ type Site struct {
 	Region             string
 	SpireServerAddress string
 	TrustDomain        string
+	AgentSpiffeID      string // SPIFFE ID the site's agent must present; empty if unbound
+	Protected          bool   // entry changes at the site need a second person's approval
 	LastSyncAt         *time.Time
 	Status             string
 	CreatedAt          time.Time
 	UpdatedAt          time.Time
 }
 
-// SiteRepository handles site database operations
-type SiteRepository struct {
-	db *sql.DB
+// sqlSiteRepository handles site database operations
+type sqlSiteRepository struct {
+	db *sqlDB
 }
 
-// NewSiteRepository creates a new SiteRepository
-func NewSiteRepository(db *sql.DB) *SiteRepository {
-	return &SiteRepository{db: db}
+// NewSiteRepository creates a SiteRepository backed by MySQL or PostgreSQL
+func NewSiteRepository(db *sql.DB) SiteRepository {
+	return &sqlSiteRepository{db: newSQLDB(db)}
 }
 
 // List returns all sites, optionally filtered by status
-func (r *SiteRepository) List(ctx context.Context, status string) ([]Site, error) {
-	query := `SELECT id, name, region, spire_server_address, trust_domain, last_sync_at, status, created_at, updated_at
+func (r *sqlSiteRepository) List(ctx context.Context, status string) ([]Site, error) {
+	query := `SELECT id, name, region, spire_server_address, trust_domain, COALESCE(agent_spiffe_id, ''),
+	                 protected, last_sync_at, status, created_at, updated_at
 	          FROM sites`
 	args := []interface{}{}
 
@@ -43,7 +49,7 @@ func (r *SiteRepository) List(ctx context.Context, status string) ([]Site, error
 
 	query += " ORDER BY name"
 
-	rows, err := r.db.QueryContext(ctx, query, args...)
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list sites: %w", err)
 	}
@@ -52,8 +58,8 @@ func (r *SiteRepository) List(ctx context.Context, status string) ([]Site, error
 	var sites []Site
 	for rows.Next() {
 		var s Site
-		if err := rows.Scan(&s.ID, &s.Name, &s.Region, &s.SpireServerAddress, &s.TrustDomain,
-			&s.LastSyncAt, &s.Status, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.Name, &s.Region, &s.SpireServerAddress, &s.TrustDomain, &s.AgentSpiffeID,
+			&s.Protected, &s.LastSyncAt, &s.Status, &s.CreatedAt, &s.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan site: %w", err)
 		}
 		sites = append(sites, s)
@@ -63,14 +69,15 @@ func (r *SiteRepository) List(ctx context.Context, status string) ([]Site, error
 }
 
 // Get returns a site by ID
-func (r *SiteRepository) Get(ctx context.Context, id string) (*Site, error) {
-	query := `SELECT id, name, region, spire_server_address, trust_domain, last_sync_at, status, created_at, updated_at
+func (r *sqlSiteRepository) Get(ctx context.Context, id string) (*Site, error) {
+	query := `SELECT id, name, region, spire_server_address, trust_domain, COALESCE(agent_spiffe_id, ''),
+	                 protected, last_sync_at, status, created_at, updated_at
 	          FROM sites WHERE id = ?`
 
 	var s Site
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&s.ID, &s.Name, &s.Region, &s.SpireServerAddress, &s.TrustDomain,
-		&s.LastSyncAt, &s.Status, &s.CreatedAt, &s.UpdatedAt)
+	err := r.db.reader(ctx).QueryRowContext(ctx, query, id).Scan(
+		&s.ID, &s.Name, &s.Region, &s.SpireServerAddress, &s.TrustDomain, &s.AgentSpiffeID,
+		&s.Protected, &s.LastSyncAt, &s.Status, &s.CreatedAt, &s.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -81,8 +88,59 @@ func (r *SiteRepository) Get(ctx context.Context, id string) (*Site, error) {
 	return &s, nil
 }
 
+// Create inserts a new site
+func (r *sqlSiteRepository) Create(ctx context.Context, s *Site) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	if s.Status == "" {
+		s.Status = "active"
+	}
+
+	query := `INSERT INTO sites (id, name, region, spire_server_address, trust_domain, agent_spiffe_id, protected, status)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, s.ID, s.Name, s.Region, s.SpireServerAddress, s.TrustDomain,
+		nullString(s.AgentSpiffeID), s.Protected, s.Status)
+	if err != nil {
+		return fmt.Errorf("failed to insert site: %w", err)
+	}
+
+	return nil
+}
+
+// ProtectedSiteIDs returns the IDs among siteIDs of sites flagged protected
+func (r *sqlSiteRepository) ProtectedSiteIDs(ctx context.Context, siteIDs []string) ([]string, error) {
+	if len(siteIDs) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(siteIDs)), ",")
+	query := `SELECT id FROM sites WHERE protected = TRUE AND id IN (` + placeholders + `) ORDER BY id`
+	args := make([]interface{}, len(siteIDs))
+	for i, id := range siteIDs {
+		args[i] = id
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find protected sites: %w", err)
+	}
+	defer rows.Close()
+
+	var protected []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan site id: %w", err)
+		}
+		protected = append(protected, id)
+	}
+
+	return protected, rows.Err()
+}
+
 // UpdateLastSyncAt updates the last sync timestamp for a site
-func (r *SiteRepository) UpdateLastSyncAt(ctx context.Context, id string) error {
+func (r *sqlSiteRepository) UpdateLastSyncAt(ctx context.Context, id string) error {
 	query := `UPDATE sites SET last_sync_at = NOW() WHERE id = ?`
 	_, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
@@ -90,3 +148,66 @@ func (r *SiteRepository) UpdateLastSyncAt(ctx context.Context, id string) error
 	}
 	return nil
 }
+
+// MarkDisconnected marks active sites as disconnected when none of their agents
+// has sent a heartbeat within the timeout. Sites that have never reported a
+// heartbeat are left unchanged. It returns the IDs of the sites it changed.
+func (r *sqlSiteRepository) MarkDisconnected(ctx context.Context, timeout time.Duration) ([]string, error) {
+	query := `SELECT s.id FROM sites s
+	          JOIN agents a ON a.site_id = s.id
+	          WHERE s.status = 'active'
+	          GROUP BY s.id
+	          HAVING MAX(a.last_heartbeat_at) < NOW() - INTERVAL ? SECOND`
+	if r.db.dialect == dialectPostgres {
+		query = strings.Replace(query, "INTERVAL ? SECOND", "make_interval(secs => ?)", 1)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, int(timeout.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find stale sites: %w", err)
+	}
+	defer rows.Close()
+
+	var staleIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan site id: %w", err)
+		}
+		staleIDs = append(staleIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, id := range staleIDs {
+		ok, err := r.updateStatus(ctx, id, "active", "disconnected")
+		if err != nil {
+			return changed, err
+		}
+		if ok {
+			changed = append(changed, id)
+		}
+	}
+
+	return changed, nil
+}
+
+// MarkConnected marks a disconnected site as active again. It reports whether
+// the site status changed.
+func (r *sqlSiteRepository) MarkConnected(ctx context.Context, id string) (bool, error) {
+	return r.updateStatus(ctx, id, "disconnected", "active")
+}
+
+// updateStatus changes a site's status only if it currently has the given status
+func (r *sqlSiteRepository) updateStatus(ctx context.Context, id, from, to string) (bool, error) {
+	query := `UPDATE sites SET status = ? WHERE id = ? AND status = ?`
+	result, err := r.db.ExecContext(ctx, query, to, id, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update site status: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/notify"
+)
+
+// State is the desired state a database can be rebuilt from: the sites, the
+// workload entries with their selectors and owners, their site assignments
+// and the namespace grants that say who may manage them. Sync progress,
+// agents, API keys and the audit log are not part of it.
+type State struct {
+	Sites   []Site
+	Entries []WorkloadEntry
+	// Assignments include those of deleted entries that sites have yet to
+	// remove, so the removal continues after a rebuild
+	Assignments     []Assignment
+	NamespaceGrants []NamespaceGrant
+}
+
+// Assignment is an entry's assignment to a site
+type Assignment struct {
+	SiteID          string
+	WorkloadEntryID string
+	// SpireEntryID is the entry's ID in the site's SPIRE server, if the site
+	// has created it
+	SpireEntryID string
+}
+
+// ImportMode is how ImportState combines a state with the database's
+type ImportMode int
+
+const (
+	// ImportMerge adds the state's rows and overwrites those with the same
+	// IDs, keeping the rest
+	ImportMerge ImportMode = iota
+	// ImportReplace deletes the sites, entries and namespace grants not in
+	// the state
+	ImportReplace
+)
+
+// sqlStateRepository exports and imports the desired state in MySQL or
+// PostgreSQL
+type sqlStateRepository struct {
+	db  *sqlDB
+	hub *notify.Hub
+}
+
+// Export reads the desired state in one transaction, so it is consistent
+func (r *sqlStateRepository) Export(ctx context.Context) (*State, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	state := &State{}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, name, region, spire_server_address, trust_domain,
+	                                          COALESCE(agent_spiffe_id, ''), protected, status, created_at, updated_at
+	                                   FROM sites ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export sites: %w", err)
+	}
+	for rows.Next() {
+		var s Site
+		if err := rows.Scan(&s.ID, &s.Name, &s.Region, &s.SpireServerAddress, &s.TrustDomain, &s.AgentSpiffeID,
+			&s.Protected, &s.Status, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan site: %w", err)
+		}
+		state.Sites = append(state.Sites, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export sites: %w", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT id, spiffe_id, parent_id, selectors, ttl, description, created_by,
+	                                         created_at, updated_at, deleted_at
+	                                  FROM workload_entries ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export workload entries: %w", err)
+	}
+	for rows.Next() {
+		var e WorkloadEntry
+		var selectorsJSON []byte
+		if err := rows.Scan(&e.ID, &e.SpiffeID, &e.ParentID, &selectorsJSON, &e.TTL, &e.Description, &e.CreatedBy,
+			&e.CreatedAt, &e.UpdatedAt, &e.DeletedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan workload entry: %w", err)
+		}
+		if err := json.Unmarshal(selectorsJSON, &e.Selectors); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to unmarshal selectors: %w", err)
+		}
+		state.Entries = append(state.Entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export workload entries: %w", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT site_id, workload_entry_id, COALESCE(spire_entry_id, '')
+	                                  FROM site_workload_entries ORDER BY workload_entry_id, site_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export site assignments: %w", err)
+	}
+	for rows.Next() {
+		var a Assignment
+		if err := rows.Scan(&a.SiteID, &a.WorkloadEntryID, &a.SpireEntryID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan site assignment: %w", err)
+		}
+		state.Assignments = append(state.Assignments, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export site assignments: %w", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT id, subject_kind, subject, namespaces, spiffe_id_prefixes, site_ids,
+	                                         created_by, created_at
+	                                  FROM namespace_grants ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export namespace grants: %w", err)
+	}
+	for rows.Next() {
+		g, err := scanNamespaceGrant(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan namespace grant: %w", err)
+		}
+		state.NamespaceGrants = append(state.NamespaceGrants, *g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export namespace grants: %w", err)
+	}
+
+	return state, nil
+}
+
+// Import writes state into the database in one transaction and queues every
+// assignment for reconciliation, so that agents check their SPIRE servers
+// against it. It returns the number of assignments queued.
+func (r *sqlStateRepository) Import(ctx context.Context, state *State, mode ImportMode) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if mode == ImportReplace {
+		// Assignments, selectors and agents go with their entries and sites
+		for _, table := range []string{"namespace_grants", "workload_entries", "sites"} {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table); err != nil {
+				return 0, fmt.Errorf("failed to clear %s: %w", table, err)
+			}
+		}
+	}
+
+	upsertSite := `INSERT INTO sites (id, name, region, spire_server_address, trust_domain, agent_spiffe_id, protected,
+	                                  status, created_at, updated_at)
+	               VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	               ON DUPLICATE KEY UPDATE name = VALUES(name), region = VALUES(region),
+	                   spire_server_address = VALUES(spire_server_address), trust_domain = VALUES(trust_domain),
+	                   agent_spiffe_id = VALUES(agent_spiffe_id), protected = VALUES(protected),
+	                   status = VALUES(status), updated_at = VALUES(updated_at)`
+	if r.db.dialect == dialectPostgres {
+		upsertSite = `INSERT INTO sites (id, name, region, spire_server_address, trust_domain, agent_spiffe_id, protected,
+		                                 status, created_at, updated_at)
+		              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		              ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, region = EXCLUDED.region,
+		                  spire_server_address = EXCLUDED.spire_server_address, trust_domain = EXCLUDED.trust_domain,
+		                  agent_spiffe_id = EXCLUDED.agent_spiffe_id, protected = EXCLUDED.protected,
+		                  status = EXCLUDED.status, updated_at = EXCLUDED.updated_at`
+	}
+	for _, s := range state.Sites {
+		if _, err := tx.ExecContext(ctx, upsertSite, s.ID, s.Name, s.Region, s.SpireServerAddress, s.TrustDomain,
+			nullString(s.AgentSpiffeID), s.Protected, s.Status, s.CreatedAt, s.UpdatedAt); err != nil {
+			return 0, fmt.Errorf("failed to import site %s: %w", s.ID, err)
+		}
+	}
+
+	upsertEntry := `INSERT INTO workload_entries (id, spiffe_id, parent_id, selectors, ttl, description, created_by,
+	                                              created_at, updated_at, deleted_at)
+	                VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	                ON DUPLICATE KEY UPDATE spiffe_id = VALUES(spiffe_id), parent_id = VALUES(parent_id),
+	                    selectors = VALUES(selectors), ttl = VALUES(ttl), description = VALUES(description),
+	                    created_by = VALUES(created_by), updated_at = VALUES(updated_at), deleted_at = VALUES(deleted_at)`
+	if r.db.dialect == dialectPostgres {
+		upsertEntry = `INSERT INTO workload_entries (id, spiffe_id, parent_id, selectors, ttl, description, created_by,
+		                                             created_at, updated_at, deleted_at)
+		               VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		               ON CONFLICT (id) DO UPDATE SET spiffe_id = EXCLUDED.spiffe_id, parent_id = EXCLUDED.parent_id,
+		                   selectors = EXCLUDED.selectors, ttl = EXCLUDED.ttl, description = EXCLUDED.description,
+		                   created_by = EXCLUDED.created_by, updated_at = EXCLUDED.updated_at,
+		                   deleted_at = EXCLUDED.deleted_at`
+	}
+	for _, e := range state.Entries {
+		selectorsJSON, err := json.Marshal(e.Selectors)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal selectors: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, upsertEntry, e.ID, e.SpiffeID, e.ParentID, string(selectorsJSON), e.TTL,
+			e.Description, e.CreatedBy, e.CreatedAt, e.UpdatedAt, e.DeletedAt); err != nil {
+			return 0, fmt.Errorf("failed to import workload entry %s: %w", e.ID, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM workload_entry_selectors WHERE workload_entry_id = ?`, e.ID); err != nil {
+			return 0, fmt.Errorf("failed to replace selectors: %w", err)
+		}
+		if err := insertSelectors(ctx, tx, e.ID, e.Selectors); err != nil {
+			return 0, err
+		}
+	}
+
+	// Existing assignments keep the SPIRE entry IDs their sites reported
+	insertAssignment := `INSERT IGNORE INTO site_workload_entries (site_id, workload_entry_id, sync_status, spire_entry_id)
+	                     VALUES (?, ?, 'pending', ?)`
+	if r.db.dialect == dialectPostgres {
+		insertAssignment = `INSERT INTO site_workload_entries (site_id, workload_entry_id, sync_status, spire_entry_id)
+		                    VALUES (?, ?, 'pending', ?) ON CONFLICT DO NOTHING`
+	}
+	for _, a := range state.Assignments {
+		if _, err := tx.ExecContext(ctx, insertAssignment, a.SiteID, a.WorkloadEntryID,
+			nullString(a.SpireEntryID)); err != nil {
+			return 0, fmt.Errorf("failed to import assignment of entry %s to site %s: %w", a.WorkloadEntryID, a.SiteID, err)
+		}
+	}
+
+	upsertGrant := `INSERT INTO namespace_grants (id, subject_kind, subject, namespaces, spiffe_id_prefixes, site_ids,
+	                                              created_by, created_at)
+	                VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	                ON DUPLICATE KEY UPDATE subject_kind = VALUES(subject_kind), subject = VALUES(subject),
+	                    namespaces = VALUES(namespaces), spiffe_id_prefixes = VALUES(spiffe_id_prefixes),
+	                    site_ids = VALUES(site_ids), created_by = VALUES(created_by)`
+	if r.db.dialect == dialectPostgres {
+		upsertGrant = `INSERT INTO namespace_grants (id, subject_kind, subject, namespaces, spiffe_id_prefixes, site_ids,
+		                                             created_by, created_at)
+		               VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		               ON CONFLICT (id) DO UPDATE SET subject_kind = EXCLUDED.subject_kind, subject = EXCLUDED.subject,
+		                   namespaces = EXCLUDED.namespaces, spiffe_id_prefixes = EXCLUDED.spiffe_id_prefixes,
+		                   site_ids = EXCLUDED.site_ids, created_by = EXCLUDED.created_by`
+	}
+	for _, g := range state.NamespaceGrants {
+		namespaces, _ := json.Marshal(nonNil(g.Namespaces))
+		prefixes, _ := json.Marshal(nonNil(g.SpiffeIDPrefixes))
+		siteIDs, _ := json.Marshal(nonNil(g.SiteIDs))
+		if _, err := tx.ExecContext(ctx, upsertGrant, g.ID, g.SubjectKind, g.Subject, string(namespaces),
+			string(prefixes), string(siteIDs), g.CreatedBy, g.CreatedAt); err != nil {
+			return 0, fmt.Errorf("failed to import namespace grant %s: %w", g.ID, err)
+		}
+	}
+
+	// Every assignment is checked again: live entries are created unless the
+	// site's SPIRE server has them, and deleted ones are removed
+	requeue := `UPDATE site_workload_entries SET sync_error = NULL, queued_at = NOW(),
+	                sync_status = CASE WHEN workload_entry_id IN (SELECT id FROM workload_entries WHERE deleted_at IS NOT NULL)
+	                                   THEN 'deleting' ELSE 'pending' END`
+	result, err := tx.ExecContext(ctx, requeue)
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue assignments for reconciliation: %w", err)
+	}
+	queued, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue assignments for reconciliation: %w", err)
+	}
+
+	siteIDs, err := queryStrings(ctx, tx, `SELECT DISTINCT site_id FROM site_workload_entries`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list assigned sites: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.hub.Notify(siteIDs...)
+	return int(queued), nil
+}
+
+// queryStrings returns the single string column of query's rows
+func queryStrings(ctx context.Context, tx *sqlTx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 // PendingEntry represents an entry pending sync to a site
@@ -22,23 +23,25 @@ type DeletionEntry struct {
 	SpireEntryID    string
 }
 
-// SyncStatusRepository handles sync status database operations
-type SyncStatusRepository struct {
-	db *sql.DB
+// sqlSyncStatusRepository handles sync status database operations
+type sqlSyncStatusRepository struct {
+	db *sqlDB
 }
 
-// NewSyncStatusRepository creates a new SyncStatusRepository
-func NewSyncStatusRepository(db *sql.DB) *SyncStatusRepository {
-	return &SyncStatusRepository{db: db}
+// NewSyncStatusRepository creates a SyncStatusRepository backed by MySQL or PostgreSQL
+func NewSyncStatusRepository(db *sql.DB) SyncStatusRepository {
+	return &sqlSyncStatusRepository{db: newSQLDB(db)}
 }
 
 // GetPendingEntries returns entries that need to be synced to a site
-func (r *SyncStatusRepository) GetPendingEntries(ctx context.Context, siteID string, maxEntries int) ([]PendingEntry, error) {
+func (r *sqlSyncStatusRepository) GetPendingEntries(ctx context.Context, siteID string, maxEntries int) ([]PendingEntry, error) {
+	// Oldest first, in the order of the (site_id, sync_status, queued_at)
+	// index, so no sort is needed however long the queue
 	query := `SELECT we.id, we.spiffe_id, we.parent_id, we.selectors, we.ttl
-	          FROM workload_entries we
-	          JOIN site_workload_entries swe ON we.id = swe.workload_entry_id
+	          FROM site_workload_entries swe
+	          JOIN workload_entries we ON we.id = swe.workload_entry_id
 	          WHERE swe.site_id = ? AND swe.sync_status = 'pending'
-	          ORDER BY we.created_at ASC
+	          ORDER BY swe.queued_at, swe.workload_entry_id
 	          LIMIT ?`
 
 	rows, err := r.db.QueryContext(ctx, query, siteID, maxEntries)
@@ -65,11 +68,14 @@ func (r *SyncStatusRepository) GetPendingEntries(ctx context.Context, siteID str
 	return entries, rows.Err()
 }
 
-// GetDeletionEntries returns entries marked for deletion from a site
-func (r *SyncStatusRepository) GetDeletionEntries(ctx context.Context, siteID string, maxEntries int) ([]DeletionEntry, error) {
+// GetDeletionEntries returns entries marked for deletion from a site. Entries
+// the site never synced have no SPIRE entry ID; the agent confirms their
+// deletion without calling SPIRE.
+func (r *sqlSyncStatusRepository) GetDeletionEntries(ctx context.Context, siteID string, maxEntries int) ([]DeletionEntry, error) {
 	query := `SELECT swe.workload_entry_id, swe.spire_entry_id
 	          FROM site_workload_entries swe
-	          WHERE swe.site_id = ? AND swe.sync_status = 'deleting' AND swe.spire_entry_id IS NOT NULL
+	          WHERE swe.site_id = ? AND swe.sync_status = 'deleting'
+	          ORDER BY swe.queued_at, swe.workload_entry_id
 	          LIMIT ?`
 
 	rows, err := r.db.QueryContext(ctx, query, siteID, maxEntries)
@@ -94,24 +100,32 @@ func (r *SyncStatusRepository) GetDeletionEntries(ctx context.Context, siteID st
 	return entries, rows.Err()
 }
 
-// UpdateSyncStatus updates the sync status for an entry at a site
-func (r *SyncStatusRepository) UpdateSyncStatus(ctx context.Context, siteID, entryID string, status string, spireEntryID string, errorMsg string) error {
+// UpdateSyncStatus updates the sync status for an entry at a site. An entry
+// being deleted stays deleting: a late sync report records its SPIRE entry ID
+// for the deletion, and a failed deletion records the error to be retried.
+func (r *sqlSyncStatusRepository) UpdateSyncStatus(ctx context.Context, siteID, entryID string, status string, spireEntryID string, errorMsg string) error {
 	var query string
 	var args []interface{}
 
 	if status == "synced" {
 		query = `UPDATE site_workload_entries
-		         SET sync_status = ?, spire_entry_id = ?, last_sync_at = NOW(), sync_error = NULL
+		         SET sync_status = ` + keepDeleting + `, spire_entry_id = ?, last_sync_at = NOW(), sync_error = NULL
 		         WHERE site_id = ? AND workload_entry_id = ?`
 		args = []interface{}{status, spireEntryID, siteID, entryID}
 	} else if status == "failed" {
 		query = `UPDATE site_workload_entries
-		         SET sync_status = ?, sync_error = ?, last_sync_at = NOW()
+		         SET sync_status = ` + keepDeleting + `, sync_error = ?, last_sync_at = NOW()
 		         WHERE site_id = ? AND workload_entry_id = ?`
 		args = []interface{}{status, errorMsg, siteID, entryID}
+	} else if status == "pending" {
+		// Requeue at the back of the site's queue
+		query = `UPDATE site_workload_entries
+		         SET sync_status = ` + keepDeleting + `, queued_at = NOW()
+		         WHERE site_id = ? AND workload_entry_id = ?`
+		args = []interface{}{status, siteID, entryID}
 	} else {
 		query = `UPDATE site_workload_entries
-		         SET sync_status = ?
+		         SET sync_status = ` + keepDeleting + `
 		         WHERE site_id = ? AND workload_entry_id = ?`
 		args = []interface{}{status, siteID, entryID}
 	}
@@ -129,18 +143,42 @@ func (r *SyncStatusRepository) UpdateSyncStatus(ctx context.Context, siteID, ent
 	return nil
 }
 
-// RemoveSiteEntry removes the site assignment after successful deletion
-func (r *SyncStatusRepository) RemoveSiteEntry(ctx context.Context, siteID, entryID string) error {
-	query := `DELETE FROM site_workload_entries WHERE site_id = ? AND workload_entry_id = ?`
-	_, err := r.db.ExecContext(ctx, query, siteID, entryID)
+// keepDeleting sets sync_status to the bound status unless the entry is
+// being deleted
+const keepDeleting = `CASE WHEN sync_status = 'deleting' THEN sync_status ELSE ? END`
+
+// RemoveSiteEntry removes the site assignment after successful deletion, and
+// the entry's tombstone once no site has the entry. It reports whether the
+// tombstone was removed.
+func (r *sqlSyncStatusRepository) RemoveSiteEntry(ctx context.Context, siteID, entryID string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to remove site entry: %w", err)
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	return nil
+	defer tx.Rollback()
+
+	query := `DELETE FROM site_workload_entries WHERE site_id = ? AND workload_entry_id = ? AND sync_status = 'deleting'`
+	if _, err := tx.ExecContext(ctx, query, siteID, entryID); err != nil {
+		return false, fmt.Errorf("failed to remove site entry: %w", err)
+	}
+
+	purgeQuery := `DELETE FROM workload_entries
+	               WHERE id = ? AND deleted_at IS NOT NULL
+	               AND NOT EXISTS (SELECT 1 FROM site_workload_entries WHERE workload_entry_id = ?)`
+	result, err := tx.ExecContext(ctx, purgeQuery, entryID, entryID)
+	if err != nil {
+		return false, fmt.Errorf("failed to purge entry: %w", err)
+	}
+	purged, _ := result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return purged > 0, nil
 }
 
 // GetSyncStatuses returns sync statuses for an entry across all sites
-func (r *SyncStatusRepository) GetSyncStatuses(ctx context.Context, entryID string) ([]SiteWorkloadEntry, error) {
+func (r *sqlSyncStatusRepository) GetSyncStatuses(ctx context.Context, entryID string) ([]SiteWorkloadEntry, error) {
 	query := `SELECT swe.site_id, s.name, swe.workload_entry_id, swe.sync_status,
 	                 swe.spire_entry_id, swe.last_sync_at, swe.sync_error
 	          FROM site_workload_entries swe
@@ -148,7 +186,7 @@ func (r *SyncStatusRepository) GetSyncStatuses(ctx context.Context, entryID stri
 	          WHERE swe.workload_entry_id = ?
 	          ORDER BY s.name`
 
-	rows, err := r.db.QueryContext(ctx, query, entryID)
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query, entryID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sync statuses: %w", err)
 	}
@@ -166,3 +204,61 @@ func (r *SyncStatusRepository) GetSyncStatuses(ctx context.Context, entryID stri
 
 	return statuses, rows.Err()
 }
+
+// SyncStats summarizes the sync state of every site, for metrics
+type SyncStats struct {
+	// Entries is the number of live workload entries
+	Entries int
+	Sites   []SiteSyncStats
+}
+
+// SiteSyncStats counts a site's assignments by sync status
+type SiteSyncStats struct {
+	SiteID   string
+	Statuses map[string]int
+	// OldestQueuedAt is when the site's oldest assignment waiting to be
+	// synced or deleted was queued; nil if the site has caught up
+	OldestQueuedAt *time.Time
+}
+
+// Stats counts live entries and every site's assignments by sync status
+func (r *sqlSyncStatusRepository) Stats(ctx context.Context) (*SyncStats, error) {
+	db := r.db.reader(ctx)
+	stats := &SyncStats{}
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM workload_entries WHERE deleted_at IS NULL`).Scan(&stats.Entries); err != nil {
+		return nil, fmt.Errorf("failed to count workload entries: %w", err)
+	}
+
+	query := `SELECT s.id, swe.sync_status, COUNT(swe.workload_entry_id),
+	                 MIN(CASE WHEN swe.sync_status IN ('pending', 'deleting') THEN swe.queued_at END)
+	          FROM sites s
+	          LEFT JOIN site_workload_entries swe ON swe.site_id = s.id
+	          GROUP BY s.id, swe.sync_status
+	          ORDER BY s.id`
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count sync statuses: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var siteID string
+		var status sql.NullString
+		var count int
+		var queuedAt *time.Time
+		if err := rows.Scan(&siteID, &status, &count, &queuedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan sync statuses: %w", err)
+		}
+		if n := len(stats.Sites); n == 0 || stats.Sites[n-1].SiteID != siteID {
+			stats.Sites = append(stats.Sites, SiteSyncStats{SiteID: siteID, Statuses: make(map[string]int)})
+		}
+		site := &stats.Sites[len(stats.Sites)-1]
+		if status.Valid {
+			site.Statuses[status.String] = count
+		}
+		if queuedAt != nil && (site.OldestQueuedAt == nil || queuedAt.Before(*site.OldestQueuedAt)) {
+			site.OldestQueuedAt = queuedAt
+		}
+	}
+	return stats, rows.Err()
+}
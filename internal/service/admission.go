@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/policy"
+)
+
+// PolicyResult is an admission rule violated by a request
+type PolicyResult struct {
+	Rule    string
+	Action  string // deny or warn
+	Message string
+}
+
+// admit evaluates admission policy for an entry
+func (s *WorkloadEntryService) admit(ctx context.Context, operation, spiffeID, parentID string,
+	selectors []Selector, siteIDs []string, ttl int) []PolicyResult {
+
+	in := policy.Input{
+		Operation: operation,
+		SpiffeID:  spiffeID,
+		ParentID:  parentID,
+		SiteIDs:   siteIDs,
+		TTL:       ttl,
+		Actor:     auth.ActorFromContext(ctx),
+	}
+	for _, sel := range selectors {
+		in.Selectors = append(in.Selectors, policy.Selector{Type: sel.Type, Value: sel.Value})
+	}
+	if p, ok := auth.PrincipalFromContext(ctx); ok {
+		in.Groups = p.Groups
+	}
+
+	violations := s.policy.Evaluate(in)
+	results := make([]PolicyResult, len(violations))
+	for i, v := range violations {
+		results[i] = PolicyResult{Rule: v.Rule, Action: v.Action, Message: v.Message}
+	}
+	return results
+}
+
+// policyError returns an ErrPolicyDenied error listing the deny results, if any
+func policyError(results []PolicyResult) error {
+	var denials []string
+	for _, r := range results {
+		if r.Action == policy.ActionDeny {
+			denials = append(denials, r.Rule+": "+r.Message)
+		}
+	}
+	if len(denials) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPolicyDenied, strings.Join(denials, "; "))
+}
+
+// policyWarnings returns the warn results for audit details
+func policyWarnings(results []PolicyResult) []string {
+	var warnings []string
+	for _, r := range results {
+		if r.Action == policy.ActionWarn {
+			warnings = append(warnings, r.Rule+": "+r.Message)
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// apiKeyPrefixLen is the number of secret characters kept to identify a key
+const apiKeyPrefixLen = 12
+
+// APIKeyService manages API keys for programmatic access and verifies them
+// for the auth layer
+type APIKeyService struct {
+	apiKeyRepo     repository.APIKeyRepository
+	uow            repository.UnitOfWork
+	recordLastUsed bool
+}
+
+// NewAPIKeyService creates a new APIKeyService. Keys are written through uow,
+// which commits them with their audit records.
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository, uow repository.UnitOfWork) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo:     apiKeyRepo,
+		uow:            uow,
+		recordLastUsed: true,
+	}
+}
+
+// RecordLastUsed controls whether verifying a key records when it was last
+// used, which a read-only standby cannot write
+func (s *APIKeyService) RecordLastUsed(record bool) {
+	s.recordLastUsed = record
+}
+
+// APIKey represents an API key response. The secret is never included.
+type APIKey struct {
+	ID             string
+	Name           string
+	KeyPrefix      string
+	Scopes         []string
+	SpiffeIDPrefix string
+	ExpiresAt      *timestamppb.Timestamp
+	CreatedBy      string
+	CreatedAt      *timestamppb.Timestamp
+	RotatedAt      *timestamppb.Timestamp
+	LastUsedAt     *timestamppb.Timestamp
+	RevokedAt      *timestamppb.Timestamp
+}
+
+// CreateAPIKey creates a new API key and returns it with its secret. The
+// secret cannot be retrieved again.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, name string, scopes []string,
+	spiffeIDPrefix string, expiresAt *time.Time) (*APIKey, string, error) {
+
+	if err := requireUserPrincipal(ctx); err != nil {
+		return nil, "", err
+	}
+	if name == "" {
+		return nil, "", fmt.Errorf("name is required")
+	}
+	if err := validateScopes(scopes); err != nil {
+		return nil, "", err
+	}
+	if spiffeIDPrefix != "" && !strings.HasPrefix(spiffeIDPrefix, "spiffe://") {
+		return nil, "", fmt.Errorf("spiffe_id_prefix must start with spiffe://")
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("expires_at must be in the future")
+	}
+
+	secret, err := generateAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	actor := auth.ActorFromContext(ctx)
+	key := &repository.APIKey{
+		Name:           name,
+		KeyHash:        hashAPIKey(secret),
+		KeyPrefix:      secret[:apiKeyPrefixLen],
+		Scopes:         scopes,
+		SpiffeIDPrefix: spiffeIDPrefix,
+		ExpiresAt:      expiresAt,
+		CreatedBy:      actor,
+	}
+	details := map[string]interface{}{
+		"name":             name,
+		"scopes":           scopes,
+		"spiffe_id_prefix": spiffeIDPrefix,
+	}
+	err = s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if err := tx.APIKeys.Create(ctx, key); err != nil {
+			return fmt.Errorf("failed to create API key: %w", err)
+		}
+		return tx.Audit.Log(ctx, actor, "create", "api_key", key.ID, withAuthz(ctx, details))
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	created, err := s.apiKeyRepo.Get(ctx, key.ID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get API key: %w", err)
+	}
+	return toAPIKey(created), secret, nil
+}
+
+// ListAPIKeys returns all API keys, including revoked ones
+func (s *APIKeyService) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	if err := requireUserPrincipal(ctx); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.apiKeyRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	result := make([]APIKey, len(keys))
+	for i := range keys {
+		result[i] = *toAPIKey(&keys[i])
+	}
+	return result, nil
+}
+
+// RotateAPIKey replaces the secret of an API key and returns the new secret.
+// The previous secret stops working immediately.
+func (s *APIKeyService) RotateAPIKey(ctx context.Context, id string) (*APIKey, string, error) {
+	if err := requireUserPrincipal(ctx); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := generateAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if err := tx.APIKeys.Rotate(ctx, id, hashAPIKey(secret), secret[:apiKeyPrefixLen]); err != nil {
+			return fmt.Errorf("failed to rotate API key: %w", err)
+		}
+		return tx.Audit.Log(ctx, auth.ActorFromContext(ctx), "rotate", "api_key", id, withAuthz(ctx, nil))
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	key, err := s.apiKeyRepo.Get(ctx, id)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get API key: %w", err)
+	}
+	if key == nil {
+		return nil, "", fmt.Errorf("API key not found")
+	}
+	return toAPIKey(key), secret, nil
+}
+
+// RevokeAPIKey permanently disables an API key
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, id string) error {
+	if err := requireUserPrincipal(ctx); err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if err := tx.APIKeys.Revoke(ctx, id); err != nil {
+			return fmt.Errorf("failed to revoke API key: %w", err)
+		}
+		return tx.Audit.Log(ctx, auth.ActorFromContext(ctx), "revoke", "api_key", id, withAuthz(ctx, nil))
+	})
+}
+
+// VerifyAPIKey implements auth.APIKeyVerifier
+func (s *APIKeyService) VerifyAPIKey(ctx context.Context, secret string) (*auth.Principal, error) {
+	key, err := s.apiKeyRepo.GetByHash(ctx, hashAPIKey(secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: unknown API key", auth.ErrUnauthenticated)
+	}
+	if key.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: API key %s is revoked", auth.ErrUnauthenticated, key.Name)
+	}
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return nil, fmt.Errorf("%w: API key %s has expired", auth.ErrUnauthenticated, key.Name)
+	}
+
+	// The request does not read the time back, so recording it leaves the
+	// request's reads on the replica
+	if s.recordLastUsed {
+		if err := s.apiKeyRepo.TouchLastUsed(repository.WithoutSession(ctx), key.ID); err != nil {
+			log.Printf("Failed to record API key use: %v", err)
+		}
+	}
+
+	return &auth.Principal{
+		Subject:        "apikey:" + key.Name,
+		APIKeyID:       key.ID,
+		APIKeyName:     key.Name,
+		Scopes:         key.Scopes,
+		SpiffeIDPrefix: key.SpiffeIDPrefix,
+	}, nil
+}
+
+// requireUserPrincipal prevents API keys from managing API keys
+func requireUserPrincipal(ctx context.Context) error {
+	if p, ok := auth.PrincipalFromContext(ctx); ok && p.IsAPIKey() {
+		return fmt.Errorf("%w: API keys cannot manage API keys", ErrPermissionDenied)
+	}
+	return nil
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		valid := false
+		for _, v := range auth.ValidScopes {
+			if scope == v {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid scope %q (valid scopes: %s)", scope, strings.Join(auth.ValidScopes, ", "))
+		}
+	}
+	return nil
+}
+
+func generateAPIKeySecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return auth.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashAPIKey hashes a secret for storage. Secrets are 256-bit random values,
+// so a fast unsalted hash is sufficient.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func toAPIKey(key *repository.APIKey) *APIKey {
+	result := &APIKey{
+		ID:             key.ID,
+		Name:           key.Name,
+		KeyPrefix:      key.KeyPrefix,
+		Scopes:         key.Scopes,
+		SpiffeIDPrefix: key.SpiffeIDPrefix,
+		CreatedBy:      key.CreatedBy,
+		CreatedAt:      timestamppb.New(key.CreatedAt),
+	}
+	if key.ExpiresAt != nil {
+		result.ExpiresAt = timestamppb.New(*key.ExpiresAt)
+	}
+	if key.RotatedAt != nil {
+		result.RotatedAt = timestamppb.New(*key.RotatedAt)
+	}
+	if key.LastUsedAt != nil {
+		result.LastUsedAt = timestamppb.New(*key.LastUsedAt)
+	}
+	if key.RevokedAt != nil {
+		result.RevokedAt = timestamppb.New(*key.RevokedAt)
+	}
+	return result
+}
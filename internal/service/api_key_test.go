@@ -0,0 +1,86 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/service"
+)
+
+func TestAPIKeyChangesAreAuditedAtomically(t *testing.T) {
+	store := newChangeStore(t)
+	ctx := userContext("alice")
+
+	svc := service.NewAPIKeyService(store.APIKeys, store.UnitOfWork)
+	key, secret, err := svc.CreateAPIKey(ctx, "ci", []string{"entries:read"}, "", nil)
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+
+	// Without its audit record, neither a key nor its revocation is stored
+	failing := service.NewAPIKeyService(store.APIKeys, failingAudit{store.UnitOfWork})
+	if _, _, err := failing.CreateAPIKey(ctx, "deploy", []string{"entries:read"}, "", nil); err == nil {
+		t.Fatal("CreateAPIKey succeeded without an audit log")
+	}
+	if keys, _ := store.APIKeys.List(context.Background()); len(keys) != 1 {
+		t.Errorf("%d API keys stored, want only the audited one", len(keys))
+	}
+	if err := failing.RevokeAPIKey(ctx, key.ID); err == nil {
+		t.Fatal("RevokeAPIKey succeeded without an audit log")
+	}
+	if _, err := svc.VerifyAPIKey(context.Background(), secret); err != nil {
+		t.Errorf("API key revoked without an audit record: %v", err)
+	}
+
+	if err := svc.RevokeAPIKey(ctx, key.ID); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if got := auditActions(t, store, "api_key", key.ID); got != "create,revoke" {
+		t.Errorf("audited actions: %q", got)
+	}
+}
+
+func TestVerifyAPIKeyRecordsLastUsed(t *testing.T) {
+	store := newChangeStore(t)
+	ctx := userContext("alice")
+	svc := service.NewAPIKeyService(store.APIKeys, store.UnitOfWork)
+
+	lastUsed := func(id string) bool {
+		t.Helper()
+		keys, err := store.APIKeys.List(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range keys {
+			if k.ID == id {
+				return k.LastUsedAt != nil
+			}
+		}
+		t.Fatalf("API key %s not found", id)
+		return false
+	}
+
+	key, secret, err := svc.CreateAPIKey(ctx, "ci", []string{"entries:read"}, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.VerifyAPIKey(context.Background(), secret); err != nil {
+		t.Fatalf("VerifyAPIKey: %v", err)
+	}
+	if !lastUsed(key.ID) {
+		t.Error("verifying a key did not record its use")
+	}
+
+	// A read-only standby verifies keys without writing
+	svc.RecordLastUsed(false)
+	key, secret, err = svc.CreateAPIKey(ctx, "deploy", []string{"entries:read"}, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.VerifyAPIKey(context.Background(), secret); err != nil {
+		t.Fatalf("VerifyAPIKey on a standby: %v", err)
+	}
+	if lastUsed(key.ID) {
+		t.Error("verifying a key on a standby recorded its use")
+	}
+}
@@ -2,22 +2,32 @@ package service
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
+	"github.com/yourorg/spire-workload-mgmt/internal/auditarchive"
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
 	"github.com/yourorg/spire-workload-mgmt/internal/repository"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // AuditService handles audit log operations
 type AuditService struct {
-	auditRepo *repository.AuditRepository
+	auditRepo    repository.AuditRepository
+	signingKey   ed25519.PrivateKey
+	archiveStore auditarchive.Store
 }
 
-// NewAuditService creates a new AuditService
-func NewAuditService(auditRepo *repository.AuditRepository) *AuditService {
-	return &AuditService{auditRepo: auditRepo}
+// NewAuditService creates a new AuditService. signingKey signs audit log
+// checkpoints and may be nil to disable them. archiveStore holds the archives
+// of expired entries and may be nil if none are archived.
+func NewAuditService(auditRepo repository.AuditRepository, signingKey ed25519.PrivateKey,
+	archiveStore auditarchive.Store) *AuditService {
+	return &AuditService{auditRepo: auditRepo, signingKey: signingKey, archiveStore: archiveStore}
 }
 
 // AuditLogEntry represents an audit log entry response
@@ -41,6 +51,10 @@ type ListAuditLogsResponse struct {
 func (s *AuditService) ListAuditLogs(ctx context.Context, pageSize int, pageToken string,
 	resourceType, resourceID, actor string, startTime, endTime *time.Time) (*ListAuditLogsResponse, error) {
 
+	if err := requireScope(ctx, auth.ScopeAuditRead); err != nil {
+		return nil, err
+	}
+
 	if pageSize <= 0 || pageSize > 100 {
 		pageSize = 50
 	}
@@ -85,3 +99,111 @@ func (s *AuditService) ListAuditLogs(ctx context.Context, pageSize int, pageToke
 
 	return result, nil
 }
+
+// AuditChainBreak describes where an audit log hash chain stops verifying
+type AuditChainBreak struct {
+	Stream  string
+	Seq     int64
+	EntryID int64
+	Reason  string
+}
+
+// VerifyAuditLogResponse is the result of verifying the audit log
+type VerifyAuditLogResponse struct {
+	Valid              bool
+	StreamsChecked     int
+	EntriesChecked     int
+	CheckpointsChecked int
+	ArchivedEntries    int
+	FirstBreak         *AuditChainBreak
+}
+
+// VerifyAuditLog walks the audit log hash chain of stream, or of every
+// stream if empty, and reports the first broken link
+func (s *AuditService) VerifyAuditLog(ctx context.Context, stream string) (*VerifyAuditLogResponse, error) {
+	if err := requireScope(ctx, auth.ScopeAuditRead); err != nil {
+		return nil, err
+	}
+
+	var key ed25519.PublicKey
+	if s.signingKey != nil {
+		key = s.signingKey.Public().(ed25519.PublicKey)
+	}
+
+	v, err := s.auditRepo.Verify(ctx, stream, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify audit log: %w", err)
+	}
+	return toVerifyAuditLogResponse(v), nil
+}
+
+// toVerifyAuditLogResponse converts a repository verification result
+func toVerifyAuditLogResponse(v *repository.AuditVerification) *VerifyAuditLogResponse {
+	result := &VerifyAuditLogResponse{
+		Valid:              v.FirstBreak == nil,
+		StreamsChecked:     v.Streams,
+		EntriesChecked:     v.Entries,
+		CheckpointsChecked: v.Checkpoints,
+		ArchivedEntries:    v.Archived,
+	}
+	if b := v.FirstBreak; b != nil {
+		result.FirstBreak = &AuditChainBreak{Stream: b.Stream, Seq: b.Seq, EntryID: b.EntryID, Reason: b.Reason}
+	}
+	return result
+}
+
+// RestoreAuditArchiveResponse is the result of restoring an audit archive
+type RestoreAuditArchiveResponse struct {
+	Archive  string
+	Entries  int
+	Restored int
+}
+
+// RestoreAuditArchive verifies the named archive of expired audit log entries
+// and loads its entries into the audit_log_restored table for investigation
+func (s *AuditService) RestoreAuditArchive(ctx context.Context, name string) (*RestoreAuditArchiveResponse, error) {
+	if err := requireScope(ctx, auth.ScopeAuditRead); err != nil {
+		return nil, err
+	}
+	if s.archiveStore == nil {
+		return nil, fmt.Errorf("%w: audit log archival is not configured", ErrInvalidArgument)
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("%w: invalid archive name %q", ErrInvalidArgument, name)
+	}
+
+	entries, restored, err := auditarchive.Restore(ctx, s.auditRepo, s.archiveStore, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore audit archive: %w", err)
+	}
+
+	details := map[string]interface{}{"entries": entries, "restored": restored}
+	if err := s.auditRepo.Log(ctx, auth.ActorFromContext(ctx), "restore", "audit_archive", name, withAuthz(ctx, details)); err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+	}
+
+	return &RestoreAuditArchiveResponse{Archive: name, Entries: entries, Restored: restored}, nil
+}
+
+// RunCheckpointer periodically stores signed checkpoints of the audit log
+// chain heads. It blocks until ctx is cancelled and does nothing without a
+// signing key.
+func (s *AuditService) RunCheckpointer(ctx context.Context, interval time.Duration) {
+	if s.signingKey == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.auditRepo.Checkpoint(ctx, s.signingKey); err != nil {
+				log.Printf("Failed to checkpoint audit log: %v", err)
+			}
+		}
+	}
+}
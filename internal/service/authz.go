@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/rbac"
+)
+
+// requireScope checks that an API key caller was granted scope
+func requireScope(ctx context.Context, scope string) error {
+	if p, ok := auth.PrincipalFromContext(ctx); ok && !p.HasScope(scope) {
+		return fmt.Errorf("%w: API key %s lacks scope %s", ErrPermissionDenied, p.APIKeyName, scope)
+	}
+	return nil
+}
+
+// requireSpiffeID checks that an API key caller may manage entries for spiffeID
+func requireSpiffeID(ctx context.Context, spiffeID string) error {
+	if p, ok := auth.PrincipalFromContext(ctx); ok && !p.AllowsSpiffeID(spiffeID) {
+		return fmt.Errorf("%w: API key %s is restricted to SPIFFE IDs under %s",
+			ErrPermissionDenied, p.APIKeyName, p.SpiffeIDPrefix)
+	}
+	return nil
+}
+
+// spiffeIDScope returns the SPIFFE ID prefix an API key caller is restricted
+// to, or "" if the caller is unrestricted
+func spiffeIDScope(ctx context.Context) string {
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok || !p.IsAPIKey() {
+		return ""
+	}
+	return p.SpiffeIDPrefix
+}
+
+// withAuthz adds the request's RBAC decision to audit details
+func withAuthz(ctx context.Context, details map[string]interface{}) map[string]interface{} {
+	d, ok := rbac.DecisionFromContext(ctx)
+	if !ok {
+		return details
+	}
+	if details == nil {
+		details = make(map[string]interface{})
+	}
+	details["authz"] = d
+	return details
+}
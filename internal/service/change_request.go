@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ChangeRequestService reviews entry changes aimed at protected sites. An
+// approved change is applied immediately on behalf of the approver.
+type ChangeRequestService struct {
+	changeRepo repository.ChangeRequestRepository
+	uow        repository.UnitOfWork
+}
+
+// NewChangeRequestService creates a new ChangeRequestService. Reviews and
+// changes are written through uow, which commits them with their audit
+// records and events.
+func NewChangeRequestService(changeRepo repository.ChangeRequestRepository,
+	uow repository.UnitOfWork) *ChangeRequestService {
+	return &ChangeRequestService{
+		changeRepo: changeRepo,
+		uow:        uow,
+	}
+}
+
+// ChangeRequest represents a change request response
+type ChangeRequest struct {
+	ID              string
+	Operation       string
+	WorkloadEntryID string
+	SiteIDs         []string
+	Status          string
+	RequestedBy     string
+	ReviewedBy      string
+	ReviewComment   string
+	ApplyError      string
+	CreatedAt       *timestamppb.Timestamp
+	ReviewedAt      *timestamppb.Timestamp
+}
+
+// ListChangeRequests returns change requests, optionally filtered by status and entry
+func (s *ChangeRequestService) ListChangeRequests(ctx context.Context, status, entryID string) ([]ChangeRequest, error) {
+	if err := requireScope(ctx, auth.ScopeEntriesRead); err != nil {
+		return nil, err
+	}
+
+	changes, err := s.changeRepo.List(ctx, status, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list change requests: %w", err)
+	}
+
+	result := make([]ChangeRequest, len(changes))
+	for i := range changes {
+		result[i] = *toChangeRequest(&changes[i])
+	}
+	return result, nil
+}
+
+// GetChangeRequest returns a change request by ID
+func (s *ChangeRequestService) GetChangeRequest(ctx context.Context, id string) (*ChangeRequest, error) {
+	if err := requireScope(ctx, auth.ScopeEntriesRead); err != nil {
+		return nil, err
+	}
+
+	change, err := s.changeRepo.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get change request: %w", err)
+	}
+	if change == nil {
+		return nil, fmt.Errorf("change request not found")
+	}
+	return toChangeRequest(change), nil
+}
+
+// ApproveChange approves a pending change request and applies it. Requesters
+// cannot approve their own changes.
+func (s *ChangeRequestService) ApproveChange(ctx context.Context, id, comment string) (*ChangeRequest, error) {
+	change, err := s.pendingChange(ctx, id, repository.ChangeApproved)
+	if err != nil {
+		return nil, err
+	}
+
+	actor := auth.ActorFromContext(ctx)
+	details := map[string]interface{}{
+		"operation":         change.Operation,
+		"workload_entry_id": change.WorkloadEntryID,
+		"site_ids":          change.SiteIDs,
+	}
+
+	// The approval, the change, its audit records and its event commit
+	// together
+	var applyErr error
+	err = s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if err := review(ctx, tx, change, repository.ChangeApproved, comment); err != nil {
+			return err
+		}
+
+		var event *repository.Event
+		switch change.Operation {
+		case repository.ChangeOpAssign:
+			applyErr = tx.Entries.AssignToSites(ctx, change.WorkloadEntryID, change.SiteIDs)
+			event = entryEvent(ctx, repository.EventEntryAssigned, change.WorkloadEntryID, change.SiteIDs,
+				map[string]interface{}{"change_request_id": id})
+		case repository.ChangeOpDelete:
+			var entry *repository.WorkloadEntryWithSites
+			if entry, applyErr = tx.Entries.Get(ctx, change.WorkloadEntryID); applyErr == nil && entry == nil {
+				applyErr = fmt.Errorf("workload entry not found")
+			}
+			if applyErr == nil {
+				applyErr = tx.Entries.Delete(ctx, change.WorkloadEntryID)
+				event = entryEvent(ctx, repository.EventEntryDeleted, change.WorkloadEntryID, entrySiteIDs(entry),
+					map[string]interface{}{"spiffe_id": entry.SpiffeID, "change_request_id": id})
+			}
+		default:
+			applyErr = fmt.Errorf("unknown operation %q", change.Operation)
+		}
+		if applyErr != nil {
+			return applyErr
+		}
+
+		if err := tx.ChangeRequests.MarkApplied(ctx, id); err != nil {
+			return err
+		}
+		if err := tx.Outbox.Append(ctx, event); err != nil {
+			return err
+		}
+		return tx.Audit.Log(ctx, actor, "apply", "change_request", id, withAuthz(ctx, details))
+	})
+	if applyErr != nil {
+		// The change was rolled back with its approval; record the approval
+		// and the failure instead
+		err = s.uow.Do(ctx, func(tx *repository.Tx) error {
+			if err := review(ctx, tx, change, repository.ChangeApproved, comment); err != nil {
+				return err
+			}
+			if err := tx.ChangeRequests.MarkFailed(ctx, id, applyErr.Error()); err != nil {
+				return err
+			}
+			details["error"] = applyErr.Error()
+			return tx.Audit.Log(ctx, actor, "apply_failed", "change_request", id, withAuthz(ctx, details))
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("change request approved but could not be applied: %w", applyErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetChangeRequest(ctx, id)
+}
+
+// RejectChange rejects a pending change request. Requesters may reject their
+// own changes to withdraw them.
+func (s *ChangeRequestService) RejectChange(ctx context.Context, id, comment string) (*ChangeRequest, error) {
+	change, err := s.pendingChange(ctx, id, repository.ChangeRejected)
+	if err != nil {
+		return nil, err
+	}
+	err = s.uow.Do(ctx, func(tx *repository.Tx) error {
+		return review(ctx, tx, change, repository.ChangeRejected, comment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetChangeRequest(ctx, id)
+}
+
+// pendingChange returns the change request the caller wants to review with
+// status, checking that the caller may review it
+func (s *ChangeRequestService) pendingChange(ctx context.Context, id, status string) (*repository.ChangeRequest, error) {
+	if p, ok := auth.PrincipalFromContext(ctx); ok && p.IsAPIKey() {
+		return nil, fmt.Errorf("%w: API keys cannot review change requests", ErrPermissionDenied)
+	}
+
+	change, err := s.changeRepo.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get change request: %w", err)
+	}
+	if change == nil {
+		return nil, fmt.Errorf("change request not found")
+	}
+
+	if status == repository.ChangeApproved && auth.ActorFromContext(ctx) == change.RequestedBy {
+		return nil, fmt.Errorf("%w: requesters cannot approve their own change", ErrPermissionDenied)
+	}
+	return change, nil
+}
+
+// review records the reviewer's decision on a pending change request and
+// its audit record in tx
+func review(ctx context.Context, tx *repository.Tx, change *repository.ChangeRequest, status, comment string) error {
+	actor := auth.ActorFromContext(ctx)
+	ok, err := tx.ChangeRequests.Review(ctx, change.ID, status, actor, comment)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("change request is %s, not pending approval", change.Status)
+	}
+
+	action := "approve"
+	if status == repository.ChangeRejected {
+		action = "reject"
+	}
+	details := map[string]interface{}{
+		"operation":         change.Operation,
+		"workload_entry_id": change.WorkloadEntryID,
+		"requested_by":      change.RequestedBy,
+		"comment":           comment,
+	}
+	return tx.Audit.Log(ctx, actor, action, "change_request", change.ID, withAuthz(ctx, details))
+}
+
+// requestChange holds an entry mutation aimed at protected sites for
+// approval, creating the change request and its audit record in tx
+func requestChange(ctx context.Context, tx *repository.Tx, operation, entryID string,
+	siteIDs []string) (*ChangeRequest, error) {
+
+	actor := auth.ActorFromContext(ctx)
+	change := &repository.ChangeRequest{
+		Operation:       operation,
+		WorkloadEntryID: entryID,
+		SiteIDs:         siteIDs,
+		RequestedBy:     actor,
+	}
+	if err := tx.ChangeRequests.Create(ctx, change); err != nil {
+		return nil, fmt.Errorf("failed to create change request: %w", err)
+	}
+
+	details := map[string]interface{}{
+		"operation":         operation,
+		"workload_entry_id": entryID,
+		"site_ids":          siteIDs,
+	}
+	if err := tx.Audit.Log(ctx, actor, "request", "change_request", change.ID, withAuthz(ctx, details)); err != nil {
+		return nil, err
+	}
+
+	return &ChangeRequest{
+		ID:              change.ID,
+		Operation:       change.Operation,
+		WorkloadEntryID: change.WorkloadEntryID,
+		SiteIDs:         change.SiteIDs,
+		Status:          change.Status,
+		RequestedBy:     change.RequestedBy,
+		CreatedAt:       timestamppb.Now(),
+	}, nil
+}
+
+// splitProtected separates site IDs into sites that can be changed directly
+// and protected sites whose changes need approval
+func (s *WorkloadEntryService) splitProtected(ctx context.Context, siteIDs []string) (direct, protected []string, err error) {
+	protected, err = s.siteRepo.ProtectedSiteIDs(ctx, siteIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, id := range siteIDs {
+		if !contains(protected, id) {
+			direct = append(direct, id)
+		}
+	}
+	return direct, protected, nil
+}
+
+func toChangeRequest(c *repository.ChangeRequest) *ChangeRequest {
+	result := &ChangeRequest{
+		ID:              c.ID,
+		Operation:       c.Operation,
+		WorkloadEntryID: c.WorkloadEntryID,
+		SiteIDs:         c.SiteIDs,
+		Status:          c.Status,
+		RequestedBy:     c.RequestedBy,
+		ReviewedBy:      c.ReviewedBy,
+		ReviewComment:   c.ReviewComment,
+		ApplyError:      c.ApplyError,
+		CreatedAt:       timestamppb.New(c.CreatedAt),
+	}
+	if c.ReviewedAt != nil {
+		result.ReviewedAt = timestamppb.New(*c.ReviewedAt)
+	}
+	return result
+}
@@ -0,0 +1,183 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/notify"
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+	"github.com/yourorg/spire-workload-mgmt/internal/service"
+)
+
+// failingAudit makes every audit write of a unit of work fail
+type failingAudit struct {
+	repository.UnitOfWork
+}
+
+func (u failingAudit) Do(ctx context.Context, fn func(tx *repository.Tx) error) error {
+	return u.UnitOfWork.Do(ctx, func(tx *repository.Tx) error {
+		tx.Audit = auditFunc(func() error { return errors.New("audit log unavailable") })
+		return fn(tx)
+	})
+}
+
+type auditFunc func() error
+
+func (f auditFunc) Log(ctx context.Context, actor, action, resourceType, resourceID string, details map[string]interface{}) error {
+	return f()
+}
+
+// newChangeStore returns a store with an open site and a protected one
+func newChangeStore(t *testing.T) *repository.Store {
+	t.Helper()
+	store := repository.NewMemoryStore(notify.NewHub())
+	for _, site := range []*repository.Site{
+		{ID: "open", Name: "Open", TrustDomain: "example.org"},
+		{ID: "prod", Name: "Production", TrustDomain: "example.org", Protected: true},
+	} {
+		if err := store.Sites.Create(context.Background(), site); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func userContext(name string) context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: name})
+}
+
+// auditActions returns the audited actions on a resource, oldest first
+func auditActions(t *testing.T, store *repository.Store, resourceType, resourceID string) string {
+	t.Helper()
+	entries, err := store.Audit.List(context.Background(), 100, 0, resourceType, resourceID, "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := make([]string, len(entries))
+	for i, e := range entries {
+		actions[len(entries)-1-i] = e.Action
+	}
+	return strings.Join(actions, ",")
+}
+
+var entrySelectors = []service.Selector{{Type: "k8s", Value: "ns:payments"}}
+
+func TestApproveChange(t *testing.T) {
+	store := newChangeStore(t)
+	entries := service.NewWorkloadEntryService(store.Entries, store.Sites, store.SyncStatus, store.NamespaceGrants,
+		nil, store.UnitOfWork)
+	changes := service.NewChangeRequestService(store.ChangeRequests, store.UnitOfWork)
+
+	alice, bob := userContext("alice"), userContext("bob")
+	resp, err := entries.CreateWorkloadEntry(alice, "spiffe://example.org/api", "spiffe://example.org/agent",
+		entrySelectors, []string{"open", "prod"}, 600, "", false)
+	if err != nil {
+		t.Fatalf("CreateWorkloadEntry: %v", err)
+	}
+	change := resp.PendingChange
+	if change == nil || change.Status != repository.ChangePendingApproval || len(change.SiteIDs) != 1 {
+		t.Fatalf("pending change: %+v", change)
+	}
+	if got := auditActions(t, store, "change_request", change.ID); got != "request" {
+		t.Errorf("audited %q for the request", got)
+	}
+
+	if _, err := changes.ApproveChange(alice, change.ID, ""); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("requester approved their own change: %v", err)
+	}
+
+	approved, err := changes.ApproveChange(bob, change.ID, "ok")
+	if err != nil {
+		t.Fatalf("ApproveChange: %v", err)
+	}
+	if approved.Status != repository.ChangeApplied || approved.ReviewedBy != "bob" {
+		t.Errorf("approved change: %+v", approved)
+	}
+	if got := auditActions(t, store, "change_request", change.ID); got != "request,approve,apply" {
+		t.Errorf("audited %q for the change", got)
+	}
+	entry, _ := store.Entries.Get(context.Background(), resp.ID)
+	if len(entry.SiteStatuses) != 2 {
+		t.Errorf("entry assigned to %d sites after approval", len(entry.SiteStatuses))
+	}
+
+	if _, err := changes.ApproveChange(bob, change.ID, ""); err == nil {
+		t.Error("change was approved twice")
+	}
+}
+
+func TestApproveChangeRecordsFailure(t *testing.T) {
+	store := newChangeStore(t)
+	entries := service.NewWorkloadEntryService(store.Entries, store.Sites, store.SyncStatus, store.NamespaceGrants,
+		nil, store.UnitOfWork)
+	changes := service.NewChangeRequestService(store.ChangeRequests, store.UnitOfWork)
+
+	created := createEntry(t, store, "spiffe://example.org/api", "prod")
+	change, err := entries.DeleteWorkloadEntry(userContext("alice"), created.ID)
+	if err != nil || change == nil {
+		t.Fatalf("DeleteWorkloadEntry: %v, %v", change, err)
+	}
+
+	// The entry is purged before the deletion is approved
+	if err := store.Entries.Delete(context.Background(), created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Entries.Purge(context.Background(), created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := changes.ApproveChange(userContext("bob"), change.ID, ""); err == nil {
+		t.Fatal("approving a change that cannot be applied succeeded")
+	}
+	got, _ := changes.GetChangeRequest(userContext("bob"), change.ID)
+	if got.Status != repository.ChangeFailed || got.ReviewedBy != "bob" || got.ApplyError == "" {
+		t.Errorf("failed change: %+v", got)
+	}
+	if got := auditActions(t, store, "change_request", change.ID); got != "request,approve,apply_failed" {
+		t.Errorf("audited %q for the change", got)
+	}
+}
+
+func TestRejectChange(t *testing.T) {
+	store := newChangeStore(t)
+	entries := service.NewWorkloadEntryService(store.Entries, store.Sites, store.SyncStatus, store.NamespaceGrants,
+		nil, store.UnitOfWork)
+	changes := service.NewChangeRequestService(store.ChangeRequests, store.UnitOfWork)
+
+	created := createEntry(t, store, "spiffe://example.org/api", "open")
+	result, err := entries.AssignToSites(userContext("alice"), created.ID, []string{"prod"}, false)
+	if err != nil || result.PendingChange == nil {
+		t.Fatalf("AssignToSites: %v, %v", result, err)
+	}
+
+	// Requesters may withdraw their own change
+	rejected, err := changes.RejectChange(userContext("alice"), result.PendingChange.ID, "withdrawn")
+	if err != nil || rejected.Status != repository.ChangeRejected {
+		t.Fatalf("RejectChange: %+v, %v", rejected, err)
+	}
+	if got := auditActions(t, store, "change_request", rejected.ID); got != "request,reject" {
+		t.Errorf("audited %q for the change", got)
+	}
+}
+
+func TestChangeRequestCommitsWithEntry(t *testing.T) {
+	store := newChangeStore(t)
+	entries := service.NewWorkloadEntryService(store.Entries, store.Sites, store.SyncStatus, store.NamespaceGrants,
+		nil, failingAudit{store.UnitOfWork})
+
+	// Without its audit records, neither the entry nor its change request is
+	// stored
+	if _, err := entries.CreateWorkloadEntry(userContext("alice"), "spiffe://example.org/api", "spiffe://example.org/agent",
+		entrySelectors, []string{"open", "prod"}, 600, "", false); err == nil {
+		t.Fatal("CreateWorkloadEntry succeeded without an audit log")
+	}
+	if list, _, _ := store.Entries.List(context.Background(), repository.EntryListOptions{PageSize: 10}); len(list) != 0 {
+		t.Errorf("%d entries stored without an audit record", len(list))
+	}
+	if pending, _ := store.ChangeRequests.List(context.Background(), "", ""); len(pending) != 0 {
+		t.Errorf("%d change requests stored without an audit record", len(pending))
+	}
+}
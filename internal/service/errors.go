@@ -0,0 +1,12 @@
+package service
+
+import "errors"
+
+// ErrPermissionDenied is returned when the caller is not allowed to perform an operation
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrPolicyDenied is returned when a request is rejected by admission policy
+var ErrPolicyDenied = errors.New("denied by admission policy")
+
+// ErrInvalidArgument is returned when a request parameter is malformed
+var ErrInvalidArgument = errors.New("invalid argument")
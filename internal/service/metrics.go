@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultMetricsInterval is how often the sync gauges are recomputed
+const DefaultMetricsInterval = 30 * time.Second
+
+// The names of these metrics are those alerting relies on, from DESIGN.md
+// §9.1
+var (
+	entriesTotal = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "entries_total",
+		Help: "Workload entries that are not deleted.",
+	})
+	syncStatusBySite = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sync_status_by_site",
+		Help: "Site assignments, by site and sync status.",
+	}, []string{"site", "status"})
+	syncLagSeconds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sync_lag_seconds",
+		Help: "Age of the oldest assignment queued for a site, or 0 when the site is caught up.",
+	}, []string{"site"})
+	syncFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sync_failures_total",
+		Help: "Failed syncs reported by site agents, by site and operation.",
+	}, []string{"site", "operation"})
+)
+
+// RunMetricsCollector periodically recomputes the entry and sync gauges from
+// the database. It blocks until ctx is cancelled.
+func (s *SiteAgentService) RunMetricsCollector(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.collectMetrics(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.collectMetrics(ctx)
+		}
+	}
+}
+
+func (s *SiteAgentService) collectMetrics(ctx context.Context) {
+	stats, err := s.syncRepo.Stats(ctx)
+	if err != nil {
+		log.Printf("Failed to collect sync metrics: %v", err)
+		return
+	}
+
+	now := time.Now()
+	entriesTotal.Set(float64(stats.Entries))
+
+	// Reset so sites and statuses no longer in the stats disappear
+	syncStatusBySite.Reset()
+	syncLagSeconds.Reset()
+	for _, site := range stats.Sites {
+		for status, n := range site.Statuses {
+			syncStatusBySite.WithLabelValues(site.SiteID, status).Set(float64(n))
+		}
+		lag := 0.0
+		if site.OldestQueuedAt != nil {
+			lag = max(now.Sub(*site.OldestQueuedAt).Seconds(), 0)
+		}
+		syncLagSeconds.WithLabelValues(site.SiteID).Set(lag)
+	}
+}
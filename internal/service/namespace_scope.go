@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/rbac"
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+)
+
+// authorizeEntryContent checks that a caller whose roles are namespace scoped
+// (developers) holds a namespace grant covering the entry's namespace
+// selectors, SPIFFE ID and sites
+func (s *WorkloadEntryService) authorizeEntryContent(ctx context.Context, spiffeID string, selectors []Selector, siteIDs []string) error {
+	d, ok := rbac.DecisionFromContext(ctx)
+	if !ok || !rbac.RequiresNamespaceScope(d.Roles) {
+		return nil
+	}
+
+	namespaces := selectorNamespaces(selectors)
+	if len(namespaces) == 0 {
+		return fmt.Errorf("%w: entries must select a Kubernetes namespace (k8s:ns)", ErrPermissionDenied)
+	}
+
+	p, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("%w: no namespace grants for anonymous callers", ErrPermissionDenied)
+	}
+
+	subjects := make(map[string][]string)
+	for _, subj := range rbac.SubjectsFor(p) {
+		subjects[subj.Kind] = append(subjects[subj.Kind], subj.Name)
+	}
+	grants, err := s.grantRepo.ListForSubjects(ctx, subjects)
+	if err != nil {
+		return fmt.Errorf("failed to load namespace grants: %w", err)
+	}
+
+	for i := range grants {
+		if grantAllows(&grants[i], spiffeID, namespaces, siteIDs) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: no namespace grant allows SPIFFE ID %s in namespaces %s on sites %s",
+		ErrPermissionDenied, spiffeID, strings.Join(namespaces, ", "), strings.Join(siteIDs, ", "))
+}
+
+// grantAllows reports whether a single grant covers all namespaces, the
+// SPIFFE ID and all sites
+func grantAllows(g *repository.NamespaceGrant, spiffeID string, namespaces, siteIDs []string) bool {
+	for _, ns := range namespaces {
+		if !contains(g.Namespaces, ns) {
+			return false
+		}
+	}
+
+	if len(g.SpiffeIDPrefixes) > 0 {
+		matched := false
+		for _, prefix := range g.SpiffeIDPrefixes {
+			if auth.UnderSpiffeIDPrefix(spiffeID, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(g.SiteIDs) > 0 {
+		for _, siteID := range siteIDs {
+			if !contains(g.SiteIDs, siteID) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// selectorNamespaces returns the namespaces selected by k8s:ns selectors,
+// accepting both the SPIRE form (type "k8s", value "ns:<name>") and the
+// API form (type "k8s:ns", value "<name>")
+func selectorNamespaces(selectors []Selector) []string {
+	var namespaces []string
+	for _, sel := range selectors {
+		switch {
+		case sel.Type == "k8s" && strings.HasPrefix(sel.Value, "ns:"):
+			namespaces = append(namespaces, strings.TrimPrefix(sel.Value, "ns:"))
+		case sel.Type == "k8s:ns":
+			namespaces = append(namespaces, sel.Value)
+		}
+	}
+	return namespaces
+}
+
+func contains(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
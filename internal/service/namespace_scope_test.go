@@ -0,0 +1,56 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/rbac"
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+	"github.com/yourorg/spire-workload-mgmt/internal/service"
+)
+
+func TestNamespaceGrantScope(t *testing.T) {
+	svc, store := newWorkloadEntryService(t, "site-a", "site-b")
+	grant := &repository.NamespaceGrant{
+		SubjectKind:      rbac.SubjectUser,
+		Subject:          "dev@example.org",
+		Namespaces:       []string{"payments"},
+		SpiffeIDPrefixes: []string{"spiffe://example.org/pay"},
+		SiteIDs:          []string{"site-a"},
+	}
+	if err := store.NamespaceGrants.Create(context.Background(), grant); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "dev", Email: "dev@example.org"})
+	ctx = rbac.WithDecision(ctx, &rbac.Decision{Permission: rbac.PermEntriesWrite, Allowed: true, Roles: []string{rbac.RoleDeveloper}})
+
+	tests := []struct {
+		name      string
+		spiffeID  string
+		namespace string
+		siteID    string
+		allowed   bool
+	}{
+		{"prefix itself", "spiffe://example.org/pay", "payments", "site-a", true},
+		{"below prefix", "spiffe://example.org/pay/api", "payments", "site-a", true},
+		{"prefix as partial segment", "spiffe://example.org/payroll", "payments", "site-a", false},
+		{"other namespace", "spiffe://example.org/pay/api", "billing", "site-a", false},
+		{"other site", "spiffe://example.org/pay/api", "payments", "site-b", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selectors := []service.Selector{{Type: "k8s", Value: "ns:" + tt.namespace}}
+			_, err := svc.CreateWorkloadEntry(ctx, tt.spiffeID, "spiffe://example.org/agent", selectors,
+				[]string{tt.siteID}, 600, "", true)
+			if tt.allowed && err != nil {
+				t.Errorf("CreateWorkloadEntry: %v", err)
+			}
+			if !tt.allowed && !errors.Is(err, service.ErrPermissionDenied) {
+				t.Errorf("CreateWorkloadEntry outside the grant: %v", err)
+			}
+		})
+	}
+}
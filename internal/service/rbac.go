@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/rbac"
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// RBACService manages role bindings and namespace grants and resolves role
+// bindings for the authorizer
+type RBACService struct {
+	bindingRepo repository.RoleBindingRepository
+	grantRepo   repository.NamespaceGrantRepository
+	uow         repository.UnitOfWork
+}
+
+// NewRBACService creates a new RBACService. Bindings and grants are written
+// through uow, which commits them with their audit records.
+func NewRBACService(bindingRepo repository.RoleBindingRepository, grantRepo repository.NamespaceGrantRepository,
+	uow repository.UnitOfWork) *RBACService {
+	return &RBACService{
+		bindingRepo: bindingRepo,
+		grantRepo:   grantRepo,
+		uow:         uow,
+	}
+}
+
+// RoleBinding represents a role binding response
+type RoleBinding struct {
+	ID          string
+	Role        string
+	SubjectKind string
+	Subject     string
+	CreatedBy   string
+	CreatedAt   *timestamppb.Timestamp
+}
+
+// NamespaceGrant represents a namespace grant response
+type NamespaceGrant struct {
+	ID               string
+	SubjectKind      string
+	Subject          string
+	Namespaces       []string
+	SpiffeIDPrefixes []string
+	SiteIDs          []string
+	CreatedBy        string
+	CreatedAt        *timestamppb.Timestamp
+}
+
+// ListRoles returns all roles and their permissions
+func (s *RBACService) ListRoles(ctx context.Context) []rbac.Role {
+	return rbac.Roles()
+}
+
+// ListRoleBindings returns role bindings, optionally filtered by subject
+func (s *RBACService) ListRoleBindings(ctx context.Context, subjectKind, subject string) ([]RoleBinding, error) {
+	bindings, err := s.bindingRepo.List(ctx, subjectKind, subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+
+	result := make([]RoleBinding, len(bindings))
+	for i := range bindings {
+		result[i] = toRoleBinding(&bindings[i])
+	}
+	return result, nil
+}
+
+// CreateRoleBinding grants a role to a user, group or API key
+func (s *RBACService) CreateRoleBinding(ctx context.Context, role, subjectKind, subject string) (*RoleBinding, error) {
+	if !rbac.IsValidRole(role) {
+		return nil, fmt.Errorf("unknown role %q", role)
+	}
+	if !rbac.IsValidSubjectKind(subjectKind) {
+		return nil, fmt.Errorf("unknown subject kind %q (valid kinds: user, group, apikey)", subjectKind)
+	}
+	if subject == "" {
+		return nil, fmt.Errorf("subject is required")
+	}
+
+	actor := auth.ActorFromContext(ctx)
+	b := &repository.RoleBinding{
+		Role:        role,
+		SubjectKind: subjectKind,
+		Subject:     subject,
+		CreatedBy:   actor,
+	}
+	details := map[string]interface{}{
+		"role":         role,
+		"subject_kind": subjectKind,
+		"subject":      subject,
+	}
+	err := s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if err := tx.RoleBindings.Create(ctx, b); err != nil {
+			return fmt.Errorf("failed to create role binding: %w", err)
+		}
+		return tx.Audit.Log(ctx, actor, "create", "role_binding", b.ID, withAuthz(ctx, details))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := s.bindingRepo.Get(ctx, b.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role binding: %w", err)
+	}
+	result := toRoleBinding(created)
+	return &result, nil
+}
+
+// DeleteRoleBinding removes a role binding
+func (s *RBACService) DeleteRoleBinding(ctx context.Context, id string) error {
+	b, err := s.bindingRepo.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get role binding: %w", err)
+	}
+	if b == nil {
+		return fmt.Errorf("role binding not found")
+	}
+
+	details := map[string]interface{}{
+		"role":         b.Role,
+		"subject_kind": b.SubjectKind,
+		"subject":      b.Subject,
+	}
+	return s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if err := tx.RoleBindings.Delete(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete role binding: %w", err)
+		}
+		return tx.Audit.Log(ctx, auth.ActorFromContext(ctx), "delete", "role_binding", id, withAuthz(ctx, details))
+	})
+}
+
+// ListNamespaceGrants returns namespace grants, optionally filtered by subject
+func (s *RBACService) ListNamespaceGrants(ctx context.Context, subjectKind, subject string) ([]NamespaceGrant, error) {
+	grants, err := s.grantRepo.List(ctx, subjectKind, subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespace grants: %w", err)
+	}
+
+	result := make([]NamespaceGrant, len(grants))
+	for i := range grants {
+		result[i] = toNamespaceGrant(&grants[i])
+	}
+	return result, nil
+}
+
+// CreateNamespaceGrant allows a developer subject to manage entries in the
+// given namespaces, under the given SPIFFE ID prefixes and on the given sites
+func (s *RBACService) CreateNamespaceGrant(ctx context.Context, subjectKind, subject string,
+	namespaces, spiffeIDPrefixes, siteIDs []string) (*NamespaceGrant, error) {
+
+	if !rbac.IsValidSubjectKind(subjectKind) {
+		return nil, fmt.Errorf("unknown subject kind %q (valid kinds: user, group, apikey)", subjectKind)
+	}
+	if subject == "" {
+		return nil, fmt.Errorf("subject is required")
+	}
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("at least one namespace is required")
+	}
+	for _, prefix := range spiffeIDPrefixes {
+		if !strings.HasPrefix(prefix, "spiffe://") {
+			return nil, fmt.Errorf("SPIFFE ID prefix %q must start with spiffe://", prefix)
+		}
+	}
+
+	actor := auth.ActorFromContext(ctx)
+	g := &repository.NamespaceGrant{
+		SubjectKind:      subjectKind,
+		Subject:          subject,
+		Namespaces:       namespaces,
+		SpiffeIDPrefixes: spiffeIDPrefixes,
+		SiteIDs:          siteIDs,
+		CreatedBy:        actor,
+	}
+	details := map[string]interface{}{
+		"subject_kind":       subjectKind,
+		"subject":            subject,
+		"namespaces":         namespaces,
+		"spiffe_id_prefixes": spiffeIDPrefixes,
+		"site_ids":           siteIDs,
+	}
+	err := s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if err := tx.NamespaceGrants.Create(ctx, g); err != nil {
+			return fmt.Errorf("failed to create namespace grant: %w", err)
+		}
+		return tx.Audit.Log(ctx, actor, "create", "namespace_grant", g.ID, withAuthz(ctx, details))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := s.grantRepo.Get(ctx, g.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get namespace grant: %w", err)
+	}
+	result := toNamespaceGrant(created)
+	return &result, nil
+}
+
+// DeleteNamespaceGrant removes a namespace grant
+func (s *RBACService) DeleteNamespaceGrant(ctx context.Context, id string) error {
+	g, err := s.grantRepo.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get namespace grant: %w", err)
+	}
+	if g == nil {
+		return fmt.Errorf("namespace grant not found")
+	}
+
+	details := map[string]interface{}{
+		"subject_kind": g.SubjectKind,
+		"subject":      g.Subject,
+		"namespaces":   g.Namespaces,
+	}
+	return s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if err := tx.NamespaceGrants.Delete(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete namespace grant: %w", err)
+		}
+		return tx.Audit.Log(ctx, auth.ActorFromContext(ctx), "delete", "namespace_grant", id, withAuthz(ctx, details))
+	})
+}
+
+// RolesFor implements rbac.BindingStore
+func (s *RBACService) RolesFor(ctx context.Context, subjects []rbac.Subject) ([]string, error) {
+	byKind := make(map[string][]string)
+	for _, subj := range subjects {
+		byKind[subj.Kind] = append(byKind[subj.Kind], subj.Name)
+	}
+	return s.bindingRepo.RolesForSubjects(ctx, byKind)
+}
+
+func toRoleBinding(b *repository.RoleBinding) RoleBinding {
+	return RoleBinding{
+		ID:          b.ID,
+		Role:        b.Role,
+		SubjectKind: b.SubjectKind,
+		Subject:     b.Subject,
+		CreatedBy:   b.CreatedBy,
+		CreatedAt:   timestamppb.New(b.CreatedAt),
+	}
+}
+
+func toNamespaceGrant(g *repository.NamespaceGrant) NamespaceGrant {
+	return NamespaceGrant{
+		ID:               g.ID,
+		SubjectKind:      g.SubjectKind,
+		Subject:          g.Subject,
+		Namespaces:       g.Namespaces,
+		SpiffeIDPrefixes: g.SpiffeIDPrefixes,
+		SiteIDs:          g.SiteIDs,
+		CreatedBy:        g.CreatedBy,
+		CreatedAt:        timestamppb.New(g.CreatedAt),
+	}
+}
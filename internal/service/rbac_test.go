@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/service"
+)
+
+func TestRoleBindingIsAuditedAtomically(t *testing.T) {
+	store := newChangeStore(t)
+	ctx := userContext("alice")
+
+	svc := service.NewRBACService(store.RoleBindings, store.NamespaceGrants, store.UnitOfWork)
+	b, err := svc.CreateRoleBinding(ctx, "operator", "user", "bob")
+	if err != nil {
+		t.Fatalf("CreateRoleBinding: %v", err)
+	}
+	if got := auditActions(t, store, "role_binding", b.ID); got != "create" {
+		t.Errorf("audited actions: %q", got)
+	}
+
+	// Without its audit record, neither a binding nor its removal is stored
+	failing := service.NewRBACService(store.RoleBindings, store.NamespaceGrants, failingAudit{store.UnitOfWork})
+	if _, err := failing.CreateRoleBinding(ctx, "viewer", "user", "carol"); err == nil {
+		t.Fatal("CreateRoleBinding succeeded without an audit log")
+	}
+	if bindings, _ := store.RoleBindings.List(context.Background(), "user", "carol"); len(bindings) != 0 {
+		t.Errorf("%d role bindings stored without an audit record", len(bindings))
+	}
+	if err := failing.DeleteRoleBinding(ctx, b.ID); err == nil {
+		t.Fatal("DeleteRoleBinding succeeded without an audit log")
+	}
+	if kept, _ := store.RoleBindings.Get(context.Background(), b.ID); kept == nil {
+		t.Error("role binding deleted without an audit record")
+	}
+}
+
+func TestNamespaceGrantIsAuditedAtomically(t *testing.T) {
+	store := newChangeStore(t)
+	ctx := userContext("alice")
+
+	failing := service.NewRBACService(store.RoleBindings, store.NamespaceGrants, failingAudit{store.UnitOfWork})
+	if _, err := failing.CreateNamespaceGrant(ctx, "user", "bob", []string{"payments"}, nil, nil); err == nil {
+		t.Fatal("CreateNamespaceGrant succeeded without an audit log")
+	}
+	if grants, _ := store.NamespaceGrants.List(context.Background(), "user", "bob"); len(grants) != 0 {
+		t.Errorf("%d namespace grants stored without an audit record", len(grants))
+	}
+}
@@ -4,17 +4,18 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
 	"github.com/yourorg/spire-workload-mgmt/internal/repository"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // SiteService handles site operations
 type SiteService struct {
-	siteRepo *repository.SiteRepository
+	siteRepo repository.SiteRepository
 }
 
 // NewSiteService creates a new SiteService
-func NewSiteService(siteRepo *repository.SiteRepository) *SiteService {
+func NewSiteService(siteRepo repository.SiteRepository) *SiteService {
 	return &SiteService{siteRepo: siteRepo}
 }
 
@@ -25,12 +26,18 @@ type Site struct {
 	Region             string
 	SpireServerAddress string
 	TrustDomain        string
+	AgentSpiffeID      string
+	Protected          bool
 	Status             string
 	LastSyncAt         *timestamppb.Timestamp
 }
 
 // ListSites returns all sites, optionally filtered by status
 func (s *SiteService) ListSites(ctx context.Context, status string) ([]Site, error) {
+	if err := requireScope(ctx, auth.ScopeSitesRead); err != nil {
+		return nil, err
+	}
+
 	sites, err := s.siteRepo.List(ctx, status)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list sites: %w", err)
@@ -44,6 +51,8 @@ func (s *SiteService) ListSites(ctx context.Context, status string) ([]Site, err
 			Region:             site.Region,
 			SpireServerAddress: site.SpireServerAddress,
 			TrustDomain:        site.TrustDomain,
+			AgentSpiffeID:      site.AgentSpiffeID,
+			Protected:          site.Protected,
 			Status:             site.Status,
 		}
 		if site.LastSyncAt != nil {
@@ -56,6 +65,10 @@ func (s *SiteService) ListSites(ctx context.Context, status string) ([]Site, err
 
 // GetSite returns a site by ID
 func (s *SiteService) GetSite(ctx context.Context, id string) (*Site, error) {
+	if err := requireScope(ctx, auth.ScopeSitesRead); err != nil {
+		return nil, err
+	}
+
 	site, err := s.siteRepo.Get(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get site: %w", err)
@@ -70,6 +83,8 @@ func (s *SiteService) GetSite(ctx context.Context, id string) (*Site, error) {
 		Region:             site.Region,
 		SpireServerAddress: site.SpireServerAddress,
 		TrustDomain:        site.TrustDomain,
+		AgentSpiffeID:      site.AgentSpiffeID,
+		Protected:          site.Protected,
 		Status:             site.Status,
 	}
 	if site.LastSyncAt != nil {
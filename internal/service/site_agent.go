@@ -4,27 +4,92 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/yourorg/spire-workload-mgmt/internal/agentauth"
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/notify"
 	"github.com/yourorg/spire-workload-mgmt/internal/repository"
 )
 
+// MaxPollWait is the longest a long-poll request is held open by the server
+const MaxPollWait = 60 * time.Second
+
+// DefaultHeartbeatTimeout is how long a site may go without an agent heartbeat
+// before it is marked disconnected
+const DefaultHeartbeatTimeout = 5 * time.Minute
+
 // SiteAgentService handles site agent sync operations
 type SiteAgentService struct {
-	syncRepo  *repository.SyncStatusRepository
-	siteRepo  *repository.SiteRepository
-	auditRepo *repository.AuditRepository
+	syncRepo  repository.SyncStatusRepository
+	siteRepo  repository.SiteRepository
+	agentRepo repository.AgentRepository
+	uow       repository.UnitOfWork
+	hub       *notify.Hub
+
+	// requireAgentIdentity rejects agent calls that did not arrive over mTLS
+	requireAgentIdentity bool
 }
 
-// NewSiteAgentService creates a new SiteAgentService
-func NewSiteAgentService(syncRepo *repository.SyncStatusRepository, siteRepo *repository.SiteRepository,
-	auditRepo *repository.AuditRepository) *SiteAgentService {
+// NewSiteAgentService creates a new SiteAgentService. Sync results and site
+// status changes are written through uow, which commits them with their audit
+// records.
+func NewSiteAgentService(syncRepo repository.SyncStatusRepository, siteRepo repository.SiteRepository,
+	agentRepo repository.AgentRepository, uow repository.UnitOfWork, hub *notify.Hub) *SiteAgentService {
 	return &SiteAgentService{
 		syncRepo:  syncRepo,
 		siteRepo:  siteRepo,
-		auditRepo: auditRepo,
+		agentRepo: agentRepo,
+		uow:       uow,
+		hub:       hub,
 	}
 }
 
+// RequireAgentIdentity controls whether agent calls must carry a verified
+// SPIFFE ID. Calls that do carry one are always checked against the site's
+// bound agent ID.
+func (s *SiteAgentService) RequireAgentIdentity(require bool) {
+	s.requireAgentIdentity = require
+}
+
+// authorizeSite verifies the site exists and the caller is its agent
+func (s *SiteAgentService) authorizeSite(ctx context.Context, siteID string) error {
+	site, err := s.siteRepo.Get(ctx, siteID)
+	if err != nil {
+		return fmt.Errorf("failed to get site: %w", err)
+	}
+	if site == nil {
+		return fmt.Errorf("site not found: %s", siteID)
+	}
+
+	peerID, ok := agentauth.PeerIDFromContext(ctx)
+	if !ok {
+		if s.requireAgentIdentity {
+			return fmt.Errorf("%w: agent calls require an mTLS client SVID", ErrPermissionDenied)
+		}
+		return nil
+	}
+
+	if site.AgentSpiffeID == "" || site.AgentSpiffeID != peerID.String() {
+		return fmt.Errorf("%w: %s is not the agent for site %s", ErrPermissionDenied, peerID, siteID)
+	}
+
+	return nil
+}
+
+// AgentInfo represents a site agent's last reported heartbeat
+type AgentInfo struct {
+	SiteID             string
+	AgentID            string
+	Version            string
+	StartedAt          time.Time
+	IsLeader           bool
+	Backlog            int
+	SpireReachable     bool
+	SpireServerVersion string
+	LastHeartbeatAt    time.Time
+}
+
 // PendingEntry represents an entry pending sync
 type PendingEntry struct {
 	WorkloadEntryID string
@@ -40,26 +105,71 @@ type DeletionEntry struct {
 	SpireEntryID    string
 }
 
-// PollEntries returns entries pending sync for a site
-func (s *SiteAgentService) PollEntries(ctx context.Context, siteID string, maxEntries int) ([]PendingEntry, error) {
-	// Verify site exists
-	site, err := s.siteRepo.Get(ctx, siteID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get site: %w", err)
-	}
-	if site == nil {
-		return nil, fmt.Errorf("site not found: %s", siteID)
+// PollEntries returns entries pending sync for a site. If wait is positive and
+// the site has no pending entries or deletions, the call blocks until work is
+// queued for the site, the wait elapses or the context is cancelled.
+func (s *SiteAgentService) PollEntries(ctx context.Context, siteID string, maxEntries int, wait time.Duration) ([]PendingEntry, error) {
+	// Verify site exists and the caller is its agent
+	if err := s.authorizeSite(ctx, siteID); err != nil {
+		return nil, err
 	}
 
 	if maxEntries <= 0 || maxEntries > 100 {
 		maxEntries = 10
 	}
 
-	entries, err := s.syncRepo.GetPendingEntries(ctx, siteID, maxEntries)
+	if wait > MaxPollWait {
+		wait = MaxPollWait
+	}
+
+	var wakeCh <-chan struct{}
+	if wait > 0 && s.hub != nil {
+		// Subscribe before querying so writes committed in between are not missed
+		ch, cancel := s.hub.Subscribe(siteID)
+		defer cancel()
+		wakeCh = ch
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		entries, err := s.syncRepo.GetPendingEntries(ctx, siteID, maxEntries)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pending entries: %w", err)
+		}
+		if len(entries) > 0 || wakeCh == nil {
+			return toPendingEntries(entries), nil
+		}
+
+		// Return early so the agent can process queued deletions
+		hasDeletions, err := s.hasDeletions(ctx, siteID)
+		if err != nil {
+			return nil, err
+		}
+		if hasDeletions {
+			return []PendingEntry{}, nil
+		}
+
+		select {
+		case <-wakeCh:
+		case <-timer.C:
+			return []PendingEntry{}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *SiteAgentService) hasDeletions(ctx context.Context, siteID string) (bool, error) {
+	deletions, err := s.syncRepo.GetDeletionEntries(ctx, siteID, 1)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pending entries: %w", err)
+		return false, fmt.Errorf("failed to get deletion entries: %w", err)
 	}
+	return len(deletions) > 0, nil
+}
 
+func toPendingEntries(entries []repository.PendingEntry) []PendingEntry {
 	result := make([]PendingEntry, len(entries))
 	for i, e := range entries {
 		selectors := make([]Selector, len(e.Selectors))
@@ -74,12 +184,15 @@ func (s *SiteAgentService) PollEntries(ctx context.Context, siteID string, maxEn
 			TTL:             e.TTL,
 		}
 	}
-
-	return result, nil
+	return result
 }
 
 // ReportSyncResult reports the result of syncing an entry
 func (s *SiteAgentService) ReportSyncResult(ctx context.Context, siteID, entryID string, success bool, spireEntryID, errorMsg string) error {
+	if err := s.authorizeSite(ctx, siteID); err != nil {
+		return err
+	}
+
 	var status string
 	if success {
 		status = "synced"
@@ -87,16 +200,6 @@ func (s *SiteAgentService) ReportSyncResult(ctx context.Context, siteID, entryID
 		status = "failed"
 	}
 
-	if err := s.syncRepo.UpdateSyncStatus(ctx, siteID, entryID, status, spireEntryID, errorMsg); err != nil {
-		return fmt.Errorf("failed to update sync status: %w", err)
-	}
-
-	// Update site last sync time
-	if err := s.siteRepo.UpdateLastSyncAt(ctx, siteID); err != nil {
-		log.Printf("Failed to update site last_sync_at: %v", err)
-	}
-
-	// Audit log for sync events
 	details := map[string]interface{}{
 		"site_id":        siteID,
 		"success":        success,
@@ -105,8 +208,22 @@ func (s *SiteAgentService) ReportSyncResult(ctx context.Context, siteID, entryID
 	if errorMsg != "" {
 		details["error"] = errorMsg
 	}
-	if err := s.auditRepo.Log(ctx, "site-agent-"+siteID, "sync", "workload_entry", entryID, details); err != nil {
-		log.Printf("Failed to write audit log: %v", err)
+	err := s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if err := tx.SyncStatus.UpdateSyncStatus(ctx, siteID, entryID, status, spireEntryID, errorMsg); err != nil {
+			return fmt.Errorf("failed to update sync status: %w", err)
+		}
+		return tx.Audit.Log(ctx, "site-agent-"+siteID, "sync", "workload_entry", entryID, withAuthz(ctx, details))
+	})
+	if err != nil {
+		return err
+	}
+	if !success {
+		syncFailuresTotal.WithLabelValues(siteID, "sync").Inc()
+	}
+
+	// Update site last sync time
+	if err := s.siteRepo.UpdateLastSyncAt(ctx, siteID); err != nil {
+		log.Printf("Failed to update site last_sync_at: %v", err)
 	}
 
 	return nil
@@ -114,13 +231,9 @@ func (s *SiteAgentService) ReportSyncResult(ctx context.Context, siteID, entryID
 
 // PollDeletions returns entries pending deletion from a site
 func (s *SiteAgentService) PollDeletions(ctx context.Context, siteID string, maxEntries int) ([]DeletionEntry, error) {
-	// Verify site exists
-	site, err := s.siteRepo.Get(ctx, siteID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get site: %w", err)
-	}
-	if site == nil {
-		return nil, fmt.Errorf("site not found: %s", siteID)
+	// Verify site exists and the caller is its agent
+	if err := s.authorizeSite(ctx, siteID); err != nil {
+		return nil, err
 	}
 
 	if maxEntries <= 0 || maxEntries > 100 {
@@ -145,19 +258,10 @@ func (s *SiteAgentService) PollDeletions(ctx context.Context, siteID string, max
 
 // ReportDeletionResult reports the result of deleting an entry from SPIRE
 func (s *SiteAgentService) ReportDeletionResult(ctx context.Context, siteID, entryID string, success bool, errorMsg string) error {
-	if success {
-		// Remove the site entry on successful deletion
-		if err := s.syncRepo.RemoveSiteEntry(ctx, siteID, entryID); err != nil {
-			return fmt.Errorf("failed to remove site entry: %w", err)
-		}
-	} else {
-		// Update status to failed
-		if err := s.syncRepo.UpdateSyncStatus(ctx, siteID, entryID, "failed", "", errorMsg); err != nil {
-			return fmt.Errorf("failed to update sync status: %w", err)
-		}
+	if err := s.authorizeSite(ctx, siteID); err != nil {
+		return err
 	}
 
-	// Audit log for deletion events
 	details := map[string]interface{}{
 		"site_id": siteID,
 		"success": success,
@@ -165,9 +269,159 @@ func (s *SiteAgentService) ReportDeletionResult(ctx context.Context, siteID, ent
 	if errorMsg != "" {
 		details["error"] = errorMsg
 	}
-	if err := s.auditRepo.Log(ctx, "site-agent-"+siteID, "delete_sync", "workload_entry", entryID, details); err != nil {
-		log.Printf("Failed to write audit log: %v", err)
+	err := s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if !success {
+			if err := tx.SyncStatus.UpdateSyncStatus(ctx, siteID, entryID, "failed", "", errorMsg); err != nil {
+				return fmt.Errorf("failed to update sync status: %w", err)
+			}
+			return tx.Audit.Log(ctx, "site-agent-"+siteID, "delete_sync", "workload_entry", entryID, withAuthz(ctx, details))
+		}
+
+		// Remove the site entry on successful deletion, and the tombstone
+		// once every site has confirmed
+		purged, err := tx.SyncStatus.RemoveSiteEntry(ctx, siteID, entryID)
+		if err != nil {
+			return fmt.Errorf("failed to remove site entry: %w", err)
+		}
+		if err := tx.Audit.Log(ctx, "site-agent-"+siteID, "delete_sync", "workload_entry", entryID, withAuthz(ctx, details)); err != nil {
+			return err
+		}
+		if purged {
+			return tx.Audit.Log(ctx, "site-agent-"+siteID, "purge", "workload_entry", entryID, map[string]interface{}{"forced": false})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !success {
+		syncFailuresTotal.WithLabelValues(siteID, "delete").Inc()
 	}
 
 	return nil
 }
+
+// Heartbeat records an agent heartbeat and reconnects its site if it had been
+// marked disconnected
+func (s *SiteAgentService) Heartbeat(ctx context.Context, hb AgentInfo) error {
+	if hb.AgentID == "" {
+		return fmt.Errorf("agent_id is required")
+	}
+	if hb.StartedAt.IsZero() {
+		hb.StartedAt = time.Now().UTC()
+	}
+
+	if err := s.authorizeSite(ctx, hb.SiteID); err != nil {
+		return err
+	}
+
+	agent := &repository.Agent{
+		SiteID:             hb.SiteID,
+		AgentID:            hb.AgentID,
+		Version:            hb.Version,
+		StartedAt:          hb.StartedAt,
+		IsLeader:           hb.IsLeader,
+		Backlog:            hb.Backlog,
+		SpireReachable:     hb.SpireReachable,
+		SpireServerVersion: hb.SpireServerVersion,
+	}
+	if err := s.agentRepo.RecordHeartbeat(ctx, agent); err != nil {
+		return fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+
+	var reconnected bool
+	err := s.uow.Do(ctx, func(tx *repository.Tx) error {
+		var err error
+		if reconnected, err = tx.Sites.MarkConnected(ctx, hb.SiteID); err != nil {
+			return fmt.Errorf("failed to update site status: %w", err)
+		}
+		if !reconnected {
+			return nil
+		}
+		details := map[string]interface{}{
+			"agent_id": hb.AgentID,
+			"version":  hb.Version,
+		}
+		return tx.Audit.Log(ctx, "site-agent-"+hb.SiteID, "reconnect", "site", hb.SiteID, withAuthz(ctx, details))
+	})
+	if err != nil {
+		return err
+	}
+	if reconnected {
+		log.Printf("Site %s reconnected (agent %s)", hb.SiteID, hb.AgentID)
+	}
+
+	return nil
+}
+
+// ListAgents returns the agent inventory, optionally filtered by site
+func (s *SiteAgentService) ListAgents(ctx context.Context, siteID string) ([]AgentInfo, error) {
+	if err := requireScope(ctx, auth.ScopeSitesRead); err != nil {
+		return nil, err
+	}
+
+	agents, err := s.agentRepo.List(ctx, siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+
+	result := make([]AgentInfo, len(agents))
+	for i, a := range agents {
+		result[i] = AgentInfo{
+			SiteID:             a.SiteID,
+			AgentID:            a.AgentID,
+			Version:            a.Version,
+			StartedAt:          a.StartedAt,
+			IsLeader:           a.IsLeader,
+			Backlog:            a.Backlog,
+			SpireReachable:     a.SpireReachable,
+			SpireServerVersion: a.SpireServerVersion,
+			LastHeartbeatAt:    a.LastHeartbeatAt,
+		}
+	}
+
+	return result, nil
+}
+
+// RunLivenessMonitor periodically marks sites disconnected when no agent
+// heartbeat has arrived within timeout. It blocks until ctx is cancelled.
+func (s *SiteAgentService) RunLivenessMonitor(ctx context.Context, timeout, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkLiveness(ctx, timeout)
+		}
+	}
+}
+
+func (s *SiteAgentService) checkLiveness(ctx context.Context, timeout time.Duration) {
+	var siteIDs []string
+	err := s.uow.Do(ctx, func(tx *repository.Tx) error {
+		var err error
+		if siteIDs, err = tx.Sites.MarkDisconnected(ctx, timeout); err != nil {
+			return err
+		}
+		details := map[string]interface{}{
+			"timeout_seconds": int(timeout.Seconds()),
+		}
+		for _, siteID := range siteIDs {
+			if err := tx.Audit.Log(ctx, "system", "disconnect", "site", siteID, details); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to check agent liveness: %v", err)
+		return
+	}
+
+	for _, siteID := range siteIDs {
+		log.Printf("Site %s disconnected: no agent heartbeat for %s", siteID, timeout)
+	}
+}
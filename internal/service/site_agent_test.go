@@ -0,0 +1,241 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/yourorg/spire-workload-mgmt/internal/agentauth"
+	"github.com/yourorg/spire-workload-mgmt/internal/notify"
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+	"github.com/yourorg/spire-workload-mgmt/internal/service"
+)
+
+func newSiteAgentService(t *testing.T, siteIDs ...string) (*service.SiteAgentService, *repository.Store) {
+	t.Helper()
+	hub := notify.NewHub()
+	store := repository.NewMemoryStore(hub)
+	for _, id := range siteIDs {
+		site := &repository.Site{ID: id, Name: "Site " + id, TrustDomain: "example.org"}
+		if err := store.Sites.Create(context.Background(), site); err != nil {
+			t.Fatalf("failed to create site %s: %v", id, err)
+		}
+	}
+	svc := service.NewSiteAgentService(store.SyncStatus, store.Sites, store.Agents, store.UnitOfWork, hub)
+	return svc, store
+}
+
+func TestPollEntriesTimesOut(t *testing.T) {
+	svc, _ := newSiteAgentService(t, "site-a")
+
+	start := time.Now()
+	entries, err := svc.PollEntries(context.Background(), "site-a", 10, 100*time.Millisecond)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("PollEntries: %v, %v", entries, err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("PollEntries returned after %s, before the wait elapsed", elapsed)
+	}
+}
+
+func TestPollEntriesWakesOnAssignment(t *testing.T) {
+	svc, store := newSiteAgentService(t, "site-a", "site-b")
+
+	type result struct {
+		entries []service.PendingEntry
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		entries, err := svc.PollEntries(context.Background(), "site-a", 10, 10*time.Second)
+		done <- result{entries, err}
+	}()
+
+	// An entry for another site does not wake the poll
+	time.Sleep(50 * time.Millisecond)
+	createEntry(t, store, "spiffe://example.org/other", "site-b")
+	select {
+	case r := <-done:
+		t.Fatalf("poll for site-a returned for a site-b entry: %v, %v", r.entries, r.err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	createEntry(t, store, "spiffe://example.org/one", "site-a")
+	select {
+	case r := <-done:
+		if r.err != nil || len(r.entries) != 1 || r.entries[0].SpiffeID != "spiffe://example.org/one" {
+			t.Errorf("PollEntries: %v, %v", r.entries, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("PollEntries was not woken by the assignment")
+	}
+}
+
+func TestPollEntriesCancelled(t *testing.T) {
+	svc, _ := newSiteAgentService(t, "site-a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := svc.PollEntries(ctx, "site-a", 10, 10*time.Second); err != context.DeadlineExceeded {
+		t.Errorf("PollEntries after cancellation: %v", err)
+	}
+}
+
+func createEntry(t *testing.T, store *repository.Store, spiffeID string, siteIDs ...string) *repository.WorkloadEntryWithSites {
+	t.Helper()
+	entry := &repository.WorkloadEntry{
+		SpiffeID:  spiffeID,
+		ParentID:  "spiffe://example.org/agent",
+		Selectors: []repository.Selector{{Type: "k8s", Value: "ns:payments"}},
+		TTL:       600,
+		CreatedBy: "tester",
+	}
+	created, err := store.Entries.Create(context.Background(), entry, siteIDs)
+	if err != nil {
+		t.Fatalf("failed to create entry %s: %v", spiffeID, err)
+	}
+	return created
+}
+
+func TestSyncReportIsAuditedAtomically(t *testing.T) {
+	_, store := newSiteAgentService(t, "site-a")
+	entry := createEntry(t, store, "spiffe://example.org/one", "site-a")
+	ctx := context.Background()
+
+	// Without its audit record, the sync result is not stored and the entry
+	// stays pending
+	failing := service.NewSiteAgentService(store.SyncStatus, store.Sites, store.Agents, failingAudit{store.UnitOfWork}, nil)
+	if err := failing.ReportSyncResult(ctx, "site-a", entry.ID, true, "spire-1", ""); err == nil {
+		t.Fatal("ReportSyncResult succeeded without an audit log")
+	}
+	statuses, err := store.SyncStatus.GetSyncStatuses(ctx, entry.ID)
+	if err != nil || len(statuses) != 1 || statuses[0].SyncStatus != "pending" {
+		t.Fatalf("sync status after an unaudited report: %+v, %v", statuses, err)
+	}
+
+	svc := service.NewSiteAgentService(store.SyncStatus, store.Sites, store.Agents, store.UnitOfWork, nil)
+	if err := svc.ReportSyncResult(ctx, "site-a", entry.ID, true, "spire-1", ""); err != nil {
+		t.Fatalf("ReportSyncResult: %v", err)
+	}
+	if statuses, _ := store.SyncStatus.GetSyncStatuses(ctx, entry.ID); statuses[0].SyncStatus != "synced" {
+		t.Errorf("sync status after a report: %s", statuses[0].SyncStatus)
+	}
+	if got := auditActions(t, store, "workload_entry", entry.ID); got != "sync" {
+		t.Errorf("audited actions: %q", got)
+	}
+}
+
+func TestAgentIdentityBoundToSite(t *testing.T) {
+	svc, store := newSiteAgentService(t)
+	ctx := context.Background()
+	for _, id := range []string{"site-a", "site-b"} {
+		site := &repository.Site{ID: id, Name: "Site " + id, TrustDomain: "example.org",
+			AgentSpiffeID: "spiffe://example.org/agent/" + id}
+		if err := store.Sites.Create(ctx, site); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc.RequireAgentIdentity(true)
+
+	agentA := agentauth.WithPeerID(ctx, spiffeid.RequireFromString("spiffe://example.org/agent/site-a"))
+	if _, err := svc.PollEntries(agentA, "site-a", 10, 0); err != nil {
+		t.Errorf("site-a's agent polling site-a: %v", err)
+	}
+	if _, err := svc.PollEntries(agentA, "site-b", 10, 0); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("site-a's agent polling site-b returned %v, want permission denied", err)
+	}
+	if err := svc.Heartbeat(agentA, service.AgentInfo{SiteID: "site-b", AgentID: "a"}); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("site-a's agent sending site-b's heartbeat returned %v, want permission denied", err)
+	}
+	if _, err := svc.PollEntries(ctx, "site-a", 10, 0); !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("polling without a client SVID returned %v, want permission denied", err)
+	}
+}
+
+// runLivenessChecks runs the liveness monitor until the returned function
+// stops it
+func runLivenessChecks(svc *service.SiteAgentService, timeout time.Duration) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		svc.RunLivenessMonitor(ctx, timeout, 5*time.Millisecond)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func siteStatus(t *testing.T, store *repository.Store, id string) string {
+	t.Helper()
+	site, err := store.Sites.Get(context.Background(), id)
+	if err != nil || site == nil {
+		t.Fatalf("failed to get site %s: %v", id, err)
+	}
+	return site.Status
+}
+
+func TestLivenessDisconnectAndReconnect(t *testing.T) {
+	svc, store := newSiteAgentService(t, "site-a", "site-b")
+	ctx := context.Background()
+	if err := svc.Heartbeat(ctx, service.AgentInfo{SiteID: "site-a", AgentID: "agent-a"}); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+
+	// site-a's agent goes quiet past the timeout; site-b has never sent a
+	// heartbeat, so there is nothing to time out
+	time.Sleep(60 * time.Millisecond)
+	stop := runLivenessChecks(svc, 50*time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for siteStatus(t, store, "site-a") != "disconnected" {
+		if time.Now().After(deadline) {
+			t.Fatal("site-a not disconnected after its heartbeat timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	stop()
+	if got := siteStatus(t, store, "site-b"); got != "active" {
+		t.Errorf("site-b without heartbeats is %s, want active", got)
+	}
+
+	// The next heartbeat reconnects the site, once
+	for i := 0; i < 2; i++ {
+		if err := svc.Heartbeat(ctx, service.AgentInfo{SiteID: "site-a", AgentID: "agent-a"}); err != nil {
+			t.Fatalf("Heartbeat: %v", err)
+		}
+	}
+	if got := siteStatus(t, store, "site-a"); got != "active" {
+		t.Errorf("site-a is %s after a heartbeat, want active", got)
+	}
+	if got := auditActions(t, store, "site", "site-a"); got != "disconnect,reconnect" {
+		t.Errorf("audited site-a actions: %q", got)
+	}
+	if got := auditActions(t, store, "site", "site-b"); got != "" {
+		t.Errorf("audited site-b actions: %q", got)
+	}
+}
+
+func TestLivenessKeepsSitesWithRecentHeartbeats(t *testing.T) {
+	svc, store := newSiteAgentService(t, "site-a", "site-b")
+	if err := svc.Heartbeat(context.Background(), service.AgentInfo{SiteID: "site-a", AgentID: "agent-a"}); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+
+	stop := runLivenessChecks(svc, time.Hour)
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	for _, id := range []string{"site-a", "site-b"} {
+		if got := siteStatus(t, store, id); got != "active" {
+			t.Errorf("%s is %s, want active", id, got)
+		}
+	}
+	if err := svc.Heartbeat(context.Background(), service.AgentInfo{SiteID: "site-a", AgentID: "agent-a"}); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	if got := auditActions(t, store, "site", "site-a"); got != "" {
+		t.Errorf("heartbeats of a connected site audited %q", got)
+	}
+}
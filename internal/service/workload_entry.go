@@ -2,37 +2,78 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
-	"log"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/policy"
 	"github.com/yourorg/spire-workload-mgmt/internal/repository"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // WorkloadEntryService implements the WorkloadEntryService gRPC interface
 type WorkloadEntryService struct {
-	entryRepo  *repository.EntryRepository
-	siteRepo   *repository.SiteRepository
-	syncRepo   *repository.SyncStatusRepository
-	auditRepo  *repository.AuditRepository
-	actor      string // Hardcoded actor for demo
+	entryRepo repository.EntryRepository
+	siteRepo  repository.SiteRepository
+	syncRepo  repository.SyncStatusRepository
+	grantRepo repository.NamespaceGrantRepository
+	policy    *policy.Engine
+	uow       repository.UnitOfWork
 }
 
-// NewWorkloadEntryService creates a new WorkloadEntryService
-func NewWorkloadEntryService(entryRepo *repository.EntryRepository, siteRepo *repository.SiteRepository,
-	syncRepo *repository.SyncStatusRepository, auditRepo *repository.AuditRepository) *WorkloadEntryService {
+// NewWorkloadEntryService creates a new WorkloadEntryService. Entry writes and
+// change requests go through uow, which commits them with their audit records
+// and events.
+func NewWorkloadEntryService(entryRepo repository.EntryRepository, siteRepo repository.SiteRepository,
+	syncRepo repository.SyncStatusRepository, grantRepo repository.NamespaceGrantRepository,
+	policyEngine *policy.Engine, uow repository.UnitOfWork) *WorkloadEntryService {
 	return &WorkloadEntryService{
 		entryRepo: entryRepo,
 		siteRepo:  siteRepo,
 		syncRepo:  syncRepo,
-		auditRepo: auditRepo,
-		actor:     "demo-user",
+		grantRepo: grantRepo,
+		policy:    policyEngine,
+		uow:       uow,
 	}
 }
 
-// CreateWorkloadEntry creates a new workload entry
+// CreateWorkloadEntry creates a new workload entry. Assignments to protected
+// sites are held in a change request until approved. With dryRun set, the
+// request is authorized and evaluated against admission policy but nothing
+// is stored; policy denials are returned as results rather than an error.
 func (s *WorkloadEntryService) CreateWorkloadEntry(ctx context.Context, spiffeID, parentID string,
-	selectors []Selector, siteIDs []string, ttl int, description string) (*WorkloadEntryResponse, error) {
+	selectors []Selector, siteIDs []string, ttl int, description string, dryRun bool) (*WorkloadEntryResponse, error) {
+
+	if err := requireScope(ctx, auth.ScopeEntriesWrite); err != nil {
+		return nil, err
+	}
+	if err := requireSpiffeID(ctx, spiffeID); err != nil {
+		return nil, err
+	}
+	if err := s.authorizeEntryContent(ctx, spiffeID, selectors, siteIDs); err != nil {
+		return nil, err
+	}
+
+	actor := auth.ActorFromContext(ctx)
+
+	results := s.admit(ctx, policy.OpCreate, spiffeID, parentID, selectors, siteIDs, ttl)
+	if dryRun {
+		return &WorkloadEntryResponse{
+			SpiffeID:      spiffeID,
+			ParentID:      parentID,
+			Selectors:     selectors,
+			TTL:           ttl,
+			Description:   description,
+			CreatedBy:     actor,
+			PolicyResults: results,
+		}, nil
+	}
+	if err := policyError(results); err != nil {
+		return nil, err
+	}
 
 	// Convert selectors to repository format
 	repoSelectors := make([]repository.Selector, len(selectors))
@@ -46,29 +87,62 @@ func (s *WorkloadEntryService) CreateWorkloadEntry(ctx context.Context, spiffeID
 		Selectors:   repoSelectors,
 		TTL:         ttl,
 		Description: description,
-		CreatedBy:   s.actor,
+		CreatedBy:   actor,
 	}
 
-	created, err := s.entryRepo.Create(ctx, entry, siteIDs)
+	directSiteIDs, protectedSiteIDs, err := s.splitProtected(ctx, siteIDs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create workload entry: %w", err)
+		return nil, err
 	}
 
-	// Audit log
+	// The entry, its audit record, its event and any change request for
+	// protected sites commit together
 	details := map[string]interface{}{
 		"spiffe_id": spiffeID,
 		"parent_id": parentID,
 		"site_ids":  siteIDs,
 	}
-	if err := s.auditRepo.Log(ctx, s.actor, "create", "workload_entry", created.ID, details); err != nil {
-		log.Printf("Failed to write audit log: %v", err)
+	if warnings := policyWarnings(results); len(warnings) > 0 {
+		details["policy_warnings"] = warnings
+	}
+	var created *repository.WorkloadEntryWithSites
+	var pending *ChangeRequest
+	err = s.uow.Do(ctx, func(tx *repository.Tx) error {
+		var err error
+		created, err = tx.Entries.Create(ctx, entry, directSiteIDs)
+		if err != nil {
+			return fmt.Errorf("failed to create workload entry: %w", err)
+		}
+		if err := tx.Outbox.Append(ctx, entryEvent(ctx, repository.EventEntryCreated, created.ID, directSiteIDs, map[string]interface{}{
+			"spiffe_id": spiffeID,
+			"parent_id": parentID,
+		})); err != nil {
+			return err
+		}
+		if err := tx.Audit.Log(ctx, actor, "create", "workload_entry", created.ID, withAuthz(ctx, details)); err != nil {
+			return err
+		}
+		if len(protectedSiteIDs) > 0 {
+			pending, err = requestChange(ctx, tx, repository.ChangeOpAssign, created.ID, protectedSiteIDs)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return toWorkloadEntryResponse(created), nil
+	resp := toWorkloadEntryResponse(created)
+	resp.PolicyResults = results
+	resp.PendingChange = pending
+	return resp, nil
 }
 
 // GetWorkloadEntry gets a workload entry by ID
 func (s *WorkloadEntryService) GetWorkloadEntry(ctx context.Context, id string) (*WorkloadEntryResponse, error) {
+	if err := requireScope(ctx, auth.ScopeEntriesRead); err != nil {
+		return nil, err
+	}
+
 	entry, err := s.entryRepo.Get(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get workload entry: %w", err)
@@ -76,72 +150,208 @@ func (s *WorkloadEntryService) GetWorkloadEntry(ctx context.Context, id string)
 	if entry == nil {
 		return nil, fmt.Errorf("workload entry not found")
 	}
+	if err := requireSpiffeID(ctx, entry.SpiffeID); err != nil {
+		return nil, err
+	}
 
 	return toWorkloadEntryResponse(entry), nil
 }
 
-// ListWorkloadEntries lists workload entries with pagination
+// ListWorkloadEntries lists workload entries, newest first, optionally
+// filtered by site, SPIFFE ID prefix and selectors. With deleting set it lists
+// deleted entries whose removal from sites is still in progress instead of
+// live entries. Page tokens are opaque
+// cursors, so deep pages cost no more than the first. Counting every matching
+// entry is only done with withTotal; otherwise TotalCount is -1.
 func (s *WorkloadEntryService) ListWorkloadEntries(ctx context.Context, pageSize int, pageToken string,
-	siteID, spiffeIDPrefix string) (*ListWorkloadEntriesResponse, error) {
+	siteID, spiffeIDPrefix string, bySelectors *SelectorFilter, deleting, withTotal bool) (*ListWorkloadEntriesResponse, error) {
+
+	if err := requireScope(ctx, auth.ScopeEntriesRead); err != nil {
+		return nil, err
+	}
 
 	if pageSize <= 0 || pageSize > 100 {
 		pageSize = 20
 	}
 
-	offset := 0
+	opts := repository.EntryListOptions{
+		// One more than the page tells whether there is a next page
+		PageSize:       pageSize + 1,
+		SiteID:         siteID,
+		SpiffeIDPrefix: spiffeIDPrefix,
+		// API keys restricted to a SPIFFE ID prefix only see entries under it
+		SpiffeIDScope: spiffeIDScope(ctx),
+		Deleting:      deleting,
+		WithTotal:     withTotal,
+	}
+	if bySelectors != nil {
+		filter, err := bySelectors.toRepository()
+		if err != nil {
+			return nil, err
+		}
+		opts.BySelectors = filter
+	}
 	if pageToken != "" {
-		fmt.Sscanf(pageToken, "%d", &offset)
+		after, err := decodeEntryPageToken(pageToken)
+		if err != nil {
+			return nil, err
+		}
+		opts.After = after
 	}
 
-	entries, totalCount, err := s.entryRepo.List(ctx, pageSize, offset, siteID, spiffeIDPrefix)
+	entries, totalCount, err := s.entryRepo.List(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list workload entries: %w", err)
 	}
 
+	var nextPageToken string
+	if len(entries) > pageSize {
+		entries = entries[:pageSize]
+		nextPageToken = encodeEntryPageToken(repository.CursorOf(&entries[pageSize-1].WorkloadEntry))
+	}
+
 	result := &ListWorkloadEntriesResponse{
-		Entries:    make([]*WorkloadEntryResponse, len(entries)),
-		TotalCount: totalCount,
+		Entries:       make([]*WorkloadEntryResponse, len(entries)),
+		NextPageToken: nextPageToken,
+		TotalCount:    totalCount,
 	}
 
 	for i, e := range entries {
 		result.Entries[i] = toWorkloadEntryResponse(&e)
 	}
 
-	if offset+pageSize < totalCount {
-		result.NextPageToken = fmt.Sprintf("%d", offset+pageSize)
-	}
-
 	return result, nil
 }
 
-// DeleteWorkloadEntry deletes a workload entry
-func (s *WorkloadEntryService) DeleteWorkloadEntry(ctx context.Context, id string) error {
+// encodeEntryPageToken returns the page token for the entries after c
+func encodeEntryPageToken(c *repository.EntryCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d/%s", c.CreatedAt.UnixNano(), c.ID)))
+}
+
+func decodeEntryPageToken(token string) (*repository.EntryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid page token", ErrInvalidArgument)
+	}
+	nanos, id, ok := strings.Cut(string(raw), "/")
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if !ok || err != nil || id == "" {
+		return nil, fmt.Errorf("%w: invalid page token", ErrInvalidArgument)
+	}
+	return &repository.EntryCursor{CreatedAt: time.Unix(0, n).UTC(), ID: id}, nil
+}
+
+// DeleteWorkloadEntry deletes a workload entry. The entry remains as a
+// tombstone until every assigned site confirms the removal of its SPIRE entry.
+// Entries assigned to a protected site are not deleted; a change request is
+// returned instead.
+func (s *WorkloadEntryService) DeleteWorkloadEntry(ctx context.Context, id string) (*ChangeRequest, error) {
+	if err := requireScope(ctx, auth.ScopeEntriesWrite); err != nil {
+		return nil, err
+	}
+
 	// Get entry first for audit log
 	entry, err := s.entryRepo.Get(ctx, id)
 	if err != nil {
-		return fmt.Errorf("failed to get workload entry: %w", err)
+		return nil, fmt.Errorf("failed to get workload entry: %w", err)
 	}
 	if entry == nil {
-		return fmt.Errorf("workload entry not found")
+		return nil, fmt.Errorf("workload entry not found")
+	}
+	if err := requireSpiffeID(ctx, entry.SpiffeID); err != nil {
+		return nil, err
+	}
+	if entry.DeletedAt != nil {
+		return nil, fmt.Errorf("%w: workload entry is already being deleted", ErrInvalidArgument)
+	}
+	if err := s.authorizeEntryContent(ctx, entry.SpiffeID, entrySelectors(entry), entrySiteIDs(entry)); err != nil {
+		return nil, err
 	}
 
-	if err := s.entryRepo.Delete(ctx, id); err != nil {
-		return fmt.Errorf("failed to delete workload entry: %w", err)
+	_, protectedSiteIDs, err := s.splitProtected(ctx, entrySiteIDs(entry))
+	if err != nil {
+		return nil, err
+	}
+	if len(protectedSiteIDs) > 0 {
+		var change *ChangeRequest
+		err := s.uow.Do(ctx, func(tx *repository.Tx) error {
+			var err error
+			change, err = requestChange(ctx, tx, repository.ChangeOpDelete, id, protectedSiteIDs)
+			return err
+		})
+		return change, err
 	}
 
-	// Audit log
 	details := map[string]interface{}{
 		"spiffe_id": entry.SpiffeID,
 	}
-	if err := s.auditRepo.Log(ctx, s.actor, "delete", "workload_entry", id, details); err != nil {
-		log.Printf("Failed to write audit log: %v", err)
+	err = s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if err := tx.Entries.Delete(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete workload entry: %w", err)
+		}
+		if err := tx.Outbox.Append(ctx, entryEvent(ctx, repository.EventEntryDeleted, id, entrySiteIDs(entry), map[string]interface{}{
+			"spiffe_id": entry.SpiffeID,
+		})); err != nil {
+			return err
+		}
+		return tx.Audit.Log(ctx, auth.ActorFromContext(ctx), "delete", "workload_entry", id, withAuthz(ctx, details))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// PurgeWorkloadEntry removes a deleted entry's tombstone without waiting for
+// the remaining sites to confirm the removal, for sites that will never
+// report back. SPIRE entries still at those sites are left in place.
+func (s *WorkloadEntryService) PurgeWorkloadEntry(ctx context.Context, id string) error {
+	if err := requireScope(ctx, auth.ScopeEntriesWrite); err != nil {
+		return err
+	}
+
+	entry, err := s.entryRepo.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get workload entry: %w", err)
+	}
+	if entry == nil {
+		return fmt.Errorf("workload entry not found")
+	}
+	if err := requireSpiffeID(ctx, entry.SpiffeID); err != nil {
+		return err
+	}
+	if entry.DeletedAt == nil {
+		return fmt.Errorf("%w: workload entry is not deleted", ErrInvalidArgument)
+	}
+	if err := s.authorizeEntryContent(ctx, entry.SpiffeID, entrySelectors(entry), entrySiteIDs(entry)); err != nil {
+		return err
 	}
 
-	return nil
+	details := map[string]interface{}{
+		"spiffe_id":         entry.SpiffeID,
+		"forced":            true,
+		"unconfirmed_sites": entrySiteIDs(entry),
+	}
+	return s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if err := tx.Entries.Purge(ctx, id); err != nil {
+			return fmt.Errorf("failed to purge workload entry: %w", err)
+		}
+		return tx.Audit.Log(ctx, auth.ActorFromContext(ctx), "purge", "workload_entry", id, withAuthz(ctx, details))
+	})
 }
 
-// AssignToSites assigns an entry to additional sites
-func (s *WorkloadEntryService) AssignToSites(ctx context.Context, entryID string, siteIDs []string) ([]SiteSyncStatus, error) {
+// AssignToSites assigns an entry to additional sites. Assignments to
+// protected sites are held in a change request until approved. With dryRun
+// set, nothing is stored and policy denials are returned as results rather
+// than an error.
+func (s *WorkloadEntryService) AssignToSites(ctx context.Context, entryID string, siteIDs []string,
+	dryRun bool) (*AssignResult, error) {
+
+	if err := requireScope(ctx, auth.ScopeEntriesWrite); err != nil {
+		return nil, err
+	}
+
 	// Verify entry exists
 	entry, err := s.entryRepo.Get(ctx, entryID)
 	if err != nil {
@@ -150,25 +360,85 @@ func (s *WorkloadEntryService) AssignToSites(ctx context.Context, entryID string
 	if entry == nil {
 		return nil, fmt.Errorf("workload entry not found")
 	}
+	if err := requireSpiffeID(ctx, entry.SpiffeID); err != nil {
+		return nil, err
+	}
+	if entry.DeletedAt != nil {
+		return nil, fmt.Errorf("%w: workload entry is being deleted", ErrInvalidArgument)
+	}
+	if err := s.authorizeEntryContent(ctx, entry.SpiffeID, entrySelectors(entry), siteIDs); err != nil {
+		return nil, err
+	}
+
+	result := &AssignResult{
+		PolicyResults: s.admit(ctx, policy.OpAssign, entry.SpiffeID, entry.ParentID, entrySelectors(entry), siteIDs, entry.TTL),
+	}
+	if dryRun {
+		result.Statuses, err = s.GetSyncStatus(ctx, entryID)
+		return result, err
+	}
+	if err := policyError(result.PolicyResults); err != nil {
+		return nil, err
+	}
 
-	if err := s.entryRepo.AssignToSites(ctx, entryID, siteIDs); err != nil {
-		return nil, fmt.Errorf("failed to assign to sites: %w", err)
+	directSiteIDs, protectedSiteIDs, err := s.splitProtected(ctx, siteIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	// Audit log
+	// Direct assignments, with their audit record and event, commit together
+	// with the change request for protected sites
 	details := map[string]interface{}{
-		"site_ids": siteIDs,
+		"site_ids": directSiteIDs,
+	}
+	if warnings := policyWarnings(result.PolicyResults); len(warnings) > 0 {
+		details["policy_warnings"] = warnings
 	}
-	if err := s.auditRepo.Log(ctx, s.actor, "assign", "workload_entry", entryID, details); err != nil {
-		log.Printf("Failed to write audit log: %v", err)
+	err = s.uow.Do(ctx, func(tx *repository.Tx) error {
+		if len(directSiteIDs) > 0 {
+			if err := tx.Entries.AssignToSites(ctx, entryID, directSiteIDs); err != nil {
+				return fmt.Errorf("failed to assign to sites: %w", err)
+			}
+			if err := tx.Outbox.Append(ctx, entryEvent(ctx, repository.EventEntryAssigned, entryID, directSiteIDs, nil)); err != nil {
+				return err
+			}
+			if err := tx.Audit.Log(ctx, auth.ActorFromContext(ctx), "assign", "workload_entry", entryID, withAuthz(ctx, details)); err != nil {
+				return err
+			}
+		}
+		if len(protectedSiteIDs) > 0 {
+			var err error
+			result.PendingChange, err = requestChange(ctx, tx, repository.ChangeOpAssign, entryID, protectedSiteIDs)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Return updated sync statuses
-	return s.GetSyncStatus(ctx, entryID)
+	result.Statuses, err = s.GetSyncStatus(ctx, entryID)
+	return result, err
 }
 
 // GetSyncStatus returns sync status for an entry
 func (s *WorkloadEntryService) GetSyncStatus(ctx context.Context, entryID string) ([]SiteSyncStatus, error) {
+	if err := requireScope(ctx, auth.ScopeEntriesRead); err != nil {
+		return nil, err
+	}
+
+	entry, err := s.entryRepo.Get(ctx, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workload entry: %w", err)
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("workload entry not found")
+	}
+	if err := requireSpiffeID(ctx, entry.SpiffeID); err != nil {
+		return nil, err
+	}
+
 	statuses, err := s.syncRepo.GetSyncStatuses(ctx, entryID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sync status: %w", err)
@@ -202,6 +472,55 @@ type Selector struct {
 	Value string
 }
 
+// SelectorFilter restricts a listing to entries by selector. Match is one of
+// the SelectorMatch values, as in SPIRE's ListEntries filter; a selector with
+// an empty value matches any selector of its type.
+type SelectorFilter struct {
+	Selectors []Selector
+	Match     string
+}
+
+// Selector match modes of a SelectorFilter
+const (
+	// SelectorMatchExact matches entries with exactly the filter's selectors
+	SelectorMatchExact = "exact"
+	// SelectorMatchSubset matches entries whose selectors are all in the filter
+	SelectorMatchSubset = "subset"
+	// SelectorMatchSuperset matches entries with every selector of the filter
+	SelectorMatchSuperset = "superset"
+	// SelectorMatchAny matches entries with any selector of the filter
+	SelectorMatchAny = "any"
+)
+
+var selectorMatches = map[string]repository.SelectorMatch{
+	SelectorMatchExact:    repository.MatchExact,
+	SelectorMatchSubset:   repository.MatchSubset,
+	SelectorMatchSuperset: repository.MatchSuperset,
+	SelectorMatchAny:      repository.MatchAny,
+}
+
+func (f *SelectorFilter) toRepository() (*repository.SelectorFilter, error) {
+	match, ok := selectorMatches[f.Match]
+	if f.Match == "" {
+		match, ok = repository.MatchExact, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown selector match %q", ErrInvalidArgument, f.Match)
+	}
+	if len(f.Selectors) == 0 {
+		return nil, fmt.Errorf("%w: selector filter has no selectors", ErrInvalidArgument)
+	}
+
+	filter := &repository.SelectorFilter{Match: match, Selectors: make([]repository.Selector, len(f.Selectors))}
+	for i, sel := range f.Selectors {
+		if sel.Type == "" {
+			return nil, fmt.Errorf("%w: selector filter has a selector without a type", ErrInvalidArgument)
+		}
+		filter.Selectors[i] = repository.Selector{Type: sel.Type, Value: sel.Value}
+	}
+	return filter, nil
+}
+
 type SiteSyncStatus struct {
 	SiteID       string
 	SiteName     string
@@ -221,7 +540,21 @@ type WorkloadEntryResponse struct {
 	CreatedBy    string
 	CreatedAt    *timestamppb.Timestamp
 	UpdatedAt    *timestamppb.Timestamp
+	DeletedAt    *timestamppb.Timestamp
 	SiteStatuses []SiteSyncStatus
+
+	// Admission policy results for create requests
+	PolicyResults []PolicyResult
+	// Assignments to protected sites awaiting approval
+	PendingChange *ChangeRequest
+}
+
+// AssignResult is the outcome of assigning an entry to sites
+type AssignResult struct {
+	Statuses      []SiteSyncStatus
+	PolicyResults []PolicyResult
+	// Assignments to protected sites awaiting approval
+	PendingChange *ChangeRequest
 }
 
 type ListWorkloadEntriesResponse struct {
@@ -230,6 +563,35 @@ type ListWorkloadEntriesResponse struct {
 	TotalCount    int
 }
 
+func entrySelectors(entry *repository.WorkloadEntryWithSites) []Selector {
+	selectors := make([]Selector, len(entry.Selectors))
+	for i, sel := range entry.Selectors {
+		selectors[i] = Selector{Type: sel.Type, Value: sel.Value}
+	}
+	return selectors
+}
+
+func entrySiteIDs(entry *repository.WorkloadEntryWithSites) []string {
+	siteIDs := make([]string, len(entry.SiteStatuses))
+	for i, st := range entry.SiteStatuses {
+		siteIDs[i] = st.SiteID
+	}
+	return siteIDs
+}
+
+// entryEvent returns a domain event about a workload entry, made by the caller
+func entryEvent(ctx context.Context, eventType, entryID string, siteIDs []string,
+	payload map[string]interface{}) *repository.Event {
+	return &repository.Event{
+		Type:         eventType,
+		ResourceType: "workload_entry",
+		ResourceID:   entryID,
+		SiteIDs:      siteIDs,
+		Actor:        auth.ActorFromContext(ctx),
+		Payload:      payload,
+	}
+}
+
 func toWorkloadEntryResponse(entry *repository.WorkloadEntryWithSites) *WorkloadEntryResponse {
 	selectors := make([]Selector, len(entry.Selectors))
 	for i, s := range entry.Selectors {
@@ -254,7 +616,7 @@ func toWorkloadEntryResponse(entry *repository.WorkloadEntryWithSites) *Workload
 		}
 	}
 
-	return &WorkloadEntryResponse{
+	resp := &WorkloadEntryResponse{
 		ID:           entry.ID,
 		SpiffeID:     entry.SpiffeID,
 		ParentID:     entry.ParentID,
@@ -266,4 +628,8 @@ func toWorkloadEntryResponse(entry *repository.WorkloadEntryWithSites) *Workload
 		UpdatedAt:    timestamppb.New(entry.UpdatedAt),
 		SiteStatuses: siteStatuses,
 	}
+	if entry.DeletedAt != nil {
+		resp.DeletedAt = timestamppb.New(*entry.DeletedAt)
+	}
+	return resp
 }
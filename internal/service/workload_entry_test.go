@@ -0,0 +1,87 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/auth"
+	"github.com/yourorg/spire-workload-mgmt/internal/notify"
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+	"github.com/yourorg/spire-workload-mgmt/internal/service"
+)
+
+func newWorkloadEntryService(t *testing.T, siteIDs ...string) (*service.WorkloadEntryService, *repository.Store) {
+	t.Helper()
+	store := repository.NewMemoryStore(notify.NewHub())
+	for _, id := range siteIDs {
+		site := &repository.Site{ID: id, Name: "Site " + id, TrustDomain: "example.org"}
+		if err := store.Sites.Create(context.Background(), site); err != nil {
+			t.Fatalf("failed to create site %s: %v", id, err)
+		}
+	}
+	svc := service.NewWorkloadEntryService(store.Entries, store.Sites, store.SyncStatus, store.NamespaceGrants,
+		nil, store.UnitOfWork)
+	return svc, store
+}
+
+// apiKeyContext returns a context for an API key restricted to prefix
+func apiKeyContext(prefix string) context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{
+		APIKeyID:       "key-1",
+		APIKeyName:     "ci",
+		Scopes:         auth.ValidScopes,
+		SpiffeIDPrefix: prefix,
+	})
+}
+
+func TestListWorkloadEntriesRestrictedToPathSegments(t *testing.T) {
+	svc, store := newWorkloadEntryService(t, "site-a")
+	createEntry(t, store, "spiffe://example.org/pay", "site-a")
+	createEntry(t, store, "spiffe://example.org/pay/api", "site-a")
+	createEntry(t, store, "spiffe://example.org/payroll", "site-a")
+
+	tests := []struct {
+		name   string
+		scope  string
+		filter string
+		want   int
+	}{
+		{"scope only", "spiffe://example.org/pay", "", 2},
+		{"scope with trailing slash", "spiffe://example.org/pay/", "", 2},
+		{"filter equal to scope", "spiffe://example.org/pay", "spiffe://example.org/pay", 2},
+		{"filter broader than scope", "spiffe://example.org/pay", "spiffe://example.org/p", 2},
+		{"filter within scope", "spiffe://example.org/pay", "spiffe://example.org/pay/", 1},
+		{"filter outside scope", "spiffe://example.org/pay", "spiffe://example.org/payroll", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := svc.ListWorkloadEntries(apiKeyContext(tt.scope), 10, "", "", tt.filter, nil, false, false)
+			if err != nil {
+				t.Fatalf("ListWorkloadEntries: %v", err)
+			}
+			if len(resp.Entries) != tt.want {
+				t.Errorf("listed %d entries, want %d", len(resp.Entries), tt.want)
+			}
+			for _, e := range resp.Entries {
+				if e.SpiffeID == "spiffe://example.org/payroll" {
+					t.Errorf("listed %s outside the key's prefix", e.SpiffeID)
+				}
+			}
+		})
+	}
+}
+
+func TestGetSyncStatusChecksSpiffeIDPrefix(t *testing.T) {
+	svc, store := newWorkloadEntryService(t, "site-a")
+	payroll := createEntry(t, store, "spiffe://example.org/payroll", "site-a")
+
+	_, err := svc.GetSyncStatus(apiKeyContext("spiffe://example.org/pay"), payroll.ID)
+	if !errors.Is(err, service.ErrPermissionDenied) {
+		t.Errorf("GetSyncStatus outside the key's prefix: %v", err)
+	}
+	statuses, err := svc.GetSyncStatus(apiKeyContext("spiffe://example.org/payroll"), payroll.ID)
+	if err != nil || len(statuses) != 1 {
+		t.Errorf("GetSyncStatus: %v, %v", statuses, err)
+	}
+}
@@ -0,0 +1,378 @@
+// Package snapshot writes the desired state of the management plane to a
+// signed, versioned archive and reads it back, so that a lost database can be
+// rebuilt. An archive is gzip-compressed JSON: a header naming the format
+// version and the signing key, an Ed25519 signature, and the state.
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+)
+
+// Format identifies state archives
+const Format = "spire-mgmt-state"
+
+// Version is the version of the archive format written. Decode reads every
+// version up to it.
+const Version = 1
+
+// maxSize bounds the decompressed size of an archive
+const maxSize = 1 << 30
+
+// archive is the outer document of a state archive
+type archive struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	KeyID     string    `json:"key_id"`
+	// Signature is the base64 Ed25519 signature of the header fields and the
+	// compact encoding of State
+	Signature string          `json:"signature"`
+	State     json.RawMessage `json:"state"`
+}
+
+// Version 1 of the state. Its fields are spelled out rather than taken from
+// the repository types, so that the format only changes with Version.
+type stateV1 struct {
+	Sites           []siteV1           `json:"sites"`
+	Entries         []entryV1          `json:"entries"`
+	Assignments     []assignmentV1     `json:"assignments"`
+	NamespaceGrants []namespaceGrantV1 `json:"namespace_grants"`
+}
+
+type siteV1 struct {
+	ID                 string    `json:"id"`
+	Name               string    `json:"name"`
+	Region             string    `json:"region"`
+	SpireServerAddress string    `json:"spire_server_address"`
+	TrustDomain        string    `json:"trust_domain"`
+	AgentSpiffeID      string    `json:"agent_spiffe_id,omitempty"`
+	Protected          bool      `json:"protected"`
+	Status             string    `json:"status"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+type selectorV1 struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type entryV1 struct {
+	ID          string       `json:"id"`
+	SpiffeID    string       `json:"spiffe_id"`
+	ParentID    string       `json:"parent_id"`
+	Selectors   []selectorV1 `json:"selectors"`
+	TTL         int          `json:"ttl"`
+	Description string       `json:"description"`
+	CreatedBy   string       `json:"created_by"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	DeletedAt   *time.Time   `json:"deleted_at,omitempty"`
+}
+
+type assignmentV1 struct {
+	SiteID          string `json:"site_id"`
+	WorkloadEntryID string `json:"workload_entry_id"`
+	SpireEntryID    string `json:"spire_entry_id,omitempty"`
+}
+
+type namespaceGrantV1 struct {
+	ID               string    `json:"id"`
+	SubjectKind      string    `json:"subject_kind"`
+	Subject          string    `json:"subject"`
+	Namespaces       []string  `json:"namespaces"`
+	SpiffeIDPrefixes []string  `json:"spiffe_id_prefixes"`
+	SiteIDs          []string  `json:"site_ids"`
+	CreatedBy        string    `json:"created_by"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Info describes an archive
+type Info struct {
+	Version         int
+	CreatedAt       time.Time
+	KeyID           string
+	Sites           int
+	Entries         int
+	Assignments     int
+	NamespaceGrants int
+}
+
+func infoOf(a *archive, state *repository.State) Info {
+	return Info{
+		Version:         a.Version,
+		CreatedAt:       a.CreatedAt,
+		KeyID:           a.KeyID,
+		Sites:           len(state.Sites),
+		Entries:         len(state.Entries),
+		Assignments:     len(state.Assignments),
+		NamespaceGrants: len(state.NamespaceGrants),
+	}
+}
+
+// Encode writes state to an archive signed with key
+func Encode(state *repository.State, key ed25519.PrivateKey, createdAt time.Time) ([]byte, Info, error) {
+	stateJSON, err := json.Marshal(toV1(state))
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	a := &archive{
+		Format:    Format,
+		Version:   Version,
+		CreatedAt: createdAt.UTC().Truncate(time.Second),
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		State:     stateJSON,
+	}
+	a.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, message(a, stateJSON)))
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(a); err != nil {
+		return nil, Info{}, fmt.Errorf("failed to encode archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, Info{}, fmt.Errorf("failed to compress archive: %w", err)
+	}
+	return buf.Bytes(), infoOf(a, state), nil
+}
+
+// Decode checks an archive's format and its signature by pub and reads the
+// state back
+func Decode(data []byte, pub ed25519.PublicKey) (*repository.State, Info, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	defer zr.Close()
+
+	var a archive
+	if err := json.NewDecoder(io.LimitReader(zr, maxSize)).Decode(&a); err != nil {
+		return nil, Info{}, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if a.Format != Format {
+		return nil, Info{}, fmt.Errorf("not a state archive")
+	}
+	if a.Version < 1 || a.Version > Version {
+		return nil, Info{}, fmt.Errorf("unsupported archive version %d, this server reads up to %d", a.Version, Version)
+	}
+	if keyID := KeyID(pub); a.KeyID != keyID {
+		return nil, Info{}, fmt.Errorf("archive signed by unknown key %s", a.KeyID)
+	}
+
+	var stateJSON bytes.Buffer
+	if err := json.Compact(&stateJSON, a.State); err != nil {
+		return nil, Info{}, fmt.Errorf("failed to read archive state: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(a.Signature)
+	if err != nil || !ed25519.Verify(pub, message(&a, stateJSON.Bytes()), sig) {
+		return nil, Info{}, fmt.Errorf("archive signature does not match: archive was modified")
+	}
+
+	var v1 stateV1
+	if err := json.Unmarshal(stateJSON.Bytes(), &v1); err != nil {
+		return nil, Info{}, fmt.Errorf("failed to read archive state: %w", err)
+	}
+	state := fromV1(&v1)
+	return state, infoOf(&a, state), nil
+}
+
+// message is the content an archive signature covers
+func message(a *archive, stateJSON []byte) []byte {
+	header := fmt.Sprintf("%s\n%d\n%d\n%s\n", a.Format, a.Version, a.CreatedAt.Unix(), a.KeyID)
+	return append([]byte(header), stateJSON...)
+}
+
+// KeyID identifies the key that signed an archive
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+func toV1(state *repository.State) *stateV1 {
+	v1 := &stateV1{
+		Sites:           make([]siteV1, 0, len(state.Sites)),
+		Entries:         make([]entryV1, 0, len(state.Entries)),
+		Assignments:     make([]assignmentV1, 0, len(state.Assignments)),
+		NamespaceGrants: make([]namespaceGrantV1, 0, len(state.NamespaceGrants)),
+	}
+	for _, s := range state.Sites {
+		v1.Sites = append(v1.Sites, siteV1{
+			ID:                 s.ID,
+			Name:               s.Name,
+			Region:             s.Region,
+			SpireServerAddress: s.SpireServerAddress,
+			TrustDomain:        s.TrustDomain,
+			AgentSpiffeID:      s.AgentSpiffeID,
+			Protected:          s.Protected,
+			Status:             s.Status,
+			CreatedAt:          s.CreatedAt.UTC(),
+			UpdatedAt:          s.UpdatedAt.UTC(),
+		})
+	}
+	for _, e := range state.Entries {
+		entry := entryV1{
+			ID:          e.ID,
+			SpiffeID:    e.SpiffeID,
+			ParentID:    e.ParentID,
+			Selectors:   make([]selectorV1, 0, len(e.Selectors)),
+			TTL:         e.TTL,
+			Description: e.Description,
+			CreatedBy:   e.CreatedBy,
+			CreatedAt:   e.CreatedAt.UTC(),
+			UpdatedAt:   e.UpdatedAt.UTC(),
+		}
+		for _, sel := range e.Selectors {
+			entry.Selectors = append(entry.Selectors, selectorV1{Type: sel.Type, Value: sel.Value})
+		}
+		if e.DeletedAt != nil {
+			deletedAt := e.DeletedAt.UTC()
+			entry.DeletedAt = &deletedAt
+		}
+		v1.Entries = append(v1.Entries, entry)
+	}
+	for _, a := range state.Assignments {
+		v1.Assignments = append(v1.Assignments, assignmentV1{
+			SiteID:          a.SiteID,
+			WorkloadEntryID: a.WorkloadEntryID,
+			SpireEntryID:    a.SpireEntryID,
+		})
+	}
+	for _, g := range state.NamespaceGrants {
+		v1.NamespaceGrants = append(v1.NamespaceGrants, namespaceGrantV1{
+			ID:               g.ID,
+			SubjectKind:      g.SubjectKind,
+			Subject:          g.Subject,
+			Namespaces:       g.Namespaces,
+			SpiffeIDPrefixes: g.SpiffeIDPrefixes,
+			SiteIDs:          g.SiteIDs,
+			CreatedBy:        g.CreatedBy,
+			CreatedAt:        g.CreatedAt.UTC(),
+		})
+	}
+	return v1
+}
+
+func fromV1(v1 *stateV1) *repository.State {
+	state := &repository.State{}
+	for _, s := range v1.Sites {
+		state.Sites = append(state.Sites, repository.Site{
+			ID:                 s.ID,
+			Name:               s.Name,
+			Region:             s.Region,
+			SpireServerAddress: s.SpireServerAddress,
+			TrustDomain:        s.TrustDomain,
+			AgentSpiffeID:      s.AgentSpiffeID,
+			Protected:          s.Protected,
+			Status:             s.Status,
+			CreatedAt:          s.CreatedAt,
+			UpdatedAt:          s.UpdatedAt,
+		})
+	}
+	for _, e := range v1.Entries {
+		entry := repository.WorkloadEntry{
+			ID:          e.ID,
+			SpiffeID:    e.SpiffeID,
+			ParentID:    e.ParentID,
+			TTL:         e.TTL,
+			Description: e.Description,
+			CreatedBy:   e.CreatedBy,
+			CreatedAt:   e.CreatedAt,
+			UpdatedAt:   e.UpdatedAt,
+			DeletedAt:   e.DeletedAt,
+		}
+		for _, sel := range e.Selectors {
+			entry.Selectors = append(entry.Selectors, repository.Selector{Type: sel.Type, Value: sel.Value})
+		}
+		state.Entries = append(state.Entries, entry)
+	}
+	for _, a := range v1.Assignments {
+		state.Assignments = append(state.Assignments, repository.Assignment{
+			SiteID:          a.SiteID,
+			WorkloadEntryID: a.WorkloadEntryID,
+			SpireEntryID:    a.SpireEntryID,
+		})
+	}
+	for _, g := range v1.NamespaceGrants {
+		state.NamespaceGrants = append(state.NamespaceGrants, repository.NamespaceGrant{
+			ID:               g.ID,
+			SubjectKind:      g.SubjectKind,
+			Subject:          g.Subject,
+			Namespaces:       g.Namespaces,
+			SpiffeIDPrefixes: g.SpiffeIDPrefixes,
+			SiteIDs:          g.SiteIDs,
+			CreatedBy:        g.CreatedBy,
+			CreatedAt:        g.CreatedAt,
+		})
+	}
+	return state
+}
+
+// LoadSigningKey reads a PEM-encoded PKCS#8 Ed25519 private key used to sign
+// state archives
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot signing key: %w", err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("snapshot signing key must be an Ed25519 key")
+	}
+	return priv, nil
+}
+
+// LoadVerifyKey reads a PEM-encoded Ed25519 public key, or the private key it
+// belongs to, used to verify state archives
+func LoadVerifyKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "PRIVATE KEY" {
+		priv, err := LoadSigningKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return priv.Public().(ed25519.PublicKey), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot verify key: %w", err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("snapshot verify key must be an Ed25519 key")
+	}
+	return pub, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	return block, nil
+}
@@ -0,0 +1,205 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourorg/spire-workload-mgmt/internal/repository"
+	"github.com/yourorg/spire-workload-mgmt/internal/snapshot"
+)
+
+func testState() *repository.State {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	deleted := created.Add(time.Hour)
+	return &repository.State{
+		Sites: []repository.Site{{
+			ID: "site-a", Name: "Site A", Region: "eu", SpireServerAddress: "spire-a:8081",
+			TrustDomain: "example.org", AgentSpiffeID: "spiffe://example.org/agent/a", Protected: true,
+			Status: "active", CreatedAt: created, UpdatedAt: created,
+		}},
+		Entries: []repository.WorkloadEntry{
+			{
+				ID: "one", SpiffeID: "spiffe://example.org/one", ParentID: "spiffe://example.org/agent",
+				Selectors: []repository.Selector{{Type: "k8s", Value: "ns:payments"}}, TTL: 600,
+				Description: "payments", CreatedBy: "alice", CreatedAt: created, UpdatedAt: created,
+			},
+			{
+				ID: "gone", SpiffeID: "spiffe://example.org/gone", ParentID: "spiffe://example.org/agent",
+				Selectors: []repository.Selector{{Type: "k8s", Value: "ns:old"}}, TTL: 600,
+				CreatedBy: "bob", CreatedAt: created, UpdatedAt: deleted, DeletedAt: &deleted,
+			},
+		},
+		Assignments: []repository.Assignment{
+			{SiteID: "site-a", WorkloadEntryID: "one", SpireEntryID: "spire-1"},
+			{SiteID: "site-a", WorkloadEntryID: "gone"},
+		},
+		NamespaceGrants: []repository.NamespaceGrant{{
+			ID: "grant-1", SubjectKind: "group", Subject: "payments-devs", Namespaces: []string{"payments"},
+			SpiffeIDPrefixes: []string{"spiffe://example.org/payments"}, SiteIDs: []string{"site-a"},
+			CreatedBy: "alice", CreatedAt: created,
+		}},
+	}
+}
+
+func newKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestRoundTrip(t *testing.T) {
+	key := newKey(t)
+	state := testState()
+	createdAt := time.Date(2024, 3, 2, 8, 30, 15, 500, time.UTC)
+
+	data, info, err := snapshot.Encode(state, key, createdAt)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded, decodedInfo, err := snapshot.Decode(data, key.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, state) {
+		t.Errorf("decoded state differs:\n got %+v\nwant %+v", decoded, state)
+	}
+	if decodedInfo != info {
+		t.Errorf("decoded info %+v, encoded %+v", decodedInfo, info)
+	}
+	want := snapshot.Info{
+		Version: snapshot.Version, CreatedAt: createdAt.Truncate(time.Second),
+		KeyID: snapshot.KeyID(key.Public().(ed25519.PublicKey)),
+		Sites: 1, Entries: 2, Assignments: 2, NamespaceGrants: 1,
+	}
+	if info != want {
+		t.Errorf("info %+v, want %+v", info, want)
+	}
+}
+
+func TestEmptyStateRoundTrip(t *testing.T) {
+	key := newKey(t)
+	data, _, err := snapshot.Encode(&repository.State{}, key, time.Now())
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	state, info, err := snapshot.Decode(data, key.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(state.Sites)+len(state.Entries)+len(state.Assignments)+len(state.NamespaceGrants) != 0 || info.Sites != 0 {
+		t.Errorf("empty state decoded as %+v, %+v", state, info)
+	}
+}
+
+func TestDecodeRejectsTampering(t *testing.T) {
+	key := newKey(t)
+	pub := key.Public().(ed25519.PublicKey)
+	data, _, err := snapshot.Encode(testState(), key, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{"modified state", `"spiffe://example.org/one"`, `"spiffe://example.org/evil"`, "signature does not match"},
+		{"modified header", `"key_id":"`, `"key_id":"0`, "unknown key"},
+		{"unsupported version", `"version":1`, `"version":99`, "unsupported archive version"},
+		{"other format", `"format":"spire-mgmt-state"`, `"format":"other"`, "not a state archive"},
+		{"added assignment", `"assignments":[`, `"assignments":[{"site_id":"site-a","workload_entry_id":"evil"},`, "signature does not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := snapshot.Decode(rewrite(t, data, tt.old, tt.new), pub)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Decode: %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := snapshot.Decode(data, newKey(t).Public().(ed25519.PublicKey)); err == nil {
+		t.Error("decoded an archive signed by another key")
+	}
+	if _, _, err := snapshot.Decode([]byte("not gzip"), pub); err == nil {
+		t.Error("decoded an archive that is not gzip")
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	key := newKey(t)
+	dir := t.TempDir()
+	privDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	privFile := writePEM(t, dir, "key.pem", "PRIVATE KEY", privDER)
+	pubFile := writePEM(t, dir, "pub.pem", "PUBLIC KEY", pubDER)
+
+	signing, err := snapshot.LoadSigningKey(privFile)
+	if err != nil || !signing.Equal(key) {
+		t.Fatalf("LoadSigningKey: %v", err)
+	}
+	// The verify key loads from the public key or the private key
+	for _, file := range []string{pubFile, privFile} {
+		pub, err := snapshot.LoadVerifyKey(file)
+		if err != nil || !pub.Equal(key.Public()) {
+			t.Errorf("LoadVerifyKey(%s): %v", filepath.Base(file), err)
+		}
+	}
+	if _, err := snapshot.LoadSigningKey(pubFile); err == nil {
+		t.Error("loaded a public key as the signing key")
+	}
+}
+
+// rewrite replaces old with new in the decompressed archive and compresses it
+// again
+func rewrite(t *testing.T, data []byte, old, new string) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(plain, []byte(old)) {
+		t.Fatalf("archive does not contain %s", old)
+	}
+	plain = bytes.Replace(plain, []byte(old), []byte(new), 1)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(plain)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
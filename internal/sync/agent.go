@@ -2,33 +2,95 @@ package sync
 
 import (
 	"context"
+	"crypto/tls"
 	"log"
 	"time"
+
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+	"github.com/yourorg/spire-workload-mgmt/internal/agentauth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// Version is the site agent version, set at build time
+var Version = "dev"
+
 // Config holds the site agent configuration
 type Config struct {
 	SiteID              string
 	SiteName            string
+	AgentID             string
 	APIServerAddress    string
 	SpireSocketPath     string
 	SyncIntervalSeconds int
 	MaxEntries          int
+	// LongPollSeconds enables long-polling when positive: the API server holds
+	// each poll until work is queued for the site, so cycles run back-to-back
+	// instead of waiting for the sync interval.
+	LongPollSeconds int
+	// JournalPath is the append-only file used to survive API server outages.
+	// When empty the journal is kept in memory only.
+	JournalPath string
+	// HeartbeatIntervalSeconds is how often liveness is reported to the API server
+	HeartbeatIntervalSeconds int
+	// SpiffeEndpointSocket enables mTLS to the API server using the agent's
+	// X.509 SVID from this Workload API address
+	SpiffeEndpointSocket string
+	// APIServerSpiffeID is the SPIFFE ID the API server must present. If empty,
+	// any server in the agent's trust domain is accepted.
+	APIServerSpiffeID string
+	// UnreadyAfterCycles is the number of consecutive cycles the SPIRE server
+	// or the API server may be unreachable before /readyz fails
+	UnreadyAfterCycles int
 }
 
 // Agent handles syncing workload entries to the local SPIRE server
 type Agent struct {
 	config      Config
 	apiClient   *APIClient
-	spireClient *SpireClient
+	spireClient spireAPI
+	journal     *Journal
+	svidSource  *workloadapi.X509Source
+	startedAt   time.Time
+	health      health
 }
 
 // NewAgent creates a new sync agent
 func NewAgent(config Config) (*Agent, error) {
-	apiClient := NewAPIClient(config.APIServerAddress)
+	var svidSource *workloadapi.X509Source
+	var tlsConfig *tls.Config
+	if config.SpiffeEndpointSocket != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		source, err := agentauth.NewX509Source(ctx, config.SpiffeEndpointSocket)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig, err = agentauth.ClientTLSConfig(source, config.APIServerSpiffeID)
+		if err != nil {
+			source.Close()
+			return nil, err
+		}
+		svidSource = source
+	}
+
+	apiClient := NewAPIClient(config.APIServerAddress, time.Duration(config.LongPollSeconds)*time.Second, tlsConfig)
 
 	spireClient, err := NewSpireClient(config.SpireSocketPath)
 	if err != nil {
+		if svidSource != nil {
+			svidSource.Close()
+		}
+		return nil, err
+	}
+
+	journal, err := OpenJournal(config.JournalPath)
+	if err != nil {
+		spireClient.Close()
+		if svidSource != nil {
+			svidSource.Close()
+		}
 		return nil, err
 	}
 
@@ -36,6 +98,9 @@ func NewAgent(config Config) (*Agent, error) {
 		config:      config,
 		apiClient:   apiClient,
 		spireClient: spireClient,
+		journal:     journal,
+		svidSource:  svidSource,
+		startedAt:   time.Now().UTC(),
 	}, nil
 }
 
@@ -43,8 +108,25 @@ func NewAgent(config Config) (*Agent, error) {
 func (a *Agent) Run(ctx context.Context) error {
 	log.Printf("Starting sync agent for site %s (%s)", a.config.SiteID, a.config.SiteName)
 	log.Printf("API server: %s", a.config.APIServerAddress)
+	if a.svidSource != nil {
+		if svid, err := a.svidSource.GetX509SVID(); err == nil {
+			log.Printf("mTLS enabled with SVID %s", svid.ID)
+		}
+	}
 	log.Printf("SPIRE socket: %s", a.config.SpireSocketPath)
 	log.Printf("Sync interval: %d seconds", a.config.SyncIntervalSeconds)
+	if a.config.JournalPath != "" {
+		log.Printf("Journal: %s (%d unreported results)", a.config.JournalPath, len(a.journal.Unreported()))
+	}
+
+	if a.config.HeartbeatIntervalSeconds > 0 {
+		go a.heartbeatLoop(ctx)
+	}
+
+	if a.config.LongPollSeconds > 0 {
+		log.Printf("Long-poll wait: %d seconds", a.config.LongPollSeconds)
+		return a.runLongPoll(ctx)
+	}
 
 	ticker := time.NewTicker(time.Duration(a.config.SyncIntervalSeconds) * time.Second)
 	defer ticker.Stop()
@@ -63,107 +145,337 @@ func (a *Agent) Run(ctx context.Context) error {
 	}
 }
 
-// syncCycle runs one sync cycle
-func (a *Agent) syncCycle(ctx context.Context) {
+// runLongPoll runs sync cycles back-to-back. Each cycle blocks in PollEntries
+// until the API server reports work, so no interval timer is needed. After a
+// cycle that made no progress the agent backs off for the sync interval: the
+// server would otherwise hand back the same work at once.
+func (a *Agent) runLongPoll(ctx context.Context) error {
+	backoff := time.Duration(a.config.SyncIntervalSeconds) * time.Second
+
+	for {
+		if progress := a.syncCycle(ctx); !progress {
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+		}
+
+		if ctx.Err() != nil {
+			log.Println("Sync agent shutting down...")
+			return ctx.Err()
+		}
+	}
+}
+
+// heartbeatLoop reports agent liveness until ctx is cancelled
+func (a *Agent) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(a.config.HeartbeatIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		a.sendHeartbeat(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendHeartbeat reports the agent's version, backlog and SPIRE health
+func (a *Agent) sendHeartbeat(ctx context.Context) {
+	hb := Heartbeat{
+		SiteID:    a.config.SiteID,
+		AgentID:   a.config.AgentID,
+		Version:   Version,
+		StartedAt: a.startedAt,
+		// There is no leader election yet, so every agent is active
+		IsLeader: true,
+		Backlog:  a.journal.Backlog(),
+	}
+
+	spireVersion, err := a.spireClient.Ping(ctx)
+	if err != nil {
+		log.Printf("[%s] SPIRE server unreachable: %v", a.config.SiteID, err)
+	} else {
+		hb.SpireReachable = true
+		hb.SpireServerVersion = spireVersion
+	}
+
+	if err := a.apiClient.SendHeartbeat(ctx, hb); err != nil && ctx.Err() == nil {
+		apiErrorsTotal.WithLabelValues("heartbeat").Inc()
+		log.Printf("[%s] Error sending heartbeat: %v", a.config.SiteID, err)
+	}
+}
+
+// syncCycle runs one sync cycle. It returns false if the cycle made no
+// progress: polling the API server failed, or every queued deletion failed.
+func (a *Agent) syncCycle(ctx context.Context) bool {
 	log.Printf("[%s] Starting sync cycle...", a.config.SiteID)
+	start := time.Now()
+
+	// 1. Report results journaled while the API server was unreachable
+	a.flushReports(ctx)
+
+	// 2. Poll for pending entries
+	ok := a.syncPendingEntries(ctx)
+	if !ok {
+		// API server unreachable: keep converging on the last known desired state
+		a.reconcileCached(ctx)
+	}
 
-	// 1. Poll for pending entries
-	a.syncPendingEntries(ctx)
+	// 3. Poll for deletions
+	stalled := a.syncDeletions(ctx)
 
-	// 2. Poll for deletions
-	a.syncDeletions(ctx)
+	// 4. Check the SPIRE server is reachable, for the readiness probe
+	_, err := a.spireClient.Ping(ctx)
+	if err != nil {
+		log.Printf("[%s] SPIRE server unreachable: %v", a.config.SiteID, err)
+	}
+	a.health.record(err == nil, ok)
 
+	cycleDuration.Observe(time.Since(start).Seconds())
 	log.Printf("[%s] Sync cycle complete", a.config.SiteID)
+	return ok && !stalled
 }
 
 // syncPendingEntries syncs pending entries to SPIRE
-func (a *Agent) syncPendingEntries(ctx context.Context) {
+func (a *Agent) syncPendingEntries(ctx context.Context) bool {
 	entries, err := a.apiClient.PollEntries(ctx, a.config.SiteID, a.config.MaxEntries)
 	if err != nil {
-		log.Printf("[%s] Error polling entries: %v", a.config.SiteID, err)
-		return
+		if ctx.Err() == nil {
+			apiErrorsTotal.WithLabelValues("poll").Inc()
+			log.Printf("[%s] Error polling entries: %v", a.config.SiteID, err)
+		}
+		return false
 	}
 
 	if len(entries) == 0 {
 		log.Printf("[%s] No pending entries to sync", a.config.SiteID)
-		return
+		return true
 	}
 
 	log.Printf("[%s] Found %d pending entries to sync", a.config.SiteID, len(entries))
 
+	if err := a.journal.RecordDesired(entries); err != nil {
+		log.Printf("[%s] Error journaling desired entries: %v", a.config.SiteID, err)
+	}
+
 	for _, entry := range entries {
 		a.syncEntry(ctx, entry)
 	}
+	return true
 }
 
 // syncEntry syncs a single entry to SPIRE
 func (a *Agent) syncEntry(ctx context.Context, entry PendingEntry) {
+	// The entry may already have been created while the API server was
+	// unreachable. The server queued it again, so check SPIRE still has it
+	// rather than trusting the journal.
+	if spireEntryID, ok := a.journal.AppliedSpireEntryID(entry.WorkloadEntryID); ok {
+		existing, err := a.spireClient.GetEntry(ctx, spireEntryID)
+		switch {
+		case err == nil && sameEntry(existing, entry):
+			log.Printf("[%s] Entry %s already exists in SPIRE as %s", a.config.SiteID, entry.WorkloadEntryID, spireEntryID)
+			a.recordOutcome(ctx, Outcome{WorkloadEntryID: entry.WorkloadEntryID, Success: true, SpireEntryID: spireEntryID})
+			return
+		case err == nil:
+			log.Printf("[%s] SPIRE entry %s no longer matches %s, replacing it", a.config.SiteID, spireEntryID, entry.WorkloadEntryID)
+			if err := a.spireClient.DeleteEntry(ctx, spireEntryID); err != nil && status.Code(err) != codes.NotFound {
+				log.Printf("[%s] Error deleting SPIRE entry %s: %v", a.config.SiteID, spireEntryID, err)
+				a.recordOutcome(ctx, Outcome{WorkloadEntryID: entry.WorkloadEntryID, ErrorMessage: err.Error()})
+				return
+			}
+		case status.Code(err) == codes.NotFound:
+			log.Printf("[%s] SPIRE entry %s for %s is missing, recreating it", a.config.SiteID, spireEntryID, entry.WorkloadEntryID)
+		default:
+			log.Printf("[%s] Error looking up SPIRE entry %s: %v", a.config.SiteID, spireEntryID, err)
+			a.recordOutcome(ctx, Outcome{WorkloadEntryID: entry.WorkloadEntryID, ErrorMessage: err.Error()})
+			return
+		}
+	}
+
 	log.Printf("[%s] Syncing entry %s (SPIFFE ID: %s)", a.config.SiteID, entry.WorkloadEntryID, entry.SpiffeID)
 
 	// Create the entry in SPIRE
 	spireEntryID, err := a.spireClient.CreateEntry(ctx, entry)
 	if err != nil {
 		log.Printf("[%s] Error creating SPIRE entry for %s: %v", a.config.SiteID, entry.WorkloadEntryID, err)
-
-		// Report failure
-		if reportErr := a.apiClient.ReportSyncResult(ctx, a.config.SiteID, entry.WorkloadEntryID, false, "", err.Error()); reportErr != nil {
-			log.Printf("[%s] Error reporting sync failure: %v", a.config.SiteID, reportErr)
-		}
+		a.recordOutcome(ctx, Outcome{WorkloadEntryID: entry.WorkloadEntryID, ErrorMessage: err.Error()})
 		return
 	}
 
 	log.Printf("[%s] Created SPIRE entry %s for %s", a.config.SiteID, spireEntryID, entry.WorkloadEntryID)
-
-	// Report success
-	if err := a.apiClient.ReportSyncResult(ctx, a.config.SiteID, entry.WorkloadEntryID, true, spireEntryID, ""); err != nil {
-		log.Printf("[%s] Error reporting sync success: %v", a.config.SiteID, err)
-	}
+	entriesCreatedTotal.Inc()
+	a.recordOutcome(ctx, Outcome{WorkloadEntryID: entry.WorkloadEntryID, Success: true, SpireEntryID: spireEntryID})
 }
 
-// syncDeletions handles pending deletions
-func (a *Agent) syncDeletions(ctx context.Context) {
+// syncDeletions handles pending deletions. It reports whether deletions were
+// queued but none of them could be made, so the same deletions are still
+// queued.
+func (a *Agent) syncDeletions(ctx context.Context) bool {
 	entries, err := a.apiClient.PollDeletions(ctx, a.config.SiteID, a.config.MaxEntries)
 	if err != nil {
-		log.Printf("[%s] Error polling deletions: %v", a.config.SiteID, err)
-		return
+		if ctx.Err() == nil {
+			apiErrorsTotal.WithLabelValues("poll_deletions").Inc()
+			log.Printf("[%s] Error polling deletions: %v", a.config.SiteID, err)
+		}
+		return false
 	}
 
 	if len(entries) == 0 {
-		return
+		return false
 	}
 
 	log.Printf("[%s] Found %d entries to delete", a.config.SiteID, len(entries))
 
+	if err := a.journal.RecordDeletions(entries); err != nil {
+		log.Printf("[%s] Error journaling deletions: %v", a.config.SiteID, err)
+	}
+
+	stalled := true
 	for _, entry := range entries {
-		a.deleteEntry(ctx, entry)
+		if a.deleteEntry(ctx, entry) {
+			stalled = false
+		}
 	}
+	return stalled
 }
 
-// deleteEntry deletes an entry from SPIRE
-func (a *Agent) deleteEntry(ctx context.Context, entry DeletionEntry) {
+// deleteEntry deletes an entry from SPIRE and reports whether it is gone
+func (a *Agent) deleteEntry(ctx context.Context, entry DeletionEntry) bool {
+	if !a.resolveDeletion(&entry) {
+		log.Printf("[%s] Entry %s was never created in SPIRE", a.config.SiteID, entry.WorkloadEntryID)
+		a.recordOutcome(ctx, Outcome{WorkloadEntryID: entry.WorkloadEntryID, Deletion: true, Success: true})
+		return true
+	}
+
 	log.Printf("[%s] Deleting SPIRE entry %s", a.config.SiteID, entry.SpireEntryID)
 
+	// An entry that is already gone needs no deleting
 	err := a.spireClient.DeleteEntry(ctx, entry.SpireEntryID)
+	if status.Code(err) == codes.NotFound {
+		log.Printf("[%s] SPIRE entry %s was already deleted", a.config.SiteID, entry.SpireEntryID)
+		a.recordOutcome(ctx, Outcome{WorkloadEntryID: entry.WorkloadEntryID, Deletion: true, Success: true})
+		return true
+	}
 	if err != nil {
 		log.Printf("[%s] Error deleting SPIRE entry %s: %v", a.config.SiteID, entry.SpireEntryID, err)
+		a.recordOutcome(ctx, Outcome{WorkloadEntryID: entry.WorkloadEntryID, Deletion: true, ErrorMessage: err.Error()})
+		return false
+	}
 
-		// Report failure
-		if reportErr := a.apiClient.ReportDeletionResult(ctx, a.config.SiteID, entry.WorkloadEntryID, false, err.Error()); reportErr != nil {
-			log.Printf("[%s] Error reporting deletion failure: %v", a.config.SiteID, reportErr)
-		}
+	log.Printf("[%s] Deleted SPIRE entry %s", a.config.SiteID, entry.SpireEntryID)
+	entriesDeletedTotal.Inc()
+	a.recordOutcome(ctx, Outcome{WorkloadEntryID: entry.WorkloadEntryID, Deletion: true, Success: true})
+	return true
+}
+
+// resolveDeletion fills in the SPIRE entry ID of an entry deleted before the
+// API server learned it, from the journal. It reports false if the entry was
+// never created in SPIRE, so there is nothing to delete.
+func (a *Agent) resolveDeletion(entry *DeletionEntry) bool {
+	if entry.SpireEntryID != "" {
+		return true
+	}
+	spireEntryID, ok := a.journal.AppliedSpireEntryID(entry.WorkloadEntryID)
+	entry.SpireEntryID = spireEntryID
+	return ok
+}
+
+// reconcileCached applies the cached desired state to SPIRE while the API
+// server is unreachable. Only successes are journaled so repeated failures
+// during a long outage do not grow the backlog of results to report.
+func (a *Agent) reconcileCached(ctx context.Context) {
+	entries := a.journal.Desired()
+	deletions := a.journal.PendingDeletions()
+	if len(entries) == 0 && len(deletions) == 0 {
 		return
 	}
 
-	log.Printf("[%s] Deleted SPIRE entry %s", a.config.SiteID, entry.SpireEntryID)
+	log.Printf("[%s] Reconciling %d cached entries and %d cached deletions", a.config.SiteID, len(entries), len(deletions))
+
+	for _, entry := range entries {
+		spireEntryID, err := a.spireClient.CreateEntry(ctx, entry)
+		if err != nil {
+			log.Printf("[%s] Error creating SPIRE entry for %s: %v", a.config.SiteID, entry.WorkloadEntryID, err)
+			continue
+		}
+		entriesCreatedTotal.Inc()
+		a.recordOutcome(ctx, Outcome{WorkloadEntryID: entry.WorkloadEntryID, Success: true, SpireEntryID: spireEntryID})
+	}
+
+	for _, entry := range deletions {
+		if !a.resolveDeletion(&entry) {
+			a.recordOutcome(ctx, Outcome{WorkloadEntryID: entry.WorkloadEntryID, Deletion: true, Success: true})
+			continue
+		}
+		err := a.spireClient.DeleteEntry(ctx, entry.SpireEntryID)
+		if err != nil && status.Code(err) != codes.NotFound {
+			log.Printf("[%s] Error deleting SPIRE entry %s: %v", a.config.SiteID, entry.SpireEntryID, err)
+			continue
+		}
+		if err == nil {
+			entriesDeletedTotal.Inc()
+		}
+		a.recordOutcome(ctx, Outcome{WorkloadEntryID: entry.WorkloadEntryID, Deletion: true, Success: true})
+	}
+}
 
-	// Report success
-	if err := a.apiClient.ReportDeletionResult(ctx, a.config.SiteID, entry.WorkloadEntryID, true, ""); err != nil {
-		log.Printf("[%s] Error reporting deletion success: %v", a.config.SiteID, err)
+// recordOutcome journals a SPIRE outcome and reports everything outstanding
+func (a *Agent) recordOutcome(ctx context.Context, o Outcome) {
+	if err := a.journal.RecordOutcome(o); err != nil {
+		log.Printf("[%s] Error journaling result for %s: %v", a.config.SiteID, o.WorkloadEntryID, err)
+	}
+	a.flushReports(ctx)
+}
+
+// flushReports reports journaled outcomes to the API server in order. It stops
+// at the first failure so results are never applied out of order.
+func (a *Agent) flushReports(ctx context.Context) {
+	for {
+		unreported := a.journal.Unreported()
+		if len(unreported) == 0 {
+			return
+		}
+		o := unreported[0]
+
+		var err error
+		operation := "report"
+		if o.Deletion {
+			operation = "report_deletion"
+			err = a.apiClient.ReportDeletionResult(ctx, a.config.SiteID, o.WorkloadEntryID, o.Success, o.ErrorMessage)
+		} else {
+			err = a.apiClient.ReportSyncResult(ctx, a.config.SiteID, o.WorkloadEntryID, o.Success, o.SpireEntryID, o.ErrorMessage)
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				apiErrorsTotal.WithLabelValues(operation).Inc()
+				log.Printf("[%s] Error reporting result for %s, will retry: %v", a.config.SiteID, o.WorkloadEntryID, err)
+			}
+			return
+		}
+
+		if err := a.journal.MarkReported(o.Seq); err != nil {
+			log.Printf("[%s] Error journaling report for %s: %v", a.config.SiteID, o.WorkloadEntryID, err)
+			return
+		}
 	}
 }
 
 // Close cleans up resources
 func (a *Agent) Close() error {
+	if a.svidSource != nil {
+		if err := a.svidSource.Close(); err != nil {
+			log.Printf("Failed to close SVID source: %v", err)
+		}
+	}
+	if a.journal != nil {
+		if err := a.journal.Close(); err != nil {
+			log.Printf("Failed to close journal: %v", err)
+		}
+	}
 	if a.spireClient != nil {
 		return a.spireClient.Close()
 	}
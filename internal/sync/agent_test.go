@@ -0,0 +1,217 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeAPIServer serves the agent endpoints of the API server from fixed
+// pending entries and deletions, and records the reports it receives
+type fakeAPIServer struct {
+	mu        sync.Mutex
+	pending   []PendingEntry
+	deletions []DeletionEntry
+	// failReports makes every report fail with 503
+	failReports bool
+	polls       int
+	reports     []map[string]interface{}
+}
+
+func (f *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/api/v1/agent/poll":
+		f.polls++
+		json.NewEncoder(w).Encode(map[string]interface{}{"entries": f.pending})
+	case "/api/v1/agent/poll-deletions":
+		json.NewEncoder(w).Encode(map[string]interface{}{"entries": f.deletions})
+	case "/api/v1/agent/report", "/api/v1/agent/report-deletion":
+		if f.failReports {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var report map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&report)
+		f.reports = append(f.reports, report)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeAPIServer) lastReport() map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.reports) == 0 {
+		return nil
+	}
+	return f.reports[len(f.reports)-1]
+}
+
+// newTestAgent creates an agent that talks to api and to spire, or to an
+// in-memory SPIRE server when spire is nil
+func newTestAgent(t *testing.T, api *fakeAPIServer, spire spireAPI) *Agent {
+	t.Helper()
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	// Ping treats the SPIRE server as reachable when its socket exists
+	socket := filepath.Join(t.TempDir(), "spire.sock")
+	if err := os.WriteFile(socket, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if spire == nil {
+		client, _ := NewSpireClient(socket)
+		spire = client
+	}
+
+	journal, err := OpenJournal("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Agent{
+		config: Config{
+			SiteID:              "site-a",
+			MaxEntries:          10,
+			SyncIntervalSeconds: 1,
+		},
+		apiClient:   NewAPIClient(strings.TrimPrefix(srv.URL, "http://"), 0, nil),
+		spireClient: spire,
+		journal:     journal,
+		startedAt:   time.Now(),
+	}
+}
+
+var testEntry = PendingEntry{
+	WorkloadEntryID: "one",
+	SpiffeID:        "spiffe://example.org/one",
+	ParentID:        "spiffe://example.org/agent",
+	Selectors:       []Selector{{Type: "k8s", Value: "ns:payments"}},
+}
+
+func TestSyncEntryRecreatesMissingJournaledEntry(t *testing.T) {
+	api := &fakeAPIServer{failReports: true}
+	a := newTestAgent(t, api, nil)
+	ctx := context.Background()
+
+	// The journal remembers a SPIRE entry that no longer exists
+	a.journal.RecordOutcome(Outcome{WorkloadEntryID: "one", Success: true, SpireEntryID: "spire-stale"})
+
+	a.syncEntry(ctx, testEntry)
+
+	unreported := a.journal.Unreported()
+	last := unreported[len(unreported)-1]
+	if !last.Success || last.SpireEntryID == "" || last.SpireEntryID == "spire-stale" {
+		t.Fatalf("outcome for a missing SPIRE entry: %+v", last)
+	}
+	if _, err := a.spireClient.GetEntry(ctx, last.SpireEntryID); err != nil {
+		t.Errorf("recreated entry is not in SPIRE: %v", err)
+	}
+}
+
+func TestSyncEntryReusesVerifiedJournaledEntry(t *testing.T) {
+	api := &fakeAPIServer{failReports: true}
+	a := newTestAgent(t, api, nil)
+	ctx := context.Background()
+
+	spireEntryID, err := a.spireClient.CreateEntry(ctx, testEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.journal.RecordOutcome(Outcome{WorkloadEntryID: "one", Success: true, SpireEntryID: spireEntryID})
+
+	a.syncEntry(ctx, testEntry)
+
+	unreported := a.journal.Unreported()
+	if last := unreported[len(unreported)-1]; !last.Success || last.SpireEntryID != spireEntryID {
+		t.Errorf("outcome for a verified SPIRE entry: %+v", last)
+	}
+}
+
+func TestSyncEntryReplacesChangedJournaledEntry(t *testing.T) {
+	api := &fakeAPIServer{failReports: true}
+	a := newTestAgent(t, api, nil)
+	ctx := context.Background()
+
+	stale := testEntry
+	stale.Selectors = []Selector{{Type: "k8s", Value: "ns:old"}}
+	staleID, _ := a.spireClient.CreateEntry(ctx, stale)
+	a.journal.RecordOutcome(Outcome{WorkloadEntryID: "one", Success: true, SpireEntryID: staleID})
+
+	a.syncEntry(ctx, testEntry)
+
+	unreported := a.journal.Unreported()
+	last := unreported[len(unreported)-1]
+	if !last.Success || last.SpireEntryID == staleID {
+		t.Fatalf("outcome for a changed SPIRE entry: %+v", last)
+	}
+	if _, err := a.spireClient.GetEntry(ctx, staleID); err == nil {
+		t.Error("stale SPIRE entry was not deleted")
+	}
+}
+
+func TestRequeuedEntryIsVerifiedInSpire(t *testing.T) {
+	api := &fakeAPIServer{pending: []PendingEntry{testEntry}}
+	a := newTestAgent(t, api, nil)
+	ctx := context.Background()
+
+	a.syncCycle(ctx)
+	first := api.lastReport()
+	if first == nil || first["success"] != true {
+		t.Fatalf("first sync report: %v", first)
+	}
+	// The confirmed entry is forgotten by the journal
+	if _, ok := a.journal.AppliedSpireEntryID("one"); ok {
+		t.Error("confirmed entry is still journaled")
+	}
+
+	// The server queues the entry again: the agent finds it in SPIRE and
+	// reports the same SPIRE entry rather than a cached one
+	a.syncCycle(ctx)
+	second := api.lastReport()
+	if second["spire_entry_id"] != first["spire_entry_id"] {
+		t.Errorf("re-queued entry reported as %v, was %v", second["spire_entry_id"], first["spire_entry_id"])
+	}
+}
+
+// failingDeletes is a SPIRE server on which every delete fails
+type failingDeletes struct {
+	*SpireClient
+}
+
+func (failingDeletes) DeleteEntry(ctx context.Context, spireEntryID string) error {
+	return status.Error(codes.Unavailable, "SPIRE server overloaded")
+}
+
+func TestLongPollBacksOffOnFailedDeletions(t *testing.T) {
+	api := &fakeAPIServer{deletions: []DeletionEntry{{WorkloadEntryID: "gone", SpireEntryID: "spire-gone"}}}
+	spire, _ := NewSpireClient("")
+	a := newTestAgent(t, api, failingDeletes{spire})
+
+	// The server keeps handing back the failed deletion, so without a back
+	// off the agent would poll again at once
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	a.runLongPoll(ctx)
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.polls > 2 {
+		t.Errorf("agent polled %d times in 1.5s with a 1s back off", api.polls)
+	}
+	if len(api.reports) == 0 || api.reports[0]["success"] != false {
+		t.Errorf("failed deletion reports: %v", api.reports)
+	}
+}
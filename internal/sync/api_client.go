@@ -3,6 +3,7 @@ package sync
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -31,25 +32,55 @@ type DeletionEntry struct {
 	SpireEntryID    string `json:"spire_entry_id"`
 }
 
+// Heartbeat is the liveness and health report sent by an agent
+type Heartbeat struct {
+	SiteID             string    `json:"site_id"`
+	AgentID            string    `json:"agent_id"`
+	Version            string    `json:"version"`
+	StartedAt          time.Time `json:"started_at"`
+	IsLeader           bool      `json:"is_leader"`
+	Backlog            int       `json:"backlog"`
+	SpireReachable     bool      `json:"spire_reachable"`
+	SpireServerVersion string    `json:"spire_server_version"`
+}
+
 // APIClient handles communication with the central API server
 type APIClient struct {
 	baseURL    string
 	httpClient *http.Client
+	pollWait   time.Duration
 }
 
-// NewAPIClient creates a new API client
-func NewAPIClient(address string) *APIClient {
+// NewAPIClient creates a new API client. If pollWait is positive, PollEntries
+// asks the server to hold the request for up to pollWait until work exists.
+// If tlsConfig is non-nil the client connects over HTTPS with it.
+func NewAPIClient(address string, pollWait time.Duration, tlsConfig *tls.Config) *APIClient {
+	scheme := "http"
+	transport := http.DefaultTransport
+	if tlsConfig != nil {
+		scheme = "https"
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = tlsConfig
+		transport = t
+	}
+
 	return &APIClient{
-		baseURL: fmt.Sprintf("http://%s", address),
+		baseURL: fmt.Sprintf("%s://%s", scheme, address),
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Transport: transport,
+			// Leave room for the server to hold long-poll requests
+			Timeout: 30*time.Second + pollWait,
 		},
+		pollWait: pollWait,
 	}
 }
 
 // PollEntries polls for entries pending sync
 func (c *APIClient) PollEntries(ctx context.Context, siteID string, maxEntries int) ([]PendingEntry, error) {
 	url := fmt.Sprintf("%s/api/v1/agent/poll?site_id=%s&max_entries=%d", c.baseURL, siteID, maxEntries)
+	if c.pollWait > 0 {
+		url += fmt.Sprintf("&wait_seconds=%d", int(c.pollWait/time.Second))
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -179,3 +210,32 @@ func (c *APIClient) ReportDeletionResult(ctx context.Context, siteID, entryID st
 
 	return nil
 }
+
+// SendHeartbeat reports agent liveness and health to the API server
+func (c *APIClient) SendHeartbeat(ctx context.Context, hb Heartbeat) error {
+	url := fmt.Sprintf("%s/api/v1/agent/heartbeat", c.baseURL)
+
+	body, err := json.Marshal(hb)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("heartbeat failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
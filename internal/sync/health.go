@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultUnreadyAfterCycles is the number of consecutive cycles the SPIRE
+// server or the API server may be unreachable before the agent is not ready
+const DefaultUnreadyAfterCycles = 3
+
+// health tracks whether recent sync cycles reached the SPIRE server and the
+// API server
+type health struct {
+	mu sync.Mutex
+	// spireFailures and apiFailures count consecutive cycles in which the
+	// server was unreachable
+	spireFailures int
+	apiFailures   int
+	lastSuccess   time.Time
+}
+
+// record records the reachability of both servers in a cycle
+func (h *health) record(spireOK, apiOK bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.spireFailures++
+	if spireOK {
+		h.spireFailures = 0
+	}
+	h.apiFailures++
+	if apiOK {
+		h.apiFailures = 0
+	}
+	if spireOK && apiOK {
+		h.lastSuccess = time.Now()
+	}
+}
+
+func (h *health) snapshot() (spireFailures, apiFailures int, lastSuccess time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.spireFailures, h.apiFailures, h.lastSuccess
+}
+
+// Handler serves the agent's probes and metrics:
+//
+//	/healthz  the process is up
+//	/readyz   neither the SPIRE server nor the API server has been unreachable
+//	          for UnreadyAfterCycles consecutive cycles
+//	/metrics  agent metrics in the Prometheus text format
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		spireFailures, apiFailures, _ := a.health.snapshot()
+		limit := a.config.UnreadyAfterCycles
+		if limit <= 0 {
+			limit = DefaultUnreadyAfterCycles
+		}
+
+		resp := map[string]interface{}{
+			"status":                   "ready",
+			"spire_failed_cycles":      spireFailures,
+			"api_server_failed_cycles": apiFailures,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if spireFailures >= limit || apiFailures >= limit {
+			resp["status"] = "not ready"
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	// The gauges that depend on the time of the scrape are set just before it
+	metricsHandler := promhttp.Handler()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		_, _, lastSuccess := a.health.snapshot()
+		if lastSuccess.IsZero() {
+			lastSuccess = a.startedAt
+		}
+		sinceLastSuccess.Set(time.Since(lastSuccess).Seconds())
+		backlogSize.Set(float64(a.journal.Backlog()))
+		metricsHandler.ServeHTTP(w, r)
+	})
+
+	return mux
+}
@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readyz returns the status code and body of the agent's readiness probe
+func readyz(t *testing.T, a *Agent) (int, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("readyz body: %v", err)
+	}
+	return rec.Code, body
+}
+
+func TestReadyzThreshold(t *testing.T) {
+	for _, server := range []string{"spire", "api_server"} {
+		t.Run(server, func(t *testing.T) {
+			a := &Agent{config: Config{UnreadyAfterCycles: 2}}
+			fail := func() {
+				a.health.record(server != "spire", server != "api_server")
+			}
+
+			// One failed cycle is below the threshold
+			fail()
+			if code, body := readyz(t, a); code != http.StatusOK || body["status"] != "ready" {
+				t.Fatalf("after one failed cycle: %d %v", code, body)
+			}
+			fail()
+			code, body := readyz(t, a)
+			if code != http.StatusServiceUnavailable || body["status"] != "not ready" {
+				t.Fatalf("after two failed cycles: %d %v", code, body)
+			}
+			if body[server+"_failed_cycles"] != float64(2) {
+				t.Errorf("failed cycles reported: %v", body)
+			}
+
+			// A cycle that reaches the server makes the agent ready again
+			a.health.record(true, true)
+			if code, body := readyz(t, a); code != http.StatusOK {
+				t.Errorf("after a successful cycle: %d %v", code, body)
+			}
+		})
+	}
+}
+
+func TestReadyzDefaultThreshold(t *testing.T) {
+	a := &Agent{}
+	for i := 1; i < DefaultUnreadyAfterCycles; i++ {
+		a.health.record(false, false)
+	}
+	if code, body := readyz(t, a); code != http.StatusOK {
+		t.Fatalf("after %d failed cycles: %d %v", DefaultUnreadyAfterCycles-1, code, body)
+	}
+	a.health.record(false, false)
+	if code, body := readyz(t, a); code != http.StatusServiceUnavailable {
+		t.Errorf("after %d failed cycles: %d %v", DefaultUnreadyAfterCycles, code, body)
+	}
+}
+
+func TestReadyzFollowsSyncCycles(t *testing.T) {
+	api := &fakeAPIServer{}
+	a := newTestAgent(t, api, nil)
+	a.config.UnreadyAfterCycles = 2
+	ctx := context.Background()
+
+	a.syncCycle(ctx)
+	if code, body := readyz(t, a); code != http.StatusOK {
+		t.Fatalf("after a successful cycle: %d %v", code, body)
+	}
+
+	// The API server goes away
+	a.apiClient = NewAPIClient("127.0.0.1:1", 0, nil)
+	a.syncCycle(ctx)
+	a.syncCycle(ctx)
+	code, body := readyz(t, a)
+	if code != http.StatusServiceUnavailable || body["api_server_failed_cycles"] != float64(2) || body["spire_failed_cycles"] != float64(0) {
+		t.Errorf("with the API server unreachable: %d %v", code, body)
+	}
+
+	rec := httptest.NewRecorder()
+	a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "spire_mgmt_agent_seconds_since_last_successful_cycle") {
+		t.Error("metrics do not report the time since the last successful cycle")
+	}
+}
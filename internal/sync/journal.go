@@ -0,0 +1,402 @@
+package sync
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Journal record types
+const (
+	recordDesired        = "desired"
+	recordDeletion       = "deletion"
+	recordSyncResult     = "sync_result"
+	recordDeletionResult = "deletion_result"
+	recordReported       = "reported"
+)
+
+// maxJournalRecords is the number of appended records after which the journal
+// is compacted down to its live state
+const maxJournalRecords = 10000
+
+// Outcome is the result of applying an entry or a deletion to SPIRE
+type Outcome struct {
+	Seq             int64  `json:"-"`
+	WorkloadEntryID string `json:"workload_entry_id"`
+	Deletion        bool   `json:"deletion,omitempty"`
+	Success         bool   `json:"success"`
+	SpireEntryID    string `json:"spire_entry_id,omitempty"`
+	ErrorMessage    string `json:"error_message,omitempty"`
+}
+
+type journalRecord struct {
+	Seq      int64          `json:"seq"`
+	Time     time.Time      `json:"time"`
+	Type     string         `json:"type"`
+	Entry    *PendingEntry  `json:"entry,omitempty"`
+	Deletion *DeletionEntry `json:"deletion,omitempty"`
+	Outcome  *Outcome       `json:"outcome,omitempty"`
+	Ref      int64          `json:"ref,omitempty"` // Seq of the outcome that was reported
+}
+
+// Journal is an append-only log of SPIRE outcomes and the last known desired
+// state. It lets the agent keep working while the API server is unreachable and
+// report results once connectivity returns. A journal without a path is kept
+// in memory only.
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	nextSeq int64
+	records int
+
+	desired    map[string]PendingEntry
+	deletions  map[string]DeletionEntry
+	applied    map[string]string // workload entry ID -> SPIRE entry ID
+	unreported []Outcome
+}
+
+// OpenJournal opens the journal at path, replays it and compacts it
+func OpenJournal(path string) (*Journal, error) {
+	j := &Journal{
+		path:      path,
+		nextSeq:   1,
+		desired:   make(map[string]PendingEntry),
+		deletions: make(map[string]DeletionEntry),
+		applied:   make(map[string]string),
+	}
+
+	if path == "" {
+		return j, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// load replays all records from the journal file
+func (j *Journal) load() error {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var rec journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A torn final write is expected after a crash; ignore the rest
+			break
+		}
+		j.apply(rec)
+		if rec.Seq >= j.nextSeq {
+			j.nextSeq = rec.Seq + 1
+		}
+	}
+
+	return scanner.Err()
+}
+
+// apply updates the in-memory state from a record
+func (j *Journal) apply(rec journalRecord) {
+	switch rec.Type {
+	case recordDesired:
+		if rec.Entry != nil {
+			j.desired[rec.Entry.WorkloadEntryID] = *rec.Entry
+		}
+	case recordDeletion:
+		if rec.Deletion != nil {
+			j.deletions[rec.Deletion.WorkloadEntryID] = *rec.Deletion
+		}
+	case recordSyncResult, recordDeletionResult:
+		if rec.Outcome == nil {
+			return
+		}
+		o := *rec.Outcome
+		o.Seq = rec.Seq
+		if o.Success {
+			if o.Deletion {
+				delete(j.desired, o.WorkloadEntryID)
+				delete(j.deletions, o.WorkloadEntryID)
+				delete(j.applied, o.WorkloadEntryID)
+			} else {
+				j.applied[o.WorkloadEntryID] = o.SpireEntryID
+			}
+		}
+		j.unreported = append(j.unreported, o)
+	case recordReported:
+		for i, o := range j.unreported {
+			if o.Seq == rec.Ref {
+				j.unreported = append(j.unreported[:i], j.unreported[i+1:]...)
+				if o.Success && !o.Deletion {
+					j.forgetConfirmed(o.WorkloadEntryID)
+				}
+				break
+			}
+		}
+	}
+}
+
+// forgetConfirmed drops an entry the API server has confirmed as synced. The
+// server now holds its SPIRE entry ID, and queues it again if it needs
+// syncing, so keeping it would only grow the journal. An entry with another
+// unreported success is kept until that is confirmed too.
+func (j *Journal) forgetConfirmed(entryID string) {
+	for _, o := range j.unreported {
+		if o.WorkloadEntryID == entryID && o.Success && !o.Deletion {
+			return
+		}
+	}
+	delete(j.desired, entryID)
+	delete(j.applied, entryID)
+}
+
+// append writes a record to the journal file and applies it
+func (j *Journal) append(rec journalRecord) (int64, error) {
+	rec.Seq = j.nextSeq
+	rec.Time = time.Now().UTC()
+
+	if j.file != nil {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal journal record: %w", err)
+		}
+		if _, err := j.file.Write(append(data, '\n')); err != nil {
+			return 0, fmt.Errorf("failed to write journal record: %w", err)
+		}
+		if err := j.file.Sync(); err != nil {
+			return 0, fmt.Errorf("failed to sync journal: %w", err)
+		}
+		j.records++
+	}
+
+	j.nextSeq++
+	j.apply(rec)
+
+	if j.records > maxJournalRecords {
+		if err := j.compact(); err != nil {
+			return 0, err
+		}
+	}
+
+	return rec.Seq, nil
+}
+
+// compact rewrites the journal so it contains only the live state
+func (j *Journal) compact() error {
+	var live []journalRecord
+	for _, e := range j.desired {
+		e := e
+		live = append(live, journalRecord{Type: recordDesired, Entry: &e})
+	}
+	for _, d := range j.deletions {
+		d := d
+		live = append(live, journalRecord{Type: recordDeletion, Deletion: &d})
+	}
+
+	// Renumber records. Applied entries are rebuilt from their unreported
+	// outcomes, as confirmed ones have been forgotten.
+	now := time.Now().UTC()
+	var unreported []Outcome
+	seq := int64(1)
+	for i := range live {
+		live[i].Seq = seq
+		live[i].Time = now
+		seq++
+	}
+	for _, o := range j.unreported {
+		o := o
+		recType := recordSyncResult
+		if o.Deletion {
+			recType = recordDeletionResult
+		}
+		live = append(live, journalRecord{Seq: seq, Time: now, Type: recType, Outcome: &o})
+		o.Seq = seq
+		unreported = append(unreported, o)
+		seq++
+	}
+
+	tmpPath := j.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create journal: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for _, rec := range live {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to marshal journal record: %w", err)
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	tmp.Close()
+
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return fmt.Errorf("failed to replace journal: %w", err)
+	}
+
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	j.file = f
+	j.records = len(live)
+	j.nextSeq = seq
+	j.unreported = unreported
+	return nil
+}
+
+// RecordDesired caches entries polled from the API server
+func (j *Journal) RecordDesired(entries []PendingEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, e := range entries {
+		e := e
+		if _, err := j.append(journalRecord{Type: recordDesired, Entry: &e}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordDeletions caches deletions polled from the API server
+func (j *Journal) RecordDeletions(entries []DeletionEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, d := range entries {
+		d := d
+		if _, err := j.append(journalRecord{Type: recordDeletion, Deletion: &d}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordOutcome records the result of a SPIRE operation as unreported
+func (j *Journal) RecordOutcome(o Outcome) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	recType := recordSyncResult
+	if o.Deletion {
+		recType = recordDeletionResult
+	}
+	_, err := j.append(journalRecord{Type: recType, Outcome: &o})
+	return err
+}
+
+// MarkReported records that an outcome was acknowledged by the API server
+func (j *Journal) MarkReported(seq int64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, err := j.append(journalRecord{Type: recordReported, Ref: seq})
+	return err
+}
+
+// Unreported returns outcomes not yet acknowledged by the API server, oldest first
+func (j *Journal) Unreported() []Outcome {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return append([]Outcome(nil), j.unreported...)
+}
+
+// Desired returns cached entries that have not been applied to SPIRE yet
+func (j *Journal) Desired() []PendingEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var entries []PendingEntry
+	for id, e := range j.desired {
+		if _, ok := j.applied[id]; !ok {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// PendingDeletions returns cached deletions that have not been applied to SPIRE yet
+func (j *Journal) PendingDeletions() []DeletionEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := make([]DeletionEntry, 0, len(j.deletions))
+	for _, d := range j.deletions {
+		entries = append(entries, d)
+	}
+	return entries
+}
+
+// Backlog returns the number of results and cached changes not yet confirmed
+// by the API server
+func (j *Journal) Backlog() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	pending := 0
+	for id := range j.desired {
+		if _, ok := j.applied[id]; !ok {
+			pending++
+		}
+	}
+	return len(j.unreported) + pending + len(j.deletions)
+}
+
+// AppliedSpireEntryID returns the SPIRE entry ID created for a workload entry
+func (j *Journal) AppliedSpireEntryID(entryID string) (string, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	id, ok := j.applied[entryID]
+	return id, ok
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file != nil {
+		err := j.file.Close()
+		j.file = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package sync
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestJournal(t *testing.T, path string) *Journal {
+	t.Helper()
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("OpenJournal: %v", err)
+	}
+	t.Cleanup(func() { j.Close() })
+	return j
+}
+
+func TestJournalReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.log")
+	j := openTestJournal(t, path)
+
+	one := PendingEntry{WorkloadEntryID: "one", SpiffeID: "spiffe://example.org/one"}
+	two := PendingEntry{WorkloadEntryID: "two", SpiffeID: "spiffe://example.org/two"}
+	if err := j.RecordDesired([]PendingEntry{one, two}); err != nil {
+		t.Fatalf("RecordDesired: %v", err)
+	}
+	if err := j.RecordOutcome(Outcome{WorkloadEntryID: "one", Success: true, SpireEntryID: "spire-1"}); err != nil {
+		t.Fatalf("RecordOutcome: %v", err)
+	}
+	if err := j.RecordDeletions([]DeletionEntry{{WorkloadEntryID: "gone", SpireEntryID: "spire-0"}}); err != nil {
+		t.Fatalf("RecordDeletions: %v", err)
+	}
+	j.Close()
+
+	j = openTestJournal(t, path)
+	if id, ok := j.AppliedSpireEntryID("one"); !ok || id != "spire-1" {
+		t.Errorf("AppliedSpireEntryID after replay: %q, %v", id, ok)
+	}
+	if desired := j.Desired(); len(desired) != 1 || desired[0].WorkloadEntryID != "two" {
+		t.Errorf("Desired after replay: %v", desired)
+	}
+	if deletions := j.PendingDeletions(); len(deletions) != 1 || deletions[0].SpireEntryID != "spire-0" {
+		t.Errorf("PendingDeletions after replay: %v", deletions)
+	}
+	unreported := j.Unreported()
+	if len(unreported) != 1 || unreported[0].WorkloadEntryID != "one" {
+		t.Fatalf("Unreported after replay: %v", unreported)
+	}
+	// Unreported outcome, desired entry and deletion
+	if backlog := j.Backlog(); backlog != 3 {
+		t.Errorf("Backlog after replay: %d", backlog)
+	}
+
+	// Sequence numbers continue after the replayed records
+	if err := j.MarkReported(unreported[0].Seq); err != nil {
+		t.Fatalf("MarkReported: %v", err)
+	}
+	if len(j.Unreported()) != 0 {
+		t.Errorf("Unreported after MarkReported: %v", j.Unreported())
+	}
+}
+
+func TestJournalForgetsConfirmedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.log")
+	j := openTestJournal(t, path)
+
+	entry := PendingEntry{WorkloadEntryID: "one", SpiffeID: "spiffe://example.org/one"}
+	if err := j.RecordDesired([]PendingEntry{entry}); err != nil {
+		t.Fatalf("RecordDesired: %v", err)
+	}
+	if err := j.RecordOutcome(Outcome{WorkloadEntryID: "one", Success: true, SpireEntryID: "spire-1"}); err != nil {
+		t.Fatalf("RecordOutcome: %v", err)
+	}
+	if err := j.RecordOutcome(Outcome{WorkloadEntryID: "one", Success: true, SpireEntryID: "spire-1"}); err != nil {
+		t.Fatalf("RecordOutcome: %v", err)
+	}
+
+	// The entry is kept until every success for it is confirmed
+	unreported := j.Unreported()
+	if err := j.MarkReported(unreported[0].Seq); err != nil {
+		t.Fatalf("MarkReported: %v", err)
+	}
+	if _, ok := j.AppliedSpireEntryID("one"); !ok {
+		t.Error("entry forgotten while a success was unreported")
+	}
+	if err := j.MarkReported(unreported[1].Seq); err != nil {
+		t.Fatalf("MarkReported: %v", err)
+	}
+	if _, ok := j.AppliedSpireEntryID("one"); ok {
+		t.Error("confirmed entry is still applied")
+	}
+	if len(j.Desired()) != 0 || j.Backlog() != 0 {
+		t.Errorf("confirmed entry still cached: %v, backlog %d", j.Desired(), j.Backlog())
+	}
+
+	// And stays forgotten after a replay
+	j.Close()
+	j = openTestJournal(t, path)
+	if _, ok := j.AppliedSpireEntryID("one"); ok || j.Backlog() != 0 {
+		t.Errorf("confirmed entry is back after replay, backlog %d", j.Backlog())
+	}
+}
+
+func TestJournalDeletionClearsEntry(t *testing.T) {
+	j := openTestJournal(t, "")
+
+	entry := PendingEntry{WorkloadEntryID: "one", SpiffeID: "spiffe://example.org/one"}
+	j.RecordDesired([]PendingEntry{entry})
+	j.RecordOutcome(Outcome{WorkloadEntryID: "one", Success: true, SpireEntryID: "spire-1"})
+	j.RecordDeletions([]DeletionEntry{{WorkloadEntryID: "one"}})
+	j.RecordOutcome(Outcome{WorkloadEntryID: "one", Deletion: true, Success: true})
+
+	if _, ok := j.AppliedSpireEntryID("one"); ok {
+		t.Error("deleted entry is still applied")
+	}
+	if len(j.Desired()) != 0 || len(j.PendingDeletions()) != 0 {
+		t.Errorf("deleted entry still cached: %v, %v", j.Desired(), j.PendingDeletions())
+	}
+}
+
+func TestJournalCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.log")
+	j := openTestJournal(t, path)
+
+	entry := PendingEntry{WorkloadEntryID: "one", SpiffeID: "spiffe://example.org/one"}
+	for i := 0; i < 100; i++ {
+		if err := j.RecordDesired([]PendingEntry{entry}); err != nil {
+			t.Fatalf("RecordDesired: %v", err)
+		}
+		if err := j.RecordOutcome(Outcome{WorkloadEntryID: "one", ErrorMessage: "boom"}); err != nil {
+			t.Fatalf("RecordOutcome: %v", err)
+		}
+		if err := j.MarkReported(j.Unreported()[0].Seq); err != nil {
+			t.Fatalf("MarkReported: %v", err)
+		}
+	}
+	j.RecordOutcome(Outcome{WorkloadEntryID: "two", Success: true, SpireEntryID: "spire-2"})
+	j.Close()
+
+	// Opening compacts the journal down to the desired entry and the
+	// unreported outcome
+	j = openTestJournal(t, path)
+	if lines := countLines(t, path); lines != 2 {
+		t.Errorf("compacted journal has %d records, want 2", lines)
+	}
+	if desired := j.Desired(); len(desired) != 1 || desired[0].WorkloadEntryID != "one" {
+		t.Errorf("Desired after compaction: %v", desired)
+	}
+	unreported := j.Unreported()
+	if len(unreported) != 1 || unreported[0].WorkloadEntryID != "two" {
+		t.Fatalf("Unreported after compaction: %v", unreported)
+	}
+	if id, ok := j.AppliedSpireEntryID("two"); !ok || id != "spire-2" {
+		t.Errorf("AppliedSpireEntryID after compaction: %q, %v", id, ok)
+	}
+
+	// Renumbered outcomes can still be confirmed, across another replay
+	if err := j.MarkReported(unreported[0].Seq); err != nil {
+		t.Fatalf("MarkReported: %v", err)
+	}
+	j.Close()
+	j = openTestJournal(t, path)
+	if len(j.Unreported()) != 0 {
+		t.Errorf("Unreported after confirming a compacted outcome: %v", j.Unreported())
+	}
+}
+
+func TestJournalIgnoresTornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.log")
+	j := openTestJournal(t, path)
+	j.RecordOutcome(Outcome{WorkloadEntryID: "one", Success: true, SpireEntryID: "spire-1"})
+	j.Close()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq":9,"type":"sync_res`)
+	f.Close()
+
+	j = openTestJournal(t, path)
+	if unreported := j.Unreported(); len(unreported) != 1 || unreported[0].WorkloadEntryID != "one" {
+		t.Errorf("Unreported after a torn write: %v", unreported)
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
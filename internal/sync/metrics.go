@@ -0,0 +1,53 @@
+package sync
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	cycleDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "spire_mgmt_agent_cycle_duration_seconds",
+		Help:    "Duration of sync cycles, including long-poll waits.",
+		Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	})
+	entriesCreatedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "spire_mgmt_agent_entries_created_total",
+		Help: "Entries created in the SPIRE server.",
+	})
+	entriesDeletedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "spire_mgmt_agent_entries_deleted_total",
+		Help: "Entries deleted from the SPIRE server.",
+	})
+	spireRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "spire_mgmt_agent_spire_request_duration_seconds",
+		Help: "SPIRE server API latency, by operation.",
+	}, []string{"operation"})
+	spireErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "spire_mgmt_agent_spire_errors_total",
+		Help: "Failed SPIRE server API calls, by operation and gRPC status code.",
+	}, []string{"operation", "code"})
+	apiErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "spire_mgmt_agent_api_errors_total",
+		Help: "Failed calls to the API server, by operation.",
+	}, []string{"operation"})
+	backlogSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "spire_mgmt_agent_backlog",
+		Help: "Results and cached changes not yet confirmed by the API server.",
+	})
+	sinceLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "spire_mgmt_agent_seconds_since_last_successful_cycle",
+		Help: "Time since a sync cycle last reached both the SPIRE server and the API server, or since start if none has.",
+	})
+)
+
+// observeSpire records the latency and outcome of a SPIRE server API call
+func observeSpire(operation string, start time.Time, err error) {
+	spireRequestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		spireErrorsTotal.WithLabelValues(operation, status.Code(err).String()).Inc()
+	}
+}
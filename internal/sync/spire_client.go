@@ -4,16 +4,34 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// spireAPI is the part of the SPIRE server entry API the agent uses
+type spireAPI interface {
+	CreateEntry(ctx context.Context, entry PendingEntry) (string, error)
+	GetEntry(ctx context.Context, spireEntryID string) (PendingEntry, error)
+	DeleteEntry(ctx context.Context, spireEntryID string) error
+	Ping(ctx context.Context) (string, error)
+	Close() error
+}
+
 // SpireClient handles communication with the local SPIRE server
 type SpireClient struct {
 	socketPath string
 	// In a real implementation, this would use:
 	// github.com/spiffe/spire-api-sdk/proto/spire/api/server/entry/v1
-	// For the alpha demo, we'll use a mock implementation
+	// For the alpha demo, entries are kept in memory with the semantics of
+	// the SPIRE entry API
+	mu      sync.Mutex
+	entries map[string]PendingEntry
 }
 
 // NewSpireClient creates a new SPIRE client
@@ -25,11 +43,15 @@ func NewSpireClient(socketPath string) (*SpireClient, error) {
 
 	return &SpireClient{
 		socketPath: socketPath,
+		entries:    make(map[string]PendingEntry),
 	}, nil
 }
 
-// CreateEntry creates a workload entry in SPIRE
-func (c *SpireClient) CreateEntry(ctx context.Context, entry PendingEntry) (string, error) {
+// CreateEntry creates a workload entry in SPIRE. Like BatchCreateEntry, it
+// returns the ID of an existing entry with the same SPIFFE ID, parent ID and
+// selectors instead of creating a duplicate.
+func (c *SpireClient) CreateEntry(ctx context.Context, entry PendingEntry) (spireEntryID string, err error) {
+	defer func(start time.Time) { observeSpire("create_entry", start, err) }(time.Now())
 	log.Printf("Creating SPIRE entry for SPIFFE ID: %s", entry.SpiffeID)
 
 	// In a real implementation, this would:
@@ -51,17 +73,50 @@ func (c *SpireClient) CreateEntry(ctx context.Context, entry PendingEntry) (stri
 	//         },
 	//     },
 	// })
+	// A result with codes.AlreadyExists carries the existing entry.
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	// For alpha demo, simulate success and generate a SPIRE entry ID
-	spireEntryID := fmt.Sprintf("spire-%s", uuid.New().String()[:8])
+	for id, existing := range c.entries {
+		if sameEntry(existing, entry) {
+			log.Printf("SPIRE entry already exists: %s -> %s", entry.SpiffeID, id)
+			return id, nil
+		}
+	}
+
+	// For alpha demo, generate a SPIRE entry ID
+	spireEntryID = fmt.Sprintf("spire-%s", uuid.New().String()[:8])
+	c.entries[spireEntryID] = entry
 
 	log.Printf("SPIRE entry created: %s -> %s", entry.SpiffeID, spireEntryID)
 
 	return spireEntryID, nil
 }
 
-// DeleteEntry deletes a workload entry from SPIRE
-func (c *SpireClient) DeleteEntry(ctx context.Context, spireEntryID string) error {
+// GetEntry returns a workload entry from SPIRE. It fails with codes.NotFound
+// if the entry does not exist.
+func (c *SpireClient) GetEntry(ctx context.Context, spireEntryID string) (entry PendingEntry, err error) {
+	defer func(start time.Time) { observeSpire("get_entry", start, err) }(time.Now())
+
+	// In a real implementation:
+	// client := entryv1.NewEntryClient(conn)
+	// resp, err := client.GetEntry(ctx, &entryv1.GetEntryRequest{Id: spireEntryID})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[spireEntryID]
+	if !ok {
+		return PendingEntry{}, status.Errorf(codes.NotFound, "entry %s not found", spireEntryID)
+	}
+	return entry, nil
+}
+
+// DeleteEntry deletes a workload entry from SPIRE. It fails with
+// codes.NotFound if the entry does not exist.
+func (c *SpireClient) DeleteEntry(ctx context.Context, spireEntryID string) (err error) {
+	defer func(start time.Time) { observeSpire("delete_entry", start, err) }(time.Now())
 	log.Printf("Deleting SPIRE entry: %s", spireEntryID)
 
 	// In a real implementation, this would:
@@ -70,20 +125,30 @@ func (c *SpireClient) DeleteEntry(ctx context.Context, spireEntryID string) erro
 	//     Ids: []string{spireEntryID},
 	// })
 
-	// For alpha demo, simulate success
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[spireEntryID]; !ok {
+		return status.Errorf(codes.NotFound, "entry %s not found", spireEntryID)
+	}
+	delete(c.entries, spireEntryID)
 	log.Printf("SPIRE entry deleted: %s", spireEntryID)
 
 	return nil
 }
 
-// ListEntries lists all entries in SPIRE
-func (c *SpireClient) ListEntries(ctx context.Context) ([]string, error) {
-	// In a real implementation:
-	// client := entryv1.NewEntryClient(conn)
-	// resp, err := client.ListEntries(ctx, &entryv1.ListEntriesRequest{})
+// Ping checks that the SPIRE server is reachable and returns its version
+func (c *SpireClient) Ping(ctx context.Context) (version string, err error) {
+	defer func(start time.Time) { observeSpire("ping", start, err) }(time.Now())
+
+	// In a real implementation this would call the SPIRE server debug or
+	// health API over the socket. For the alpha demo, the server counts as
+	// reachable when its socket exists, and fails as a dial would.
+	if _, err := os.Stat(c.socketPath); err != nil {
+		return "", status.Errorf(codes.Unavailable, "SPIRE socket unavailable: %v", err)
+	}
 
-	// For alpha demo, return empty list
-	return []string{}, nil
+	return "unknown", nil
 }
 
 // Close closes the SPIRE client connection
@@ -91,3 +156,17 @@ func (c *SpireClient) Close() error {
 	log.Println("Closing SPIRE client connection")
 	return nil
 }
+
+// sameEntry reports whether two entries have the same identity in SPIRE: the
+// same SPIFFE ID, parent ID and selectors
+func sameEntry(a, b PendingEntry) bool {
+	if a.SpiffeID != b.SpiffeID || a.ParentID != b.ParentID || len(a.Selectors) != len(b.Selectors) {
+		return false
+	}
+	for _, sel := range a.Selectors {
+		if !slices.Contains(b.Selectors, sel) {
+			return false
+		}
+	}
+	return true
+}
@@ -21,7 +21,9 @@ docker build -t spire-mgmt-ui:alpha -f "$PROJECT_DIR/backstage-plugin/Dockerfile
 
 # Deploy
 echo -e "${YELLOW}Deploying API server...${NC}"
-helm upgrade --install api "$PROJECT_DIR/deploy/helm/spire-mgmt-api" -n spire-mgmt --wait
+# The demo has no SPIRE agent sockets for mTLS, so agents connect insecurely
+helm upgrade --install api "$PROJECT_DIR/deploy/helm/spire-mgmt-api" -n spire-mgmt \
+    --set agents.mtls.enabled=false --set agents.insecure=true --set rbac.anonymousRole=admin --wait
 
 echo -e "${YELLOW}Seeding data...${NC}"
 "$SCRIPT_DIR/seed-demo-data.sh" || true
@@ -29,11 +31,11 @@ echo -e "${YELLOW}Seeding data...${NC}"
 echo -e "${YELLOW}Deploying site agents...${NC}"
 helm upgrade --install agent-a "$PROJECT_DIR/deploy/helm/site-agent" -n site-a \
     --set siteId=site-a --set siteName="US East" \
-    --set apiServer.address="api-spire-mgmt-api.spire-mgmt.svc.cluster.local:8081" --wait || true
+    --set apiServer.address="api-spire-mgmt-api.spire-mgmt.svc.cluster.local:8081" --set apiServer.mtls.enabled=false --wait || true
 
 helm upgrade --install agent-b "$PROJECT_DIR/deploy/helm/site-agent" -n site-b \
     --set siteId=site-b --set siteName="EU West" \
-    --set apiServer.address="api-spire-mgmt-api.spire-mgmt.svc.cluster.local:8081" --wait || true
+    --set apiServer.address="api-spire-mgmt-api.spire-mgmt.svc.cluster.local:8081" --set apiServer.mtls.enabled=false --wait || true
 
 echo -e "${YELLOW}Deploying UI...${NC}"
 helm upgrade --install ui "$PROJECT_DIR/deploy/helm/backstage" -n spire-mgmt --wait || true
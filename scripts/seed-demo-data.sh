@@ -20,14 +20,15 @@ fi
 # Insert/update sites
 echo -e "${YELLOW}Inserting site configurations...${NC}"
 kubectl exec -n spire-mgmt "$MYSQL_POD" -- mysql -u root -pdemo-password spire_mgmt -e "
-INSERT INTO sites (id, name, region, spire_server_address, trust_domain, status) VALUES
-    ('site-a', 'US East', 'us-east-1', 'spire-a-server.site-a.svc.cluster.local:8081', 'site-a.demo', 'active'),
-    ('site-b', 'EU West', 'eu-west-1', 'spire-b-server.site-b.svc.cluster.local:8081', 'site-b.demo', 'active')
+INSERT INTO sites (id, name, region, spire_server_address, trust_domain, agent_spiffe_id, status) VALUES
+    ('site-a', 'US East', 'us-east-1', 'spire-a-server.site-a.svc.cluster.local:8081', 'site-a.demo', 'spiffe://site-a.demo/ns/site-a/sa/site-agent', 'active'),
+    ('site-b', 'EU West', 'eu-west-1', 'spire-b-server.site-b.svc.cluster.local:8081', 'site-b.demo', 'spiffe://site-b.demo/ns/site-b/sa/site-agent', 'active')
 ON DUPLICATE KEY UPDATE
     name = VALUES(name),
     region = VALUES(region),
     spire_server_address = VALUES(spire_server_address),
     trust_domain = VALUES(trust_domain),
+    agent_spiffe_id = VALUES(agent_spiffe_id),
     status = VALUES(status);
 "
 